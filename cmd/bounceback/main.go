@@ -4,19 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/exp/slices"
 
+	"github.com/D00Movenok/BounceBack/internal/admin"
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/packs"
 	"github.com/D00Movenok/BounceBack/internal/proxy"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/pkg/lists"
 )
 
 const banner = `
@@ -32,6 +41,11 @@ const banner = `
 
 `
 
+const (
+	defaultShutdownTimeout = 5 * time.Second
+	listsFetchTimeout      = 5 * time.Minute
+)
+
 var (
 	version = "0.0.0-next"
 
@@ -55,11 +69,22 @@ var (
 )
 
 func main() {
-	fmt.Fprintf(os.Stdout, banner[1:], version)
-
 	initPflag()
+	if pflag.NArg() > 0 {
+		// stdout of commands may be piped, e.g. "packs export"
+		fmt.Fprintf(os.Stderr, banner[1:], version)
+	} else {
+		fmt.Fprintf(os.Stdout, banner[1:], version)
+	}
+
 	initLogger()
 	setLogLevel()
+
+	if pflag.NArg() > 0 {
+		runCommand(pflag.Args())
+		return
+	}
+
 	parseConfig()
 
 	db := createKeyValueStorage()
@@ -68,19 +93,46 @@ func main() {
 	cfg := parseProxyConfig()
 	log.Debug().Any("config", cfg).Msg("Parsed config")
 
+	s := runListsScheduler(cfg)
 	m := runProxyManager(db, cfg)
+	a := runAdminServer(cfg, m)
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	<-c
-
-	ctx, cancel := context.WithTimeout(
-		context.Background(),
-		time.Second*5, //nolint:gomnd
+	signal.Notify(
+		c,
+		os.Interrupt,
+		syscall.SIGTERM,
+		syscall.SIGINT,
+		syscall.SIGUSR1,
+		syscall.SIGUSR2,
 	)
+loop:
+	for sig := range c {
+		switch sig {
+		case syscall.SIGUSR1:
+			drainProxies(m)
+		case syscall.SIGUSR2:
+			if err := m.Kill("signal"); err != nil {
+				log.Error().Err(err).Msg("Can't kill engagement")
+			}
+		default:
+			break loop
+		}
+	}
+
+	shutdownTimeout := cfg.Globals.ShutdownTimeout
+	if shutdownTimeout == 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
+	shutdownAdminServer(a)
 	shutdownProxyManager(ctx, m)
+	s.Stop()
+	if err := rules.CloseWatcher(); err != nil {
+		log.Error().Err(err).Msg("Can't close rules watcher")
+	}
 
 	log.Info().Msg("Shutdown successful")
 }
@@ -89,6 +141,11 @@ func initPflag() {
 	pflag.ErrHelp = errors.New("") //nolint:reassign // remove error from output
 	pflag.Usage = func() {
 		fmt.Fprintln(os.Stdout, "Usage of BounceBack:")
+		fmt.Fprintln(os.Stdout, "  bounceback [flags]")
+		fmt.Fprintln(os.Stdout, "  bounceback [flags] lists update [name...]")
+		fmt.Fprintln(os.Stdout, "  bounceback [flags] packs list")
+		fmt.Fprintln(os.Stdout, "  bounceback [flags] packs export <name>")
+		fmt.Fprintln(os.Stdout, "Flags:")
 		pflag.PrintDefaults()
 	}
 	pflag.Parse()
@@ -146,13 +203,173 @@ func createKeyValueStorage() *database.DB {
 }
 
 func parseProxyConfig() *common.Config {
-	cfg := new(common.Config)
-	if err := viper.Unmarshal(cfg); err != nil {
+	cfg, err := unmarshalConfig()
+	if err != nil {
 		log.Fatal().Err(err).Msg("Can't parse proxy config from file")
 	}
 	return cfg
 }
 
+func unmarshalConfig() (*common.Config, error) {
+	cfg := new(common.Config)
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	))
+	if err := viper.Unmarshal(cfg, hook); err != nil {
+		return nil, fmt.Errorf("can't unmarshal config: %w", err)
+	}
+	return cfg, nil
+}
+
+// runCommand runs subcommand instead of proxies.
+func runCommand(args []string) {
+	switch {
+	case len(args) >= 2 && args[0] == "lists" && args[1] == "update":
+		parseConfig()
+		updateLists(parseProxyConfig(), args[2:])
+	case len(args) == 2 && args[0] == "packs" && args[1] == "list":
+		listPacks()
+	case len(args) == 3 && args[0] == "packs" && args[1] == "export":
+		exportPack(args[2])
+	default:
+		pflag.Usage()
+		log.Fatal().Strs("args", args).Msg("Unknown command")
+	}
+}
+
+func getListsUpdater(cfg *common.Config) *lists.Updater {
+	dir := cfg.Globals.ListsDir
+	if dir == "" {
+		dir = lists.DefaultDir
+	}
+	return lists.NewUpdater(dir, &http.Client{Timeout: listsFetchTimeout})
+}
+
+func getListSources(cfg *common.Config) []lists.Source {
+	sources := make([]lists.Source, 0, len(cfg.Lists))
+	for _, l := range cfg.Lists {
+		sources = append(sources, lists.Source{
+			Name: l.Name,
+			URL:  l.URL,
+			Options: lists.Options{
+				Format:    l.Format,
+				Column:    l.Column,
+				Delimiter: l.Delimiter,
+				Selector:  l.Selector,
+			},
+			Refresh:      l.Refresh,
+			SHA256:       l.SHA256,
+			ChecksumURL:  l.ChecksumURL,
+			SignatureURL: l.SignatureURL,
+			PublicKey:    l.PublicKey,
+		})
+	}
+	return sources
+}
+
+func logListUpdate(src lists.Source, l *lists.List, err error) {
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("list", src.Name).
+			Str("source", src.URL).
+			Msg("Can't update list")
+		return
+	}
+	log.Info().
+		Str("list", l.Name).
+		Str("source", l.Source).
+		Str("sha256", l.SHA256).
+		Int("subnets", len(l.Prefixes)).
+		Msg("List updated")
+}
+
+// updateLists updates lists with given names (all if empty) and exits with
+// error if any of them failed.
+func updateLists(cfg *common.Config, names []string) {
+	u := getListsUpdater(cfg)
+	sources := getListSources(cfg)
+	for _, name := range names {
+		if !slices.ContainsFunc(sources, func(src lists.Source) bool {
+			return src.Name == name
+		}) {
+			log.Fatal().Str("list", name).Msg("Unknown list")
+		}
+	}
+
+	failed := false
+	for _, src := range sources {
+		if len(names) != 0 && !slices.Contains(names, src.Name) {
+			continue
+		}
+		l, err := u.Update(context.Background(), src)
+		logListUpdate(src, l, err)
+		failed = failed || err != nil
+	}
+	if failed {
+		log.Fatal().Msg("Some lists weren't updated")
+	}
+}
+
+// runListsScheduler fetches lists that were never fetched, so rules can
+// use them, and starts periodic updates.
+func runListsScheduler(cfg *common.Config) *lists.Scheduler {
+	u := getListsUpdater(cfg)
+	sources := getListSources(cfg)
+	for _, src := range sources {
+		if err := src.Validate(); err != nil {
+			log.Fatal().Err(err).Msg("Invalid list config")
+		}
+		if _, ok := u.Age(src); !ok {
+			log.Info().Str("list", src.Name).Msg("Fetching list")
+			l, err := u.Update(context.Background(), src)
+			logListUpdate(src, l, err)
+		}
+	}
+
+	s := lists.NewScheduler(u, sources, logListUpdate)
+	s.Start()
+	return s
+}
+
+// listPacks prints embedded rule packs.
+func listPacks() {
+	l, err := packs.List()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Can't list packs")
+	}
+
+	//nolint:gomnd // column padding
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVERSION\tENTRIES\tRULES\tDESCRIPTION")
+	for _, p := range l {
+		fmt.Fprintf(
+			w,
+			"%s%s\t%s\t%d\t%s\t%s\n",
+			packs.Scheme,
+			p.Name,
+			p.Version,
+			p.Entries,
+			p.Use,
+			p.Description,
+		)
+	}
+	if err = w.Flush(); err != nil {
+		log.Fatal().Err(err).Msg("Can't print packs")
+	}
+}
+
+// exportPack prints content of pack, so it can be customized and used as
+// a regular list.
+func exportPack(name string) {
+	name = strings.TrimPrefix(name, packs.Scheme)
+	if err := packs.Export(name, os.Stdout); err != nil {
+		log.Fatal().Err(err).Msg("Can't export pack")
+	}
+}
+
 func runProxyManager(db *database.DB, cfg *common.Config) *proxy.Manager {
 	log.Info().Msg("Starting proxies")
 	m, err := proxy.NewManager(db, cfg)
@@ -165,9 +382,55 @@ func runProxyManager(db *database.DB, cfg *common.Config) *proxy.Manager {
 	return m
 }
 
+// drainProxies re-reads config and drains proxies marked with "drain".
+func drainProxies(m *proxy.Manager) {
+	log.Info().Msg("Draining proxies")
+	if err := viper.ReadInConfig(); err != nil {
+		log.Error().Err(err).Msg("Can't read config from yaml")
+		return
+	}
+	cfg, err := unmarshalConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Can't parse proxy config from file")
+		return
+	}
+
+	for _, pc := range cfg.Proxies {
+		if !pc.Drain {
+			continue
+		}
+		var stateErr *proxy.DrainStateError
+		if err = m.Drain(pc.Name); err != nil && !errors.As(err, &stateErr) {
+			log.Error().Err(err).Msg("Can't drain proxy")
+		}
+	}
+}
+
+func runAdminServer(cfg *common.Config, m *proxy.Manager) *admin.Server {
+	if cfg.Globals.AdminSocket == "" {
+		return nil
+	}
+	log.Info().Str("socket", cfg.Globals.AdminSocket).Msg("Starting admin")
+	a := admin.NewServer(cfg.Globals.AdminSocket, m)
+	if err := a.Start(); err != nil {
+		log.Fatal().Err(err).Msg("Can't start admin server")
+	}
+	return a
+}
+
+func shutdownAdminServer(a *admin.Server) {
+	if a == nil {
+		return
+	}
+	if err := a.Shutdown(); err != nil {
+		log.Error().Err(err).Msg("Can't shutdown admin server")
+	}
+}
+
 func shutdownProxyManager(ctx context.Context, m *proxy.Manager) {
 	log.Info().Msg("Shutting down proxies")
+	// sessions left after shutdown timeout are already closed
 	if err := m.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Can't shutdown proxies")
+		log.Error().Err(err).Msg("Can't shutdown proxies gracefully")
 	}
 }
@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/proxy"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CommandStatus = "status"
+	CommandDrain  = "drain"
+	CommandKill   = "kill"
+	CommandResume = "resume"
+
+	commandTimeout = 10 * time.Second
+	maxCommandSize = 1024
+)
+
+// Server serves admin commands on unix socket. Client sends single
+// command line and receives plain text answer:
+//   - status - state and active sessions of every proxy.
+//   - drain <proxy> - drain proxy, see proxy.Manager.Drain.
+//   - kill [reason] - flip engagement kill switch, see proxy.Manager.Kill.
+//   - resume - flip engagement kill switch back.
+type Server struct {
+	path string
+	m    *proxy.Manager
+
+	closing  bool
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+func NewServer(path string, m *proxy.Manager) *Server {
+	return &Server{
+		path: path,
+		m:    m,
+	}
+}
+
+func (s *Server) Start() error {
+	// socket left by previous run
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("can't remove old socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+	if err = os.Chmod(s.path, 0600); err != nil { //nolint:gomnd // rw-------
+		l.Close()
+		return fmt.Errorf("can't set socket permissions: %w", err)
+	}
+	s.listener = l
+
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+func (s *Server) Shutdown() error {
+	s.closing = true
+	if err := s.listener.Close(); err != nil {
+		return fmt.Errorf("can't close listener: %w", err)
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !s.closing {
+				log.Error().Err(err).Msg("Can't accept admin connection")
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handleConnection(conn)
+	}
+}
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(commandTimeout))
+	line, err := bufio.NewReaderSize(conn, maxCommandSize).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Warn().Err(err).Msg("Can't read admin command")
+		return
+	}
+
+	// proxy names may contain spaces
+	command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	log.Info().Str("command", command).Str("arg", arg).Msg("Admin command")
+
+	switch command {
+	case CommandStatus:
+		s.status(conn)
+	case CommandDrain:
+		if arg == "" {
+			fmt.Fprintln(conn, "error: usage: drain <proxy>")
+			return
+		}
+		if err = s.m.Drain(arg); err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+			return
+		}
+		fmt.Fprintln(conn, "ok")
+	case CommandKill:
+		if arg == "" {
+			arg = "admin command"
+		}
+		if err = s.m.Kill(arg); err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+			return
+		}
+		fmt.Fprintln(conn, "ok")
+	case CommandResume:
+		if err = s.m.Resume(); err != nil {
+			fmt.Fprintf(conn, "error: %s\n", err)
+			return
+		}
+		fmt.Fprintln(conn, "ok")
+	default:
+		fmt.Fprintf(conn, "error: unknown command: %s\n", command)
+	}
+}
+
+func (s *Server) status(w io.Writer) {
+	fmt.Fprintf(w, "engagement: %s\n\n", s.m.EngagementState())
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:gomnd // padding
+	fmt.Fprintln(tw, "PROXY\tSTATE\tSESSIONS\tRESTARTS\tSINCE\tLAST ERROR")
+	for _, h := range s.m.Health() {
+		lastErr := "-"
+		if h.LastError != nil {
+			lastErr = h.LastError.Error()
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%d\t%d\t%s\t%s\n",
+			h.Name,
+			h.State,
+			h.Sessions,
+			h.Restarts,
+			h.Since.Format(time.RFC3339),
+			lastErr,
+		)
+	}
+	tw.Flush()
+}
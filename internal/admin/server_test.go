@@ -0,0 +1,125 @@
+package admin_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/admin"
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/proxy"
+	"github.com/D00Movenok/BounceBack/internal/proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *proxy.Manager {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	m, err := proxy.NewManager(db, &common.Config{
+		Proxies: []common.ProxyConfig{
+			{
+				Name:       "test",
+				Type:       tcp.ProxyType,
+				ListenAddr: "127.0.0.1:0",
+				TargetAddr: "tcp://127.0.0.1:1",
+				Timeout:    time.Second,
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionDrop,
+				},
+			},
+		},
+	})
+	require.NoError(t, err, "can't create manager")
+	require.NoError(t, m.StartAll())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = m.Shutdown(ctx)
+	})
+	return m
+}
+
+// send command to admin socket and return answer.
+func sendCommand(t *testing.T, path string, command string) string {
+	t.Helper()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err, "can't connect to admin socket")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte(command + "\n"))
+	require.NoError(t, err)
+	answer, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(answer)
+}
+
+func TestServer_Commands(t *testing.T) {
+	m := newTestManager(t)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	// socket left by previous run is replaced
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	s := admin.NewServer(path, m)
+	require.NoError(t, s.Start())
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	status := sendCommand(t, path, admin.CommandStatus)
+	require.True(
+		t,
+		strings.HasPrefix(status, "engagement: active\n"),
+		status,
+	)
+	require.Regexp(t, `(?m)^test\s+running\s+0\s+0\s`, status)
+
+	// kill switch is persisted by engagement
+	require.Equal(t, "ok\n", sendCommand(t, path, "kill leaked ip"))
+	require.Equal(t, engagement.StateKilled, m.EngagementState())
+	require.True(
+		t,
+		strings.HasPrefix(
+			sendCommand(t, path, admin.CommandStatus),
+			"engagement: killed\n",
+		),
+	)
+	require.Equal(t, "ok\n", sendCommand(t, path, admin.CommandResume))
+	require.Equal(t, engagement.StateActive, m.EngagementState())
+
+	require.Equal(
+		t,
+		"error: usage: drain <proxy>\n",
+		sendCommand(t, path, admin.CommandDrain),
+	)
+	require.True(
+		t,
+		strings.HasPrefix(sendCommand(t, path, "drain unknown"), "error: "),
+	)
+	require.Equal(t, "ok\n", sendCommand(t, path, "drain test"))
+	require.Eventually(t, func() bool {
+		return m.Health()[0].State == proxy.StateDrained
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(
+		t,
+		"error: unknown command: reload\n",
+		sendCommand(t, path, "reload"),
+	)
+
+	require.NoError(t, s.Shutdown())
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
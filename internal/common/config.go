@@ -7,6 +7,8 @@ const (
 	RejectActionRedirect = "redirect"
 	RejectActionDrop     = "drop"
 	RejectActionNone     = "none"
+	RejectActionReply    = "reply"
+	RejectActionBanner   = "banner"
 )
 
 const (
@@ -14,6 +16,41 @@ const (
 	FilterActionReject = "reject"
 )
 
+const (
+	ResponseActionDecoy       = "decoy"
+	ResponseActionStripHeader = "strip_header"
+	ResponseActionReplaceBody = "replace_body"
+)
+
+const (
+	BalancingRoundRobin = "round_robin"
+	BalancingWeighted   = "weighted"
+	BalancingSticky     = "sticky"
+)
+
+const (
+	HealthCheckNone = "none"
+	HealthCheckTCP  = "tcp"
+	HealthCheckHTTP = "http"
+)
+
+const (
+	EnrichmentVerdict   = "verdict"
+	EnrichmentRule      = "rule"
+	EnrichmentCountry   = "country"
+	EnrichmentASN       = "asn"
+	EnrichmentPTR       = "ptr"
+	EnrichmentTLS       = "tls"
+	EnrichmentRequestID = "request_id"
+)
+
+const (
+	MuxProtocolAny  = "any"
+	MuxProtocolTLS  = "tls"
+	MuxProtocolHTTP = "http"
+	MuxProtocolSSH  = "ssh"
+)
+
 type RuleConfig struct {
 	Name   string         `mapstructure:"name"`
 	Type   string         `mapstructure:"type"`
@@ -39,24 +76,166 @@ type Filter struct {
 	Action string `mapstructure:"action"`
 }
 
+type ResponseFilter struct {
+	Rule    string `mapstructure:"rule"`
+	Action  string `mapstructure:"action"`
+	Header  string `mapstructure:"header"`
+	Regexp  string `mapstructure:"regexp"`
+	Replace string `mapstructure:"replace"`
+}
+
+type Target struct {
+	Address string `mapstructure:"address"`
+	Weight  uint   `mapstructure:"weight"`
+}
+
+type HealthCheck struct {
+	Type     string        `mapstructure:"type"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Path     string        `mapstructure:"path"`
+}
+
+type TargetSettings struct {
+	Balancing   string      `mapstructure:"balancing"`
+	HealthCheck HealthCheck `mapstructure:"health_check"`
+}
+
+type Persona struct {
+	Name           string            `mapstructure:"name"`
+	StripHeaders   []string          `mapstructure:"strip_headers"`
+	SetHeaders     map[string]string `mapstructure:"set_headers"`
+	RedirectStatus int               `mapstructure:"redirect_status"`
+}
+
+type Enrichment struct {
+	Prefix string   `mapstructure:"prefix"`
+	Fields []string `mapstructure:"fields"`
+}
+
+type MuxRoute struct {
+	Protocol string   `mapstructure:"protocol"`
+	SNI      []string `mapstructure:"sni"`
+	ALPN     []string `mapstructure:"alpn"`
+	Proxy    string   `mapstructure:"proxy"`
+}
+
+type Mux struct {
+	SniffTimeout time.Duration `mapstructure:"sniff_timeout"`
+	Routes       []MuxRoute    `mapstructure:"routes"`
+}
+
+type SNIRoute struct {
+	SNI    []string `mapstructure:"sni"`
+	Target string   `mapstructure:"target"`
+}
+
+type Preread struct {
+	Bytes   int           `mapstructure:"bytes"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type SSH struct {
+	Banner string `mapstructure:"banner"`
+}
+
+type SMTP struct {
+	Hostname    string `mapstructure:"hostname"`
+	RejectReply string `mapstructure:"reject_reply"`
+	MaxSize     int    `mapstructure:"max_size"`
+}
+
+type BannerReply struct {
+	Match string `mapstructure:"match"`
+	Reply string `mapstructure:"reply"`
+	Close bool   `mapstructure:"close"`
+}
+
+type Banner struct {
+	Persona  string        `mapstructure:"persona"`
+	Greeting string        `mapstructure:"greeting"`
+	Replies  []BannerReply `mapstructure:"replies"`
+	MaxLines int           `mapstructure:"max_lines"`
+}
+
+type Limits struct {
+	MaxConnections      int           `mapstructure:"max_connections"`
+	MaxConnectionsPerIP int           `mapstructure:"max_connections_per_ip"`
+	IPv4Prefix          int           `mapstructure:"ipv4_prefix"`
+	IPv6Prefix          int           `mapstructure:"ipv6_prefix"`
+	HeaderTimeout       time.Duration `mapstructure:"header_timeout"`
+	MinRate             int           `mapstructure:"min_rate"`
+	MinRatePeriod       time.Duration `mapstructure:"min_rate_period"`
+}
+
 type ProxyConfig struct {
-	Name         string        `mapstructure:"name"`
-	Type         string        `mapstructure:"type"`
-	ListenAddr   string        `mapstructure:"listen"`
-	TargetAddr   string        `mapstructure:"target"`
-	Timeout      time.Duration `mapstructure:"timeout"`
-	TLS          []TLS         `mapstructure:"tls"`
-	RuleSettings RuleSettings  `mapstructure:"filter_settings"`
-	Filters      []Filter      `mapstructure:"filters"`
+	Name           string         `mapstructure:"name"`
+	Type           string         `mapstructure:"type"`
+	ListenAddr     string         `mapstructure:"listen"`
+	TargetAddr     string         `mapstructure:"target"`
+	Targets        []Target       `mapstructure:"targets"`
+	TargetSettings TargetSettings `mapstructure:"target_settings"`
+	Timeout        time.Duration  `mapstructure:"timeout"`
+	TLS            []TLS          `mapstructure:"tls"`
+	Persona        Persona        `mapstructure:"persona"`
+	RuleSettings   RuleSettings   `mapstructure:"filter_settings"`
+	Filters        []Filter       `mapstructure:"filters"`
+
+	ResponseFilters []ResponseFilter `mapstructure:"response_filters"`
+	Enrichment      Enrichment       `mapstructure:"enrichment"`
+	Mux             Mux              `mapstructure:"mux"`
+	SNIRoutes       []SNIRoute       `mapstructure:"sni_routes"`
+	Preread         Preread          `mapstructure:"preread"`
+	SSH             SSH              `mapstructure:"ssh"`
+	SMTP            SMTP             `mapstructure:"smtp"`
+	Banner          Banner           `mapstructure:"banner"`
+	Limits          Limits           `mapstructure:"limits"`
+	Drain           bool             `mapstructure:"drain"`
 }
 
 type Globals struct {
 	IPApiComKey string `mapstructure:"ip-apicom_key"`
 	IPApiCoKey  string `mapstructure:"ipapico_key"`
+
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	DrainTimeout    time.Duration `mapstructure:"drain_timeout"`
+	AdminSocket     string        `mapstructure:"admin_socket"`
+	ListsDir        string        `mapstructure:"lists_dir"`
+
+	Resolver ResolverConfig `mapstructure:"resolver"`
+}
+
+type ResolverConfig struct {
+	Upstreams []string      `mapstructure:"upstreams"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	Retries   int           `mapstructure:"retries"`
+	CacheSize int           `mapstructure:"cache_size"`
+}
+
+type Engagement struct {
+	Start    time.Time `mapstructure:"start"`
+	End      time.Time `mapstructure:"end"`
+	KillFile string    `mapstructure:"kill_file"`
+}
+
+type ListConfig struct {
+	Name         string        `mapstructure:"name"`
+	URL          string        `mapstructure:"url"`
+	Format       string        `mapstructure:"format"`
+	Column       string        `mapstructure:"column"`
+	Delimiter    string        `mapstructure:"delimiter"`
+	Selector     string        `mapstructure:"selector"`
+	Refresh      time.Duration `mapstructure:"refresh"`
+	SHA256       string        `mapstructure:"sha256"`
+	ChecksumURL  string        `mapstructure:"checksum_url"`
+	SignatureURL string        `mapstructure:"signature_url"`
+	PublicKey    string        `mapstructure:"public_key"`
 }
 
 type Config struct {
-	Rules   []RuleConfig  `mapstructure:"rules"`
-	Proxies []ProxyConfig `mapstructure:"proxies"`
-	Globals Globals       `mapstructure:"globals"`
+	Rules      []RuleConfig  `mapstructure:"rules"`
+	Proxies    []ProxyConfig `mapstructure:"proxies"`
+	Globals    Globals       `mapstructure:"globals"`
+	Engagement Engagement    `mapstructure:"engagement"`
+	Lists      []ListConfig  `mapstructure:"lists"`
 }
@@ -3,6 +3,7 @@ package database
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	xdr "github.com/davecgh/go-xdr/xdr2"
 	badger "github.com/dgraph-io/badger/v3"
@@ -54,13 +55,28 @@ func getCache[T any](db *DB, key string, prefix string) (*T, error) {
 // save cache to cache db to "prefix-key".
 // it is not a method, because of previous func.
 func saveCache(db *DB, key string, prefix string, data any) error {
+	return saveCacheTTL(db, key, prefix, data, 0)
+}
+
+// save cache which expires after ttl, zero ttl means forever.
+func saveCacheTTL(
+	db *DB,
+	key string,
+	prefix string,
+	data any,
+	ttl time.Duration,
+) error {
 	err := db.DB.Update(func(txn *badger.Txn) error {
 		var w bytes.Buffer
 		_, err := xdr.Marshal(&w, data)
 		if err != nil {
 			return fmt.Errorf("can't marshal value: %w", err)
 		}
-		err = txn.Set([]byte(prefix+key), w.Bytes())
+		e := badger.NewEntry([]byte(prefix+key), w.Bytes())
+		if ttl > 0 {
+			e = e.WithTTL(ttl)
+		}
+		err = txn.SetEntry(e)
 		if err != nil {
 			return fmt.Errorf("can't save value to storage: %w", err)
 		}
@@ -0,0 +1,24 @@
+package database
+
+import "time"
+
+const DNSBLPrefix string = "ip-dnsbl-"
+
+// DNSBL is an answer of DNSBL zone, no codes means IP isn't listed.
+type DNSBL struct {
+	Codes []string
+}
+
+func (db *DB) GetDNSBL(zone string, ip string) (*DNSBL, error) {
+	return getCache[DNSBL](db, zone+"-"+ip, DNSBLPrefix)
+}
+
+// SaveDNSBL caches answer for ttl of zone.
+func (db *DB) SaveDNSBL(
+	zone string,
+	ip string,
+	d *DNSBL,
+	ttl time.Duration,
+) error {
+	return saveCacheTTL(db, zone+"-"+ip, DNSBLPrefix, d, ttl)
+}
@@ -1,6 +1,8 @@
 package database
 
-const GeolocationPrefix string = "ip-geo-"
+// GeolocationPrefix is versioned, because cache of older versions has
+// no coordinates.
+const GeolocationPrefix string = "ip-geo-v2-"
 
 type Geolocation struct {
 	Organisation []string
@@ -11,6 +13,15 @@ type Geolocation struct {
 	City         string
 	Timezone     string
 	ASN          string
+	// Located is false if coordinates are unknown.
+	Located   bool
+	Latitude  float64
+	Longitude float64
+	// Classified is false if Proxy, Hosting and Mobile are unknown.
+	Classified bool
+	Proxy      bool
+	Hosting    bool
+	Mobile     bool
 }
 
 func (db *DB) GetGeolocation(ip string) (*Geolocation, error) {
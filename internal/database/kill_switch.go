@@ -0,0 +1,36 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+const (
+	KillSwitchPrefix string = "engagement-"
+
+	killSwitchKey = "kill"
+)
+
+type KillSwitch struct {
+	Killed bool
+	Reason string
+	// unix time of the kill.
+	Time int64
+}
+
+func (db *DB) GetKillSwitch() (*KillSwitch, error) {
+	ks, err := getCache[KillSwitch](db, killSwitchKey, KillSwitchPrefix)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("can't get kill switch: %w", err)
+	}
+	if ks == nil {
+		return &KillSwitch{}, nil
+	}
+	return ks, nil
+}
+
+func (db *DB) SaveKillSwitch(ks *KillSwitch) error {
+	return saveCache(db, killSwitchKey, KillSwitchPrefix, ks)
+}
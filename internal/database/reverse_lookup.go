@@ -1,5 +1,7 @@
 package database
 
+import "time"
+
 const ReverseLookupPrefix string = "ip-lookup-"
 
 type ReverseLookup struct {
@@ -13,3 +15,19 @@ func (db *DB) GetReverseLookup(ip string) (*ReverseLookup, error) {
 func (db *DB) SaveReverseLookup(ip string, rl *ReverseLookup) error {
 	return saveCache(db, ip, ReverseLookupPrefix, rl)
 }
+
+// ConfirmedLookupPrefix keeps only forward-confirmed PTR domains.
+const ConfirmedLookupPrefix string = "ip-fcrdns-"
+
+func (db *DB) GetConfirmedLookup(ip string) (*ReverseLookup, error) {
+	return getCache[ReverseLookup](db, ip, ConfirmedLookupPrefix)
+}
+
+// SaveConfirmedLookup caches confirmed domains for ttl of DNS answers.
+func (db *DB) SaveConfirmedLookup(
+	ip string,
+	rl *ReverseLookup,
+	ttl time.Duration,
+) error {
+	return saveCacheTTL(db, ip, ConfirmedLookupPrefix, rl, ttl)
+}
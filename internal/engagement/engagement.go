@@ -0,0 +1,177 @@
+package engagement
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/rs/zerolog/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	StateActive  = "active"
+	StatePending = "pending"
+	StateEnded   = "ended"
+	StateKilled  = "killed"
+
+	killFileInterval = time.Second
+)
+
+// Engagement tracks whether requests may be passed to targets: only
+// within engagement window and until kill switch is flipped. Kill switch
+// is persisted, so it survives restarts until resumed.
+type Engagement struct {
+	start    time.Time
+	end      time.Time
+	killFile string
+
+	db     *database.DB
+	killed *atomic.Bool
+
+	mu     sync.Mutex
+	onKill []func()
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(cfg common.Engagement, db *database.DB) (*Engagement, error) {
+	if !cfg.Start.IsZero() && !cfg.End.IsZero() && !cfg.End.After(cfg.Start) {
+		return nil, ErrInvalidWindow
+	}
+
+	ks, err := db.GetKillSwitch()
+	if err != nil {
+		return nil, fmt.Errorf("can't load kill switch: %w", err)
+	}
+	if ks.Killed {
+		log.Warn().
+			Str("reason", ks.Reason).
+			Time("killed_at", time.Unix(ks.Time, 0)).
+			Msg("Engagement is killed, all requests are rejected")
+	}
+
+	e := &Engagement{
+		start:    cfg.Start,
+		end:      cfg.End,
+		killFile: cfg.KillFile,
+
+		db:     db,
+		killed: atomic.NewBool(ks.Killed),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	return e, nil
+}
+
+// OnKill registers f to be called after kill switch is flipped.
+func (e *Engagement) OnKill(f func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onKill = append(e.onKill, f)
+}
+
+// State returns current engagement state.
+func (e *Engagement) State() string {
+	if e.killed.Load() {
+		return StateKilled
+	}
+	now := time.Now()
+	if !e.start.IsZero() && now.Before(e.start) {
+		return StatePending
+	}
+	if !e.end.IsZero() && !now.Before(e.end) {
+		return StateEnded
+	}
+	return StateActive
+}
+
+// Active returns true if requests may be passed to targets.
+func (e *Engagement) Active() bool {
+	return e.State() == StateActive
+}
+
+// Kill flips kill switch, so all requests are rejected.
+func (e *Engagement) Kill(reason string) error {
+	if e.killed.Swap(true) {
+		return nil
+	}
+	log.Warn().Str("reason", reason).Msg("Engagement killed")
+
+	ks := &database.KillSwitch{
+		Killed: true,
+		Reason: reason,
+		Time:   time.Now().Unix(),
+	}
+	err := e.db.SaveKillSwitch(ks)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, f := range e.onKill {
+		f()
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't save kill switch: %w", err)
+	}
+	return nil
+}
+
+// Resume flips kill switch back. Kill file must be removed before,
+// otherwise engagement is killed again.
+func (e *Engagement) Resume() error {
+	if err := e.db.SaveKillSwitch(&database.KillSwitch{}); err != nil {
+		return fmt.Errorf("can't save kill switch: %w", err)
+	}
+	if e.killed.Swap(false) {
+		log.Warn().Msg("Engagement resumed")
+	}
+	return nil
+}
+
+// Start watching kill file.
+func (e *Engagement) Start() {
+	if e.killFile == "" {
+		close(e.done)
+		return
+	}
+	go e.watchKillFile()
+}
+
+func (e *Engagement) Stop() {
+	close(e.stop)
+	<-e.done
+}
+
+func (e *Engagement) watchKillFile() {
+	defer close(e.done)
+
+	t := time.NewTicker(killFileInterval)
+	defer t.Stop()
+	for {
+		_, err := os.Stat(e.killFile)
+		switch {
+		case err == nil:
+			if !e.killed.Load() {
+				if err = e.Kill("kill file " + e.killFile); err != nil {
+					log.Error().Err(err).Msg("Can't kill engagement")
+				}
+			}
+		case !errors.Is(err, fs.ErrNotExist):
+			log.Error().Err(err).Msg("Can't check kill file")
+		}
+
+		select {
+		case <-e.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
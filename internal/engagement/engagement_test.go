@@ -0,0 +1,146 @@
+package engagement_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func newDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+	return db
+}
+
+func TestEngagement_State(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		cfg     common.Engagement
+		want    string
+		wantErr error
+	}{
+		{
+			name: "no window",
+			want: engagement.StateActive,
+		},
+		{
+			name: "before start",
+			cfg: common.Engagement{
+				Start: now.Add(time.Hour),
+				End:   now.Add(2 * time.Hour),
+			},
+			want: engagement.StatePending,
+		},
+		{
+			name: "active",
+			cfg: common.Engagement{
+				Start: now.Add(-time.Hour),
+				End:   now.Add(time.Hour),
+			},
+			want: engagement.StateActive,
+		},
+		{
+			name: "after end",
+			cfg: common.Engagement{
+				Start: now.Add(-2 * time.Hour),
+				End:   now.Add(-time.Hour),
+			},
+			want: engagement.StateEnded,
+		},
+		{
+			name: "start only",
+			cfg:  common.Engagement{Start: now.Add(-time.Hour)},
+			want: engagement.StateActive,
+		},
+		{
+			name: "end only",
+			cfg:  common.Engagement{End: now.Add(-time.Hour)},
+			want: engagement.StateEnded,
+		},
+		{
+			name: "invalid window",
+			cfg: common.Engagement{
+				Start: now.Add(time.Hour),
+				End:   now,
+			},
+			wantErr: engagement.ErrInvalidWindow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := engagement.New(tt.cfg, newDB(t))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, e.State())
+			require.Equal(t, tt.want == engagement.StateActive, e.Active())
+		})
+	}
+}
+
+func TestEngagement_Kill(t *testing.T) {
+	db := newDB(t)
+	e, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err)
+
+	killed := atomic.NewInt64(0)
+	e.OnKill(func() { killed.Inc() })
+	require.NoError(t, e.Kill("test"))
+	require.NoError(t, e.Kill("again"))
+	require.Equal(t, engagement.StateKilled, e.State())
+	require.Equal(t, int64(1), killed.Load())
+
+	// kill switch survives restart
+	e, err = engagement.New(common.Engagement{}, db)
+	require.NoError(t, err)
+	require.Equal(t, engagement.StateKilled, e.State())
+	ks, err := db.GetKillSwitch()
+	require.NoError(t, err)
+	require.Equal(t, "test", ks.Reason)
+
+	require.NoError(t, e.Resume())
+	require.Equal(t, engagement.StateActive, e.State())
+	e, err = engagement.New(common.Engagement{}, db)
+	require.NoError(t, err)
+	require.Equal(t, engagement.StateActive, e.State())
+}
+
+func TestEngagement_KillFile(t *testing.T) {
+	killFile := filepath.Join(t.TempDir(), "kill")
+	e, err := engagement.New(
+		common.Engagement{KillFile: killFile},
+		newDB(t),
+	)
+	require.NoError(t, err)
+
+	killed := atomic.NewInt64(0)
+	e.OnKill(func() { killed.Inc() })
+	e.Start()
+	defer e.Stop()
+	require.Equal(t, engagement.StateActive, e.State())
+
+	require.NoError(t, os.WriteFile(killFile, nil, 0o600))
+	require.Eventually(t, func() bool {
+		return killed.Load() == 1
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Equal(t, engagement.StateKilled, e.State())
+
+	// removed kill file doesn't resume engagement
+	require.NoError(t, os.Remove(killFile))
+	time.Sleep(1500 * time.Millisecond)
+	require.Equal(t, engagement.StateKilled, e.State())
+	require.Equal(t, int64(1), killed.Load())
+}
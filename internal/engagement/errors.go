@@ -0,0 +1,7 @@
+package engagement
+
+import "errors"
+
+var (
+	ErrInvalidWindow = errors.New("engagement end must be after start")
+)
@@ -0,0 +1,130 @@
+package packs
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Scheme prefixes paths of packs, e.g. "builtin://scanner-paths".
+const Scheme = "builtin://"
+
+const dataDir = "data"
+
+// header keys of pack.
+const (
+	headerPack        = "pack"
+	headerVersion     = "version"
+	headerDescription = "description"
+	headerUse         = "use"
+)
+
+var (
+	ErrUnknownPack = errors.New("unknown pack")
+)
+
+//go:embed data/*.txt
+var data embed.FS
+
+// Pack is a curated list embedded in binary.
+type Pack struct {
+	Name        string
+	Version     string
+	Description string
+	Use         string
+	Entries     int
+}
+
+// IsBuiltin returns pack name if path has Scheme.
+func IsBuiltin(p string) (string, bool) {
+	return strings.CutPrefix(p, Scheme)
+}
+
+// Open returns content of pack.
+func Open(name string) (io.ReadCloser, error) {
+	b, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// Export writes content of pack to w.
+func Export(name string, w io.Writer) error {
+	b, err := read(name)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(b); err != nil {
+		return fmt.Errorf("can't write pack: %w", err)
+	}
+	return nil
+}
+
+// List returns all packs sorted by name.
+func List() ([]Pack, error) {
+	files, err := data.ReadDir(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read packs: %w", err)
+	}
+
+	l := make([]Pack, 0, len(files))
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".txt")
+		b, rerr := read(name)
+		if rerr != nil {
+			return nil, rerr
+		}
+		l = append(l, parse(name, b))
+	}
+	return l, nil
+}
+
+func read(name string) ([]byte, error) {
+	if strings.ContainsAny(name, "/\\") {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPack, name)
+	}
+	b, err := data.ReadFile(path.Join(dataDir, name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPack, name)
+	}
+	return b, nil
+}
+
+// parse reads "# key: value" header and counts entries.
+func parse(name string, b []byte) Pack {
+	p := Pack{Name: name}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			p.Entries++
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case headerPack:
+			p.Name = value
+		case headerVersion:
+			p.Version = value
+		case headerDescription:
+			p.Description = value
+		case headerUse:
+			p.Use = value
+		}
+	}
+	return p
+}
+
+func (p Pack) String() string {
+	return fmt.Sprintf("%s%s (v%s)", Scheme, p.Name, p.Version)
+}
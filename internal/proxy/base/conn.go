@@ -0,0 +1,92 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const pipeBufSize = 64 * 1024
+
+// ReplayConn is a connection which returns already read (peeked) data
+// before reading from underlying connection.
+type ReplayConn struct {
+	net.Conn
+
+	r io.Reader
+}
+
+func NewReplayConn(conn net.Conn, data []byte) *ReplayConn {
+	return &ReplayConn{
+		Conn: conn,
+		r:    io.MultiReader(bytes.NewReader(data), conn),
+	}
+}
+
+func (c *ReplayConn) Read(b []byte) (int, error) {
+	return c.r.Read(b) //nolint: wrapcheck // transparent wrapper
+}
+
+// Forward connects to addr and passes data between src and it as is.
+func Forward(
+	src net.Conn,
+	addr string,
+	timeout time.Duration,
+	l zerolog.Logger,
+) {
+	dst, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		l.Error().Err(err).Msg("Failed to connect to target")
+		return
+	}
+	Pipe(src, dst, timeout, l)
+}
+
+// Pipe passes data between connections in both directions as is until
+// one of them is closed or idle timeout is exceeded. Both connections
+// are closed after that.
+func Pipe(a net.Conn, b net.Conn, timeout time.Duration, l zerolog.Logger) {
+	handler := func(src net.Conn, dst net.Conn, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if err := pipeOneSide(src, dst, timeout); err != nil &&
+			!IsConnectionClosed(err) {
+			l.Error().Err(err).Msg("Connection error")
+		}
+
+		// if not close both, one side will wait until timeout
+		src.Close()
+		dst.Close()
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2) //nolint:gomnd // two connections
+	go handler(a, b, &wg)
+	go handler(b, a, &wg)
+	wg.Wait()
+}
+
+func pipeOneSide(src net.Conn, dst net.Conn, timeout time.Duration) error {
+	buf := make([]byte, pipeBufSize)
+	for {
+		_ = src.SetReadDeadline(time.Now().Add(timeout))
+		nr, err := src.Read(buf)
+		if nr > 0 {
+			_ = dst.SetWriteDeadline(time.Now().Add(timeout))
+			if _, werr := dst.Write(buf[:nr]); werr != nil {
+				return fmt.Errorf("proxy connection write: %w", werr)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("proxy connection read: %w", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package base
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"golang.org/x/exp/slices"
+)
+
+func GetEnrichmentFields() []string {
+	return []string{
+		common.EnrichmentVerdict,
+		common.EnrichmentRule,
+		common.EnrichmentCountry,
+		common.EnrichmentASN,
+		common.EnrichmentPTR,
+		common.EnrichmentTLS,
+		common.EnrichmentRequestID,
+	}
+}
+
+func verifyEnrichment(cfg common.Enrichment) error {
+	if cfg.Prefix == "" {
+		if len(cfg.Fields) != 0 {
+			return ErrNoEnrichPrefix
+		}
+		return nil
+	}
+	fields := GetEnrichmentFields()
+	for _, f := range cfg.Fields {
+		if !slices.Contains(fields, f) {
+			return &UnknownEnrichmentFieldError{field: f}
+		}
+	}
+	return nil
+}
+
+// EnrichmentFields returns configured enrichment fields, all fields if
+// none are configured and nil if enrichment is disabled.
+func (p *Proxy) EnrichmentFields() []string {
+	switch {
+	case p.Config.Enrichment.Prefix == "":
+		return nil
+	case len(p.Config.Enrichment.Fields) == 0:
+		return GetEnrichmentFields()
+	default:
+		return p.Config.Enrichment.Fields
+	}
+}
+
+// Enrich returns verdict context for configured enrichment fields.
+// Geolocation and PTR are taken from cache only, so they are empty if
+// no rule looked them up. Proxy specific fields (e.g. "tls") are skipped.
+func (p *Proxy) Enrich(ip netip.Addr, v Verdict) map[string]string {
+	fields := p.EnrichmentFields()
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		switch f {
+		case common.EnrichmentVerdict:
+			values[f] = v.Action
+		case common.EnrichmentRule:
+			values[f] = v.Rule
+		case common.EnrichmentCountry:
+			if geo, err := p.db.GetGeolocation(ip.String()); err == nil {
+				values[f] = geo.CountryCode
+			}
+		case common.EnrichmentASN:
+			if geo, err := p.db.GetGeolocation(ip.String()); err == nil {
+				values[f] = geo.ASN
+			}
+		case common.EnrichmentPTR:
+			rl, err := p.db.GetReverseLookup(ip.String())
+			if err == nil {
+				values[f] = strings.Join(rl.Domains, ",")
+			}
+		}
+	}
+	return values
+}
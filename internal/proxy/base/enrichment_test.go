@@ -0,0 +1,86 @@
+package base
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Enrich(t *testing.T) {
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	defer db.DB.Close()
+
+	known := netip.MustParseAddr("1.1.1.1")
+	geo := &database.Geolocation{CountryCode: "US", ASN: "AS13335"}
+	require.NoError(t, db.SaveGeolocation(known.String(), geo))
+	rl := &database.ReverseLookup{
+		Domains: []string{"one.one.one.one", "cloudflare-dns.com"},
+	}
+	require.NoError(t, db.SaveReverseLookup(known.String(), rl))
+	v := Verdict{Action: VerdictReject, Rule: "scanner"}
+
+	tests := []struct {
+		name string
+		cfg  common.Enrichment
+		ip   netip.Addr
+		want map[string]string
+	}{
+		{
+			name: "disabled",
+			cfg:  common.Enrichment{},
+			ip:   known,
+			want: map[string]string{},
+		},
+		{
+			// proxy specific fields are filled by proxy
+			name: "all fields",
+			cfg:  common.Enrichment{Prefix: "X-BB-"},
+			ip:   known,
+			want: map[string]string{
+				common.EnrichmentVerdict: VerdictReject,
+				common.EnrichmentRule:    "scanner",
+				common.EnrichmentCountry: "US",
+				common.EnrichmentASN:     "AS13335",
+				common.EnrichmentPTR:     "one.one.one.one,cloudflare-dns.com",
+			},
+		},
+		{
+			name: "selected fields",
+			cfg: common.Enrichment{
+				Prefix: "X-BB-",
+				Fields: []string{
+					common.EnrichmentVerdict,
+					common.EnrichmentASN,
+				},
+			},
+			ip: known,
+			want: map[string]string{
+				common.EnrichmentVerdict: VerdictReject,
+				common.EnrichmentASN:     "AS13335",
+			},
+		},
+		{
+			// nothing is looked up, only cache is used
+			name: "not cached",
+			cfg:  common.Enrichment{Prefix: "X-BB-"},
+			ip:   netip.MustParseAddr("8.8.8.8"),
+			want: map[string]string{
+				common.EnrichmentVerdict: VerdictReject,
+				common.EnrichmentRule:    "scanner",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Proxy{
+				Config: common.ProxyConfig{Enrichment: tt.cfg},
+				db:     db,
+			}
+			require.Equal(t, tt.want, p.Enrich(tt.ip, v))
+		})
+	}
+}
@@ -1,9 +1,71 @@
 package base
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrShutdownTimeout = errors.New("proxy shutdown timeout")
 	ErrDropped         = errors.New("connection dropped")
 	ErrTLSUnsupported  = errors.New("TLS is unsopported")
+	ErrNoTargets       = errors.New("no targets specified")
+	ErrTargetsConflict = errors.New("\"target\" and \"targets\" are both set")
+	ErrNoEnrichPrefix  = errors.New("enrichment fields require prefix")
+	ErrNegativeLimit   = errors.New("limits can't be negative")
+	ErrProxyLimit      = errors.New("proxy connection limit exceeded")
+	ErrIPLimit         = errors.New("source connection limit exceeded")
 )
+
+type UnknownBalancingError struct {
+	balancing string
+}
+
+func (e UnknownBalancingError) Error() string {
+	return fmt.Sprintf("unknown balancing: %s", e.balancing)
+}
+
+type UnknownHealthCheckError struct {
+	check string
+}
+
+func (e UnknownHealthCheckError) Error() string {
+	return fmt.Sprintf("unknown health check: %s", e.check)
+}
+
+type UnsupportedHealthCheckError struct {
+	check  string
+	target string
+}
+
+func (e UnsupportedHealthCheckError) Error() string {
+	return fmt.Sprintf(
+		"health check \"%s\" isn't supported by target \"%s\"",
+		e.check,
+		e.target,
+	)
+}
+
+type UnhealthyStatusError struct {
+	status int
+}
+
+func (e UnhealthyStatusError) Error() string {
+	return fmt.Sprintf("unhealthy status code: %d", e.status)
+}
+
+type UnknownEnrichmentFieldError struct {
+	field string
+}
+
+func (e UnknownEnrichmentFieldError) Error() string {
+	return fmt.Sprintf("unknown enrichment field: %s", e.field)
+}
+
+type InvalidLimitPrefixError struct {
+	bits int
+}
+
+func (e InvalidLimitPrefixError) Error() string {
+	return fmt.Sprintf("invalid limit prefix length: %d", e.bits)
+}
@@ -0,0 +1,216 @@
+package base
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/rs/zerolog"
+	"go.uber.org/atomic"
+)
+
+const (
+	defaultLimitIPv4Prefix = 32
+	defaultLimitIPv6Prefix = 64
+	defaultMinRatePeriod   = 10 * time.Second
+)
+
+// Limiter limits concurrent connections of the proxy and of every source
+// prefix and closes connections sending data too slowly. Zero limits
+// mean unlimited.
+type Limiter struct {
+	cfg common.Limits
+
+	mu     sync.Mutex
+	total  int
+	perNet map[netip.Prefix]int
+
+	limited *atomic.Uint64
+	slow    *atomic.Uint64
+}
+
+func NewLimiter(cfg common.Limits) (*Limiter, error) {
+	if cfg.MaxConnections < 0 || cfg.MaxConnectionsPerIP < 0 ||
+		cfg.MinRate < 0 || cfg.HeaderTimeout < 0 || cfg.MinRatePeriod < 0 {
+		return nil, ErrNegativeLimit
+	}
+
+	if cfg.IPv4Prefix == 0 {
+		cfg.IPv4Prefix = defaultLimitIPv4Prefix
+	}
+	if cfg.IPv4Prefix < 0 || cfg.IPv4Prefix > 32 {
+		return nil, &InvalidLimitPrefixError{bits: cfg.IPv4Prefix}
+	}
+	if cfg.IPv6Prefix == 0 {
+		cfg.IPv6Prefix = defaultLimitIPv6Prefix
+	}
+	if cfg.IPv6Prefix < 0 || cfg.IPv6Prefix > 128 {
+		return nil, &InvalidLimitPrefixError{bits: cfg.IPv6Prefix}
+	}
+	if cfg.MinRatePeriod == 0 {
+		cfg.MinRatePeriod = defaultMinRatePeriod
+	}
+
+	l := &Limiter{
+		cfg:    cfg,
+		perNet: map[netip.Prefix]int{},
+
+		limited: atomic.NewUint64(0),
+		slow:    atomic.NewUint64(0),
+	}
+	return l, nil
+}
+
+// Acquire reserves connection slot for ip. Returned release func must be
+// called when connection is closed, it's a noop if limit is exceeded.
+// Slots are counted even without limits, so active sessions are known.
+func (l *Limiter) Acquire(ip netip.Addr) (func(), error) {
+	bits := l.cfg.IPv6Prefix
+	if ip.Is4() {
+		bits = l.cfg.IPv4Prefix
+	}
+	prefix, _ := ip.Prefix(bits)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.MaxConnections > 0 && l.total >= l.cfg.MaxConnections {
+		l.limited.Inc()
+		return func() {}, ErrProxyLimit
+	}
+	if l.cfg.MaxConnectionsPerIP > 0 &&
+		l.perNet[prefix] >= l.cfg.MaxConnectionsPerIP {
+		l.limited.Inc()
+		return func() {}, ErrIPLimit
+	}
+	l.total++
+	l.perNet[prefix]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(prefix)
+		})
+	}, nil
+}
+
+func (l *Limiter) release(prefix netip.Prefix) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	l.perNet[prefix]--
+	if l.perNet[prefix] <= 0 {
+		delete(l.perNet, prefix)
+	}
+}
+
+// Limited returns count of connections over the limits.
+func (l *Limiter) Limited() uint64 {
+	return l.limited.Load()
+}
+
+// Slow returns count of connections closed due to low transfer rate.
+func (l *Limiter) Slow() uint64 {
+	return l.slow.Load()
+}
+
+// Active returns count of connections holding a slot.
+func (l *Limiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// WrapConn returns connection which is closed if client sends data
+// slower than min rate. Idle periods (no data at all) are left to
+// proxy timeouts.
+func (l *Limiter) WrapConn(conn net.Conn, logger zerolog.Logger) net.Conn {
+	if l.cfg.MinRate == 0 {
+		return conn
+	}
+
+	period := l.cfg.MinRatePeriod
+	minBytes := int64(float64(l.cfg.MinRate) * period.Seconds())
+	return newRateConn(conn, minBytes, period, func(n int64) {
+		logger.Warn().
+			Int64("bytes", n).
+			Stringer("period", period).
+			Uint64("slow_total", l.slow.Inc()).
+			Msg("Transfer rate is too low, closing")
+	})
+}
+
+// listener wrapping accepted connections with Limiter.WrapConn.
+type rateListener struct {
+	net.Listener
+
+	limiter *Limiter
+	logger  zerolog.Logger
+}
+
+func (l rateListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint: wrapcheck // servers check raw error
+	}
+	from := NetAddrToNetipAddrPort(conn.RemoteAddr()).Addr().Unmap()
+	logger := l.logger.With().Stringer("from", from).Logger()
+	return l.limiter.WrapConn(conn, logger), nil
+}
+
+// rateConn counts read bytes and closes connection if less than minBytes
+// (but more than zero) were read during a period.
+type rateConn struct {
+	net.Conn
+
+	read *atomic.Int64
+
+	mu     sync.Mutex
+	closed bool
+	timer  *time.Timer
+}
+
+func newRateConn(
+	conn net.Conn,
+	minBytes int64,
+	period time.Duration,
+	onSlow func(n int64),
+) *rateConn {
+	c := &rateConn{
+		Conn: conn,
+		read: atomic.NewInt64(0),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = time.AfterFunc(period, func() {
+		n := c.read.Swap(0)
+		if n > 0 && n < minBytes {
+			onSlow(n)
+			_ = c.Close()
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.closed {
+			c.timer.Reset(period)
+		}
+	})
+	return c
+}
+
+func (c *rateConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err //nolint: wrapcheck // transparent wrapper
+}
+
+func (c *rateConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.timer.Stop()
+	c.mu.Unlock()
+	return c.Conn.Close() //nolint: wrapcheck // transparent wrapper
+}
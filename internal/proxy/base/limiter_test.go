@@ -0,0 +1,175 @@
+package base
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     common.Limits
+		wantErr error
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "negative max connections",
+			cfg:     common.Limits{MaxConnections: -1},
+			wantErr: ErrNegativeLimit,
+		},
+		{
+			name:    "negative min rate",
+			cfg:     common.Limits{MinRate: -1},
+			wantErr: ErrNegativeLimit,
+		},
+		{
+			name:    "invalid ipv4 prefix",
+			cfg:     common.Limits{IPv4Prefix: 33},
+			wantErr: &InvalidLimitPrefixError{bits: 33},
+		},
+		{
+			name:    "invalid ipv6 prefix",
+			cfg:     common.Limits{IPv6Prefix: -1},
+			wantErr: &InvalidLimitPrefixError{bits: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLimiter(tt.cfg)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLimiter_Acquire(t *testing.T) {
+	l, err := NewLimiter(common.Limits{
+		MaxConnections:      3,
+		MaxConnectionsPerIP: 2,
+		IPv4Prefix:          24,
+	})
+	require.NoError(t, err)
+
+	a := netip.MustParseAddr("10.0.0.1")
+	b := netip.MustParseAddr("10.0.0.2")
+	c := netip.MustParseAddr("10.0.1.1")
+
+	releaseA, err := l.Acquire(a)
+	require.NoError(t, err)
+	_, err = l.Acquire(b)
+	require.NoError(t, err)
+
+	// a and b are in the same /24
+	_, err = l.Acquire(a)
+	require.ErrorIs(t, err, ErrIPLimit)
+
+	releaseC, err := l.Acquire(c)
+	require.NoError(t, err)
+	_, err = l.Acquire(netip.MustParseAddr("10.0.2.1"))
+	require.ErrorIs(t, err, ErrProxyLimit)
+	require.Equal(t, 3, l.Active())
+	require.Equal(t, uint64(2), l.Limited())
+
+	// release is idempotent, so slots can't be freed twice
+	releaseA()
+	releaseA()
+	require.Equal(t, 2, l.Active())
+	releaseC()
+	require.Equal(t, 1, l.Active())
+
+	_, err = l.Acquire(a)
+	require.NoError(t, err)
+	_, err = l.Acquire(a)
+	require.ErrorIs(t, err, ErrIPLimit)
+	require.Equal(t, 2, l.Active())
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l, err := NewLimiter(common.Limits{})
+	require.NoError(t, err)
+
+	ip := netip.MustParseAddr("2001:db8::1")
+	releases := make([]func(), 0, 100)
+	for i := 0; i < 100; i++ {
+		release, aerr := l.Acquire(ip)
+		require.NoError(t, aerr)
+		releases = append(releases, release)
+	}
+	require.Equal(t, 100, l.Active())
+	for _, release := range releases {
+		release()
+	}
+	require.Equal(t, 0, l.Active())
+	require.Zero(t, l.Limited())
+}
+
+func TestLimiter_WrapConn(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantSlow bool
+	}{
+		{
+			name:     "slow",
+			data:     []byte("a"),
+			wantSlow: true,
+		},
+		{
+			name: "fast",
+			data: make([]byte, 64),
+		},
+		{
+			// idle connections are left to proxy timeouts
+			name: "idle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLimiter(common.Limits{
+				MinRate:       100,
+				MinRatePeriod: 100 * time.Millisecond,
+			})
+			require.NoError(t, err)
+
+			server, client := net.Pipe()
+			defer client.Close()
+			conn := l.WrapConn(server, log.Logger)
+			defer conn.Close()
+
+			go func() {
+				if len(tt.data) > 0 {
+					_, _ = client.Write(tt.data)
+				}
+			}()
+			if len(tt.data) > 0 {
+				buf := make([]byte, len(tt.data))
+				_, err = conn.Read(buf)
+				require.NoError(t, err)
+			}
+
+			time.Sleep(250 * time.Millisecond)
+			_ = client.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+			_, err = client.Read(make([]byte, 1))
+			if tt.wantSlow {
+				require.Equal(t, uint64(1), l.Slow())
+				require.ErrorIs(t, err, io.EOF)
+			} else {
+				require.Zero(t, l.Slow())
+				require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+			}
+		})
+	}
+}
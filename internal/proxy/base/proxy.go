@@ -4,11 +4,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
+	"net/netip"
 	"sync"
 	"time"
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/rules"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
 	"github.com/rs/zerolog"
@@ -24,6 +27,7 @@ func NewBaseProxy(
 	cfg common.ProxyConfig,
 	rs *rules.RuleSet,
 	db *database.DB,
+	eng *engagement.Engagement,
 	actions []string,
 ) (*Proxy, error) {
 	logger := log.With().
@@ -56,6 +60,32 @@ func NewBaseProxy(
 		}
 	}
 
+	responseActions := []string{
+		common.ResponseActionDecoy,
+		common.ResponseActionStripHeader,
+		common.ResponseActionReplaceBody,
+	}
+	for _, f := range cfg.ResponseFilters {
+		_, ok := rs.Get(f.Rule)
+		if !ok {
+			return nil, fmt.Errorf(
+				"can't find response rule \"%s\" for proxy \"%s\"",
+				f.Rule,
+				cfg.Name,
+			)
+		}
+		if !slices.Contains(responseActions, f.Action) {
+			return nil, fmt.Errorf(
+				"unknown response filter action: %s",
+				f.Action,
+			)
+		}
+	}
+
+	if err = verifyEnrichment(cfg.Enrichment); err != nil {
+		return nil, err
+	}
+
 	if cfg.Timeout == 0 {
 		cfg.Timeout = defaultTimeout
 		logger.Debug().Msgf(
@@ -64,14 +94,24 @@ func NewBaseProxy(
 		)
 	}
 
+	limiter, err := NewLimiter(cfg.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("can't create limiter: %w", err)
+	}
+
 	base := &Proxy{
-		Config: cfg,
+		Config:  cfg,
+		Limiter: limiter,
 
 		Closing: false,
 		Logger:  logger,
 
-		db:    db,
-		rules: rs,
+		errs:     make(chan error, 1),
+		sessions: newSessions(),
+
+		db:         db,
+		rules:      rs,
+		engagement: eng,
 	}
 
 	if len(cfg.TLS) > 0 {
@@ -126,6 +166,7 @@ func NewBaseProxy(
 type Proxy struct {
 	Config    common.ProxyConfig
 	TLSConfig *tls.Config
+	Limiter   *Limiter
 
 	Closing bool
 	WG      sync.WaitGroup
@@ -133,27 +174,117 @@ type Proxy struct {
 
 	db    *database.DB
 	rules *rules.RuleSet
+	errs  chan error
+
+	engagement *engagement.Engagement
+
+	sessions *sessions
+}
+
+// Fail reports unexpected server error, so proxy can be restarted.
+// Only the first error is kept.
+func (p *Proxy) Fail(err error) {
+	select {
+	case p.errs <- err:
+	default:
+	}
+}
+
+// Errors returns channel of unexpected server errors.
+func (p *Proxy) Errors() <-chan error {
+	return p.errs
 }
 
 func (p *Proxy) GetLogger() *zerolog.Logger {
 	logger := p.Logger.With().
 		Str("listen", p.Config.ListenAddr).
-		Str("target", p.Config.TargetAddr).
+		Str("target", formatTargets(p.Config)).
 		Str("type", p.Config.Type).
 		Logger()
 	return &logger
 }
 
+// Acquire reserves connection slot for ip, exceeded limits are logged.
+// Returns false if limits are exceeded, then connection must be rejected.
+func (p *Proxy) Acquire(
+	ip netip.Addr,
+	logger zerolog.Logger,
+) (func(), bool) {
+	release, err := p.Limiter.Acquire(ip)
+	if err != nil {
+		p.LogLimited(err, logger)
+		return release, false
+	}
+	return release, true
+}
+
+// LogLimited logs connection over the limits.
+func (p *Proxy) LogLimited(err error, logger zerolog.Logger) {
+	logger.Warn().
+		Err(err).
+		Uint64("limited_total", p.Limiter.Limited()).
+		Msg("Connection limit exceeded")
+}
+
+// Listener wraps l, so accepted connections are tracked and too slow
+// ones are closed.
+func (p *Proxy) Listener(l net.Listener) net.Listener {
+	l = trackListener{Listener: l, sessions: p.sessions}
+	if p.Config.Limits.MinRate == 0 {
+		return l
+	}
+	return rateListener{Listener: l, limiter: p.Limiter, logger: p.Logger}
+}
+
+// Sessions returns count of active sessions.
+func (p *Proxy) Sessions() int {
+	return p.Limiter.Active()
+}
+
+// CloseSessions closes all connections accepted by Listener. It's used
+// when sessions outlive drain or shutdown deadline.
+func (p *Proxy) CloseSessions() {
+	p.sessions.closeAll()
+}
+
+// EngagementActive returns false if engagement is killed or out of window.
+func (p *Proxy) EngagementActive() bool {
+	return p.engagement.Active()
+}
+
+// RejectAction returns configured reject action. "none" is replaced with
+// "drop" while engagement isn't active, so requests never reach targets.
+func (p *Proxy) RejectAction() string {
+	a := p.Config.RuleSettings.RejectAction
+	if a == common.RejectActionNone && !p.EngagementActive() {
+		return common.RejectActionDrop
+	}
+	return a
+}
+
 // Return true if entity passed all checks and false if filtered.
 func (p *Proxy) RunFilters(e wrapper.Entity, logger zerolog.Logger) bool {
+	return p.Filter(e, logger).Accepted()
+}
+
+// Return verdict with the rule it was made by.
+func (p *Proxy) Filter(e wrapper.Entity, logger zerolog.Logger) Verdict {
 	ip := e.GetIP().String()
 
+	if state := p.engagement.State(); state != engagement.StateActive {
+		logger.Warn().
+			Str("engagement", state).
+			Msg("Engagement isn't active, rejecting")
+		return Verdict{Action: VerdictReject}
+	}
+
 	if p.isRejectedByThreshold(ip, logger) {
-		return false
+		return Verdict{Action: VerdictReject}
 	}
 
 	mg := p.prepareRules(e, logger)
 
+	v := Verdict{Action: VerdictPass}
 	// TODO: cache filters for equal entities for optimization.
 	for i, f := range p.Config.Filters {
 		mg[i].Lock()
@@ -179,10 +310,11 @@ func (p *Proxy) RunFilters(e wrapper.Entity, logger zerolog.Logger) bool {
 			if err != nil {
 				logger.Error().Err(err).Msg("Can't increase rejects")
 			}
-			return false
+			return Verdict{Action: VerdictReject, Rule: f.Rule}
 		}
 
 		// accept action
+		v = Verdict{Action: VerdictAccept, Rule: f.Rule}
 		break
 	}
 
@@ -192,7 +324,40 @@ func (p *Proxy) RunFilters(e wrapper.Entity, logger zerolog.Logger) bool {
 		logger.Error().Err(err).Msg("Can't increase accepts")
 	}
 
-	return true
+	return v
+}
+
+// Return all response filters fired on entity. Unlike RunFilters it
+// doesn't stop on first match and doesn't affect verdict counters.
+func (p *Proxy) RunResponseFilters(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) []common.ResponseFilter {
+	var fired []common.ResponseFilter
+	for _, f := range p.Config.ResponseFilters {
+		ruleLogger := logger.With().Str("response_rule", f.Rule).Logger()
+		rule, _ := p.rules.Get(f.Rule)
+
+		if err := rule.Prepare(e, ruleLogger); err != nil {
+			ruleLogger.Error().Err(err).Msg("Prepare error, skipping...")
+			continue
+		}
+
+		ruleLogger.Trace().Msg("Applying response rule")
+		ok, err := rule.Apply(e, ruleLogger)
+		if err != nil {
+			ruleLogger.Error().Err(err).Msg("Rule error, skipping...")
+			continue
+		}
+
+		if ok {
+			ruleLogger.Warn().
+				Str("action", f.Action).
+				Msg("Running response action")
+			fired = append(fired, f)
+		}
+	}
+	return fired
 }
 
 // check NoRejectThreshold and RejectThreshold.
@@ -241,5 +406,9 @@ func (p *Proxy) prepareRules(
 
 func (p *Proxy) String() string {
 	return fmt.Sprintf("%s proxy \"%s\" (%s->%s)",
-		p.Config.Type, p.Config.Name, p.Config.ListenAddr, p.Config.TargetAddr)
+		p.Config.Type,
+		p.Config.Name,
+		p.Config.ListenAddr,
+		formatTargets(p.Config),
+	)
 }
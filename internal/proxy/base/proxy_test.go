@@ -0,0 +1,72 @@
+package base
+
+import (
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_RejectAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		cfg    common.Engagement
+		killed bool
+		want   string
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   common.RejectActionNone,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+			want:   common.RejectActionDrop,
+		},
+		{
+			name:   "pending none",
+			action: common.RejectActionNone,
+			cfg:    common.Engagement{Start: time.Now().Add(time.Hour)},
+			want:   common.RejectActionDrop,
+		},
+		{
+			name:   "ended none",
+			action: common.RejectActionNone,
+			cfg:    common.Engagement{End: time.Now().Add(-time.Hour)},
+			want:   common.RejectActionDrop,
+		},
+		{
+			name:   "killed proxy",
+			action: common.RejectActionProxy,
+			killed: true,
+			want:   common.RejectActionProxy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			defer db.DB.Close()
+
+			eng, err := engagement.New(tt.cfg, db)
+			require.NoError(t, err, "can't create engagement")
+			if tt.killed {
+				require.NoError(t, eng.Kill("test"))
+			}
+
+			p := &Proxy{
+				Config: common.ProxyConfig{
+					RuleSettings: common.RuleSettings{RejectAction: tt.action},
+				},
+				engagement: eng,
+			}
+			require.Equal(t, tt.want, p.RejectAction())
+		})
+	}
+}
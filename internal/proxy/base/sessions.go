@@ -0,0 +1,70 @@
+package base
+
+import (
+	"net"
+	"sync"
+)
+
+// sessions tracks accepted connections, so they can be closed when
+// drain or shutdown deadline is exceeded.
+type sessions struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newSessions() *sessions {
+	return &sessions{conns: map[net.Conn]struct{}{}}
+}
+
+func (s *sessions) add(c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[c] = struct{}{}
+}
+
+func (s *sessions) remove(c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+}
+
+func (s *sessions) closeAll() {
+	s.mu.Lock()
+	conns := make([]net.Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+}
+
+// listener tracking accepted connections until they are closed.
+type trackListener struct {
+	net.Listener
+
+	sessions *sessions
+}
+
+func (l trackListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint: wrapcheck // servers check raw error
+	}
+	c := &trackConn{Conn: conn, sessions: l.sessions}
+	l.sessions.add(c)
+	return c, nil
+}
+
+type trackConn struct {
+	net.Conn
+
+	sessions *sessions
+}
+
+func (c *trackConn) Close() error {
+	c.sessions.remove(c)
+	return c.Conn.Close() //nolint: wrapcheck // transparent wrapper
+}
@@ -0,0 +1,44 @@
+package base
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_Sessions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &Proxy{sessions: newSessions()}
+	l = p.Listener(l)
+	defer l.Close()
+
+	clients := make([]net.Conn, 0, 3)
+	servers := make([]net.Conn, 0, 3)
+	for i := 0; i < 3; i++ {
+		c, derr := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, derr)
+		t.Cleanup(func() { c.Close() })
+		clients = append(clients, c)
+
+		s, aerr := l.Accept()
+		require.NoError(t, aerr)
+		servers = append(servers, s)
+	}
+	require.Len(t, p.sessions.conns, 3)
+
+	// closed connections aren't tracked anymore
+	require.NoError(t, servers[0].Close())
+	require.Len(t, p.sessions.conns, 2)
+
+	p.CloseSessions()
+	require.Empty(t, p.sessions.conns)
+	for _, c := range clients[1:] {
+		_ = c.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = c.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+	}
+}
@@ -0,0 +1,323 @@
+package base
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/rs/zerolog"
+	"go.uber.org/atomic"
+)
+
+const (
+	defaultHealthCheckInterval = time.Second * 10
+	defaultHealthCheckTimeout  = time.Second * 3
+)
+
+// TargetParser parses configured target address into proxy specific
+// value and returns probe URL used by health checks. Probe URL scheme
+// must be "http" or "https", host must be in form of host:port. Probe is
+// nil if target can't be health checked (e.g. UDP target).
+type TargetParser[T any] func(addr string) (T, *url.URL, error)
+
+type Target[T any] struct {
+	Value   T
+	Address string
+
+	weight  int
+	current int
+	probe   *url.URL
+	healthy *atomic.Bool
+}
+
+func (t *Target[T]) IsHealthy() bool {
+	return t.healthy.Load()
+}
+
+func (t *Target[T]) String() string {
+	return t.Address
+}
+
+// NewTargets creates pool of targets from "target" or "targets" config
+// fields. Only one of them may be used.
+func NewTargets[T any](
+	cfg common.ProxyConfig,
+	parse TargetParser[T],
+	logger zerolog.Logger,
+) (*Targets[T], error) {
+	tcs := cfg.Targets
+	switch {
+	case cfg.TargetAddr != "" && len(tcs) > 0:
+		return nil, ErrTargetsConflict
+	case cfg.TargetAddr != "":
+		tcs = []common.Target{{Address: cfg.TargetAddr}}
+	case len(tcs) == 0:
+		return nil, ErrNoTargets
+	}
+
+	settings := cfg.TargetSettings
+	switch settings.Balancing {
+	case "":
+		settings.Balancing = common.BalancingRoundRobin
+	case common.BalancingRoundRobin,
+		common.BalancingWeighted,
+		common.BalancingSticky:
+	default:
+		return nil, &UnknownBalancingError{balancing: settings.Balancing}
+	}
+
+	hc := &settings.HealthCheck
+	switch hc.Type {
+	case "":
+		hc.Type = common.HealthCheckNone
+	case common.HealthCheckNone,
+		common.HealthCheckTCP,
+		common.HealthCheckHTTP:
+	default:
+		return nil, &UnknownHealthCheckError{check: hc.Type}
+	}
+	if hc.Interval == 0 {
+		hc.Interval = defaultHealthCheckInterval
+	}
+	if hc.Timeout == 0 {
+		hc.Timeout = defaultHealthCheckTimeout
+	}
+
+	t := &Targets[T]{
+		settings: settings,
+		counter:  atomic.NewUint64(0),
+		logger:   logger,
+		client: &http.Client{
+			Timeout: hc.Timeout,
+			Transport: &http.Transport{
+				//nolint: gosec // selfsigned support
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+			CheckRedirect: func(
+				_ *http.Request,
+				_ []*http.Request,
+			) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		v, probe, err := parse(tc.Address)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse target \"%s\": %w",
+				tc.Address,
+				err,
+			)
+		}
+
+		if probe == nil && hc.Type != common.HealthCheckNone {
+			return nil, &UnsupportedHealthCheckError{
+				check:  hc.Type,
+				target: tc.Address,
+			}
+		}
+
+		weight := int(tc.Weight)
+		if weight == 0 {
+			weight = 1
+		}
+
+		t.targets = append(t.targets, &Target[T]{
+			Value:   v,
+			Address: tc.Address,
+			weight:  weight,
+			probe:   probe,
+			healthy: atomic.NewBool(true),
+		})
+	}
+
+	return t, nil
+}
+
+// Targets is a pool of upstream targets with load balancing and
+// active health checks.
+type Targets[T any] struct {
+	targets  []*Target[T]
+	settings common.TargetSettings
+	counter  *atomic.Uint64
+	client   *http.Client
+	logger   zerolog.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Next returns next healthy target for client ip according to balancing
+// settings. Returns false if there are no healthy targets.
+func (t *Targets[T]) Next(ip netip.Addr) (*Target[T], bool) {
+	if t.settings.Balancing == common.BalancingSticky {
+		return t.nextSticky(ip)
+	}
+
+	healthy := make([]*Target[T], 0, len(t.targets))
+	for _, target := range t.targets {
+		if target.IsHealthy() {
+			healthy = append(healthy, target)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, false
+	}
+
+	if t.settings.Balancing == common.BalancingWeighted {
+		return t.nextWeighted(healthy), true
+	}
+	i := t.counter.Inc() - 1
+	return healthy[int(i%uint64(len(healthy)))], true
+}
+
+// ip hash is mapped over all targets, so clients of healthy targets keep
+// their targets if another one goes down. Clients of unhealthy target
+// are moved to the next healthy one.
+func (t *Targets[T]) nextSticky(ip netip.Addr) (*Target[T], bool) {
+	h := fnv.New32a()
+	b, _ := ip.MarshalBinary()
+	_, _ = h.Write(b)
+	start := int(h.Sum32() % uint32(len(t.targets)))
+	for i := range t.targets {
+		target := t.targets[(start+i)%len(t.targets)]
+		if target.IsHealthy() {
+			return target, true
+		}
+	}
+	return nil, false
+}
+
+// smooth weighted round-robin (same as nginx uses).
+func (t *Targets[T]) nextWeighted(healthy []*Target[T]) *Target[T] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var (
+		best  *Target[T]
+		total int
+	)
+	for _, target := range healthy {
+		target.current += target.weight
+		total += target.weight
+		if best == nil || target.current > best.current {
+			best = target
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// StartHealthChecks runs health checks in background
+// until StopHealthChecks call.
+func (t *Targets[T]) StartHealthChecks() {
+	if t.settings.HealthCheck.Type == common.HealthCheckNone {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.settings.HealthCheck.Interval)
+		defer ticker.Stop()
+		for {
+			t.checkAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (t *Targets[T]) StopHealthChecks() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.wg.Wait()
+	t.client.CloseIdleConnections()
+}
+
+func (t *Targets[T]) checkAll(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	wg.Add(len(t.targets))
+	for _, target := range t.targets {
+		go func(target *Target[T]) {
+			defer wg.Done()
+
+			err := t.check(ctx, target)
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger := t.logger.With().Stringer("target", target).Logger()
+			healthy := err == nil
+			if target.healthy.Swap(healthy) == healthy {
+				return
+			}
+			if healthy {
+				logger.Info().Msg("Target is healthy")
+			} else {
+				logger.Warn().Err(err).Msg("Target is unhealthy")
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+func (t *Targets[T]) check(ctx context.Context, target *Target[T]) error {
+	hc := t.settings.HealthCheck
+	ctx, cancel := context.WithTimeout(ctx, hc.Timeout)
+	defer cancel()
+
+	switch hc.Type {
+	case common.HealthCheckTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", target.probe.Host)
+		if err != nil {
+			return fmt.Errorf("can't connect: %w", err)
+		}
+		conn.Close()
+	case common.HealthCheckHTTP:
+		u := *target.probe
+		u.Path = strings.TrimSuffix(u.Path, "/") + hc.Path
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodGet,
+			u.String(),
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("can't create http request: %w", err)
+		}
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("can't make http request: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return &UnhealthyStatusError{status: resp.StatusCode}
+		}
+	}
+	return nil
+}
+
+func (t *Targets[T]) String() string {
+	return common.FormatStringerSlice(t.targets)
+}
@@ -0,0 +1,339 @@
+package base
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestTarget(addr string) (string, *url.URL, error) {
+	return addr, &url.URL{Scheme: "http", Host: addr}, nil
+}
+
+func parseNoProbeTarget(addr string) (string, *url.URL, error) {
+	return addr, nil, nil
+}
+
+func newTestTargets(
+	t *testing.T,
+	balancing string,
+	targets ...common.Target,
+) *Targets[string] {
+	t.Helper()
+
+	cfg := common.ProxyConfig{
+		Targets:        targets,
+		TargetSettings: common.TargetSettings{Balancing: balancing},
+	}
+	ts, err := NewTargets(cfg, parseTestTarget, log.Logger)
+	require.NoError(t, err)
+	return ts
+}
+
+func testIP(i int) netip.Addr {
+	return netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)})
+}
+
+func TestNewTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     common.ProxyConfig
+		noProbe bool
+		wantErr bool
+	}{
+		{
+			name: "target",
+			cfg:  common.ProxyConfig{TargetAddr: "127.0.0.1:80"},
+		},
+		{
+			name: "targets",
+			cfg: common.ProxyConfig{
+				Targets: []common.Target{
+					{Address: "127.0.0.1:80"},
+					{Address: "127.0.0.1:81", Weight: 2},
+				},
+				TargetSettings: common.TargetSettings{
+					Balancing: common.BalancingWeighted,
+				},
+			},
+		},
+		{
+			name:    "no targets",
+			cfg:     common.ProxyConfig{},
+			wantErr: true,
+		},
+		{
+			name: "target and targets",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				Targets:    []common.Target{{Address: "127.0.0.1:81"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown balancing",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				TargetSettings: common.TargetSettings{
+					Balancing: "random",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown health check",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				TargetSettings: common.TargetSettings{
+					HealthCheck: common.HealthCheck{Type: "icmp"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "no probe without health check",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				TargetSettings: common.TargetSettings{
+					HealthCheck: common.HealthCheck{
+						Type: common.HealthCheckNone,
+					},
+				},
+			},
+			noProbe: true,
+		},
+		{
+			name: "no probe with tcp health check",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				TargetSettings: common.TargetSettings{
+					HealthCheck: common.HealthCheck{
+						Type: common.HealthCheckTCP,
+					},
+				},
+			},
+			noProbe: true,
+			wantErr: true,
+		},
+		{
+			name: "no probe with http health check",
+			cfg: common.ProxyConfig{
+				TargetAddr: "127.0.0.1:80",
+				TargetSettings: common.TargetSettings{
+					HealthCheck: common.HealthCheck{
+						Type: common.HealthCheckHTTP,
+					},
+				},
+			},
+			noProbe: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parse := parseTestTarget
+			if tt.noProbe {
+				parse = parseNoProbeTarget
+			}
+			_, err := NewTargets(tt.cfg, parse, log.Logger)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTargets_RoundRobin(t *testing.T) {
+	ts := newTestTargets(
+		t,
+		"",
+		common.Target{Address: "a"},
+		common.Target{Address: "b"},
+		common.Target{Address: "c"},
+	)
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		target, ok := ts.Next(testIP(0))
+		require.True(t, ok)
+		got = append(got, target.Value)
+	}
+	require.Equal(t, "abcabc", strings.Join(got, ""))
+
+	ts.targets[1].healthy.Store(false)
+	got = nil
+	for i := 0; i < 4; i++ {
+		target, ok := ts.Next(testIP(0))
+		require.True(t, ok)
+		got = append(got, target.Value)
+	}
+	require.NotContains(t, got, "b")
+}
+
+func TestTargets_Weighted(t *testing.T) {
+	ts := newTestTargets(
+		t,
+		common.BalancingWeighted,
+		common.Target{Address: "a", Weight: 3},
+		common.Target{Address: "b"},
+	)
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		target, ok := ts.Next(testIP(0))
+		require.True(t, ok)
+		counts[target.Value]++
+	}
+	require.Equal(t, map[string]int{"a": 6, "b": 2}, counts)
+
+	ts.targets[0].healthy.Store(false)
+	for i := 0; i < 4; i++ {
+		target, ok := ts.Next(testIP(0))
+		require.True(t, ok)
+		require.Equal(t, "b", target.Value)
+	}
+}
+
+func TestTargets_Sticky(t *testing.T) {
+	ts := newTestTargets(
+		t,
+		common.BalancingSticky,
+		common.Target{Address: "a"},
+		common.Target{Address: "b"},
+		common.Target{Address: "c"},
+		common.Target{Address: "d"},
+	)
+
+	const clients = 256
+	before := make([]string, clients)
+	for i := range before {
+		target, ok := ts.Next(testIP(i))
+		require.True(t, ok)
+		before[i] = target.Value
+
+		again, _ := ts.Next(testIP(i))
+		require.Equal(t, target, again, "client must keep its target")
+	}
+
+	// only clients of unhealthy target are remapped
+	ts.targets[1].healthy.Store(false)
+	moved := 0
+	for i := range before {
+		target, ok := ts.Next(testIP(i))
+		require.True(t, ok)
+		if before[i] != "b" {
+			require.Equal(t, before[i], target.Value)
+			continue
+		}
+		require.NotEqual(t, "b", target.Value)
+		moved++
+	}
+	require.NotZero(t, moved)
+
+	// clients return after target is healthy again
+	ts.targets[1].healthy.Store(true)
+	for i := range before {
+		target, _ := ts.Next(testIP(i))
+		require.Equal(t, before[i], target.Value)
+	}
+
+	for _, target := range ts.targets {
+		target.healthy.Store(false)
+	}
+	_, ok := ts.Next(testIP(0))
+	require.False(t, ok)
+}
+
+func TestTargets_HealthCheck(t *testing.T) {
+	var status int
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(status)
+		},
+	))
+	defer s.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	up := strings.TrimPrefix(s.URL, "http://")
+	tests := []struct {
+		name   string
+		check  common.HealthCheck
+		status int
+		want   map[string]bool
+	}{
+		{
+			name:  "tcp",
+			check: common.HealthCheck{Type: common.HealthCheckTCP},
+			want:  map[string]bool{up: true, closedAddr: false},
+		},
+		{
+			name: "http",
+			check: common.HealthCheck{
+				Type: common.HealthCheckHTTP,
+				Path: "/health",
+			},
+			status: http.StatusOK,
+			want:   map[string]bool{up: true, closedAddr: false},
+		},
+		{
+			name: "http server error",
+			check: common.HealthCheck{
+				Type: common.HealthCheckHTTP,
+				Path: "/health",
+			},
+			status: http.StatusServiceUnavailable,
+			want:   map[string]bool{up: false, closedAddr: false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status = tt.status
+			cfg := common.ProxyConfig{
+				Targets: []common.Target{
+					{Address: up},
+					{Address: closedAddr},
+				},
+				TargetSettings: common.TargetSettings{
+					HealthCheck: tt.check,
+				},
+			}
+			cfg.TargetSettings.HealthCheck.Timeout = time.Second
+			ts, err := NewTargets(cfg, parseTestTarget, log.Logger)
+			require.NoError(t, err)
+			defer ts.StopHealthChecks()
+
+			ts.checkAll(context.Background())
+			for _, target := range ts.targets {
+				require.Equal(
+					t,
+					tt.want[target.Address],
+					target.IsHealthy(),
+					target.Address,
+				)
+			}
+
+			_, ok := ts.Next(testIP(0))
+			require.Equal(t, tt.want[up], ok)
+		})
+	}
+}
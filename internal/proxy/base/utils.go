@@ -44,3 +44,22 @@ func IsConnectionClosed(err error) bool {
 func NetAddrToNetipAddrPort(a net.Addr) netip.AddrPort {
 	return netip.MustParseAddrPort(a.String())
 }
+
+func formatTargets(cfg common.ProxyConfig) string {
+	var addrs []string
+	switch {
+	case len(cfg.Targets) > 0:
+		for _, t := range cfg.Targets {
+			addrs = append(addrs, t.Address)
+		}
+	case len(cfg.Mux.Routes) > 0:
+		for _, r := range cfg.Mux.Routes {
+			if !slices.Contains(addrs, r.Proxy) {
+				addrs = append(addrs, r.Proxy)
+			}
+		}
+	default:
+		return cfg.TargetAddr
+	}
+	return common.FormatStringSlice(addrs)
+}
@@ -0,0 +1,20 @@
+package base
+
+const (
+	// VerdictAccept means accept filter fired.
+	VerdictAccept = "accept"
+	// VerdictPass means no filter fired.
+	VerdictPass = "pass"
+	// VerdictReject means reject filter fired or reject threshold reached.
+	VerdictReject = "reject"
+)
+
+type Verdict struct {
+	Action string
+	// Rule is a name of the fired rule, empty if no rule fired.
+	Rule string
+}
+
+func (v Verdict) Accepted() bool {
+	return v.Action != VerdictReject
+}
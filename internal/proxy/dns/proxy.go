@@ -2,11 +2,14 @@ package dns
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/netip"
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/proxy/base"
 	"github.com/D00Movenok/BounceBack/internal/rules"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
@@ -31,15 +34,16 @@ func NewProxy(
 	cfg common.ProxyConfig,
 	rs *rules.RuleSet,
 	db *database.DB,
+	eng *engagement.Engagement,
 ) (*Proxy, error) {
-	baseProxy, err := base.NewBaseProxy(cfg, rs, db, AllowedActions)
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
 	if err != nil {
 		return nil, fmt.Errorf("can't create base proxy: %w", err)
 	}
 
-	target, err := netip.ParseAddrPort(cfg.TargetAddr)
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse AddrPort: %w", err)
+		return nil, fmt.Errorf("can't create targets: %w", err)
 	}
 
 	var action netip.AddrPort
@@ -53,7 +57,7 @@ func NewProxy(
 
 	p := &Proxy{
 		Proxy:     baseProxy,
-		TargetURL: target,
+		Targets:   targets,
 		ActionURL: action,
 
 		servertcp: &dns.Server{
@@ -88,7 +92,7 @@ func NewProxy(
 type Proxy struct {
 	*base.Proxy
 
-	TargetURL netip.AddrPort
+	Targets   *base.Targets[netip.AddrPort]
 	ActionURL netip.AddrPort
 
 	// NOTE: servertcp is a tcp or tcp-tls server,
@@ -100,11 +104,34 @@ type Proxy struct {
 }
 
 func (p *Proxy) Start() error {
-	p.WG.Add(1)
-	go p.servetcp()
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening tcp: %w", err)
+	}
+	l = p.Listener(l)
+	if p.TLSConfig != nil {
+		l = tls.NewListener(l, p.TLSConfig)
+	}
+	p.servertcp.Listener = l
+
+	if p.TLSConfig == nil {
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", p.Config.ListenAddr)
+		if err != nil {
+			l.Close()
+			return fmt.Errorf("can't start listening udp: %w", err)
+		}
+		p.serverudp.PacketConn = pc
+	}
+
+	p.Targets.StartHealthChecks()
+	if err = p.activate(p.servertcp); err != nil {
+		return err
+	}
 	if p.TLSConfig == nil {
-		p.WG.Add(1)
-		go p.serveudp()
+		if err = p.activate(p.serverudp); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -119,6 +146,7 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 			return fmt.Errorf("can't shutdown udp server: %w", err)
 		}
 	}
+	p.Targets.StopHealthChecks()
 
 	done := make(chan any, 1)
 	go func() {
@@ -156,19 +184,42 @@ func (p *Proxy) proxyRequest(
 func (p *Proxy) processVerdict(
 	w dns.ResponseWriter,
 	r *dns.Msg,
+	from netip.Addr,
 	logger zerolog.Logger,
 ) {
-	switch p.Config.RuleSettings.RejectAction {
+	switch p.RejectAction() {
 	case common.RejectActionProxy:
 		p.proxyRequest(p.ActionURL, w, r, logger)
 	case common.RejectActionDrop:
 		// do nothing (no proxy request)
 	default:
 		logger.Warn().Msg("Request was filtered, but action is none")
-		p.proxyRequest(p.TargetURL, w, r, logger)
+		p.proxyToTarget(w, r, from, logger)
 	}
 }
 
+// proxy request to the next healthy target or fallback to reject action.
+func (p *Proxy) proxyToTarget(
+	w dns.ResponseWriter,
+	r *dns.Msg,
+	from netip.Addr,
+	logger zerolog.Logger,
+) {
+	t, ok := p.Targets.Next(from)
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		if p.Config.RuleSettings.RejectAction != common.RejectActionNone {
+			p.processVerdict(w, r, from, logger)
+		}
+		return
+	}
+
+	p.proxyRequest(t.Value, w, r, logger.With().
+		Stringer("target", t).
+		Logger(),
+	)
+}
+
 func (p *Proxy) getHandler(t string) dns.HandlerFunc {
 	return func(w dns.ResponseWriter, r *dns.Msg) {
 		defer w.Close()
@@ -181,31 +232,56 @@ func (p *Proxy) getHandler(t string) dns.HandlerFunc {
 
 		logRequest(r, logger)
 
+		// request over the limits is dropped even if action is "none"
+		release, ok := p.Acquire(from, logger)
+		defer release()
+		if !ok {
+			if p.RejectAction() != common.RejectActionNone {
+				p.processVerdict(w, r, from, logger)
+			}
+			return
+		}
+
 		e := &wrapper.DNSRequest{
 			Request: r,
 			From:    from,
 		}
 		if !p.RunFilters(e, logger) {
-			p.processVerdict(w, r, logger)
+			p.processVerdict(w, r, from, logger)
 			return
 		}
 
-		p.proxyRequest(p.TargetURL, w, r, logger)
+		p.proxyToTarget(w, r, from, logger)
 	}
 }
 
-func (p *Proxy) servetcp() {
-	defer p.WG.Done()
-	err := p.servertcp.ListenAndServe()
-	if err != nil {
-		p.Logger.Fatal().Err(err).Msg("Unexpected tcp server error")
+// start serving and wait until server is started. Errors after start
+// are reported with Fail.
+func (p *Proxy) activate(s *dns.Server) error {
+	started := make(chan struct{})
+	s.NotifyStartedFunc = func() {
+		close(started)
 	}
-}
 
-func (p *Proxy) serveudp() {
-	defer p.WG.Done()
-	err := p.serverudp.ListenAndServe()
-	if err != nil {
-		p.Logger.Fatal().Err(err).Msg("Unexpected udp server error")
+	errs := make(chan error, 1)
+	p.WG.Add(1)
+	go func() {
+		defer p.WG.Done()
+		err := s.ActivateAndServe()
+		select {
+		case <-started:
+			if err != nil && !p.Closing {
+				p.Fail(fmt.Errorf("can't serve %s: %w", s.Net, err))
+			}
+		default:
+			errs <- err
+		}
+	}()
+
+	select {
+	case <-started:
+		return nil
+	case err := <-errs:
+		return fmt.Errorf("can't start %s server: %w", s.Net, err)
 	}
 }
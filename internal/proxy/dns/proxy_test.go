@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// start target which counts queries and answers them with A record.
+func newTarget(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen target")
+
+	received := atomic.NewInt64(0)
+	s := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			received.Inc()
+			m := new(dns.Msg)
+			m.SetReply(r)
+			rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 10.0.0.1")
+			m.Answer = append(m.Answer, rr)
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = s.ActivateAndServe() }()
+	t.Cleanup(func() { _ = s.Shutdown() })
+	return pc.LocalAddr().String(), received
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	killed bool,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.Timeout = time.Second
+	rs := &rules.RuleSet{Rules: map[string]rules.Rule{}}
+	p, err := NewProxy(cfg, rs, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	require.NoError(t, p.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+func TestProxy_EngagementKilled(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		killed bool
+		want   bool
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   true,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+			want:   false,
+		},
+		{
+			name:   "killed drop",
+			action: common.RejectActionDrop,
+			killed: true,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, received := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+			}, tt.killed)
+
+			c := &dns.Client{Net: "tcp", Timeout: time.Second / 2}
+			m := new(dns.Msg)
+			m.SetQuestion("example.test.", dns.TypeA)
+			r, _, err := c.Exchange(m, p.servertcp.Listener.Addr().String())
+			if tt.want {
+				require.NoError(t, err)
+				require.Len(t, r.Answer, 1)
+			} else {
+				require.Error(t, err)
+			}
+			require.Equal(t, tt.want, received.Load() != 0)
+		})
+	}
+}
@@ -1,10 +1,26 @@
 package dns
 
 import (
+	"fmt"
+	"net/netip"
+	"net/url"
+
 	"github.com/miekg/dns"
 	"github.com/rs/zerolog"
 )
 
+// targets are queried over UDP, so they can't be health checked.
+func parseTarget(addr string) (netip.AddrPort, *url.URL, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf(
+			"can't parse AddrPort: %w",
+			err,
+		)
+	}
+	return ap, nil, nil
+}
+
 func logRequest(r *dns.Msg, logger zerolog.Logger) {
 	arr := zerolog.Arr()
 	for _, q := range r.Question {
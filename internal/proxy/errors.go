@@ -9,3 +9,58 @@ type InvalidProxyTypeError struct {
 func (e InvalidProxyTypeError) Error() string {
 	return fmt.Sprintf("invalid proxy type: %s", e.t)
 }
+
+type NoListenAddrError struct {
+	proxy string
+}
+
+func (e NoListenAddrError) Error() string {
+	return fmt.Sprintf(
+		"proxy \"%s\" has no listen address and isn't used by mux",
+		e.proxy,
+	)
+}
+
+type MuxChildListenError struct {
+	proxy string
+}
+
+func (e MuxChildListenError) Error() string {
+	return fmt.Sprintf(
+		"proxy \"%s\" is used by mux and must not have listen address",
+		e.proxy,
+	)
+}
+
+type MuxChildSharedError struct {
+	proxy string
+}
+
+func (e MuxChildSharedError) Error() string {
+	return fmt.Sprintf("proxy \"%s\" is already used by another mux", e.proxy)
+}
+
+type MuxChildTypeError struct {
+	proxy string
+}
+
+func (e MuxChildTypeError) Error() string {
+	return fmt.Sprintf("proxy \"%s\" can't be used by mux", e.proxy)
+}
+
+type UnknownProxyError struct {
+	proxy string
+}
+
+func (e UnknownProxyError) Error() string {
+	return fmt.Sprintf("unknown proxy \"%s\"", e.proxy)
+}
+
+type DrainStateError struct {
+	proxy string
+	state string
+}
+
+func (e DrainStateError) Error() string {
+	return fmt.Sprintf("proxy \"%s\" can't be drained: %s", e.proxy, e.state)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+)
+
+const requestIDSize = 16
+
+type enrichmentKey struct{}
+
+// suffixes of enrichment header names.
+func getEnrichmentHeaders() map[string]string {
+	return map[string]string{
+		common.EnrichmentVerdict:   "Verdict",
+		common.EnrichmentRule:      "Rule",
+		common.EnrichmentCountry:   "Country",
+		common.EnrichmentASN:       "Asn",
+		common.EnrichmentPTR:       "Ptr",
+		common.EnrichmentTLS:       "Ja3",
+		common.EnrichmentRequestID: "Request-Id",
+	}
+}
+
+// strip inbound headers with enrichment prefix, so clients can't forge
+// them.
+func (p *Proxy) stripEnrichment(r *http.Request, logger zerolog.Logger) {
+	prefix := strings.ToLower(p.Config.Enrichment.Prefix)
+	if prefix == "" {
+		return
+	}
+	for k := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), prefix) {
+			logger.Warn().Str("header", k).Msg("Stripped enrichment header")
+			r.Header.Del(k)
+		}
+	}
+}
+
+// enrich saves enrichment headers into request context. They are added
+// only to requests to targets, but not to reject action ones.
+func (p *Proxy) enrich(
+	r *http.Request,
+	e wrapper.Entity,
+	v base.Verdict,
+	logger zerolog.Logger,
+) (*http.Request, zerolog.Logger) {
+	fields := p.EnrichmentFields()
+	if fields == nil {
+		return r, logger
+	}
+
+	values := p.Enrich(e.GetIP(), v)
+	for _, f := range fields {
+		switch f {
+		case common.EnrichmentTLS:
+			if hello := getClientHello(r.Context()); hello != nil {
+				values[f] = hello.JA3Hash()
+			}
+		case common.EnrichmentRequestID:
+			id, err := newRequestID()
+			if err != nil {
+				logger.Error().Err(err).Msg("Can't create request id")
+				continue
+			}
+			values[f] = id
+			logger = logger.With().Str("request_id", id).Logger()
+		}
+	}
+
+	names := getEnrichmentHeaders()
+	h := http.Header{}
+	for f, v := range values {
+		if v != "" {
+			h.Set(p.Config.Enrichment.Prefix+names[f], v)
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), enrichmentKey{}, h)
+	return r.WithContext(ctx), logger
+}
+
+func getEnrichment(ctx context.Context) http.Header {
+	h, _ := ctx.Value(enrichmentKey{}).(http.Header)
+	return h
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, requestIDSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err //nolint: wrapcheck // crypto/rand error is clear
+	}
+	return hex.EncodeToString(b), nil
+}
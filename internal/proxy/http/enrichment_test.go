@@ -0,0 +1,153 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+const testEnrichmentPrefix = "X-Bb-"
+
+// scanRule fires if request has X-Scan header and records if enrichment
+// headers were visible for rules.
+type scanRule struct {
+	forged *atomic.Bool
+}
+
+func (r scanRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r scanRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	h, err := e.GetHeaders()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	if len(getPrefixed(h)) != 0 {
+		r.forged.Store(true)
+	}
+	_, ok := h["X-Scan"]
+	return ok, nil
+}
+
+func (r scanRule) String() string {
+	return "scan"
+}
+
+// return headers with enrichment prefix.
+func getPrefixed(h http.Header) http.Header {
+	prefixed := http.Header{}
+	for k, v := range h {
+		if strings.HasPrefix(k, testEnrichmentPrefix) {
+			prefixed[k] = v
+		}
+	}
+	return prefixed
+}
+
+func TestProxy_Enrichment(t *testing.T) {
+	decoyHeaders := make(chan http.Header, 1)
+	decoy, _ := newTarget(t, func(_ http.ResponseWriter, r *http.Request) {
+		decoyHeaders <- r.Header.Clone()
+	})
+
+	tests := []struct {
+		name        string
+		action      string
+		header      http.Header
+		wantVerdict string
+		wantRule    string
+		wantDecoy   bool
+	}{
+		{
+			name:   "passed",
+			action: common.FilterActionReject,
+			header: http.Header{
+				"X-Bb-Verdict": {base.VerdictAccept},
+				"X-Bb-Rule":    {"allowlist"},
+			},
+			wantVerdict: base.VerdictPass,
+		},
+		{
+			name:   "accepted",
+			action: common.FilterActionAccept,
+			header: http.Header{
+				"X-Scan":    {"1"},
+				"x-bb-rule": {"allowlist"},
+			},
+			wantVerdict: base.VerdictAccept,
+			wantRule:    "scan",
+		},
+		{
+			// enrichment doesn't leak to reject action requests
+			name:   "rejected",
+			action: common.FilterActionReject,
+			header: http.Header{
+				"X-Scan":       {"1"},
+				"X-Bb-Verdict": {base.VerdictAccept},
+			},
+			wantDecoy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetHeaders := make(chan http.Header, 1)
+			target, _ := newTarget(
+				t,
+				func(_ http.ResponseWriter, r *http.Request) {
+					targetHeaders <- r.Header.Clone()
+				},
+			)
+			forged := atomic.NewBool(false)
+			_, addr := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionProxy,
+					RejectURL:    decoy,
+				},
+				Filters: []common.Filter{
+					{Rule: "scan", Action: tt.action},
+				},
+				Enrichment: common.Enrichment{Prefix: testEnrichmentPrefix},
+			}, map[string]rules.Rule{
+				"scan": scanRule{forged: forged},
+			}, false)
+
+			//nolint: noctx // test
+			req, err := http.NewRequest(http.MethodGet, addr, nil)
+			require.NoError(t, err)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			// inbound enrichment headers are stripped before rules
+			require.False(t, forged.Load())
+			if tt.wantDecoy {
+				require.Empty(t, getPrefixed(<-decoyHeaders))
+				require.Empty(t, targetHeaders)
+				return
+			}
+
+			h := getPrefixed(<-targetHeaders)
+			require.Equal(t, []string{tt.wantVerdict}, h["X-Bb-Verdict"])
+			if tt.wantRule == "" {
+				require.NotContains(t, h, "X-Bb-Rule")
+			} else {
+				require.Equal(t, []string{tt.wantRule}, h["X-Bb-Rule"])
+			}
+			require.Len(t, h.Get("X-Bb-Request-Id"), 2*requestIDSize)
+			require.Empty(t, decoyHeaders)
+		})
+	}
+}
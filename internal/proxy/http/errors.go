@@ -0,0 +1,31 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrDecoyWithoutRejectAction = errors.New(
+		"\"decoy\" response action requires reject action",
+	)
+	ErrEmptyStripHeader = errors.New(
+		"\"strip_header\" response action requires header",
+	)
+)
+
+type UnknownPersonaError struct {
+	persona string
+}
+
+func (e UnknownPersonaError) Error() string {
+	return fmt.Sprintf("unknown persona: %s", e.persona)
+}
+
+type InvalidRedirectStatusError struct {
+	status int
+}
+
+func (e InvalidRedirectStatusError) Error() string {
+	return fmt.Sprintf("invalid redirect status: %d", e.status)
+}
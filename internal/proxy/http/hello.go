@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync/atomic"
+
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+)
+
+type helloConnKey struct{}
+
+// helloListener wraps accepted connections with helloConn.
+type helloListener struct {
+	net.Listener
+}
+
+func (l helloListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint: wrapcheck // http.Server checks raw error
+	}
+	return &helloConn{Conn: conn}, nil
+}
+
+// helloConn records TLS ClientHello while it's read by TLS server.
+type helloConn struct {
+	net.Conn
+
+	buf   []byte
+	done  bool
+	hello atomic.Pointer[tlshello.ClientHello]
+}
+
+func (c *helloConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if !c.done && n > 0 {
+		c.buf = append(c.buf, b[:n]...)
+		hello, perr := tlshello.Parse(c.buf)
+		if !errors.Is(perr, tlshello.ErrIncomplete) {
+			c.done = true
+			c.buf = nil
+			if perr == nil {
+				c.hello.Store(hello)
+			}
+		}
+	}
+	return n, err //nolint: wrapcheck // transparent wrapper
+}
+
+// Hello returns recorded ClientHello or nil.
+func (c *helloConn) Hello() *tlshello.ClientHello {
+	return c.hello.Load()
+}
+
+// save connection wrappers into connection context, so handler can
+// access them.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	for {
+		switch v := c.(type) {
+		case *tls.Conn:
+			c = v.NetConn()
+		case *helloConn:
+			ctx = context.WithValue(ctx, helloConnKey{}, v)
+			c = v.Conn
+		case *limitConn:
+			return context.WithValue(ctx, limitConnKey{}, v)
+		default:
+			return ctx
+		}
+	}
+}
+
+// getClientHello returns ClientHello of request's TLS connection or nil.
+func getClientHello(ctx context.Context) *tlshello.ClientHello {
+	hc, ok := ctx.Value(helloConnKey{}).(*helloConn)
+	if !ok {
+		return nil
+	}
+	return hc.Hello()
+}
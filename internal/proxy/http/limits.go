@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"net"
+
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+)
+
+type limitConnKey struct{}
+
+// limitListener reserves connection limits slot for accepted connections.
+// Connections over the limits are accepted too, so reject action is
+// applied to their requests.
+type limitListener struct {
+	net.Listener
+
+	limiter *base.Limiter
+}
+
+func (l limitListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err //nolint: wrapcheck // http.Server checks raw error
+	}
+	from := base.NetAddrToNetipAddrPort(conn.RemoteAddr()).Addr().Unmap()
+	release, err := l.limiter.Acquire(from)
+	return &limitConn{Conn: conn, release: release, err: err}, nil
+}
+
+// limitConn frees limits slot on close.
+type limitConn struct {
+	net.Conn
+
+	release func()
+	err     error
+}
+
+func (c *limitConn) Close() error {
+	c.release()
+	return c.Conn.Close() //nolint: wrapcheck // transparent wrapper
+}
+
+// getLimitError returns error if request's connection is over the limits.
+func getLimitError(ctx context.Context) error {
+	lc, ok := ctx.Value(limitConnKey{}).(*limitConn)
+	if !ok {
+		return nil
+	}
+	return lc.err
+}
@@ -0,0 +1,324 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	PersonaNginx  = "nginx"
+	PersonaApache = "apache"
+	PersonaIIS    = "iis"
+)
+
+// Persona describes how synthesized responses (errors and redirects)
+// look like and how upstream responses are normalized.
+type Persona struct {
+	Name    string
+	Server  string
+	Headers map[string]string
+	// header names with persona's casing in order they are sent in
+	// synthesized responses.
+	HeaderOrder    []string
+	StripHeaders   []string
+	SetHeaders     map[string]string
+	RedirectStatus int
+	ContentType    string
+	ErrorPage      func(status int) string
+	RedirectPage   func(status int, location string) string
+}
+
+// upstream headers that leak teamserver or framework information.
+func defaultStripHeaders() []string {
+	return []string{
+		"Server",
+		"X-Powered-By",
+		"X-AspNet-Version",
+		"X-AspNetMvc-Version",
+		"X-Generator",
+		"X-Runtime",
+		"Via",
+	}
+}
+
+func GetPersonas() map[string]*Persona {
+	return map[string]*Persona{
+		PersonaNginx: {
+			Name:   PersonaNginx,
+			Server: "nginx",
+			HeaderOrder: []string{
+				"Server",
+				"Date",
+				"Content-Type",
+				"Content-Length",
+				"Connection",
+				"Location",
+			},
+			RedirectStatus: http.StatusMovedPermanently,
+			ContentType:    "text/html",
+			ErrorPage: func(status int) string {
+				return nginxPage(status)
+			},
+			RedirectPage: func(status int, _ string) string {
+				return nginxPage(status)
+			},
+		},
+		PersonaApache: {
+			Name:   PersonaApache,
+			Server: "Apache",
+			HeaderOrder: []string{
+				"Date",
+				"Server",
+				"Location",
+				"Content-Length",
+				"Connection",
+				"Content-Type",
+			},
+			RedirectStatus: http.StatusFound,
+			ContentType:    "text/html; charset=iso-8859-1",
+			ErrorPage:      apacheErrorPage,
+			RedirectPage:   apacheRedirectPage,
+		},
+		PersonaIIS: {
+			Name:   PersonaIIS,
+			Server: "Microsoft-IIS/10.0",
+			Headers: map[string]string{
+				"X-Powered-By": "ASP.NET",
+			},
+			HeaderOrder: []string{
+				"Content-Type",
+				"Location",
+				"Server",
+				"X-Powered-By",
+				"Date",
+				"Connection",
+				"Content-Length",
+			},
+			RedirectStatus: http.StatusFound,
+			ContentType:    "text/html; charset=UTF-8",
+			ErrorPage:      iisErrorPage,
+			RedirectPage:   iisRedirectPage,
+		},
+	}
+}
+
+// newPersona creates persona from config. Returns nil persona if
+// nothing is configured, so Go's default responses will be used.
+func newPersona(cfg common.Persona) (*Persona, error) {
+	var p Persona
+	if cfg.Name != "" {
+		builtin, ok := GetPersonas()[strings.ToLower(cfg.Name)]
+		if !ok {
+			return nil, &UnknownPersonaError{persona: cfg.Name}
+		}
+		p = *builtin
+		p.StripHeaders = defaultStripHeaders()
+	} else if len(cfg.StripHeaders) == 0 && len(cfg.SetHeaders) == 0 {
+		return nil, nil //nolint: nilnil // persona is not configured
+	}
+
+	p.StripHeaders = append(p.StripHeaders, cfg.StripHeaders...)
+	p.SetHeaders = cfg.SetHeaders
+	if cfg.RedirectStatus != 0 {
+		if cfg.RedirectStatus < 300 || cfg.RedirectStatus > 399 {
+			return nil, &InvalidRedirectStatusError{status: cfg.RedirectStatus}
+		}
+		p.RedirectStatus = cfg.RedirectStatus
+	}
+	if p.RedirectStatus == 0 {
+		p.RedirectStatus = http.StatusMovedPermanently
+	}
+
+	return &p, nil
+}
+
+// NormalizeHeaders strips and rewrites upstream response headers.
+func (p *Persona) NormalizeHeaders(h http.Header) {
+	for _, name := range p.StripHeaders {
+		h.Del(name)
+	}
+	if p.Server != "" {
+		h.Set("Server", p.Server)
+	}
+	for k, v := range p.Headers {
+		h.Set(k, v)
+	}
+	p.setHeaders(h)
+}
+
+// configured headers are set as is to keep their casing.
+func (p *Persona) setHeaders(h http.Header) {
+	for k, v := range p.SetHeaders {
+		h.Del(k)
+		h[k] = []string{v}
+	}
+}
+
+// BuildResponse returns synthesized response headers and body.
+func (p *Persona) BuildResponse(
+	status int,
+	location string,
+) (http.Header, []byte) {
+	var body string
+	if location != "" && p.RedirectPage != nil {
+		body = p.RedirectPage(status, location)
+	} else if p.ErrorPage != nil {
+		body = p.ErrorPage(status)
+	}
+
+	h := http.Header{}
+	if p.Server != "" {
+		h.Set("Server", p.Server)
+	}
+	for k, v := range p.Headers {
+		h.Set(k, v)
+	}
+	h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if p.ContentType != "" {
+		h.Set("Content-Type", p.ContentType)
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("Connection", "close")
+	if location != "" {
+		h.Set("Location", location)
+	}
+	p.setHeaders(h)
+
+	return h, []byte(body)
+}
+
+// WriteRaw serializes HTTP/1.x response keeping persona's
+// header order and casing.
+func (p *Persona) WriteRaw(
+	status int,
+	h http.Header,
+	body []byte,
+	withBody bool,
+) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+
+	written := map[string]bool{}
+	for _, name := range p.HeaderOrder {
+		key := http.CanonicalHeaderKey(name)
+		for _, v := range h[key] {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, v)
+		}
+		written[key] = true
+	}
+	keys := maps.Keys(h)
+	slices.Sort(keys)
+	for _, k := range keys {
+		if written[k] {
+			continue
+		}
+		for _, v := range h[k] {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	b.WriteString("\r\n")
+
+	if withBody {
+		b.Write(body)
+	}
+	return b.Bytes()
+}
+
+func statusLine(status int) string {
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
+
+func nginxPage(status int) string {
+	s := statusLine(status)
+	return "<html>\r\n" +
+		"<head><title>" + s + "</title></head>\r\n" +
+		"<body>\r\n" +
+		"<center><h1>" + s + "</h1></center>\r\n" +
+		"<hr><center>nginx</center>\r\n" +
+		"</body>\r\n" +
+		"</html>\r\n"
+}
+
+func apacheErrorPage(status int) string {
+	var msg string
+	switch status {
+	case http.StatusNotFound:
+		msg = "The requested URL was not found on this server."
+	case http.StatusForbidden:
+		msg = "You don't have permission to access this resource."
+	case http.StatusBadGateway:
+		msg = "The proxy server received an invalid\n" +
+			"response from an upstream server."
+	default:
+		msg = "The server encountered an internal error or\n" +
+			"misconfiguration and was unable to complete\n" +
+			"your request."
+	}
+	return apachePage(status, "<p>"+msg+"</p>\n")
+}
+
+func apacheRedirectPage(status int, location string) string {
+	return apachePage(
+		status,
+		"<p>The document has moved <a href=\""+
+			html.EscapeString(location)+
+			"\">here</a>.</p>\n",
+	)
+}
+
+func apachePage(status int, content string) string {
+	return "<!DOCTYPE HTML PUBLIC \"-//IETF//DTD HTML 2.0//EN\">\n" +
+		"<html><head>\n" +
+		"<title>" + statusLine(status) + "</title>\n" +
+		"</head><body>\n" +
+		"<h1>" + http.StatusText(status) + "</h1>\n" +
+		content +
+		"</body></html>\n"
+}
+
+func iisErrorPage(status int) string {
+	var title string
+	switch status {
+	case http.StatusNotFound:
+		title = "404 - File or directory not found."
+	case http.StatusForbidden:
+		title = "403 - Forbidden: Access is denied."
+	case http.StatusBadGateway:
+		title = "502 - Web server received an invalid response " +
+			"while acting as a gateway or proxy server."
+	default:
+		title = "500 - Internal server error."
+	}
+	return "<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Strict//EN\" " +
+		"\"http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd\">\r\n" +
+		"<html xmlns=\"http://www.w3.org/1999/xhtml\">\r\n" +
+		"<head>\r\n" +
+		"<meta http-equiv=\"Content-Type\" " +
+		"content=\"text/html; charset=iso-8859-1\"/>\r\n" +
+		"<title>" + statusLine(status) + "</title>\r\n" +
+		"</head>\r\n" +
+		"<body>\r\n" +
+		"<div id=\"header\"><h1>Server Error</h1></div>\r\n" +
+		"<div id=\"content\">\r\n" +
+		" <div class=\"content-container\"><fieldset>\r\n" +
+		"  <h2>" + title + "</h2>\r\n" +
+		" </fieldset></div>\r\n" +
+		"</div>\r\n" +
+		"</body>\r\n" +
+		"</html>\r\n"
+}
+
+func iisRedirectPage(_ int, location string) string {
+	return "<head><title>Document Moved</title></head>\n" +
+		"<body><h1>Object Moved</h1>This document may be found " +
+		"<a HREF=\"" + html.EscapeString(location) + "\">here</a></body>"
+}
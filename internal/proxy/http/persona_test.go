@@ -0,0 +1,246 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+// send raw request and return response header lines and body.
+func rawRequest(t *testing.T, url, method string) ([]string, string) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte(method + " / HTTP/1.1\r\nHost: test\r\n\r\n"))
+	require.NoError(t, err)
+
+	var lines []string
+	br := bufio.NewReader(conn)
+	for {
+		line, rerr := br.ReadString('\n')
+		require.NoError(t, rerr)
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	body, err := io.ReadAll(br)
+	require.NoError(t, err)
+	return lines, string(body)
+}
+
+// return header names in order they were sent.
+func headerNames(lines []string) []string {
+	names := make([]string, 0, len(lines))
+	for _, line := range lines[1:] {
+		name, _, _ := strings.Cut(line, ":")
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestNewPersona(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        common.Persona
+		wantNil    bool
+		wantErr    error
+		wantStatus int
+		wantStrip  []string
+	}{
+		{
+			name:    "empty",
+			wantNil: true,
+		},
+		{
+			name:    "unknown",
+			cfg:     common.Persona{Name: "lighttpd"},
+			wantErr: &UnknownPersonaError{persona: "lighttpd"},
+		},
+		{
+			name:       "builtin",
+			cfg:        common.Persona{Name: "NGINX"},
+			wantStatus: http.StatusMovedPermanently,
+			wantStrip:  defaultStripHeaders(),
+		},
+		{
+			name: "builtin with options",
+			cfg: common.Persona{
+				Name:           PersonaApache,
+				StripHeaders:   []string{"X-Backend"},
+				RedirectStatus: http.StatusTemporaryRedirect,
+			},
+			wantStatus: http.StatusTemporaryRedirect,
+			wantStrip:  append(defaultStripHeaders(), "X-Backend"),
+		},
+		{
+			name: "unnamed",
+			cfg: common.Persona{
+				SetHeaders: map[string]string{"x-custom": "1"},
+			},
+			wantStatus: http.StatusMovedPermanently,
+		},
+		{
+			name: "invalid redirect status",
+			cfg: common.Persona{
+				Name:           PersonaIIS,
+				RedirectStatus: http.StatusOK,
+			},
+			wantErr: &InvalidRedirectStatusError{status: http.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPersona(tt.cfg)
+			if tt.wantErr != nil {
+				require.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNil {
+				require.Nil(t, p)
+				return
+			}
+			require.NotNil(t, p)
+			require.Equal(t, tt.wantStatus, p.RedirectStatus)
+			require.Equal(t, tt.wantStrip, p.StripHeaders)
+		})
+	}
+}
+
+func TestPersona_NormalizeHeaders(t *testing.T) {
+	p, err := newPersona(common.Persona{
+		Name:       PersonaIIS,
+		SetHeaders: map[string]string{"x-Custom": "value"},
+	})
+	require.NoError(t, err)
+
+	h := http.Header{}
+	h.Set("Server", "teamserver")
+	h.Set("Via", "1.1 backend")
+	h.Set("X-Custom", "old")
+	h.Set("Content-Type", "text/plain")
+	p.NormalizeHeaders(h)
+
+	require.Equal(t, http.Header{
+		"Server":       {"Microsoft-IIS/10.0"},
+		"X-Powered-By": {"ASP.NET"},
+		"x-Custom":     {"value"},
+		"Content-Type": {"text/plain"},
+	}, h)
+}
+
+func TestPersona_WriteRaw(t *testing.T) {
+	p, err := newPersona(common.Persona{
+		Name:       PersonaApache,
+		SetHeaders: map[string]string{"x-trace": "1"},
+	})
+	require.NoError(t, err)
+
+	h, body := p.BuildResponse(http.StatusFound, "/new")
+	raw := string(p.WriteRaw(http.StatusFound, h, body, false))
+	head, rest, ok := strings.Cut(raw, "\r\n\r\n")
+	require.True(t, ok)
+	require.Empty(t, rest, "body must not be written")
+
+	lines := strings.Split(head, "\r\n")
+	require.Equal(t, "HTTP/1.1 302 Found", lines[0])
+	require.Equal(t, []string{
+		"Date",
+		"Server",
+		"Location",
+		"Content-Length",
+		"Connection",
+		"Content-Type",
+		"x-trace",
+	}, headerNames(lines))
+
+	raw = string(p.WriteRaw(http.StatusFound, h, body, true))
+	require.True(t, strings.HasSuffix(raw, string(body)))
+	require.Contains(t, string(body), `href="/new"`)
+}
+
+func TestProxy_Persona(t *testing.T) {
+	target, _ := newTarget(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Powered-By", "teamserver")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("ok"))
+	})
+	_, url := newTestProxy(t, common.ProxyConfig{
+		TargetAddr: target,
+		RuleSettings: common.RuleSettings{
+			RejectAction: common.RejectActionNone,
+		},
+		Persona: common.Persona{
+			Name:       PersonaNginx,
+			SetHeaders: map[string]string{"x-frame-options": "DENY"},
+		},
+	}, nil, false)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// proxied responses keep connection alive
+	br := bufio.NewReader(conn)
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		_, err = conn.Write(
+			[]byte(method + " / HTTP/1.1\r\nHost: test\r\n\r\n"),
+		)
+		require.NoError(t, err)
+
+		req := &http.Request{Method: method}
+		response, rerr := http.ReadResponse(br, req)
+		require.NoError(t, rerr, method)
+		body, rerr := io.ReadAll(response.Body)
+		require.NoError(t, rerr, method)
+		response.Body.Close()
+
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.False(t, response.Close, method)
+		require.Equal(t, "nginx", response.Header.Get("Server"))
+		require.Equal(t, "DENY", response.Header.Get("X-Frame-Options"))
+		require.Empty(t, response.Header.Get("X-Powered-By"))
+		if method == http.MethodGet {
+			require.Equal(t, "ok", string(body))
+		} else {
+			require.Empty(t, body)
+		}
+	}
+}
+
+func TestProxy_UnnamedPersonaError(t *testing.T) {
+	p, err := newPersona(common.Persona{
+		SetHeaders: map[string]string{"x-served-by": "edge"},
+	})
+	require.NoError(t, err)
+	proxy := &Proxy{Persona: p}
+
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			proxy.handleError(w, r, http.StatusBadGateway, log.Logger)
+		},
+	))
+	defer s.Close()
+
+	lines, body := rawRequest(t, s.URL, http.MethodGet)
+	require.Equal(t, "HTTP/1.1 502 Bad Gateway", lines[0])
+	require.Contains(t, lines, "x-served-by: edge")
+	require.Contains(t, lines, "Content-Length: 0")
+	require.Empty(t, body)
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/proxy/base"
 	"github.com/D00Movenok/BounceBack/internal/rules"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
@@ -33,15 +36,16 @@ func NewProxy(
 	cfg common.ProxyConfig,
 	rs *rules.RuleSet,
 	db *database.DB,
+	eng *engagement.Engagement,
 ) (*Proxy, error) {
-	baseProxy, err := base.NewBaseProxy(cfg, rs, db, AllowedActions)
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
 	if err != nil {
 		return nil, fmt.Errorf("can't create base proxy: %w", err)
 	}
 
-	target, err := url.Parse(cfg.TargetAddr)
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse target url: %w", err)
+		return nil, fmt.Errorf("can't create targets: %w", err)
 	}
 
 	var action *url.URL
@@ -53,10 +57,23 @@ func NewProxy(
 		}
 	}
 
+	persona, err := newPersona(cfg.Persona)
+	if err != nil {
+		return nil, fmt.Errorf("can't create persona: %w", err)
+	}
+
+	replacers, err := compileReplacers(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("can't create response filters: %w", err)
+	}
+
 	p := &Proxy{
 		Proxy:     baseProxy,
-		TargetURL: target,
+		Targets:   targets,
 		ActionURL: action,
+		Persona:   persona,
+
+		replacers: replacers,
 
 		client: &http.Client{
 			Timeout: baseProxy.Config.Timeout,
@@ -75,6 +92,9 @@ func NewProxy(
 		WriteTimeout: baseProxy.Config.Timeout,
 		IdleTimeout:  baseProxy.Config.Timeout,
 		Handler:      p.getHandler(),
+		ConnContext:  connContext,
+
+		ReadHeaderTimeout: cfg.Limits.HeaderTimeout,
 	}
 
 	if p.TLSConfig != nil {
@@ -91,16 +111,32 @@ func NewProxy(
 type Proxy struct {
 	*base.Proxy
 
-	TargetURL *url.URL
+	Targets   *base.Targets[*url.URL]
 	ActionURL *url.URL
+	Persona   *Persona
+
+	// compiled "replace_body" regexps.
+	replacers map[string]*regexp.Regexp
 
 	server *http.Server
 	client *http.Client
 }
 
 func (p *Proxy) Start() error {
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+	return p.StartListener(l)
+}
+
+// StartListener starts serving connections accepted by l.
+func (p *Proxy) StartListener(l net.Listener) error {
+	l = limitListener{Listener: p.Listener(l), limiter: p.Limiter}
+
+	p.Targets.StartHealthChecks()
 	p.WG.Add(1)
-	go p.serve()
+	go p.serve(l)
 	return nil
 }
 
@@ -110,6 +146,7 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 		return fmt.Errorf("can't shutdown server: %w", err)
 	}
 	p.client.CloseIdleConnections()
+	p.Targets.StopHealthChecks()
 
 	done := make(chan any, 1)
 	go func() {
@@ -133,6 +170,26 @@ func (p *Proxy) proxyRequest(
 	e wrapper.Entity,
 	logger zerolog.Logger,
 ) {
+	response, err := p.doRequest(url, r, e, nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't make proxy request")
+		p.handleError(w, r, http.StatusBadGateway, logger)
+		return
+	}
+	defer response.Body.Close()
+
+	p.writeResponse(w, response, nil, logger)
+}
+
+// make upstream request. Original request is left untouched, so it may
+// be proxied again (e.g. to decoy). Extra headers are set as is.
+func (p *Proxy) doRequest(
+	url *url.URL,
+	r *http.Request,
+	e wrapper.Entity,
+	extra http.Header,
+) (*http.Response, error) {
+	r = r.Clone(r.Context())
 	r.URL.Scheme = url.Scheme
 	r.URL.Host = url.Host
 	r.URL.Path = url.Path + r.URL.Path
@@ -147,26 +204,42 @@ func (p *Proxy) proxyRequest(
 	} else {
 		r.Header.Set("X-Forwarded-For", e.GetIP().String())
 	}
+	for k, v := range extra {
+		r.Header[k] = v
+	}
 
 	response, err := p.client.Do(r)
 	if err != nil {
-		logger.Error().Err(err).Msg("Can't make proxy request")
-		handleError(w)
-		return
+		return nil, fmt.Errorf("can't do request: %w", err)
 	}
-	defer response.Body.Close()
+	return response, nil
+}
 
+// write upstream response. If body is nil, it's copied from response.
+// Headers are only normalized: raw writes with persona's header order
+// would need hijacking and break keep-alive of proxied connections.
+func (p *Proxy) writeResponse(
+	w http.ResponseWriter,
+	response *http.Response,
+	body io.Reader,
+	logger zerolog.Logger,
+) {
 	for k, vals := range response.Header {
 		for _, v := range vals {
 			w.Header().Add(k, v)
 		}
 	}
+	if p.Persona != nil {
+		p.Persona.NormalizeHeaders(w.Header())
+	}
 	w.WriteHeader(response.StatusCode)
 
-	if _, err = io.Copy(w, response.Body); err != nil {
+	if body == nil {
+		body = response.Body
+	}
+	// NOTE: headers are already sent, so only log the error
+	if _, err := io.Copy(w, body); err != nil {
 		logger.Error().Err(err).Msg("Can't copy body")
-		handleError(w)
-		return
 	}
 }
 
@@ -176,24 +249,67 @@ func (p *Proxy) processVerdict(
 	e wrapper.Entity,
 	logger zerolog.Logger,
 ) {
-	switch p.Config.RuleSettings.RejectAction {
+	switch p.RejectAction() {
 	case common.RejectActionProxy:
 		p.proxyRequest(p.ActionURL, w, r, e, logger)
 	case common.RejectActionRedirect:
-		http.Redirect(w, r, p.ActionURL.String(), http.StatusMovedPermanently)
+		p.redirect(w, r, p.ActionURL.String(), logger)
 	case common.RejectActionDrop:
-		hj, _ := w.(http.Hijacker)
-		conn, _, err := hj.Hijack()
-		if err != nil {
-			logger.Error().Err(err).Msg("Can't hijack response")
-			handleError(w)
-			return
-		}
-		conn.Close()
+		p.drop(w, r, logger)
 	default:
 		logger.Warn().Msg("Request was filtered, but action is none")
-		p.proxyRequest(p.TargetURL, w, r, e, logger)
+		p.proxyToTarget(w, r, e, logger)
+	}
+}
+
+// close client connection without response.
+func (p *Proxy) drop(
+	w http.ResponseWriter,
+	r *http.Request,
+	logger zerolog.Logger,
+) {
+	hj, _ := w.(http.Hijacker)
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't hijack response")
+		p.handleError(w, r, http.StatusInternalServerError, logger)
+		return
+	}
+	conn.Close()
+}
+
+// proxy request to the next healthy target or fallback to reject action.
+func (p *Proxy) proxyToTarget(
+	w http.ResponseWriter,
+	r *http.Request,
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) {
+	t, ok := p.Targets.Next(e.GetIP())
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		if p.Config.RuleSettings.RejectAction == common.RejectActionNone {
+			p.handleError(w, r, http.StatusBadGateway, logger)
+			return
+		}
+		p.processVerdict(w, r, e, logger)
+		return
+	}
+
+	logger = logger.With().Stringer("target", t).Logger()
+	response, err := p.doRequest(t.Value, r, e, getEnrichment(r.Context()))
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't make proxy request")
+		p.handleError(w, r, http.StatusBadGateway, logger)
+		return
 	}
+	defer response.Body.Close()
+
+	if len(p.Config.ResponseFilters) == 0 {
+		p.writeResponse(w, response, nil, logger)
+		return
+	}
+	p.filterResponse(w, r, e, response, logger)
 }
 
 func (p *Proxy) createEntity(r *http.Request) (wrapper.Entity, error) {
@@ -203,7 +319,10 @@ func (p *Proxy) createEntity(r *http.Request) (wrapper.Entity, error) {
 	}
 	r.Header.Set("Host", r.Host)
 
-	e := &wrapper.HTTPRequest{Request: r}
+	e := &wrapper.HTTPRequest{
+		Request:     r,
+		ClientHello: getClientHello(r.Context()),
+	}
 	return e, nil
 }
 
@@ -212,7 +331,7 @@ func (p *Proxy) getHandler() http.HandlerFunc {
 		e, err := p.createEntity(r)
 		if err != nil {
 			p.Logger.Error().Err(err).Msg("Can't create entity")
-			handleError(w)
+			p.handleError(w, r, http.StatusInternalServerError, p.Logger)
 			return
 		}
 
@@ -220,27 +339,40 @@ func (p *Proxy) getHandler() http.HandlerFunc {
 			Stringer("from", e.GetIP()).
 			Logger()
 
+		p.stripEnrichment(r, logger)
 		logRequest(e, logger)
-		if !p.RunFilters(e, logger) {
+		// connection over the limits is dropped if action is "none"
+		if err = getLimitError(r.Context()); err != nil {
+			p.LogLimited(err, logger)
+			w.Header().Set("Connection", "close")
+			if p.RejectAction() == common.RejectActionNone {
+				p.drop(w, r, logger)
+				return
+			}
+			p.processVerdict(w, r, e, logger)
+			return
+		}
+		v := p.Filter(e, logger)
+		r, logger = p.enrich(r, e, v, logger)
+		if !v.Accepted() {
 			p.processVerdict(w, r, e, logger)
 			return
 		}
 
-		p.proxyRequest(p.TargetURL, w, r, e, logger)
+		p.proxyToTarget(w, r, e, logger)
 	}
 }
 
-func (p *Proxy) serve() {
+func (p *Proxy) serve(l net.Listener) {
 	defer p.WG.Done()
+	var err error
 	if p.TLSConfig != nil {
-		err := p.server.ListenAndServeTLS("", "")
-		if err != nil && err != http.ErrServerClosed {
-			p.Logger.Fatal().Err(err).Msg("Unexpected server error")
-		}
+		// ClientHello is recorded for TLS fingerprinting.
+		err = p.server.ServeTLS(helloListener{Listener: l}, "", "")
 	} else {
-		err := p.server.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			p.Logger.Fatal().Err(err).Msg("Unexpected server error")
-		}
+		err = p.server.Serve(l)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		p.Fail(fmt.Errorf("can't serve: %w", err))
 	}
 }
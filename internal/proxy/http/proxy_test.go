@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// start target which counts requests.
+func newTarget(t *testing.T, h http.HandlerFunc) (string, *atomic.Int64) {
+	t.Helper()
+
+	requests := atomic.NewInt64(0)
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests.Inc()
+			h(w, r)
+		},
+	))
+	t.Cleanup(s.Close)
+	return s.URL, requests
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	rs map[string]rules.Rule,
+	killed bool,
+) (*Proxy, string) {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.Timeout = time.Second
+	p, err := NewProxy(cfg, &rules.RuleSet{Rules: rs}, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen proxy")
+	require.NoError(t, p.StartListener(l))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p, "http://" + l.Addr().String()
+}
+
+func TestProxy_EngagementKilled(t *testing.T) {
+	decoy, decoyRequests := newTarget(
+		t,
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	)
+
+	tests := []struct {
+		name   string
+		action string
+		url    string
+		killed bool
+		want   int
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+		},
+		{
+			name:   "killed drop",
+			action: common.RejectActionDrop,
+			killed: true,
+		},
+		{
+			name:   "killed proxy",
+			action: common.RejectActionProxy,
+			url:    decoy,
+			killed: true,
+			want:   http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, requests := newTarget(
+				t,
+				func(http.ResponseWriter, *http.Request) {},
+			)
+			_, addr := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+					RejectURL:    tt.url,
+				},
+			}, nil, tt.killed)
+
+			decoyBefore := decoyRequests.Load()
+			resp, err := http.Get(addr) //nolint: noctx // test
+			if tt.want == 0 {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				resp.Body.Close()
+				require.Equal(t, tt.want, resp.StatusCode)
+			}
+			require.Equal(t, !tt.killed, requests.Load() != 0)
+			require.Equal(
+				t,
+				tt.action == common.RejectActionProxy,
+				decoyRequests.Load() != decoyBefore,
+			)
+		})
+	}
+}
+
+func TestProxy_Limits(t *testing.T) {
+	target, requests := newTarget(
+		t,
+		func(http.ResponseWriter, *http.Request) {},
+	)
+	p, addr := newTestProxy(t, common.ProxyConfig{
+		TargetAddr: target,
+		RuleSettings: common.RuleSettings{
+			RejectAction: common.RejectActionNone,
+		},
+		Limits: common.Limits{MaxConnections: 1},
+	}, nil, false)
+
+	// first client keeps connection alive, so it holds the slot
+	first := &http.Client{Transport: &http.Transport{}}
+	defer first.CloseIdleConnections()
+	resp, err := first.Get(addr) //nolint: noctx // test
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	second := &http.Client{Transport: &http.Transport{}}
+	defer second.CloseIdleConnections()
+	_, err = second.Get(addr) //nolint: noctx // test
+	require.Error(t, err, "connection over the limits must be dropped")
+	require.Equal(t, int64(1), requests.Load())
+	require.Equal(t, uint64(1), p.Limiter.Limited())
+}
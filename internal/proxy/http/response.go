@@ -0,0 +1,114 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+)
+
+// MaxReplaceBodySize is a max size of upstream response body which
+// "replace_body" action is applied to, larger bodies are passed as is.
+const MaxReplaceBodySize = 1024 * 1024
+
+func compileReplacers(
+	cfg common.ProxyConfig,
+) (map[string]*regexp.Regexp, error) {
+	replacers := map[string]*regexp.Regexp{}
+	for _, f := range cfg.ResponseFilters {
+		switch f.Action {
+		case common.ResponseActionDecoy:
+			if cfg.RuleSettings.RejectAction == common.RejectActionNone {
+				return nil, ErrDecoyWithoutRejectAction
+			}
+		case common.ResponseActionStripHeader:
+			if f.Header == "" {
+				return nil, ErrEmptyStripHeader
+			}
+		case common.ResponseActionReplaceBody:
+			re, err := regexp.Compile(f.Regexp)
+			if err != nil {
+				return nil, fmt.Errorf("can't compile regexp: %w", err)
+			}
+			replacers[f.Regexp] = re
+		}
+	}
+	return replacers, nil
+}
+
+// run response filters on upstream response and write result.
+// "decoy" action replaces response with proxy's reject action.
+func (p *Proxy) filterResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	e wrapper.Entity,
+	response *http.Response,
+	logger zerolog.Logger,
+) {
+	// only body prefix is visible for rules, the rest is streamed
+	prefix, err := io.ReadAll(
+		io.LimitReader(response.Body, wrapper.ResponseBodyPrefixSize),
+	)
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't read response body")
+		p.handleError(w, r, http.StatusBadGateway, logger)
+		return
+	}
+	body := io.MultiReader(bytes.NewReader(prefix), response.Body)
+
+	re := &wrapper.HTTPResponse{
+		Response: response,
+		Body:     prefix,
+		From:     e.GetIP(),
+	}
+	fired := p.RunResponseFilters(re, logger)
+
+	var replace []common.ResponseFilter
+	for _, f := range fired {
+		switch f.Action {
+		case common.ResponseActionDecoy:
+			p.processVerdict(w, r, e, logger)
+			return
+		case common.ResponseActionStripHeader:
+			response.Header.Del(f.Header)
+		case common.ResponseActionReplaceBody:
+			replace = append(replace, f)
+		}
+	}
+
+	if len(replace) == 0 {
+		p.writeResponse(w, response, body, logger)
+		return
+	}
+	if response.Header.Get("Content-Encoding") != "" {
+		logger.Warn().Msg("Can't replace encoded body, skipping...")
+		p.writeResponse(w, response, body, logger)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxReplaceBodySize+1))
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't read response body")
+		p.handleError(w, r, http.StatusBadGateway, logger)
+		return
+	}
+	if len(data) > MaxReplaceBodySize {
+		logger.Warn().Msg("Body is too large to replace, skipping...")
+		body = io.MultiReader(bytes.NewReader(data), response.Body)
+		p.writeResponse(w, response, body, logger)
+		return
+	}
+
+	for _, f := range replace {
+		data = p.replacers[f.Regexp].ReplaceAll(data, []byte(f.Replace))
+	}
+	response.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	response.Header.Del("Transfer-Encoding")
+	p.writeResponse(w, response, bytes.NewReader(data), logger)
+}
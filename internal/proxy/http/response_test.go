@@ -0,0 +1,214 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// bodyRule fires if body visible for rules contains substr and records
+// size of the body.
+type bodyRule struct {
+	substr string
+	seen   *atomic.Int64
+}
+
+func (r bodyRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r bodyRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	b, err := e.GetBody()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	r.seen.Store(int64(len(b)))
+	return bytes.Contains(b, []byte(r.substr)), nil
+}
+
+func (r bodyRule) String() string {
+	return "body"
+}
+
+func TestProxy_FilterResponse(t *testing.T) {
+	decoy, _ := newTarget(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("decoy"))
+	})
+	large := strings.Repeat("a", MaxReplaceBodySize) + "secret"
+
+	tests := []struct {
+		name     string
+		filters  []common.ResponseFilter
+		substr   string
+		header   http.Header
+		body     string
+		want     string
+		wantSeen int
+		// header must be stripped
+		stripped string
+	}{
+		{
+			name: "not fired",
+			filters: []common.ResponseFilter{
+				{Rule: "body", Action: common.ResponseActionDecoy},
+			},
+			substr:   "secret",
+			body:     "public",
+			want:     "public",
+			wantSeen: len("public"),
+		},
+		{
+			name: "decoy",
+			filters: []common.ResponseFilter{
+				{Rule: "body", Action: common.ResponseActionDecoy},
+			},
+			substr:   "secret",
+			body:     "secret",
+			want:     "decoy",
+			wantSeen: len("secret"),
+		},
+		{
+			name: "strip header",
+			filters: []common.ResponseFilter{
+				{
+					Rule:   "body",
+					Action: common.ResponseActionStripHeader,
+					Header: "X-Teamserver",
+				},
+			},
+			header:   http.Header{"X-Teamserver": {"1"}},
+			substr:   "secret",
+			body:     "secret",
+			want:     "secret",
+			wantSeen: len("secret"),
+			stripped: "X-Teamserver",
+		},
+		{
+			name: "replace body",
+			filters: []common.ResponseFilter{
+				{
+					Rule:    "body",
+					Action:  common.ResponseActionReplaceBody,
+					Regexp:  "s.cret",
+					Replace: "public",
+				},
+			},
+			substr:   "secret",
+			body:     "a secret b",
+			want:     "a public b",
+			wantSeen: len("a secret b"),
+		},
+		{
+			name: "replace encoded body",
+			filters: []common.ResponseFilter{
+				{
+					Rule:    "body",
+					Action:  common.ResponseActionReplaceBody,
+					Regexp:  "secret",
+					Replace: "public",
+				},
+			},
+			header:   http.Header{"Content-Encoding": {"identity"}},
+			substr:   "secret",
+			body:     "secret",
+			want:     "secret",
+			wantSeen: len("secret"),
+		},
+		{
+			name: "only prefix is visible",
+			filters: []common.ResponseFilter{
+				{Rule: "body", Action: common.ResponseActionDecoy},
+			},
+			substr:   "secret",
+			body:     large,
+			want:     large,
+			wantSeen: wrapper.ResponseBodyPrefixSize,
+		},
+		{
+			name: "replace too large body",
+			filters: []common.ResponseFilter{
+				{
+					Rule:    "body",
+					Action:  common.ResponseActionReplaceBody,
+					Regexp:  "a+",
+					Replace: "b",
+				},
+			},
+			substr:   "a",
+			body:     large,
+			want:     large,
+			wantSeen: wrapper.ResponseBodyPrefixSize,
+		},
+		{
+			name: "replace large body",
+			filters: []common.ResponseFilter{
+				{
+					Rule:    "body",
+					Action:  common.ResponseActionReplaceBody,
+					Regexp:  "a+",
+					Replace: "b",
+				},
+			},
+			substr:   "a",
+			body:     large[len("secret"):],
+			want:     "bsecret",
+			wantSeen: wrapper.ResponseBodyPrefixSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := atomic.NewInt64(0)
+			rs := map[string]rules.Rule{
+				"body": bodyRule{substr: tt.substr, seen: seen},
+			}
+			p, _ := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: "http://127.0.0.1:1",
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionProxy,
+					RejectURL:    decoy,
+				},
+				ResponseFilters: tt.filters,
+			}, rs, false)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			e, err := p.createEntity(r)
+			require.NoError(t, err)
+
+			header := http.Header{
+				"Content-Length": {strconv.Itoa(len(tt.body))},
+			}
+			for k, v := range tt.header {
+				header[k] = v
+			}
+			response := &http.Response{
+				Proto:      "HTTP/1.1",
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			w := httptest.NewRecorder()
+			p.filterResponse(w, r, e, response, log.Logger)
+
+			require.Equal(t, tt.want, w.Body.String())
+			require.Equal(t, int64(tt.wantSeen), seen.Load())
+			if l := w.Header().Get("Content-Length"); l != "" {
+				require.Equal(t, strconv.Itoa(len(tt.want)), l)
+			}
+			if tt.stripped != "" {
+				require.Empty(t, w.Header().Get(tt.stripped))
+			}
+		})
+	}
+}
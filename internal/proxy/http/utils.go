@@ -1,14 +1,108 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
 	"github.com/rs/zerolog"
 )
 
-func handleError(w http.ResponseWriter) {
-	http.Error(w, "internal error", http.StatusInternalServerError)
+func (p *Proxy) handleError(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	logger zerolog.Logger,
+) {
+	if p.Persona == nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	p.writeSynthesized(w, r, status, "", logger)
+}
+
+func (p *Proxy) redirect(
+	w http.ResponseWriter,
+	r *http.Request,
+	location string,
+	logger zerolog.Logger,
+) {
+	if p.Persona == nil {
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+	p.writeSynthesized(w, r, p.Persona.RedirectStatus, location, logger)
+}
+
+// write persona response. Persona without pages sends empty body.
+// Only responses built by proxy itself are written raw, proxied ones
+// keep their connection alive.
+func (p *Proxy) writeSynthesized(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	location string,
+	logger zerolog.Logger,
+) {
+	h, body := p.Persona.BuildResponse(status, location)
+	if p.writeRaw(w, r, status, h, body, logger) {
+		return
+	}
+
+	h.Del("Connection")
+	for k, v := range h {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write(body); err != nil {
+			logger.Error().Err(err).Msg("Can't write response")
+		}
+	}
+}
+
+// write response to hijacked HTTP/1.x connection to keep persona's
+// header order and casing, so connection is closed after. Returns false
+// if connection can't be hijacked, then nothing is written.
+func (p *Proxy) writeRaw(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	h http.Header,
+	body []byte,
+	logger zerolog.Logger,
+) bool {
+	hj, ok := w.(http.Hijacker)
+	if !ok || r.ProtoMajor != 1 {
+		return false
+	}
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't hijack response")
+		return false
+	}
+	defer conn.Close()
+
+	h.Set("Connection", "close")
+	withBody := r.Method != http.MethodHead
+	_, err = bufrw.Write(p.Persona.WriteRaw(status, h, body, withBody))
+	if err == nil {
+		err = bufrw.Flush()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't write response")
+	}
+	return true
+}
+
+func parseTarget(addr string) (*url.URL, *url.URL, error) {
+	target, err := url.Parse(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't parse target url: %w", err)
+	}
+	probe := &url.URL{Scheme: target.Scheme, Host: target.Host}
+	return target, probe, nil
 }
 
 func logRequest(e wrapper.Entity, logger zerolog.Logger) {
@@ -11,6 +11,13 @@ type Proxy interface {
 	Start() error
 	Shutdown(context.Context) error
 	GetLogger() *zerolog.Logger
+	// Errors reports unexpected errors after which proxy must be
+	// restarted.
+	Errors() <-chan error
+	// Sessions returns count of active sessions.
+	Sessions() int
+	// CloseSessions closes sessions outliving drain or shutdown deadline.
+	CloseSessions()
 
 	fmt.Stringer
 }
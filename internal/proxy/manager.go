@@ -8,8 +8,13 @@ import (
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/proxy/dns"
 	"github.com/D00Movenok/BounceBack/internal/proxy/http"
+	"github.com/D00Movenok/BounceBack/internal/proxy/mux"
+	"github.com/D00Movenok/BounceBack/internal/proxy/passthrough"
+	"github.com/D00Movenok/BounceBack/internal/proxy/smtp"
+	"github.com/D00Movenok/BounceBack/internal/proxy/ssh"
 	"github.com/D00Movenok/BounceBack/internal/proxy/tcp"
 	"github.com/D00Movenok/BounceBack/internal/proxy/udp"
 	"github.com/D00Movenok/BounceBack/internal/rules"
@@ -23,21 +28,39 @@ func NewManager(db *database.DB, cfg *common.Config) (*Manager, error) {
 		return nil, fmt.Errorf("can't create rules: %w", err)
 	}
 
-	proxies := make([]Proxy, len(cfg.Proxies))
-	for i, pc := range cfg.Proxies {
-		log.Trace().Any("proxy_cfg", pc).Msg("Creating proxy")
-		switch pc.Type {
-		case http.ProxyType:
-			proxies[i], err = http.NewProxy(pc, rs, db)
-		case dns.ProxyType:
-			proxies[i], err = dns.NewProxy(pc, rs, db)
-		case tcp.ProxyType:
-			proxies[i], err = tcp.NewProxy(pc, rs, db)
-		case udp.ProxyType:
-			proxies[i], err = udp.NewProxy(pc, rs, db)
-		default:
-			return nil, &InvalidProxyTypeError{t: pc.Type}
+	eng, err := engagement.New(cfg.Engagement, db)
+	if err != nil {
+		return nil, fmt.Errorf("can't create engagement: %w", err)
+	}
+
+	// mux proxies are created last, because they need their children.
+	created := make(map[string]Proxy, len(cfg.Proxies))
+	for _, pc := range cfg.Proxies {
+		if pc.Type == mux.ProxyType {
+			continue
+		}
+		if created[pc.Name], err = newProxy(pc, rs, db, eng); err != nil {
+			return nil, err
+		}
+	}
+
+	owned := map[string]bool{}
+	for _, pc := range cfg.Proxies {
+		if pc.Type != mux.ProxyType {
+			continue
+		}
+		var children map[string]mux.Child
+		children, err = getMuxChildren(pc, created, owned)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't create proxy \"%s\": %w",
+				pc.Name,
+				err,
+			)
 		}
+		log.Trace().Any("proxy_cfg", pc).Msg("Creating proxy")
+		var p Proxy
+		p, err = mux.NewProxy(pc, rs, db, eng, children)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"can't create proxy \"%s\": %w",
@@ -45,55 +68,222 @@ func NewManager(db *database.DB, cfg *common.Config) (*Manager, error) {
 				err,
 			)
 		}
-		proxies[i].GetLogger().Debug().Msg("Created new proxy")
+		p.GetLogger().Debug().Msg("Created new proxy")
+		created[pc.Name] = p
+	}
+
+	// children are started and shut down by their mux.
+	supervisors := make([]*supervisor, 0, len(cfg.Proxies))
+	drained := map[*supervisor]bool{}
+	for _, pc := range cfg.Proxies {
+		if owned[pc.Name] {
+			if pc.ListenAddr != "" {
+				return nil, &MuxChildListenError{proxy: pc.Name}
+			}
+			continue
+		}
+		if pc.ListenAddr == "" {
+			return nil, &NoListenAddrError{proxy: pc.Name}
+		}
+		s := newSupervisor(
+			pc.Name,
+			created[pc.Name],
+			getFactory(pc, cfg.Proxies, rs, db, eng),
+		)
+		drained[s] = pc.Drain
+		supervisors = append(supervisors, s)
 	}
 
-	m := &Manager{proxies}
+	m := &Manager{
+		supervisors:  supervisors,
+		drained:      drained,
+		drainTimeout: cfg.Globals.DrainTimeout,
+		engagement:   eng,
+	}
+	// established sessions must not outlive kill switch
+	eng.OnKill(m.closeSessions)
 	return m, nil
 }
 
+// getFactory returns func recreating proxy from config on restart.
+// Mux children are recreated together with their mux.
+func getFactory(
+	pc common.ProxyConfig,
+	all []common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+) func() (Proxy, error) {
+	if pc.Type != mux.ProxyType {
+		return func() (Proxy, error) {
+			return newProxy(pc, rs, db, eng)
+		}
+	}
+
+	return func() (Proxy, error) {
+		created := map[string]Proxy{}
+		for _, r := range pc.Mux.Routes {
+			for _, c := range all {
+				if c.Name != r.Proxy || created[c.Name] != nil {
+					continue
+				}
+				p, err := newProxy(c, rs, db, eng)
+				if err != nil {
+					return nil, err
+				}
+				created[c.Name] = p
+			}
+		}
+		children, err := getMuxChildren(pc, created, map[string]bool{})
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't create proxy \"%s\": %w",
+				pc.Name,
+				err,
+			)
+		}
+		p, err := mux.NewProxy(pc, rs, db, eng, children)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't create proxy \"%s\": %w",
+				pc.Name,
+				err,
+			)
+		}
+		p.GetLogger().Debug().Msg("Created new proxy")
+		return p, nil
+	}
+}
+
+func newProxy(
+	pc common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+) (Proxy, error) {
+	log.Trace().Any("proxy_cfg", pc).Msg("Creating proxy")
+	var (
+		p   Proxy
+		err error
+	)
+	switch pc.Type {
+	case http.ProxyType:
+		p, err = http.NewProxy(pc, rs, db, eng)
+	case dns.ProxyType:
+		p, err = dns.NewProxy(pc, rs, db, eng)
+	case tcp.ProxyType:
+		p, err = tcp.NewProxy(pc, rs, db, eng)
+	case udp.ProxyType:
+		p, err = udp.NewProxy(pc, rs, db, eng)
+	case passthrough.ProxyType:
+		p, err = passthrough.NewProxy(pc, rs, db, eng)
+	case ssh.ProxyType:
+		p, err = ssh.NewProxy(pc, rs, db, eng)
+	case smtp.ProxyType:
+		p, err = smtp.NewProxy(pc, rs, db, eng)
+	default:
+		return nil, &InvalidProxyTypeError{t: pc.Type}
+	}
+	if err != nil {
+		return nil, fmt.Errorf(
+			"can't create proxy \"%s\": %w",
+			pc.Name,
+			err,
+		)
+	}
+	p.GetLogger().Debug().Msg("Created new proxy")
+	return p, nil
+}
+
+// collect proxies referenced by mux routes and mark them as owned.
+// Unknown proxies are skipped here and reported by mux itself.
+func getMuxChildren(
+	pc common.ProxyConfig,
+	created map[string]Proxy,
+	owned map[string]bool,
+) (map[string]mux.Child, error) {
+	children := map[string]mux.Child{}
+	for _, r := range pc.Mux.Routes {
+		if _, ok := children[r.Proxy]; ok {
+			continue
+		}
+		p, ok := created[r.Proxy]
+		if !ok {
+			continue
+		}
+		if owned[r.Proxy] {
+			return nil, &MuxChildSharedError{proxy: r.Proxy}
+		}
+		c, ok := p.(mux.Child)
+		if !ok {
+			return nil, &MuxChildTypeError{proxy: r.Proxy}
+		}
+		children[r.Proxy] = c
+	}
+
+	for name := range children {
+		owned[name] = true
+	}
+	return children, nil
+}
+
 type Manager struct {
-	proxies []Proxy
+	supervisors []*supervisor
+	// proxies drained in config aren't started.
+	drained      map[*supervisor]bool
+	drainTimeout time.Duration
+	engagement   *engagement.Engagement
 }
 
+// StartAll starts all proxies and their supervision. Already started
+// proxies are shut down if any proxy fails to start.
 func (m *Manager) StartAll() error {
-	for i, p := range m.proxies {
-		p.GetLogger().Info().Msg("Starting proxy")
-		if err := p.Start(); err != nil {
+	m.engagement.Start()
+	log.Info().Str("state", m.engagement.State()).Msg("Engagement")
+
+	for i, s := range m.supervisors {
+		if m.drained[s] {
+			s.current().GetLogger().Info().Msg("Proxy is drained, skipping")
+			s.skip()
+			continue
+		}
+		if err := s.start(); err != nil {
 			ctx, cancel := context.WithTimeout(
 				context.Background(),
 				time.Second*5, //nolint:gomnd
 			)
 			defer cancel()
 			for j := 0; j < i; j++ {
-				if serr := m.proxies[j].Shutdown(ctx); serr != nil {
+				if serr := m.supervisors[j].shutdown(ctx); serr != nil {
 					log.Error().Err(serr).Msgf(
 						"Error shutting down %s forcefully",
-						m.proxies[j],
+						m.supervisors[j],
 					)
 				}
 			}
-			return fmt.Errorf("can't start \"%s\": %w", p, err)
+			m.engagement.Stop()
+			return err
 		}
 	}
 	return nil
 }
 
 func (m *Manager) Shutdown(ctx context.Context) error {
+	m.engagement.Stop()
+
 	wg := sync.WaitGroup{}
-	wg.Add(len(m.proxies))
-	errCh := make(chan error)
-	for _, p := range m.proxies {
-		p.GetLogger().Info().Msg("Shutting down proxy")
-		go func(p Proxy) {
+	wg.Add(len(m.supervisors))
+	errCh := make(chan error, len(m.supervisors))
+	for _, s := range m.supervisors {
+		go func(s *supervisor) {
 			defer wg.Done()
-			if err := p.Shutdown(ctx); err != nil {
+			if err := s.shutdown(ctx); err != nil {
 				select {
-				case errCh <- fmt.Errorf("can't shutdown \"%s\": %w", p, err):
+				case errCh <- err:
 				default:
 				}
 			}
-		}(p)
+		}(s)
 	}
 	wg.Wait()
 	select {
@@ -103,3 +293,46 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 		return nil
 	}
 }
+
+// Health returns state of every supervised proxy.
+func (m *Manager) Health() []Health {
+	health := make([]Health, 0, len(m.supervisors))
+	for _, s := range m.supervisors {
+		health = append(health, s.getHealth())
+	}
+	return health
+}
+
+// Drain closes listener of the proxy and keeps its active sessions until
+// they finish or drain timeout is exceeded. Drained proxy isn't
+// restarted.
+func (m *Manager) Drain(name string) error {
+	for _, s := range m.supervisors {
+		if s.getHealth().Name == name {
+			return s.drain(m.drainTimeout)
+		}
+	}
+	return &UnknownProxyError{proxy: name}
+}
+
+// Kill flips engagement kill switch: all requests are rejected and
+// active sessions are closed.
+func (m *Manager) Kill(reason string) error {
+	return m.engagement.Kill(reason) //nolint: wrapcheck // engagement errors
+}
+
+// Resume flips engagement kill switch back.
+func (m *Manager) Resume() error {
+	return m.engagement.Resume() //nolint: wrapcheck // engagement errors
+}
+
+// EngagementState returns current engagement state.
+func (m *Manager) EngagementState() string {
+	return m.engagement.State()
+}
+
+func (m *Manager) closeSessions() {
+	for _, s := range m.supervisors {
+		s.current().CloseSessions()
+	}
+}
@@ -0,0 +1,28 @@
+package mux
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoRoutes      = errors.New("no routes specified")
+	ErrEmptyRouteTo  = errors.New("route proxy is not specified")
+	ErrTLSOnlyFields = errors.New("\"sni\" and \"alpn\" require tls protocol")
+)
+
+type UnknownProtocolError struct {
+	protocol string
+}
+
+func (e UnknownProtocolError) Error() string {
+	return fmt.Sprintf("unknown mux protocol: %s", e.protocol)
+}
+
+type UnknownChildError struct {
+	proxy string
+}
+
+func (e UnknownChildError) Error() string {
+	return fmt.Sprintf("unknown mux child proxy: %s", e.proxy)
+}
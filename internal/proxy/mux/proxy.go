@@ -0,0 +1,371 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ProxyType = "mux"
+
+	defaultSniffTimeout = time.Second * 3
+)
+
+var (
+	AllowedActions = []string{
+		common.RejectActionDrop,
+		common.RejectActionNone,
+	}
+)
+
+// Child is a proxy which can serve connections accepted by mux.
+type Child interface {
+	// StartListener starts serving connections from listener
+	// instead of own listen address.
+	StartListener(l net.Listener) error
+	Shutdown(ctx context.Context) error
+	Errors() <-chan error
+	Sessions() int
+
+	fmt.Stringer
+}
+
+type route struct {
+	protocol string
+	sni      []*regexp.Regexp
+	alpn     []string
+	proxy    string
+}
+
+// NewProxy creates mux proxy. Children are proxies referenced by
+// routes, they are started and shut down by mux.
+func NewProxy(
+	cfg common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+	children map[string]Child,
+) (*Proxy, error) {
+	if len(cfg.TLS) > 0 {
+		return nil, base.ErrTLSUnsupported
+	}
+
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
+	if err != nil {
+		return nil, fmt.Errorf("can't create base proxy: %w", err)
+	}
+
+	routes, err := parseRoutes(cfg.Mux.Routes, children)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse routes: %w", err)
+	}
+
+	p := &Proxy{
+		Proxy:    baseProxy,
+		children: children,
+		routes:   routes,
+
+		sniffTimeout: cfg.Mux.SniffTimeout,
+	}
+	if p.sniffTimeout == 0 {
+		p.sniffTimeout = defaultSniffTimeout
+	}
+
+	return p, nil
+}
+
+func parseRoutes(
+	cfg []common.MuxRoute,
+	children map[string]Child,
+) ([]route, error) {
+	if len(cfg) == 0 {
+		return nil, ErrNoRoutes
+	}
+
+	protocols := []string{
+		common.MuxProtocolAny,
+		common.MuxProtocolTLS,
+		common.MuxProtocolHTTP,
+		common.MuxProtocolSSH,
+	}
+	routes := make([]route, 0, len(cfg))
+	for _, rc := range cfg {
+		r := route{
+			protocol: rc.Protocol,
+			alpn:     rc.ALPN,
+			proxy:    rc.Proxy,
+		}
+		if r.protocol == "" {
+			r.protocol = common.MuxProtocolAny
+		}
+		if !slices.Contains(protocols, r.protocol) {
+			return nil, &UnknownProtocolError{protocol: r.protocol}
+		}
+		if r.protocol != common.MuxProtocolTLS &&
+			(len(rc.SNI) > 0 || len(rc.ALPN) > 0) {
+			return nil, ErrTLSOnlyFields
+		}
+		if r.proxy == "" {
+			return nil, ErrEmptyRouteTo
+		}
+		if _, ok := children[r.proxy]; !ok {
+			return nil, &UnknownChildError{proxy: r.proxy}
+		}
+		for _, s := range rc.SNI {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("can't compile sni regexp: %w", err)
+			}
+			r.sni = append(r.sni, re)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+type Proxy struct {
+	*base.Proxy
+
+	children  map[string]Child
+	routes    []route
+	listeners map[string]*listener
+
+	sniffTimeout time.Duration
+
+	listener net.Listener
+	closed   chan struct{}
+}
+
+func (p *Proxy) Start() error {
+	var err error
+	p.listener, err = net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+
+	p.listeners = make(map[string]*listener, len(p.children))
+	for name, c := range p.children {
+		l := newListener(p.listener.Addr())
+		if err = c.StartListener(l); err != nil {
+			p.listener.Close()
+			p.shutdownChildren()
+			return fmt.Errorf("can't start child \"%s\": %w", c, err)
+		}
+		p.listeners[name] = l
+	}
+
+	// children can't be restarted alone, so mux is restarted instead
+	p.closed = make(chan struct{})
+	for _, c := range p.children {
+		p.WG.Add(1)
+		go p.watchChild(c)
+	}
+
+	p.listener = p.Listener(p.listener)
+	p.WG.Add(1)
+	go p.serve()
+	return nil
+}
+
+// Shutdown shuts down mux and all its children even if some of them
+// fail, errors are joined.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.Closing = true
+	close(p.closed)
+
+	var errs []error
+	if err := p.listener.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("can't close listener: %w", err))
+	}
+
+	// children close their listeners, so pending deliveries are canceled
+	for name, c := range p.children {
+		if _, ok := p.listeners[name]; !ok {
+			continue
+		}
+		if err := c.Shutdown(ctx); err != nil {
+			errs = append(
+				errs,
+				fmt.Errorf("can't shutdown child \"%s\": %w", c, err),
+			)
+		}
+	}
+
+	done := make(chan interface{}, 1)
+	go func() {
+		p.WG.Wait()
+		done <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		errs = append(errs, base.ErrShutdownTimeout)
+	case <-done:
+		break
+	}
+	return errors.Join(errs...)
+}
+
+// shut down already started children if mux fails to start.
+func (p *Proxy) shutdownChildren() {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Second*5, //nolint:gomnd
+	)
+	defer cancel()
+	for name, c := range p.children {
+		if _, ok := p.listeners[name]; !ok {
+			continue
+		}
+		if err := c.Shutdown(ctx); err != nil {
+			p.Logger.Error().Err(err).Msgf(
+				"Error shutting down child \"%s\" forcefully",
+				c,
+			)
+		}
+	}
+}
+
+// Sessions returns count of active sessions of mux and its children.
+func (p *Proxy) Sessions() int {
+	n := p.Proxy.Sessions()
+	for _, c := range p.children {
+		n += c.Sessions()
+	}
+	return n
+}
+
+func (p *Proxy) watchChild(c Child) {
+	defer p.WG.Done()
+	select {
+	case <-p.closed:
+	case err := <-c.Errors():
+		p.Fail(fmt.Errorf("child \"%s\" failed: %w", c, err))
+	}
+}
+
+// returns true if need return from func.
+func (p *Proxy) processVerdict(
+	conn net.Conn,
+	logger zerolog.Logger,
+) bool {
+	switch p.RejectAction() {
+	case common.RejectActionDrop:
+		conn.Close()
+		return true
+	default:
+		logger.Warn().Msg("Request was filtered, but action is none")
+		return false
+	}
+}
+
+// find first route matching sniffed protocol.
+func (p *Proxy) match(s sniffed) *route {
+	for i, r := range p.routes {
+		if r.protocol != common.MuxProtocolAny && r.protocol != s.protocol {
+			continue
+		}
+		if len(r.sni) > 0 && (s.hello == nil || !matchSNI(r.sni, s)) {
+			continue
+		}
+		if len(r.alpn) > 0 && (s.hello == nil || !matchALPN(r.alpn, s)) {
+			continue
+		}
+		return &p.routes[i]
+	}
+	return nil
+}
+
+func matchSNI(sni []*regexp.Regexp, s sniffed) bool {
+	for _, re := range sni {
+		if re.MatchString(s.hello.ServerName) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchALPN(alpn []string, s sniffed) bool {
+	for _, proto := range s.hello.ALPN {
+		if slices.Contains(alpn, proto) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) handleConnection(conn net.Conn) {
+	defer p.WG.Done()
+
+	from := base.NetAddrToNetipAddrPort(conn.RemoteAddr()).Addr().Unmap()
+	logger := p.Logger.With().
+		Stringer("from", from).
+		Logger()
+
+	logger.Info().Msg("New request")
+
+	// slot is held until connection is passed to child. Connection over
+	// the limits is closed even if action is "none".
+	release, ok := p.Acquire(from, logger)
+	defer release()
+	if !ok {
+		conn.Close()
+		return
+	}
+
+	e := &wrapper.RawPacket{
+		Content: []byte{},
+		From:    from,
+	}
+	if !p.RunFilters(e, logger) && p.processVerdict(conn, logger) {
+		return
+	}
+
+	data, s := p.sniff(conn)
+	ev := logger.Debug().Str("protocol", s.protocol)
+	if s.hello != nil {
+		ev = ev.Str("sni", s.hello.ServerName).Strs("alpn", s.hello.ALPN)
+	}
+	ev.Msg("Sniffed protocol")
+
+	r := p.match(s)
+	if r == nil {
+		logger.Warn().Str("protocol", s.protocol).Msg("No matching route")
+		conn.Close()
+		return
+	}
+
+	logger.Debug().Str("route", r.proxy).Msg("Passing connection")
+	if !p.listeners[r.proxy].deliver(base.NewReplayConn(conn, data)) {
+		conn.Close()
+	}
+}
+
+func (p *Proxy) serve() {
+	defer p.WG.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if !p.Closing {
+				p.Fail(fmt.Errorf("can't accept connection: %w", err))
+			}
+			return
+		}
+
+		p.WG.Add(1)
+		go p.handleConnection(conn)
+	}
+}
@@ -0,0 +1,234 @@
+package mux
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/stretchr/testify/require"
+)
+
+var errStart = errors.New("start failed")
+
+type testChild struct {
+	name        string
+	fail        bool
+	shutdownErr error
+	started     bool
+	shutdown    bool
+	errs        chan error
+}
+
+func newTestChild(name string, fail bool) *testChild {
+	return &testChild{name: name, fail: fail, errs: make(chan error)}
+}
+
+func (c *testChild) StartListener(_ net.Listener) error {
+	if c.fail {
+		return errStart
+	}
+	c.started = true
+	return nil
+}
+
+func (c *testChild) Shutdown(_ context.Context) error {
+	c.shutdown = true
+	return c.shutdownErr
+}
+
+func (c *testChild) Errors() <-chan error { return c.errs }
+func (c *testChild) Sessions() int        { return 0 }
+func (c *testChild) String() string       { return c.name }
+
+// record ClientHello sent by crypto/tls client.
+func recordHello(t *testing.T, cfg *tls.Config) []byte {
+	t.Helper()
+
+	client, server := net.Pipe()
+	go func() {
+		//nolint: errcheck // handshake fails after server closes pipe
+		tls.Client(client, cfg).Handshake()
+		client.Close()
+	}()
+
+	var data []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := server.Read(buf)
+		data = append(data, buf[:n]...)
+		if _, done := detect(data); done || err != nil {
+			break
+		}
+	}
+	server.Close()
+	return data
+}
+
+func TestDetect(t *testing.T) {
+	//nolint: gosec // client is used only to generate hello
+	hello := recordHello(t, &tls.Config{
+		ServerName:         "example.com",
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		protocol string
+		done     bool
+		sni      string
+	}{
+		{
+			name:     "empty",
+			protocol: common.MuxProtocolAny,
+		},
+		{
+			name:     "tls",
+			data:     hello,
+			protocol: common.MuxProtocolTLS,
+			done:     true,
+			sni:      "example.com",
+		},
+		{
+			name:     "partial tls",
+			data:     hello[:10],
+			protocol: common.MuxProtocolTLS,
+		},
+		{
+			name:     "http",
+			data:     []byte("GET / HTTP/1.1\r\n"),
+			protocol: common.MuxProtocolHTTP,
+			done:     true,
+		},
+		{
+			name:     "http2 prior knowledge",
+			data:     []byte("PRI * HTTP/2.0\r\n"),
+			protocol: common.MuxProtocolHTTP,
+			done:     true,
+		},
+		{
+			name:     "partial http",
+			data:     []byte("OPTI"),
+			protocol: common.MuxProtocolAny,
+		},
+		{
+			name:     "ssh",
+			data:     []byte("SSH-2.0-OpenSSH_9.6\r\n"),
+			protocol: common.MuxProtocolSSH,
+			done:     true,
+		},
+		{
+			name:     "partial ssh",
+			data:     []byte("SS"),
+			protocol: common.MuxProtocolAny,
+		},
+		{
+			name:     "raw",
+			data:     []byte{0x00, 0x01, 0x02, 0x03},
+			protocol: common.MuxProtocolAny,
+			done:     true,
+		},
+		{
+			name:     "method without space",
+			data:     []byte("GETX"),
+			protocol: common.MuxProtocolAny,
+			done:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, done := detect(tt.data)
+			require.Equal(t, tt.protocol, s.protocol)
+			require.Equal(t, tt.done, done)
+			if tt.sni == "" {
+				require.Nil(t, s.hello)
+				return
+			}
+			require.NotNil(t, s.hello)
+			require.Equal(t, tt.sni, s.hello.ServerName)
+			require.Equal(t, []string{"h2", "http/1.1"}, s.hello.ALPN)
+		})
+	}
+}
+
+func newTestProxy(t *testing.T, children map[string]Child) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+
+	p, err := NewProxy(
+		common.ProxyConfig{
+			Name:       "test",
+			Type:       ProxyType,
+			ListenAddr: "127.0.0.1:0",
+			Timeout:    time.Second,
+			RuleSettings: common.RuleSettings{
+				RejectAction: common.RejectActionDrop,
+			},
+			Mux: common.Mux{
+				Routes: []common.MuxRoute{
+					{Protocol: common.MuxProtocolHTTP, Proxy: "a"},
+					{Protocol: common.MuxProtocolSSH, Proxy: "b"},
+					{Proxy: "c"},
+				},
+			},
+		},
+		&rules.RuleSet{Rules: map[string]rules.Rule{}},
+		db,
+		eng,
+		children,
+	)
+	require.NoError(t, err, "can't create proxy")
+	return p
+}
+
+func TestProxy_StartChildFailed(t *testing.T) {
+	children := map[string]Child{
+		"a": newTestChild("a", false),
+		"b": newTestChild("b", false),
+		"c": newTestChild("c", true),
+	}
+	p := newTestProxy(t, children)
+
+	require.ErrorIs(t, p.Start(), errStart)
+	for _, c := range children {
+		tc := c.(*testChild)
+		require.Equal(t, tc.started, tc.shutdown, tc.name)
+	}
+}
+
+func TestProxy_ShutdownChildFailed(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	children := map[string]Child{
+		"a": newTestChild("a", false),
+		"b": newTestChild("b", false),
+		"c": newTestChild("c", false),
+	}
+	children["a"].(*testChild).shutdownErr = errA
+	children["b"].(*testChild).shutdownErr = errB
+	p := newTestProxy(t, children)
+	require.NoError(t, p.Start())
+
+	// every child is shut down and all errors are returned
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := p.Shutdown(ctx)
+	require.ErrorIs(t, err, errA)
+	require.ErrorIs(t, err, errB)
+	for _, c := range children {
+		require.True(t, c.(*testChild).shutdown, c.String())
+	}
+}
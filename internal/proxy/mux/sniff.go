@@ -0,0 +1,97 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+)
+
+const (
+	sniffBufSize = 4 * 1024
+
+	tlsRecordHandshake = 0x16
+	sshPrefix          = "SSH-"
+)
+
+type sniffed struct {
+	protocol string
+	// hello is nil if protocol isn't tls or ClientHello is malformed.
+	hello *tlshello.ClientHello
+}
+
+func getHTTPMethods() []string {
+	return []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodConnect,
+		http.MethodOptions,
+		http.MethodTrace,
+		"PRI", // HTTP/2 prior knowledge
+	}
+}
+
+// read first bytes of connection until protocol is detected or sniff
+// timeout exceeded. Returns read data, so it may be replayed.
+func (p *Proxy) sniff(conn net.Conn) ([]byte, sniffed) {
+	_ = conn.SetReadDeadline(time.Now().Add(p.sniffTimeout))
+	defer func() {
+		_ = conn.SetReadDeadline(time.Time{})
+	}()
+
+	var (
+		data []byte
+		buf  = make([]byte, sniffBufSize)
+	)
+	for {
+		n, err := conn.Read(buf)
+		data = append(data, buf[:n]...)
+		s, done := detect(data)
+		if done || err != nil {
+			return data, s
+		}
+	}
+}
+
+// detect protocol by first bytes. Returns false if more data is needed.
+func detect(data []byte) (sniffed, bool) {
+	s := sniffed{protocol: common.MuxProtocolAny}
+	if len(data) == 0 {
+		return s, false
+	}
+
+	if data[0] == tlsRecordHandshake {
+		s.protocol = common.MuxProtocolTLS
+		hello, err := tlshello.Parse(data)
+		if errors.Is(err, tlshello.ErrIncomplete) {
+			return s, false
+		}
+		s.hello = hello
+		return s, true
+	}
+
+	if bytes.HasPrefix(data, []byte(sshPrefix)) {
+		s.protocol = common.MuxProtocolSSH
+		return s, true
+	}
+	partial := bytes.HasPrefix([]byte(sshPrefix), data)
+
+	for _, m := range getHTTPMethods() {
+		m += " "
+		if bytes.HasPrefix(data, []byte(m)) {
+			s.protocol = common.MuxProtocolHTTP
+			return s, true
+		}
+		partial = partial || bytes.HasPrefix([]byte(m), data)
+	}
+
+	return s, !partial
+}
@@ -0,0 +1,7 @@
+package passthrough
+
+import "errors"
+
+var (
+	ErrEmptySNIRoute = errors.New("sni route requires \"sni\" and \"target\"")
+)
@@ -0,0 +1,327 @@
+package passthrough
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/rs/zerolog"
+)
+
+const (
+	ProxyType = "tls_passthrough"
+
+	BufSize = 64 * 1024
+)
+
+var (
+	AllowedActions = []string{
+		common.RejectActionProxy,
+		common.RejectActionDrop,
+		common.RejectActionNone,
+	}
+)
+
+func NewProxy(
+	cfg common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+) (*Proxy, error) {
+	if len(cfg.TLS) > 0 {
+		return nil, base.ErrTLSUnsupported
+	}
+
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
+	if err != nil {
+		return nil, fmt.Errorf("can't create base proxy: %w", err)
+	}
+
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create targets: %w", err)
+	}
+
+	routes, err := newRoutes(cfg, baseProxy.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create sni routes: %w", err)
+	}
+
+	var action netip.AddrPort
+	if cfg.RuleSettings.RejectAction == common.RejectActionProxy {
+		action, err = netip.ParseAddrPort(cfg.RuleSettings.RejectURL)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse action address: %w", err)
+		}
+	}
+
+	p := &Proxy{
+		Proxy:      baseProxy,
+		Targets:    targets,
+		ActionAddr: action,
+
+		routes: routes,
+	}
+
+	return p, nil
+}
+
+type route struct {
+	sni     []*regexp.Regexp
+	targets *base.Targets[netip.AddrPort]
+}
+
+// every route has own targets pool with proxy's target settings.
+func newRoutes(
+	cfg common.ProxyConfig,
+	logger zerolog.Logger,
+) ([]route, error) {
+	routes := make([]route, 0, len(cfg.SNIRoutes))
+	for _, rc := range cfg.SNIRoutes {
+		if len(rc.SNI) == 0 || rc.Target == "" {
+			return nil, ErrEmptySNIRoute
+		}
+
+		var r route
+		for _, s := range rc.SNI {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("can't compile sni regexp: %w", err)
+			}
+			r.sni = append(r.sni, re)
+		}
+
+		rcfg := cfg
+		rcfg.TargetAddr = rc.Target
+		rcfg.Targets = nil
+		targets, err := base.NewTargets(rcfg, parseTarget, logger)
+		if err != nil {
+			return nil, fmt.Errorf("can't create targets: %w", err)
+		}
+		r.targets = targets
+
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+type Proxy struct {
+	*base.Proxy
+
+	Targets    *base.Targets[netip.AddrPort]
+	ActionAddr netip.AddrPort
+
+	routes   []route
+	listener net.Listener
+}
+
+func (p *Proxy) Start() error {
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+	return p.StartListener(l)
+}
+
+// StartListener starts serving connections accepted by l.
+func (p *Proxy) StartListener(l net.Listener) error {
+	p.listener = p.Listener(l)
+
+	p.Targets.StartHealthChecks()
+	for _, r := range p.routes {
+		r.targets.StartHealthChecks()
+	}
+	p.WG.Add(1)
+	go p.serve()
+	return nil
+}
+
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.Closing = true
+	if err := p.listener.Close(); err != nil {
+		return fmt.Errorf("can't close listener: %w", err)
+	}
+	p.Targets.StopHealthChecks()
+	for _, r := range p.routes {
+		r.targets.StopHealthChecks()
+	}
+
+	done := make(chan interface{}, 1)
+	go func() {
+		p.WG.Wait()
+		done <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		return base.ErrShutdownTimeout
+	case <-done:
+		break
+	}
+	return nil
+}
+
+// returns true if need return from func.
+func (p *Proxy) processVerdict(
+	src net.Conn,
+	data []byte,
+	logger zerolog.Logger,
+) bool {
+	switch p.RejectAction() {
+	case common.RejectActionProxy:
+		p.splice(src, p.ActionAddr, data, logger)
+		return true
+	case common.RejectActionDrop:
+		return true
+	default:
+		logger.Warn().Msg("Request was filtered, but action is none")
+		return false
+	}
+}
+
+// read ClientHello without TLS termination. Returns read data,
+// so it can be forwarded as is.
+func (p *Proxy) readHello(
+	src net.Conn,
+) ([]byte, *tlshello.ClientHello, error) {
+	_ = src.SetReadDeadline(time.Now().Add(p.Config.Timeout))
+	defer func() {
+		_ = src.SetReadDeadline(time.Time{})
+	}()
+
+	var (
+		data []byte
+		buf  = make([]byte, BufSize)
+	)
+	for {
+		n, err := src.Read(buf)
+		data = append(data, buf[:n]...)
+		hello, perr := tlshello.Parse(data)
+		if !errors.Is(perr, tlshello.ErrIncomplete) {
+			return data, hello, perr //nolint: wrapcheck // tlshello errors
+		}
+		if err != nil {
+			return data, nil, fmt.Errorf("can't read client hello: %w", err)
+		}
+	}
+}
+
+// choose targets by SNI, default targets are used if no route matched.
+func (p *Proxy) getTargets(
+	hello *tlshello.ClientHello,
+) *base.Targets[netip.AddrPort] {
+	if hello.ServerName == "" {
+		return p.Targets
+	}
+	for _, r := range p.routes {
+		for _, re := range r.sni {
+			if re.MatchString(hello.ServerName) {
+				return r.targets
+			}
+		}
+	}
+	return p.Targets
+}
+
+// connect to addr, send already read data and pass all other data
+// in both directions as is.
+func (p *Proxy) splice(
+	src net.Conn,
+	addr netip.AddrPort,
+	data []byte,
+	logger zerolog.Logger,
+) {
+	dst, err := net.DialTimeout("tcp", addr.String(), p.Config.Timeout)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to connect to target")
+		return
+	}
+	defer dst.Close()
+
+	_ = dst.SetWriteDeadline(time.Now().Add(p.Config.Timeout))
+	if _, err = dst.Write(data); err != nil {
+		logger.Error().Err(err).Msg("Can't write client hello")
+		return
+	}
+
+	base.Pipe(src, dst, p.Config.Timeout, logger)
+}
+
+func (p *Proxy) handleConnection(src net.Conn) {
+	defer p.WG.Done()
+	defer src.Close()
+
+	from := base.NetAddrToNetipAddrPort(src.RemoteAddr()).Addr().Unmap()
+	logger := p.Logger.With().
+		Stringer("from", from).
+		Logger()
+
+	logger.Info().Msg("New request")
+
+	// client hello isn't read yet, so it's passed to decoy as is.
+	// Connection over the limits is closed even if action is "none".
+	release, ok := p.Acquire(from, logger)
+	defer release()
+	if !ok {
+		if p.RejectAction() != common.RejectActionNone {
+			p.processVerdict(src, nil, logger)
+		}
+		return
+	}
+
+	// TLS server would reject anything but ClientHello, so it's never
+	// forwarded to target.
+	data, hello, err := p.readHello(src)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Can't parse client hello, dropping")
+		return
+	}
+	logger = logger.With().Str("sni", hello.ServerName).Logger()
+	logger.Info().
+		Strs("alpn", hello.ALPN).
+		Str("ja3", hello.JA3Hash()).
+		Str("ja4", hello.JA4()).
+		Msg("Client hello")
+	e := &wrapper.TLSHello{Hello: hello, From: from}
+
+	if !p.RunFilters(e, logger) && p.processVerdict(src, data, logger) {
+		return
+	}
+
+	t, ok := p.getTargets(hello).Next(from)
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		p.processVerdict(src, data, logger)
+		return
+	}
+	logger = logger.With().Stringer("target", t).Logger()
+
+	p.splice(src, t.Value, data, logger)
+}
+
+func (p *Proxy) serve() {
+	defer p.WG.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if !p.Closing {
+				p.Fail(fmt.Errorf("can't accept connection: %w", err))
+			}
+			return
+		}
+
+		p.WG.Add(1)
+		go p.handleConnection(conn)
+	}
+}
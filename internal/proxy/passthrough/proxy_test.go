@@ -0,0 +1,354 @@
+package passthrough
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// start target which counts accepted connections and echoes data.
+func newTarget(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen target")
+	t.Cleanup(func() { l.Close() })
+
+	dialed := atomic.NewInt64(0)
+	go func() {
+		for {
+			conn, aerr := l.Accept()
+			if aerr != nil {
+				return
+			}
+			dialed.Inc()
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String(), dialed
+}
+
+// sniRule fires if ClientHello server name contains substr.
+type sniRule struct {
+	substr string
+}
+
+func (r sniRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r sniRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	hello, err := e.GetClientHello()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	return strings.Contains(hello.ServerName, r.substr), nil
+}
+
+func (r sniRule) String() string {
+	return "sni"
+}
+
+// record ClientHello sent by crypto/tls client.
+func recordHello(t *testing.T, serverName string) []byte {
+	t.Helper()
+
+	client, server := net.Pipe()
+	go func() {
+		//nolint: errcheck // handshake fails after server closes pipe
+		tls.Client(client, &tls.Config{
+			ServerName: serverName,
+			//nolint: gosec // client is used only to generate hello
+			InsecureSkipVerify: true,
+		}).Handshake()
+		client.Close()
+	}()
+
+	var data []byte
+	buf := make([]byte, BufSize)
+	for {
+		n, err := server.Read(buf)
+		data = append(data, buf[:n]...)
+		if _, perr := tlshello.Parse(data); perr == nil || err != nil {
+			break
+		}
+	}
+	server.Close()
+	return data
+}
+
+// send data to proxy and return data echoed by target, empty if
+// connection is dropped.
+func sendData(t *testing.T, p *Proxy, data []byte, closeWrite bool) []byte {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", p.listener.Addr().String())
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+	if closeWrite {
+		require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	}
+	got := make([]byte, len(data))
+	n, err := io.ReadFull(conn, got)
+	if n == 0 {
+		require.ErrorIs(t, err, io.EOF)
+	}
+	return got[:n]
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	rs map[string]rules.Rule,
+	killed bool,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.Timeout = time.Second
+	p, err := NewProxy(cfg, &rules.RuleSet{Rules: rs}, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen proxy")
+	require.NoError(t, p.StartListener(l))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+func TestProxy_EngagementKilled(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		killed bool
+		want   bool
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   true,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+			want:   false,
+		},
+		{
+			name:   "killed drop",
+			action: common.RejectActionDrop,
+			killed: true,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+			}, nil, tt.killed)
+
+			conn, err := net.Dial("tcp", p.listener.Addr().String())
+			require.NoError(t, err, "can't connect to proxy")
+			defer conn.Close()
+
+			// target isn't a TLS server, so handshake fails anyway
+			_ = conn.SetDeadline(time.Now().Add(time.Second))
+			err = tls.Client(conn, &tls.Config{
+				ServerName: "example.test",
+				//nolint: gosec // test
+				InsecureSkipVerify: true,
+			}).Handshake()
+			require.Error(t, err)
+			if !tt.want {
+				require.ErrorIs(t, err, io.EOF)
+			}
+			require.Equal(t, tt.want, dialed.Load() != 0)
+		})
+	}
+}
+
+func TestProxy_Routes(t *testing.T) {
+	target, dialed := newTarget(t)
+	api, apiDialed := newTarget(t)
+	p := newTestProxy(t, common.ProxyConfig{
+		TargetAddr: target,
+		SNIRoutes: []common.SNIRoute{
+			{SNI: []string{`^api\.example\.com$`}, Target: api},
+		},
+		RuleSettings: common.RuleSettings{
+			RejectAction: common.RejectActionDrop,
+		},
+	}, nil, false)
+
+	tests := []struct {
+		name    string
+		sni     string
+		wantAPI bool
+	}{
+		{
+			name:    "route",
+			sni:     "api.example.com",
+			wantAPI: true,
+		},
+		{
+			name: "fallback",
+			sni:  "cdn.api.example.com",
+		},
+		{
+			name: "no sni",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialedBefore, apiBefore := dialed.Load(), apiDialed.Load()
+
+			// original ClientHello is spliced as is
+			hello := recordHello(t, tt.sni)
+			require.Equal(t, hello, sendData(t, p, hello, false))
+			require.Equal(t, tt.wantAPI, apiDialed.Load() != apiBefore)
+			require.Equal(t, !tt.wantAPI, dialed.Load() != dialedBefore)
+		})
+	}
+}
+
+func TestProxy_Filter(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		sni        string
+		wantTarget bool
+		wantDecoy  bool
+	}{
+		{
+			name:       "accepted",
+			action:     common.RejectActionDrop,
+			sni:        "example.com",
+			wantTarget: true,
+		},
+		{
+			name:   "rejected drop",
+			action: common.RejectActionDrop,
+			sni:    "scanner.example.com",
+		},
+		{
+			name:      "rejected proxy",
+			action:    common.RejectActionProxy,
+			sni:       "scanner.example.com",
+			wantDecoy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			decoy, decoyDialed := newTarget(t)
+			cfg := common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+				Filters: []common.Filter{
+					{Rule: "sni", Action: common.FilterActionReject},
+				},
+			}
+			if tt.action == common.RejectActionProxy {
+				cfg.RuleSettings.RejectURL = decoy
+			}
+			p := newTestProxy(t, cfg, map[string]rules.Rule{
+				"sni": sniRule{substr: "scanner"},
+			}, false)
+
+			hello := recordHello(t, tt.sni)
+			got := sendData(t, p, hello, false)
+			if tt.wantTarget || tt.wantDecoy {
+				require.Equal(t, hello, got)
+			} else {
+				require.Empty(t, got)
+			}
+			require.Equal(t, tt.wantTarget, dialed.Load() != 0)
+			require.Equal(t, tt.wantDecoy, decoyDialed.Load() != 0)
+		})
+	}
+}
+
+func TestProxy_InvalidHello(t *testing.T) {
+	hello := recordHello(t, "example.com")
+	tests := []struct {
+		name       string
+		data       []byte
+		closeWrite bool
+	}{
+		{
+			name: "not tls",
+			data: []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		},
+		{
+			name: "not hello",
+			data: []byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00},
+		},
+		{
+			name:       "truncated",
+			data:       hello[:len(hello)/2],
+			closeWrite: true,
+		},
+		{
+			// timeout exceeded before hello is complete
+			name: "incomplete",
+			data: hello[:len(hello)/2],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			// "none" action would pass rejected connections to target
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionNone,
+				},
+			}, nil, false)
+
+			require.Empty(t, sendData(t, p, tt.data, tt.closeWrite))
+			require.Zero(t, dialed.Load())
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package passthrough
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+)
+
+// health check probe uses https, because targets are TLS servers.
+func parseTarget(addr string) (netip.AddrPort, *url.URL, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf(
+			"can't parse AddrPort: %w",
+			err,
+		)
+	}
+	return ap, &url.URL{Scheme: "https", Host: ap.String()}, nil
+}
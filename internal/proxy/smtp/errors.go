@@ -0,0 +1,21 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNegativeMaxSize = errors.New("\"max_size\" can't be negative")
+)
+
+type InvalidRejectReplyError struct {
+	reply string
+}
+
+func (e InvalidRejectReplyError) Error() string {
+	return fmt.Sprintf(
+		"reject reply must start with 4xx or 5xx code: %s",
+		e.reply,
+	)
+}
@@ -0,0 +1,303 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+)
+
+const (
+	ProxyType = "smtp"
+
+	DefaultMaxSize     = 25 * 1024 * 1024
+	DefaultRejectReply = "550 5.7.1 Message rejected"
+)
+
+var (
+	AllowedActions = []string{
+		common.RejectActionReply,
+		common.RejectActionProxy,
+		common.RejectActionDrop,
+		common.RejectActionNone,
+	}
+)
+
+func NewProxy(
+	cfg common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+) (*Proxy, error) {
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
+	if err != nil {
+		return nil, fmt.Errorf("can't create base proxy: %w", err)
+	}
+
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create targets: %w", err)
+	}
+
+	var action netip.AddrPort
+	if cfg.RuleSettings.RejectAction == common.RejectActionProxy {
+		action, err = netip.ParseAddrPort(cfg.RuleSettings.RejectURL)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse action address: %w", err)
+		}
+	}
+
+	hostname := cfg.SMTP.Hostname
+	if hostname == "" {
+		if hostname, err = os.Hostname(); err != nil {
+			hostname = "localhost"
+		}
+	}
+
+	reply := cfg.SMTP.RejectReply
+	if reply == "" {
+		reply = DefaultRejectReply
+	}
+	if !regexp.MustCompile(`^[45]\d\d( |$)`).MatchString(reply) ||
+		strings.ContainsAny(reply, "\r\n") {
+		return nil, &InvalidRejectReplyError{reply: reply}
+	}
+
+	maxSize := cfg.SMTP.MaxSize
+	switch {
+	case maxSize < 0:
+		return nil, ErrNegativeMaxSize
+	case maxSize == 0:
+		maxSize = DefaultMaxSize
+	}
+
+	p := &Proxy{
+		Proxy:      baseProxy,
+		Targets:    targets,
+		ActionAddr: action,
+
+		hostname:    hostname,
+		rejectReply: reply,
+		maxSize:     maxSize,
+	}
+
+	return p, nil
+}
+
+type Proxy struct {
+	*base.Proxy
+
+	Targets    *base.Targets[netip.AddrPort]
+	ActionAddr netip.AddrPort
+
+	hostname    string
+	rejectReply string
+	maxSize     int
+	listener    net.Listener
+}
+
+func (p *Proxy) Start() error {
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+	return p.StartListener(l)
+}
+
+// StartListener starts serving connections accepted by l.
+func (p *Proxy) StartListener(l net.Listener) error {
+	p.listener = p.Listener(l)
+
+	p.Targets.StartHealthChecks()
+	p.WG.Add(1)
+	go p.serve()
+	return nil
+}
+
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.Closing = true
+	if err := p.listener.Close(); err != nil {
+		return fmt.Errorf("can't close listener: %w", err)
+	}
+	p.Targets.StopHealthChecks()
+
+	done := make(chan interface{}, 1)
+	go func() {
+		p.WG.Wait()
+		done <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		return base.ErrShutdownTimeout
+	case <-done:
+		break
+	}
+	return nil
+}
+
+// returns true if need return from func.
+func (p *Proxy) processVerdict(
+	s *session,
+	m *wrapper.SMTPMessage,
+	logger zerolog.Logger,
+) bool {
+	switch p.RejectAction() {
+	case common.RejectActionReply:
+		s.reply(p.rejectReply)
+		return true
+	case common.RejectActionProxy:
+		p.deliver(s, p.ActionAddr, m, logger)
+		return true
+	case common.RejectActionDrop:
+		s.close()
+		return true
+	default:
+		logger.Warn().Msg("Request was filtered, but action is none")
+		return false
+	}
+}
+
+// apply reject action before greeting is sent, so decoy talks to client
+// directly. Connection is dropped by caller for "drop" and "none".
+func (p *Proxy) rejectConn(s *session, logger zerolog.Logger) {
+	switch p.RejectAction() {
+	case common.RejectActionReply:
+		s.reply(p.rejectReply)
+	case common.RejectActionProxy:
+		base.Forward(s.conn, p.ActionAddr.String(), p.Config.Timeout, logger)
+	}
+}
+
+// filter message received after DATA and deliver it.
+func (p *Proxy) handleMessage(s *session, data []byte) {
+	m := wrapper.NewSMTPMessage(s.envelope(), data, s.from)
+	logger := s.logger.With().
+		Str("helo", m.Envelope.Helo).
+		Str("mail_from", m.Envelope.MailFrom).
+		Strs("rcpt_to", m.Envelope.RcptTo).
+		Logger()
+
+	logger.Info().Int("size", len(data)).Msg("New message")
+
+	if !p.RunFilters(m, logger) && p.processVerdict(s, m, logger) {
+		return
+	}
+
+	t, ok := p.Targets.Next(s.from)
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		if p.Config.RuleSettings.RejectAction == common.RejectActionNone {
+			s.reply("451 4.3.0 Error: queue file write error")
+			return
+		}
+		p.processVerdict(s, m, logger)
+		return
+	}
+	logger = logger.With().Stringer("target", t).Logger()
+
+	p.deliver(s, t.Value, m, logger)
+}
+
+// relay message to addr and reply to client with relay result.
+func (p *Proxy) deliver(
+	s *session,
+	addr netip.AddrPort,
+	m *wrapper.SMTPMessage,
+	logger zerolog.Logger,
+) {
+	err := p.relay(addr, m)
+	var te *textproto.Error
+	switch {
+	case err == nil:
+		logger.Debug().Msg("Message relayed")
+		s.reply("250 2.0.0 Ok: queued")
+	case errors.As(err, &te):
+		// upstream reply is forwarded as is, so client may retry later
+		logger.Warn().Err(err).Msg("Upstream rejected message")
+		msg, _, _ := strings.Cut(te.Msg, "\n")
+		s.reply(fmt.Sprintf("%d %s", te.Code, msg))
+	default:
+		logger.Error().Err(err).Msg("Can't relay message")
+		s.reply("451 4.3.0 Error: queue file write error")
+	}
+}
+
+// relay message to upstream MTA using STARTTLS if it's supported.
+func (p *Proxy) relay(addr netip.AddrPort, m *wrapper.SMTPMessage) error {
+	conn, err := net.DialTimeout("tcp", addr.String(), p.Config.Timeout)
+	if err != nil {
+		return fmt.Errorf("can't connect to upstream: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(p.Config.Timeout))
+
+	c, err := smtp.NewClient(conn, addr.Addr().String())
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("can't read upstream greeting: %w", err)
+	}
+	defer c.Close()
+
+	if err = c.Hello(m.Envelope.Helo); err != nil {
+		return fmt.Errorf("can't send HELO: %w", err)
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		//nolint: gosec // upstream MTA usually has selfsigned cert
+		err = c.StartTLS(&tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return fmt.Errorf("can't start upstream tls: %w", err)
+		}
+	}
+	if err = c.Mail(m.Envelope.MailFrom); err != nil {
+		return fmt.Errorf("can't send MAIL FROM: %w", err)
+	}
+	for _, rcpt := range m.Envelope.RcptTo {
+		if err = c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("can't send RCPT TO: %w", err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("can't send DATA: %w", err)
+	}
+	if _, err = w.Write(m.Data); err != nil {
+		return fmt.Errorf("can't write message: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("can't finish message: %w", err)
+	}
+	_ = c.Quit()
+	return nil
+}
+
+func (p *Proxy) serve() {
+	defer p.WG.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if !p.Closing {
+				p.Fail(fmt.Errorf("can't accept connection: %w", err))
+			}
+			return
+		}
+
+		p.WG.Add(1)
+		go p.handleConnection(conn)
+	}
+}
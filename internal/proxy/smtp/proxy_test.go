@@ -0,0 +1,356 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// senderRule fires if MAIL FROM address contains substr.
+type senderRule struct {
+	substr string
+}
+
+func (r senderRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r senderRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	env, err := e.GetSMTPEnvelope()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	return strings.Contains(env.MailFrom, r.substr), nil
+}
+
+func (r senderRule) String() string {
+	return "sender"
+}
+
+// start upstream MTA which rejects recipients containing "unknown" and
+// sends received messages to channel.
+func newUpstream(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen upstream")
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan string, 16)
+	go func() {
+		for {
+			conn, aerr := l.Accept()
+			if aerr != nil {
+				return
+			}
+			go serveUpstream(conn, messages)
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func serveUpstream(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 upstream ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "RCPT":
+			if strings.Contains(arg, "unknown") {
+				_ = text.PrintfLine("550 5.1.1 User unknown")
+				continue
+			}
+			_ = text.PrintfLine("250 Ok")
+		case "DATA":
+			_ = text.PrintfLine("354 Go ahead")
+			data, rerr := io.ReadAll(text.DotReader())
+			if rerr != nil {
+				return
+			}
+			messages <- string(data)
+			_ = text.PrintfLine("250 Queued")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("250 Ok")
+		}
+	}
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	rs map[string]rules.Rule,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.Timeout = time.Second
+	cfg.SMTP.Hostname = "mx.example.com"
+	p, err := NewProxy(cfg, &rules.RuleSet{Rules: rs}, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen proxy")
+	require.NoError(t, p.StartListener(l))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+// send message through proxy and return error of the first failed step.
+func sendMail(p *Proxy, from string, to string, msg string) error {
+	conn, err := net.Dial("tcp", p.listener.Addr().String())
+	if err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	c, err := smtp.NewClient(conn, "mx.example.com")
+	if err != nil {
+		conn.Close()
+		return err //nolint: wrapcheck // test
+	}
+	defer c.Close()
+
+	if err = c.Hello("client.example.com"); err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	if err = c.Mail(from); err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	if err = c.Rcpt(to); err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	if err = w.Close(); err != nil {
+		return err //nolint: wrapcheck // test
+	}
+	return c.Quit() //nolint: wrapcheck // test
+}
+
+func TestNewProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		smtp    common.SMTP
+		wantErr error
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "custom reply",
+			smtp: common.SMTP{RejectReply: "451 4.7.1 Try again later"},
+		},
+		{
+			name:    "success reply",
+			smtp:    common.SMTP{RejectReply: "250 Ok"},
+			wantErr: &InvalidRejectReplyError{reply: "250 Ok"},
+		},
+		{
+			name:    "multiline reply",
+			smtp:    common.SMTP{RejectReply: "550 a\r\n250 b"},
+			wantErr: &InvalidRejectReplyError{reply: "550 a\r\n250 b"},
+		},
+		{
+			name:    "negative max size",
+			smtp:    common.SMTP{MaxSize: -1},
+			wantErr: ErrNegativeMaxSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			defer db.DB.Close()
+			eng, err := engagement.New(common.Engagement{}, db)
+			require.NoError(t, err, "can't create engagement")
+
+			_, err = NewProxy(common.ProxyConfig{
+				Name:       "test",
+				Type:       ProxyType,
+				TargetAddr: "127.0.0.1:25",
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionReply,
+				},
+				SMTP: tt.smtp,
+			}, &rules.RuleSet{}, db, eng)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		arg    string
+		want   string
+		wantOK bool
+	}{
+		{arg: "FROM:<a@example.com>", want: "a@example.com", wantOK: true},
+		{arg: "from: <a@example.com>", want: "a@example.com", wantOK: true},
+		{
+			arg:    "FROM:<a@example.com> SIZE=100 BODY=8BITMIME",
+			want:   "a@example.com",
+			wantOK: true,
+		},
+		{arg: "FROM:<>", wantOK: true},
+		{arg: "FROM:a@example.com"},
+		{arg: "FROM:<a@example.com"},
+		{arg: "FROM:<a b@example.com>"},
+		{arg: "TO:<a@example.com>"},
+		{arg: "FR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, ok := parsePath(tt.arg, "FROM:")
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProxy_Message(t *testing.T) {
+	decoy, decoyMessages := newUpstream(t)
+	tests := []struct {
+		name      string
+		action    string
+		from      string
+		to        string
+		maxSize   int
+		wantCode  int
+		wantDrop  bool
+		wantRelay bool
+		wantDecoy bool
+	}{
+		{
+			name:      "accepted",
+			action:    common.RejectActionReply,
+			from:      "user@example.com",
+			to:        "victim@example.com",
+			wantRelay: true,
+		},
+		{
+			name:     "rejected reply",
+			action:   common.RejectActionReply,
+			from:     "scanner@example.com",
+			to:       "victim@example.com",
+			wantCode: 550,
+		},
+		{
+			name:      "rejected proxy",
+			action:    common.RejectActionProxy,
+			from:      "scanner@example.com",
+			to:        "victim@example.com",
+			wantDecoy: true,
+		},
+		{
+			name:     "rejected drop",
+			action:   common.RejectActionDrop,
+			from:     "scanner@example.com",
+			to:       "victim@example.com",
+			wantDrop: true,
+		},
+		{
+			// upstream reply is forwarded to client
+			name:     "upstream rejected",
+			action:   common.RejectActionReply,
+			from:     "user@example.com",
+			to:       "unknown@example.com",
+			wantCode: 550,
+		},
+		{
+			name:     "too big",
+			action:   common.RejectActionReply,
+			from:     "user@example.com",
+			to:       "victim@example.com",
+			maxSize:  8,
+			wantCode: 552,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, messages := newUpstream(t)
+			cfg := common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+				Filters: []common.Filter{
+					{Rule: "sender", Action: common.FilterActionReject},
+				},
+				SMTP: common.SMTP{MaxSize: tt.maxSize},
+			}
+			if tt.action == common.RejectActionProxy {
+				cfg.RuleSettings.RejectURL = decoy
+			}
+			p := newTestProxy(t, cfg, map[string]rules.Rule{
+				"sender": senderRule{substr: "scanner"},
+			})
+
+			err := sendMail(p, tt.from, tt.to, "Subject: test\r\n\r\nbody\r\n")
+			var te *textproto.Error
+			switch {
+			case tt.wantCode != 0:
+				require.ErrorAs(t, err, &te)
+				require.Equal(t, tt.wantCode, te.Code)
+			case tt.wantDrop:
+				require.Error(t, err)
+				require.False(t, errors.As(err, &te), err)
+			default:
+				require.NoError(t, err)
+			}
+
+			select {
+			case m := <-messages:
+				require.True(t, tt.wantRelay, "message must not be relayed")
+				require.Equal(t, "Subject: test\n\nbody\n", m)
+			case m := <-decoyMessages:
+				require.True(t, tt.wantDecoy, "message must not reach decoy")
+				require.Equal(t, "Subject: test\n\nbody\n", m)
+			default:
+				require.False(t, tt.wantRelay || tt.wantDecoy)
+			}
+		})
+	}
+}
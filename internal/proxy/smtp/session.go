@@ -0,0 +1,283 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+)
+
+const maxRecipients = 100
+
+// session is a state of SMTP dialogue with one client.
+type session struct {
+	p      *Proxy
+	conn   net.Conn
+	text   *textproto.Conn
+	from   netip.Addr
+	logger zerolog.Logger
+
+	tls      bool
+	helo     string
+	hasMail  bool
+	mailFrom string
+	rcptTo   []string
+}
+
+func (p *Proxy) handleConnection(conn net.Conn) {
+	defer p.WG.Done()
+
+	from := base.NetAddrToNetipAddrPort(conn.RemoteAddr()).Addr().Unmap()
+	s := &session{
+		p:    p,
+		conn: conn,
+		text: textproto.NewConn(conn),
+		from: from,
+		logger: p.Logger.With().
+			Stringer("from", from).
+			Logger(),
+	}
+	defer s.close()
+
+	s.logger.Info().Msg("New request")
+
+	// connection over the limits is closed even if action is "none"
+	release, ok := p.Acquire(from, s.logger)
+	defer release()
+	if !ok {
+		p.rejectConn(s, s.logger)
+		return
+	}
+
+	s.reply("220 " + p.hostname + " ESMTP")
+	for {
+		_ = s.conn.SetReadDeadline(time.Now().Add(p.Config.Timeout))
+		line, err := s.text.ReadLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.logger.Debug().Err(err).Msg("Can't read command")
+			}
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		if !s.handleCommand(strings.ToUpper(verb), arg) {
+			return
+		}
+	}
+}
+
+// returns false if session is over.
+func (s *session) handleCommand(verb string, arg string) bool {
+	switch verb {
+	case "HELO", "EHLO":
+		s.hello(verb, arg)
+	case "STARTTLS":
+		return s.startTLS(arg)
+	case "MAIL":
+		s.mail(arg)
+	case "RCPT":
+		s.rcpt(arg)
+	case "DATA":
+		return s.data()
+	case "RSET":
+		s.reset()
+		s.reply("250 2.0.0 Ok")
+	case "NOOP":
+		s.reply("250 2.0.0 Ok")
+	case "VRFY":
+		s.reply("502 5.5.1 VRFY command is disabled")
+	case "QUIT":
+		s.reply("221 2.0.0 Bye")
+		return false
+	default:
+		s.reply("502 5.5.2 Error: command not recognized")
+	}
+	return true
+}
+
+func (s *session) hello(verb string, arg string) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		s.reply("501 5.5.4 Syntax: " + verb + " hostname")
+		return
+	}
+	s.reset()
+	s.helo = arg
+
+	if verb == "HELO" {
+		s.reply("250 " + s.p.hostname)
+		return
+	}
+	lines := []string{
+		s.p.hostname,
+		fmt.Sprintf("SIZE %d", s.p.maxSize),
+		"8BITMIME",
+	}
+	if s.p.TLSConfig != nil && !s.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	lines = append(lines, "ENHANCEDSTATUSCODES")
+	for i, l := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		s.reply("250" + sep + l)
+	}
+}
+
+// returns false if session is over.
+func (s *session) startTLS(arg string) bool {
+	switch {
+	case s.p.TLSConfig == nil:
+		s.reply("502 5.5.1 Error: command not implemented")
+		return true
+	case s.tls:
+		s.reply("554 5.5.1 Error: TLS already active")
+		return true
+	case arg != "":
+		s.reply("501 5.5.4 Syntax: STARTTLS")
+		return true
+	case s.text.R.Buffered() > 0:
+		// commands sent before handshake must not be treated as
+		// sent over TLS
+		s.reply("554 5.5.1 Error: pipelined commands after STARTTLS")
+		return false
+	}
+
+	s.reply("220 2.0.0 Ready to start TLS")
+	conn := tls.Server(s.conn, s.p.TLSConfig)
+	_ = conn.SetDeadline(time.Now().Add(s.p.Config.Timeout))
+	if err := conn.Handshake(); err != nil {
+		s.logger.Warn().Err(err).Msg("TLS handshake error")
+		return false
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	s.conn = conn
+	s.text = textproto.NewConn(conn)
+	s.tls = true
+	s.helo = ""
+	s.reset()
+	return true
+}
+
+func (s *session) mail(arg string) {
+	switch {
+	case s.helo == "":
+		s.reply("503 5.5.1 Error: send HELO/EHLO first")
+		return
+	case s.hasMail:
+		s.reply("503 5.5.1 Error: nested MAIL command")
+		return
+	}
+	addr, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply("501 5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+	s.hasMail = true
+	s.mailFrom = addr
+	s.reply("250 2.1.0 Ok")
+}
+
+func (s *session) rcpt(arg string) {
+	if !s.hasMail {
+		s.reply("503 5.5.1 Error: need MAIL command")
+		return
+	}
+	addr, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" {
+		s.reply("501 5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+	if len(s.rcptTo) >= maxRecipients {
+		s.reply("452 4.5.3 Error: too many recipients")
+		return
+	}
+	s.rcptTo = append(s.rcptTo, addr)
+	s.reply("250 2.1.5 Ok")
+}
+
+// returns false if session is over.
+func (s *session) data() bool {
+	if len(s.rcptTo) == 0 {
+		s.reply("503 5.5.1 Error: need RCPT command")
+		return true
+	}
+	s.reply("354 End data with <CR><LF>.<CR><LF>")
+
+	_ = s.conn.SetReadDeadline(time.Now().Add(s.p.Config.Timeout))
+	r := s.text.DotReader()
+	data, err := io.ReadAll(io.LimitReader(r, int64(s.p.maxSize)+1))
+	if err == nil && len(data) > s.p.maxSize {
+		_, err = io.Copy(io.Discard, r)
+		if err == nil {
+			s.reply("552 5.3.4 Error: message file too big")
+			s.reset()
+			return true
+		}
+	}
+	if err != nil {
+		s.logger.Debug().Err(err).Msg("Can't read message")
+		return false
+	}
+
+	s.p.handleMessage(s, data)
+	s.reset()
+	return true
+}
+
+func (s *session) envelope() *wrapper.SMTPEnvelope {
+	return &wrapper.SMTPEnvelope{
+		Helo:     s.helo,
+		MailFrom: s.mailFrom,
+		RcptTo:   s.rcptTo,
+	}
+}
+
+// reset mail transaction.
+func (s *session) reset() {
+	s.hasMail = false
+	s.mailFrom = ""
+	s.rcptTo = nil
+}
+
+func (s *session) reply(line string) {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.p.Config.Timeout))
+	if err := s.text.PrintfLine("%s", line); err != nil {
+		s.logger.Debug().Err(err).Msg("Can't write reply")
+	}
+}
+
+func (s *session) close() {
+	s.conn.Close()
+}
+
+// parse "FROM:<address> PARAMS" argument and return address.
+// Parameters (e.g. SIZE) are ignored.
+func parsePath(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) ||
+		!strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	addr, _, ok := strings.Cut(path[1:], ">")
+	if !ok || strings.ContainsAny(addr, " <") {
+		return "", false
+	}
+	return addr, true
+}
@@ -0,0 +1,18 @@
+package smtp
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+)
+
+func parseTarget(addr string) (netip.AddrPort, *url.URL, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf(
+			"can't parse AddrPort: %w",
+			err,
+		)
+	}
+	return ap, &url.URL{Scheme: "http", Host: ap.String()}, nil
+}
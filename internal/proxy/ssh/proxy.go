@@ -0,0 +1,329 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/rs/zerolog"
+	"go.uber.org/atomic"
+)
+
+const (
+	ProxyType = "ssh"
+
+	BufSize = 4 * 1024
+)
+
+var (
+	AllowedActions = []string{
+		common.RejectActionProxy,
+		common.RejectActionDrop,
+		common.RejectActionNone,
+	}
+)
+
+func NewProxy(
+	cfg common.ProxyConfig,
+	rs *rules.RuleSet,
+	db *database.DB,
+	eng *engagement.Engagement,
+) (*Proxy, error) {
+	if len(cfg.TLS) > 0 {
+		return nil, base.ErrTLSUnsupported
+	}
+
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
+	if err != nil {
+		return nil, fmt.Errorf("can't create base proxy: %w", err)
+	}
+
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create targets: %w", err)
+	}
+
+	if cfg.SSH.Banner != "" {
+		if _, err = hassh.ParseIdent(cfg.SSH.Banner); err != nil {
+			return nil, fmt.Errorf("can't parse banner: %w", err)
+		}
+	}
+
+	var action netip.AddrPort
+	if cfg.RuleSettings.RejectAction == common.RejectActionProxy {
+		action, err = netip.ParseAddrPort(cfg.RuleSettings.RejectURL)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse action address: %w", err)
+		}
+	}
+
+	p := &Proxy{
+		Proxy:      baseProxy,
+		Targets:    targets,
+		ActionAddr: action,
+
+		banner: atomic.NewString(cfg.SSH.Banner),
+	}
+
+	return p, nil
+}
+
+type Proxy struct {
+	*base.Proxy
+
+	Targets    *base.Targets[netip.AddrPort]
+	ActionAddr netip.AddrPort
+
+	// server identification string sent to clients. Client's KEXINIT
+	// is sent only after it, so it can't be taken from target after
+	// filtering. Configured or cached from targets.
+	banner   *atomic.String
+	listener net.Listener
+}
+
+func (p *Proxy) Start() error {
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("can't start listening: %w", err)
+	}
+	return p.StartListener(l)
+}
+
+// StartListener starts serving connections accepted by l.
+func (p *Proxy) StartListener(l net.Listener) error {
+	p.listener = p.Listener(l)
+
+	p.Targets.StartHealthChecks()
+	p.WG.Add(1)
+	go p.serve()
+	return nil
+}
+
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.Closing = true
+	if err := p.listener.Close(); err != nil {
+		return fmt.Errorf("can't close listener: %w", err)
+	}
+	p.Targets.StopHealthChecks()
+
+	done := make(chan interface{}, 1)
+	go func() {
+		p.WG.Wait()
+		done <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		return base.ErrShutdownTimeout
+	case <-done:
+		break
+	}
+	return nil
+}
+
+// returns true if need return from func.
+func (p *Proxy) processVerdict(
+	src net.Conn,
+	data []byte,
+	logger zerolog.Logger,
+) bool {
+	switch p.RejectAction() {
+	case common.RejectActionProxy:
+		p.splice(src, p.ActionAddr, data, false, logger)
+		return true
+	case common.RejectActionDrop:
+		return true
+	default:
+		logger.Warn().Msg("Request was filtered, but action is none")
+		return false
+	}
+}
+
+// apply reject action before banner is sent, so decoy talks to client
+// directly. Connection is dropped by caller if action isn't "proxy".
+func (p *Proxy) rejectConn(src net.Conn, logger zerolog.Logger) {
+	if p.RejectAction() == common.RejectActionProxy {
+		base.Forward(src, p.ActionAddr.String(), p.Config.Timeout, logger)
+	}
+}
+
+// return configured or cached banner, fetch it from target if empty.
+func (p *Proxy) getBanner(from netip.Addr) (string, error) {
+	if b := p.banner.Load(); b != "" {
+		return b, nil
+	}
+
+	t, ok := p.Targets.Next(from)
+	if !ok {
+		return "", base.ErrNoTargets
+	}
+	conn, err := net.DialTimeout("tcp", t.Value.String(), p.Config.Timeout)
+	if err != nil {
+		return "", fmt.Errorf("can't connect to target: %w", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(p.Config.Timeout))
+	b, err := hassh.ReadIdent(conn)
+	if err != nil {
+		return "", fmt.Errorf("can't read target banner: %w", err)
+	}
+	p.banner.Store(b)
+	return b, nil
+}
+
+// read client identification string and KEXINIT. Returns read data,
+// so it can be forwarded as is.
+func (p *Proxy) readHandshake(
+	src net.Conn,
+) ([]byte, *hassh.Handshake, error) {
+	_ = src.SetReadDeadline(time.Now().Add(p.Config.Timeout))
+	defer func() {
+		_ = src.SetReadDeadline(time.Time{})
+	}()
+
+	var (
+		data []byte
+		buf  = make([]byte, BufSize)
+	)
+	for {
+		n, err := src.Read(buf)
+		data = append(data, buf[:n]...)
+		h, perr := hassh.Parse(data)
+		if !errors.Is(perr, hassh.ErrIncomplete) {
+			return data, h, perr //nolint: wrapcheck // hassh errors
+		}
+		if err != nil {
+			return data, nil, fmt.Errorf("can't read handshake: %w", err)
+		}
+	}
+}
+
+// connect to addr, skip its banner (client already got one), send
+// already read data and pass all other data in both directions as is.
+func (p *Proxy) splice(
+	src net.Conn,
+	addr netip.AddrPort,
+	data []byte,
+	isTarget bool,
+	logger zerolog.Logger,
+) {
+	dst, err := net.DialTimeout("tcp", addr.String(), p.Config.Timeout)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to connect to target")
+		return
+	}
+	defer dst.Close()
+
+	_ = dst.SetReadDeadline(time.Now().Add(p.Config.Timeout))
+	b, err := hassh.ReadIdent(dst)
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't read target banner")
+		return
+	}
+	if sent := p.banner.Load(); b != sent {
+		// key exchange will fail, because banner is a part of exchange hash
+		logger.Warn().
+			Str("sent", sent).
+			Str("received", b).
+			Msg("Banner mismatch")
+		if isTarget && p.Config.SSH.Banner == "" {
+			p.banner.Store(b)
+		}
+	}
+
+	_ = dst.SetWriteDeadline(time.Now().Add(p.Config.Timeout))
+	if _, err = dst.Write(data); err != nil {
+		logger.Error().Err(err).Msg("Can't write client handshake")
+		return
+	}
+
+	base.Pipe(src, dst, p.Config.Timeout, logger)
+}
+
+func (p *Proxy) handleConnection(src net.Conn) {
+	defer p.WG.Done()
+	defer src.Close()
+
+	from := base.NetAddrToNetipAddrPort(src.RemoteAddr()).Addr().Unmap()
+	logger := p.Logger.With().
+		Stringer("from", from).
+		Logger()
+
+	logger.Info().Msg("New request")
+
+	// banner may be fetched from target, so connection is rejected before
+	// if engagement isn't active. Connection over the limits is closed
+	// even if action is "none".
+	release, ok := p.Acquire(from, logger)
+	defer release()
+	if !ok || !p.EngagementActive() {
+		p.rejectConn(src, logger)
+		return
+	}
+
+	banner, err := p.getBanner(from)
+	if err != nil {
+		logger.Error().Err(err).Msg("Can't get banner")
+		return
+	}
+	_ = src.SetWriteDeadline(time.Now().Add(p.Config.Timeout))
+	if _, err = src.Write([]byte(banner + "\r\n")); err != nil {
+		logger.Error().Err(err).Msg("Can't write banner")
+		return
+	}
+
+	var e wrapper.Entity
+	data, h, err := p.readHandshake(src)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Can't parse ssh handshake")
+		e = &wrapper.RawPacket{Content: data, From: from}
+	} else {
+		logger = logger.With().Str("client", h.Ident.Raw).Logger()
+		logger.Info().
+			Str("hassh", h.KexInit.HASSH()).
+			Str("hassh_algorithms", h.KexInit.HASSHAlgorithms()).
+			Msg("Client handshake")
+		e = &wrapper.SSHHandshake{Handshake: h, From: from}
+	}
+
+	if !p.RunFilters(e, logger) && p.processVerdict(src, data, logger) {
+		return
+	}
+
+	t, ok := p.Targets.Next(from)
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		p.processVerdict(src, data, logger)
+		return
+	}
+	logger = logger.With().Stringer("target", t).Logger()
+
+	p.splice(src, t.Value, data, true, logger)
+}
+
+func (p *Proxy) serve() {
+	defer p.WG.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if !p.Closing {
+				p.Fail(fmt.Errorf("can't accept connection: %w", err))
+			}
+			return
+		}
+
+		p.WG.Add(1)
+		go p.handleConnection(conn)
+	}
+}
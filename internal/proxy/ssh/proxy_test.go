@@ -0,0 +1,271 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+const targetBanner = "SSH-2.0-OpenSSH_9.6"
+
+// identRule fires if client identification string contains substr.
+type identRule struct {
+	substr string
+}
+
+func (r identRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r identRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	h, err := e.GetSSHHandshake()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	return strings.Contains(h.Ident.Raw, r.substr), nil
+}
+
+func (r identRule) String() string {
+	return "ident"
+}
+
+func buildKexInit() []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(20) //nolint: gomnd // SSH_MSG_KEXINIT
+	payload.Write(make([]byte, 16))
+	for _, l := range [10]string{
+		"curve25519-sha256",
+		"ssh-ed25519",
+		"aes128-ctr",
+		"aes128-ctr",
+		"hmac-sha2-256",
+		"hmac-sha2-256",
+		"none",
+		"none",
+	} {
+		_ = binary.Write(&payload, binary.BigEndian, uint32(len(l)))
+		payload.WriteString(l)
+	}
+	payload.WriteByte(0)
+	payload.Write(make([]byte, 4))
+
+	padding := 8 - (payload.Len()+5)%8
+	if padding < 4 {
+		padding += 8
+	}
+
+	var packet bytes.Buffer
+	_ = binary.Write(
+		&packet,
+		binary.BigEndian,
+		uint32(payload.Len()+padding+1),
+	)
+	packet.WriteByte(byte(padding))
+	packet.Write(payload.Bytes())
+	packet.Write(make([]byte, padding))
+	return packet.Bytes()
+}
+
+func handshake(ident string) []byte {
+	return append([]byte(ident+"\r\n"), buildKexInit()...)
+}
+
+// start target which sends banner, counts accepted connections and
+// echoes data.
+func newTarget(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen target")
+	t.Cleanup(func() { l.Close() })
+
+	dialed := atomic.NewInt64(0)
+	go func() {
+		for {
+			conn, aerr := l.Accept()
+			if aerr != nil {
+				return
+			}
+			dialed.Inc()
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte(targetBanner + "\r\n"))
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String(), dialed
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	rs map[string]rules.Rule,
+	killed bool,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.Timeout = time.Second
+	p, err := NewProxy(cfg, &rules.RuleSet{Rules: rs}, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen proxy")
+	require.NoError(t, p.StartListener(l))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+// connect to proxy, read banner and send handshake. Returns banner and
+// data echoed by target (empty if connection was closed).
+func runClient(t *testing.T, p *Proxy, ident string) (string, []byte) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", p.listener.Addr().String())
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	r := bufio.NewReader(conn)
+	banner, err := r.ReadString('\n')
+	if err != nil {
+		return "", nil
+	}
+
+	data := handshake(ident)
+	_, err = conn.Write(data)
+	require.NoError(t, err)
+	echo := make([]byte, len(data))
+	n, _ := io.ReadFull(r, echo)
+	return strings.TrimRight(banner, "\r\n"), echo[:n]
+}
+
+func TestProxy_Handshake(t *testing.T) {
+	target, dialed := newTarget(t)
+	p := newTestProxy(t, common.ProxyConfig{
+		TargetAddr: target,
+		RuleSettings: common.RuleSettings{
+			RejectAction: common.RejectActionDrop,
+		},
+	}, nil, false)
+
+	// banner is fetched from target once and cached
+	for i := 1; i <= 2; i++ {
+		banner, echo := runClient(t, p, "SSH-2.0-OpenSSH_9.6")
+		require.Equal(t, targetBanner, banner)
+		require.Equal(t, handshake("SSH-2.0-OpenSSH_9.6"), echo)
+		require.Equal(t, int64(i+1), dialed.Load())
+	}
+}
+
+func TestProxy_Filter(t *testing.T) {
+	decoy, decoyDialed := newTarget(t)
+	tests := []struct {
+		name       string
+		action     string
+		ident      string
+		killed     bool
+		wantBanner string
+		wantTarget bool
+		wantDecoy  bool
+	}{
+		{
+			name:       "accepted",
+			action:     common.RejectActionDrop,
+			ident:      "SSH-2.0-OpenSSH_9.6",
+			wantBanner: "SSH-2.0-Configured",
+			wantTarget: true,
+		},
+		{
+			name:       "rejected drop",
+			action:     common.RejectActionDrop,
+			ident:      "SSH-2.0-Scanner",
+			wantBanner: "SSH-2.0-Configured",
+		},
+		{
+			name:       "rejected proxy",
+			action:     common.RejectActionProxy,
+			ident:      "SSH-2.0-Scanner",
+			wantBanner: "SSH-2.0-Configured",
+			wantDecoy:  true,
+		},
+		{
+			// banner is sent by decoy itself
+			name:       "killed proxy",
+			action:     common.RejectActionProxy,
+			ident:      "SSH-2.0-OpenSSH_9.6",
+			killed:     true,
+			wantBanner: targetBanner,
+			wantDecoy:  true,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			ident:  "SSH-2.0-OpenSSH_9.6",
+			killed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoyDialed.Store(0)
+			target, dialed := newTarget(t)
+			cfg := common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+				Filters: []common.Filter{
+					{Rule: "ident", Action: common.FilterActionReject},
+				},
+				SSH: common.SSH{Banner: "SSH-2.0-Configured"},
+			}
+			if tt.action == common.RejectActionProxy {
+				cfg.RuleSettings.RejectURL = decoy
+			}
+			p := newTestProxy(t, cfg, map[string]rules.Rule{
+				"ident": identRule{substr: "Scanner"},
+			}, tt.killed)
+
+			banner, echo := runClient(t, p, tt.ident)
+			require.Equal(t, tt.wantBanner, banner)
+			if tt.wantTarget || tt.wantDecoy {
+				require.Equal(t, handshake(tt.ident), echo)
+			} else {
+				require.Empty(t, echo)
+			}
+			require.Equal(t, tt.wantTarget, dialed.Load() != 0)
+			require.Equal(t, tt.wantDecoy, decoyDialed.Load() != 0)
+		})
+	}
+}
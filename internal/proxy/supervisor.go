@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+	// proxy running longer than stableUptime is considered recovered,
+	// so restart backoff is reset.
+	stableUptime = time.Minute
+	// live sessions of failed proxy are not waited longer.
+	failedShutdownTimeout = 5 * time.Second
+)
+
+const (
+	StateStarting   = "starting"
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateDraining   = "draining"
+	StateDrained    = "drained"
+	StateStopped    = "stopped"
+)
+
+// Health is a state of supervised proxy.
+type Health struct {
+	Name      string
+	State     string
+	Restarts  int
+	Sessions  int
+	LastError error
+	Since     time.Time
+}
+
+// supervisor starts proxy and restarts it with backoff when it reports
+// unexpected error. Proxy is recreated from config on restart.
+type supervisor struct {
+	create func() (Proxy, error)
+
+	mu     sync.Mutex
+	proxy  Proxy
+	health Health
+
+	// serializes drain and shutdown.
+	ctl sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+	// closed when draining is finished.
+	drained chan struct{}
+}
+
+func newSupervisor(
+	name string,
+	p Proxy,
+	create func() (Proxy, error),
+) *supervisor {
+	return &supervisor{
+		create: create,
+		proxy:  p,
+		health: Health{
+			Name:  name,
+			State: StateStopped,
+			Since: time.Now(),
+		},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+func (s *supervisor) String() string {
+	return s.current().String()
+}
+
+func (s *supervisor) current() Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.proxy
+}
+
+func (s *supervisor) getHealth() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.health
+	if h.State == StateRunning || h.State == StateDraining {
+		h.Sessions = s.proxy.Sessions()
+	}
+	return h
+}
+
+func (s *supervisor) setState(state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.health.State != state {
+		s.health.Since = time.Now()
+	}
+	s.health.State = state
+	if err != nil {
+		s.health.LastError = err
+	}
+}
+
+// start proxy and begin supervision.
+func (s *supervisor) start() error {
+	p := s.current()
+	p.GetLogger().Info().Msg("Starting proxy")
+	s.setState(StateStarting, nil)
+	if err := p.Start(); err != nil {
+		s.setState(StateStopped, err)
+		close(s.done)
+		return fmt.Errorf("can't start \"%s\": %w", p, err)
+	}
+	s.setState(StateRunning, nil)
+
+	go s.run()
+	return nil
+}
+
+func (s *supervisor) run() {
+	defer close(s.done)
+
+	backoff := minRestartBackoff
+	for {
+		p := s.current()
+		started := time.Now()
+
+		var err error
+		select {
+		case <-s.stop:
+			return
+		case err = <-p.Errors():
+		}
+
+		logger := p.GetLogger()
+		logger.Error().Err(err).Msg("Proxy failed")
+		s.setState(StateRestarting, err)
+		s.shutdownFailed(p)
+
+		if time.Since(started) > stableUptime {
+			backoff = minRestartBackoff
+		}
+		for {
+			logger.Info().Stringer("backoff", backoff).Msg("Restarting proxy")
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > maxRestartBackoff {
+				backoff = maxRestartBackoff
+			}
+			if err = s.restart(); err == nil {
+				break
+			}
+			logger.Error().Err(err).Msg("Can't restart proxy")
+			s.setState(StateRestarting, err)
+		}
+		logger.Info().Msg("Proxy restarted")
+	}
+}
+
+// shut down failed proxy, but don't wait for its live sessions for long.
+func (s *supervisor) shutdownFailed(p Proxy) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		failedShutdownTimeout,
+	)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		p.GetLogger().Warn().Err(err).Msg("Can't shutdown failed proxy")
+	}
+}
+
+func (s *supervisor) restart() error {
+	p, err := s.create()
+	if err != nil {
+		return err
+	}
+	if err = p.Start(); err != nil {
+		return fmt.Errorf("can't start \"%s\": %w", p, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.proxy = p
+	s.health.Restarts++
+	s.health.State = StateRunning
+	s.health.Since = time.Now()
+	return nil
+}
+
+// stop supervision without shutting down proxy.
+func (s *supervisor) stopSupervision() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+// skip proxy, it's left drained without being started.
+func (s *supervisor) skip() {
+	s.setState(StateDrained, nil)
+	close(s.done)
+	close(s.drained)
+}
+
+// drain stops supervision and closes proxy's listener. Active sessions
+// are kept until they finish or timeout is exceeded, zero timeout means
+// no deadline.
+func (s *supervisor) drain(timeout time.Duration) error {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	h := s.getHealth()
+	if h.State != StateRunning && h.State != StateRestarting {
+		return &DrainStateError{proxy: h.Name, state: h.State}
+	}
+
+	// state can't change after supervision is stopped
+	s.stopSupervision()
+	state := s.getHealth().State
+	p := s.current()
+	logger := p.GetLogger()
+	if state != StateRunning {
+		// failed proxy is already shut down, restart is canceled
+		logger.Info().Msg("Proxy drained")
+		s.setState(StateDrained, nil)
+		close(s.drained)
+		return nil
+	}
+
+	logger.Info().
+		Int("sessions", p.Sessions()).
+		Stringer("timeout", timeout).
+		Msg("Draining proxy")
+	s.setState(StateDraining, nil)
+	go func() {
+		defer close(s.drained)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		defer cancel()
+		if err := p.Shutdown(ctx); err != nil {
+			logger.Warn().
+				Err(err).
+				Int("sessions", p.Sessions()).
+				Msg("Drain deadline exceeded, closing sessions")
+			p.CloseSessions()
+		}
+		s.setState(StateDrained, nil)
+		logger.Info().Msg("Proxy drained")
+	}()
+	return nil
+}
+
+// stop supervision and shut down proxy if it's running. Sessions left
+// after ctx is done are closed.
+func (s *supervisor) shutdown(ctx context.Context) error {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	if s.getHealth().State == StateStopped {
+		return nil
+	}
+	s.stopSupervision()
+
+	p := s.current()
+	switch s.getHealth().State {
+	case StateRunning:
+	case StateDraining:
+		select {
+		case <-s.drained:
+			return nil
+		case <-ctx.Done():
+			p.CloseSessions()
+			return fmt.Errorf(
+				"can't shutdown \"%s\": %w",
+				p,
+				base.ErrShutdownTimeout,
+			)
+		}
+	default:
+		return nil
+	}
+
+	p.GetLogger().Info().Msg("Shutting down proxy")
+	s.setState(StateStopped, nil)
+	if err := p.Shutdown(ctx); err != nil {
+		p.CloseSessions()
+		return fmt.Errorf("can't shutdown \"%s\": %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+var (
+	errStart  = errors.New("start failed")
+	errFailed = errors.New("accept failed")
+)
+
+type testProxy struct {
+	name     string
+	startErr error
+	errs     chan error
+	// Shutdown waits for release if it's not nil.
+	release chan struct{}
+
+	started  atomic.Bool
+	shutdown atomic.Bool
+	closed   atomic.Bool
+	sessions atomic.Int64
+}
+
+func newTestProxy(name string) *testProxy {
+	return &testProxy{name: name, errs: make(chan error, 1)}
+}
+
+func (p *testProxy) Start() error {
+	if p.startErr != nil {
+		return p.startErr
+	}
+	p.started.Store(true)
+	return nil
+}
+
+func (p *testProxy) Shutdown(ctx context.Context) error {
+	p.shutdown.Store(true)
+	if p.release == nil {
+		return nil
+	}
+	select {
+	case <-p.release:
+		return nil
+	case <-ctx.Done():
+		return base.ErrShutdownTimeout
+	}
+}
+
+func (p *testProxy) GetLogger() *zerolog.Logger {
+	logger := zerolog.Nop()
+	return &logger
+}
+
+func (p *testProxy) Errors() <-chan error { return p.errs }
+func (p *testProxy) Sessions() int        { return int(p.sessions.Load()) }
+func (p *testProxy) CloseSessions()       { p.closed.Store(true) }
+func (p *testProxy) String() string       { return p.name }
+
+func TestSupervisor_StartFailed(t *testing.T) {
+	p := newTestProxy("test")
+	p.startErr = errStart
+	s := newSupervisor("test", p, func() (Proxy, error) {
+		return nil, errStart
+	})
+
+	require.ErrorIs(t, s.start(), errStart)
+	h := s.getHealth()
+	require.Equal(t, StateStopped, h.State)
+	require.ErrorIs(t, h.LastError, errStart)
+	require.NoError(t, s.shutdown(context.Background()))
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	first := newTestProxy("test")
+	second := newTestProxy("test")
+	created := atomic.NewInt64(0)
+	s := newSupervisor("test", first, func() (Proxy, error) {
+		// first restart attempt fails, so it's retried after backoff
+		if created.Inc() == 1 {
+			return nil, errStart
+		}
+		return second, nil
+	})
+	require.NoError(t, s.start())
+	require.True(t, first.started.Load())
+	require.Equal(t, StateRunning, s.getHealth().State)
+
+	first.errs <- errFailed
+	require.Eventually(t, func() bool {
+		return s.getHealth().State == StateRestarting
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, first.shutdown.Load(), "failed proxy must be shut down")
+
+	require.Eventually(t, func() bool {
+		return s.getHealth().State == StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	h := s.getHealth()
+	require.Equal(t, 1, h.Restarts)
+	require.ErrorIs(t, h.LastError, errStart)
+	require.Equal(t, int64(2), created.Load())
+	require.True(t, second.started.Load())
+	require.Same(t, second, s.current())
+
+	require.NoError(t, s.shutdown(context.Background()))
+	require.True(t, second.shutdown.Load())
+	require.Equal(t, StateStopped, s.getHealth().State)
+}
+
+func TestSupervisor_Shutdown(t *testing.T) {
+	p := newTestProxy("test")
+	p.release = make(chan struct{})
+	s := newSupervisor("test", p, func() (Proxy, error) {
+		return nil, errStart
+	})
+	require.NoError(t, s.start())
+
+	// sessions outliving deadline are closed
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		50*time.Millisecond,
+	)
+	defer cancel()
+	require.ErrorIs(t, s.shutdown(ctx), base.ErrShutdownTimeout)
+	require.True(t, p.closed.Load())
+	require.Equal(t, StateStopped, s.getHealth().State)
+
+	// supervision is stopped, so errors don't restart proxy
+	p.errs <- errFailed
+	require.NoError(t, s.shutdown(context.Background()))
+	require.Equal(t, StateStopped, s.getHealth().State)
+}
+
+func TestSupervisor_Drain(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// sessions finish before timeout if true
+		finish     bool
+		wantClosed bool
+	}{
+		{
+			name:    "sessions finished",
+			timeout: time.Second,
+			finish:  true,
+		},
+		{
+			name:       "deadline exceeded",
+			timeout:    50 * time.Millisecond,
+			wantClosed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProxy("test")
+			p.release = make(chan struct{})
+			p.sessions.Store(2)
+			s := newSupervisor("test", p, func() (Proxy, error) {
+				return nil, errStart
+			})
+			require.NoError(t, s.start())
+
+			// listener is closed in background
+			require.NoError(t, s.drain(tt.timeout))
+			require.Eventually(
+				t,
+				p.shutdown.Load,
+				time.Second,
+				time.Millisecond,
+			)
+			h := s.getHealth()
+			require.Equal(t, StateDraining, h.State)
+			require.Equal(t, 2, h.Sessions)
+
+			// draining proxy can't be drained again
+			require.Equal(
+				t,
+				&DrainStateError{proxy: "test", state: StateDraining},
+				s.drain(tt.timeout),
+			)
+
+			if tt.finish {
+				close(p.release)
+			}
+			select {
+			case <-s.drained:
+			case <-time.After(2 * time.Second):
+				require.Fail(t, "proxy isn't drained")
+			}
+			require.Equal(t, StateDrained, s.getHealth().State)
+			require.Equal(t, tt.wantClosed, p.closed.Load())
+
+			// drained proxy isn't restarted
+			p.errs <- errFailed
+			require.NoError(t, s.shutdown(context.Background()))
+			require.Equal(t, StateDrained, s.getHealth().State)
+		})
+	}
+}
+
+func TestSupervisor_DrainRestarting(t *testing.T) {
+	p := newTestProxy("test")
+	created := atomic.NewInt64(0)
+	s := newSupervisor("test", p, func() (Proxy, error) {
+		created.Inc()
+		return newTestProxy("test"), nil
+	})
+	require.NoError(t, s.start())
+
+	p.errs <- errFailed
+	require.Eventually(t, func() bool {
+		return s.getHealth().State == StateRestarting
+	}, time.Second, 10*time.Millisecond)
+
+	// pending restart is canceled
+	require.NoError(t, s.drain(time.Second))
+	<-s.drained
+	require.Equal(t, StateDrained, s.getHealth().State)
+	require.Zero(t, created.Load())
+}
+
+func TestSupervisor_ShutdownDraining(t *testing.T) {
+	p := newTestProxy("test")
+	p.release = make(chan struct{})
+	s := newSupervisor("test", p, func() (Proxy, error) {
+		return nil, errStart
+	})
+	require.NoError(t, s.start())
+	require.NoError(t, s.drain(0))
+
+	// shutdown waits for drain until its own deadline
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		50*time.Millisecond,
+	)
+	defer cancel()
+	require.ErrorIs(t, s.shutdown(ctx), base.ErrShutdownTimeout)
+	require.True(t, p.closed.Load())
+
+	close(p.release)
+	<-s.drained
+	require.NoError(t, s.shutdown(context.Background()))
+}
+
+func TestSupervisor_Skip(t *testing.T) {
+	p := newTestProxy("test")
+	s := newSupervisor("test", p, func() (Proxy, error) {
+		return nil, errStart
+	})
+	s.skip()
+
+	require.Equal(t, StateDrained, s.getHealth().State)
+	require.Equal(
+		t,
+		&DrainStateError{proxy: "test", state: StateDrained},
+		s.drain(time.Second),
+	)
+	require.NoError(t, s.shutdown(context.Background()))
+	require.False(t, p.started.Load())
+	require.False(t, p.shutdown.Load())
+}
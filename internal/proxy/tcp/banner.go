@@ -0,0 +1,300 @@
+package tcp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/proxy/base"
+	"github.com/rs/zerolog"
+)
+
+const (
+	BannerSSH  = "ssh"
+	BannerFTP  = "ftp"
+	BannerSMTP = "smtp"
+	BannerHTTP = "http"
+
+	defaultBannerMaxLines = 16
+	bannerMaxLineLength   = 4 * 1024
+)
+
+// BannerReply is a scripted reply to client line matching Match.
+type BannerReply struct {
+	Match *regexp.Regexp
+	// Reply is sent with CRLF line endings, nothing is sent if empty.
+	Reply string
+	Close bool
+}
+
+// BannerPersona describes how rejected connections are talked to:
+// greeting is sent right after accept, then every client line is
+// answered with the first matching reply. Lines without matching reply
+// are ignored. Connection is closed after MaxLines client lines.
+type BannerPersona struct {
+	Name     string
+	Greeting string
+	Replies  []BannerReply
+	MaxLines int
+}
+
+func GetBannerPersonas() map[string]*BannerPersona {
+	return map[string]*BannerPersona{
+		BannerSSH: {
+			Name:     BannerSSH,
+			Greeting: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6",
+			Replies: []BannerReply{
+				// looks like sshd dropped connection due to MaxStartups
+				{Match: regexp.MustCompile(`^SSH-`), Close: true},
+				{
+					Match: regexp.MustCompile(`.*`),
+					Reply: "Invalid SSH identification string.",
+					Close: true,
+				},
+			},
+		},
+		BannerFTP: {
+			Name:     BannerFTP,
+			Greeting: "220 (vsFTPd 3.0.5)",
+			Replies: []BannerReply{
+				{
+					Match: regexp.MustCompile(`(?i)^USER\b`),
+					Reply: "331 Please specify the password.",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^PASS\b`),
+					Reply: "530 Login incorrect.",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^FEAT\b`),
+					Reply: "211-Features:\n EPRT\n EPSV\n MDTM\n PASV\n" +
+						" REST STREAM\n SIZE\n TVFS\n211 End",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^QUIT\b`),
+					Reply: "221 Goodbye.",
+					Close: true,
+				},
+				{
+					Match: regexp.MustCompile(`.*`),
+					Reply: "530 Please login with USER and PASS.",
+				},
+			},
+		},
+		BannerSMTP: {
+			Name:     BannerSMTP,
+			Greeting: "220 {hostname} ESMTP Postfix (Ubuntu)",
+			Replies: []BannerReply{
+				{
+					Match: regexp.MustCompile(`(?i)^EHLO\b`),
+					Reply: "250-{hostname}\n250-PIPELINING\n" +
+						"250-SIZE 10240000\n250-VRFY\n250-ETRN\n" +
+						"250-ENHANCEDSTATUSCODES\n250-8BITMIME\n" +
+						"250-DSN\n250-SMTPUTF8\n250 CHUNKING",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^HELO\b`),
+					Reply: "250 {hostname}",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^MAIL\b`),
+					Reply: "250 2.1.0 Ok",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^RCPT\b`),
+					Reply: "554 5.7.1 Relay access denied",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^DATA\b`),
+					Reply: "554 5.5.1 Error: no valid recipients",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^(RSET|NOOP)\b`),
+					Reply: "250 2.0.0 Ok",
+				},
+				{
+					Match: regexp.MustCompile(`(?i)^QUIT\b`),
+					Reply: "221 2.0.0 Bye",
+					Close: true,
+				},
+				{
+					Match: regexp.MustCompile(`.*`),
+					Reply: "502 5.5.2 Error: command not recognized",
+				},
+			},
+		},
+		BannerHTTP: {
+			Name: BannerHTTP,
+			Replies: []BannerReply{
+				// answer after the end of request headers
+				{
+					Match: regexp.MustCompile(`^$`),
+					Reply: nginxBadRequest(),
+					Close: true,
+				},
+			},
+		},
+	}
+}
+
+func nginxBadRequest() string {
+	body := strings.ReplaceAll(
+		"<html>\n"+
+			"<head><title>400 Bad Request</title></head>\n"+
+			"<body>\n"+
+			"<center><h1>400 Bad Request</h1></center>\n"+
+			"<hr><center>nginx</center>\n"+
+			"</body>\n"+
+			"</html>\n",
+		"\n",
+		"\r\n",
+	)
+	return "HTTP/1.1 400 Bad Request\n" +
+		"Server: nginx\n" +
+		"Date: {date}\n" +
+		"Content-Type: text/html\n" +
+		fmt.Sprintf("Content-Length: %d\n", len(body)) +
+		"Connection: close\n" +
+		"\n" +
+		body
+}
+
+// newBanner creates banner persona from config. Configured replies
+// take precedence over persona's ones.
+func newBanner(cfg common.Banner) (*BannerPersona, error) {
+	var b BannerPersona
+	if cfg.Persona != "" {
+		builtin, ok := GetBannerPersonas()[strings.ToLower(cfg.Persona)]
+		if !ok {
+			return nil, &UnknownBannerPersonaError{persona: cfg.Persona}
+		}
+		b = *builtin
+	}
+
+	if cfg.Greeting != "" {
+		b.Greeting = cfg.Greeting
+	}
+	replies := make([]BannerReply, 0, len(cfg.Replies)+len(b.Replies))
+	for _, r := range cfg.Replies {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+		replies = append(replies, BannerReply{
+			Match: re,
+			Reply: r.Reply,
+			Close: r.Close,
+		})
+	}
+	b.Replies = append(replies, b.Replies...)
+	if b.Greeting == "" && len(b.Replies) == 0 {
+		return nil, ErrEmptyBanner
+	}
+
+	b.MaxLines = cfg.MaxLines
+	if b.MaxLines <= 0 {
+		b.MaxLines = defaultBannerMaxLines
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	b.Greeting = strings.ReplaceAll(b.Greeting, "{hostname}", hostname)
+	for i := range b.Replies {
+		b.Replies[i].Reply = strings.ReplaceAll(
+			b.Replies[i].Reply,
+			"{hostname}",
+			hostname,
+		)
+	}
+
+	return &b, nil
+}
+
+// Serve talks to the client until close reply, MaxLines client lines
+// or timeout. Already read client data is processed first.
+func (b *BannerPersona) Serve(
+	conn net.Conn,
+	data []byte,
+	timeout time.Duration,
+	logger zerolog.Logger,
+) {
+	if b.Greeting != "" && !writeBanner(conn, b.Greeting, timeout, logger) {
+		return
+	}
+
+	r := bufio.NewReaderSize(
+		base.NewReplayConn(conn, data),
+		bannerMaxLineLength,
+	)
+	for i := 0; i < b.MaxLines; i++ {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		raw, err := r.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			if !errors.Is(err, io.EOF) {
+				logger.Debug().Err(err).Msg("Can't read banner request")
+			}
+			return
+		}
+		line := strings.TrimRight(string(raw), "\r\n")
+
+		reply := b.match(line)
+		if reply == nil {
+			continue
+		}
+		logger.Debug().
+			Str("line", line).
+			Stringer("match", reply.Match).
+			Msg("Banner reply")
+		if reply.Reply != "" &&
+			!writeBanner(conn, reply.Reply, timeout, logger) {
+			return
+		}
+		if reply.Close {
+			return
+		}
+	}
+}
+
+func (b *BannerPersona) match(line string) *BannerReply {
+	for i, r := range b.Replies {
+		if r.Match.MatchString(line) {
+			return &b.Replies[i]
+		}
+	}
+	return nil
+}
+
+// write reply with CRLF line endings. Returns false on error.
+func writeBanner(
+	conn net.Conn,
+	s string,
+	timeout time.Duration,
+	logger zerolog.Logger,
+) bool {
+	s = strings.ReplaceAll(
+		s,
+		"{date}",
+		time.Now().UTC().Format(http.TimeFormat),
+	)
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	if !strings.HasSuffix(s, "\r\n") {
+		s += "\r\n"
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(s)); err != nil {
+		logger.Debug().Err(err).Msg("Can't write banner")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package tcp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBanner(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		cfg          common.Banner
+		wantErr      bool
+		wantGreeting string
+		wantMaxLines int
+		wantFirst    string
+	}{
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name:    "unknown persona",
+			cfg:     common.Banner{Persona: "telnet"},
+			wantErr: true,
+		},
+		{
+			name: "invalid regexp",
+			cfg: common.Banner{
+				Greeting: "hello",
+				Replies:  []common.BannerReply{{Match: "("}},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "persona",
+			cfg:          common.Banner{Persona: "SMTP"},
+			wantGreeting: "220 " + hostname + " ESMTP Postfix (Ubuntu)",
+			wantMaxLines: defaultBannerMaxLines,
+			wantFirst:    "250-" + hostname,
+		},
+		{
+			name: "persona with overrides",
+			cfg: common.Banner{
+				Persona:  BannerFTP,
+				Greeting: "220 Microsoft FTP Service",
+				Replies: []common.BannerReply{
+					{Match: `(?i)^SYST\b`, Reply: "215 Windows_NT"},
+				},
+				MaxLines: 4,
+			},
+			wantGreeting: "220 Microsoft FTP Service",
+			wantMaxLines: 4,
+			wantFirst:    "215 Windows_NT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newBanner(tt.cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantGreeting, b.Greeting)
+			require.Equal(t, tt.wantMaxLines, b.MaxLines)
+			require.True(
+				t,
+				strings.HasPrefix(b.Replies[0].Reply, tt.wantFirst),
+				b.Replies[0].Reply,
+			)
+		})
+	}
+}
+
+func TestBannerPersona_Serve(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   common.Banner
+		data  string
+		lines []string
+		want  string
+	}{
+		{
+			name:  "ftp",
+			cfg:   common.Banner{Persona: BannerFTP},
+			lines: []string{"USER anonymous", "PASS test", "QUIT"},
+			want: "220 (vsFTPd 3.0.5)\r\n" +
+				"331 Please specify the password.\r\n" +
+				"530 Login incorrect.\r\n" +
+				"221 Goodbye.\r\n",
+		},
+		{
+			name:  "ssh",
+			cfg:   common.Banner{Persona: BannerSSH},
+			lines: []string{"SSH-2.0-Go"},
+			want:  "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n",
+		},
+		{
+			// preread data is answered first
+			name:  "http preread",
+			cfg:   common.Banner{Persona: BannerHTTP},
+			data:  "GET / HTTP/1.1\r\n",
+			lines: []string{"Host: example.com", ""},
+			want:  "HTTP/1.1 400 Bad Request\r\nServer: nginx\r\n",
+		},
+		{
+			// lines without reply are ignored until max lines
+			name: "max lines",
+			cfg: common.Banner{
+				Replies: []common.BannerReply{
+					{Match: "^ping$", Reply: "pong"},
+				},
+				MaxLines: 3,
+			},
+			lines: []string{"ping", "noise", "ping", "ping"},
+			want:  "pong\r\npong\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newBanner(tt.cfg)
+			require.NoError(t, err)
+
+			server, client := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				b.Serve(server, []byte(tt.data), time.Second, log.Logger)
+			}()
+			go func() {
+				for _, l := range tt.lines {
+					_, werr := client.Write([]byte(l + "\r\n"))
+					if werr != nil {
+						return
+					}
+				}
+			}()
+
+			_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+			got, err := io.ReadAll(client)
+			require.NoError(t, err)
+			require.True(
+				t,
+				strings.HasPrefix(string(got), tt.want),
+				string(got),
+			)
+			if tt.cfg.Persona != BannerHTTP {
+				require.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+func TestProxy_Banner(t *testing.T) {
+	target, dialed := newTarget(t)
+	p := newTestProxy(t, common.ProxyConfig{
+		TargetAddr: target,
+		RuleSettings: common.RuleSettings{
+			RejectAction: common.RejectActionBanner,
+		},
+		Filters: []common.Filter{
+			{Rule: "content", Action: common.FilterActionReject},
+		},
+		Preread: common.Preread{Bytes: 5},
+		Banner:  common.Banner{Persona: BannerFTP},
+	}, map[string]rules.Rule{
+		"content": contentRule{substr: "USER"},
+	}, false)
+
+	conn, err := net.Dial("tcp", p.listener.Addr().String())
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// greeting is sent after preread data is filtered
+	_, err = conn.Write([]byte("USER anonymous\r\nQUIT\r\n"))
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	for _, want := range []string{
+		"220 (vsFTPd 3.0.5)",
+		"331 Please specify the password.",
+		"221 Goodbye.",
+	} {
+		line, rerr := r.ReadString('\n')
+		require.NoError(t, rerr)
+		require.Equal(t, want, strings.TrimRight(line, "\r\n"))
+	}
+	_, err = r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+	require.Zero(t, dialed.Load())
+}
@@ -1,9 +1,16 @@
 package tcp
 
 import (
+	"errors"
 	"fmt"
 )
 
+var (
+	ErrEmptyBanner = errors.New(
+		"\"banner\" reject action requires persona, greeting or replies",
+	)
+)
+
 type UnknownShemeError struct {
 	scheme string
 }
@@ -19,3 +26,11 @@ type InvalidSchemeAddrPortError struct {
 func (e InvalidSchemeAddrPortError) Error() string {
 	return fmt.Sprintf("invalid SchemeAddrPort: %s", e.url)
 }
+
+type UnknownBannerPersonaError struct {
+	persona string
+}
+
+func (e UnknownBannerPersonaError) Error() string {
+	return fmt.Sprintf("unknown banner persona: %s", e.persona)
+}
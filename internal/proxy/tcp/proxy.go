@@ -13,6 +13,7 @@ import (
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/proxy/base"
 	"github.com/D00Movenok/BounceBack/internal/rules"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
@@ -27,6 +28,7 @@ const (
 
 var (
 	AllowedActions = []string{
+		common.RejectActionBanner,
 		common.RejectActionDrop,
 		common.RejectActionNone,
 	}
@@ -36,53 +38,66 @@ func NewProxy(
 	cfg common.ProxyConfig,
 	rs *rules.RuleSet,
 	db *database.DB,
+	eng *engagement.Engagement,
 ) (*Proxy, error) {
-	baseProxy, err := base.NewBaseProxy(cfg, rs, db, AllowedActions)
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
 	if err != nil {
 		return nil, fmt.Errorf("can't create base proxy: %w", err)
 	}
 
-	p := &Proxy{
-		Proxy: baseProxy,
+	targets, err := base.NewTargets(cfg, parseTarget, baseProxy.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't create targets: %w", err)
 	}
 
-	scheme, ap, err := parseSchemeAddrPort(cfg.TargetAddr)
-	if err != nil {
-		return nil, fmt.Errorf("can't parse SchemeAddrPort: %w", err)
+	var banner *BannerPersona
+	if cfg.RuleSettings.RejectAction == common.RejectActionBanner {
+		banner, err = newBanner(cfg.Banner)
+		if err != nil {
+			return nil, fmt.Errorf("can't create banner: %w", err)
+		}
 	}
-	switch scheme {
-	case "tcp":
-		p.IsTLS = false
-	case "tls":
-		p.IsTLS = true
-	default:
-		return nil, &UnknownShemeError{scheme: scheme}
+
+	p := &Proxy{
+		Proxy:   baseProxy,
+		Targets: targets,
+		Banner:  banner,
 	}
-	p.TargetURL = ap
 
 	return p, nil
 }
 
+type Target struct {
+	IsTLS bool
+	Addr  netip.AddrPort
+}
+
 type Proxy struct {
 	*base.Proxy
 
-	IsTLS     bool
-	TargetURL netip.AddrPort
+	Targets *base.Targets[Target]
+	Banner  *BannerPersona
 
 	listener net.Listener
 }
 
 func (p *Proxy) Start() error {
-	var err error
-	if p.TLSConfig != nil {
-		p.listener, err = tls.Listen("tcp", p.Config.ListenAddr, p.TLSConfig)
-	} else {
-		p.listener, err = net.Listen("tcp", p.Config.ListenAddr)
-	}
+	l, err := net.Listen("tcp", p.Config.ListenAddr)
 	if err != nil {
 		return fmt.Errorf("can't start listening: %w", err)
 	}
+	return p.StartListener(l)
+}
+
+// StartListener starts serving connections accepted by l.
+func (p *Proxy) StartListener(l net.Listener) error {
+	l = p.Listener(l)
+	if p.TLSConfig != nil {
+		l = tls.NewListener(l, p.TLSConfig)
+	}
+	p.listener = l
 
+	p.Targets.StartHealthChecks()
 	p.WG.Add(1)
 	go p.serve()
 	return nil
@@ -93,6 +108,7 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 	if err := p.listener.Close(); err != nil {
 		return fmt.Errorf("can't close listener: %w", err)
 	}
+	p.Targets.StopHealthChecks()
 
 	done := make(chan interface{}, 1)
 	go func() {
@@ -109,12 +125,21 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-// returns true if need return from func.
+// returns true if need return from func. Banner is served only before
+// target is dialed (dialed is false), otherwise connection is dropped.
 func (p *Proxy) processVerdict(
 	src net.Conn,
+	data []byte,
+	dialed bool,
 	logger zerolog.Logger,
 ) bool {
-	switch p.Config.RuleSettings.RejectAction {
+	switch p.RejectAction() {
+	case common.RejectActionBanner:
+		if !dialed {
+			p.Banner.Serve(src, data, p.Config.Timeout, logger)
+		}
+		src.Close()
+		return true
 	case common.RejectActionDrop:
 		src.Close()
 		return true
@@ -146,7 +171,8 @@ func (p *Proxy) oneSideHandler(
 		e.MU.Lock()
 		if ingress {
 			e.Content = append(e.Content, data...)
-			if !p.RunFilters(e, logger) && p.processVerdict(src, logger) {
+			if !p.RunFilters(e, logger) &&
+				p.processVerdict(src, nil, true, logger) {
 				e.MU.Unlock()
 				return nil
 			}
@@ -169,6 +195,25 @@ func (p *Proxy) oneSideHandler(
 	return nil
 }
 
+// read first client bytes until preread size or timeout is reached.
+func (p *Proxy) preread(src net.Conn) ([]byte, error) {
+	timeout := p.Config.Preread.Timeout
+	if timeout == 0 {
+		timeout = p.Config.Timeout
+	}
+	_ = src.SetReadDeadline(time.Now().Add(timeout))
+	defer func() {
+		_ = src.SetReadDeadline(time.Time{})
+	}()
+
+	data := make([]byte, p.Config.Preread.Bytes)
+	n, err := io.ReadFull(src, data)
+	if err != nil {
+		return data[:n], fmt.Errorf("can't preread data: %w", err)
+	}
+	return data, nil
+}
+
 func (p *Proxy) handleConnection(src net.Conn) {
 	defer p.WG.Done()
 	defer src.Close()
@@ -180,35 +225,73 @@ func (p *Proxy) handleConnection(src net.Conn) {
 
 	logger.Info().Msg("New request")
 
-	// first packet analysis so no data was read
+	// connection over the limits is closed even if action is "none"
+	release, ok := p.Acquire(from, logger)
+	defer release()
+	if !ok {
+		if p.RejectAction() != common.RejectActionNone {
+			p.processVerdict(src, nil, false, logger)
+		}
+		return
+	}
+
+	// first packet analysis, target isn't dialed until the first
+	// client bytes are inspected if preread is enabled.
 	// TODO: drop filtered packets after SYN, not ACK.
 	e := &wrapper.RawPacket{
 		Content: []byte{},
 		From:    from,
 	}
-	if !p.RunFilters(e, logger) && p.processVerdict(src, logger) {
+	if p.Config.Preread.Bytes > 0 {
+		data, err := p.preread(src)
+		if len(data) == 0 {
+			logger.Info().Err(err).Msg("No data received, closing")
+			return
+		}
+		e.Content = data
+	}
+	if !p.RunFilters(e, logger) &&
+		p.processVerdict(src, e.Content, false, logger) {
+		return
+	}
+
+	t, ok := p.Targets.Next(from)
+	if !ok {
+		logger.Error().Msg("No healthy targets")
+		p.processVerdict(src, e.Content, false, logger)
 		return
 	}
+	logger = logger.With().Stringer("target", t).Logger()
 
 	var (
 		dst net.Conn
 		err error
 	)
-	if p.IsTLS {
+	if t.Value.IsTLS {
 		dst, err = tls.Dial(
 			"tcp",
-			p.TargetURL.String(),
+			t.Value.Addr.String(),
 			//nolint: gosec // selfsigned support
 			&tls.Config{InsecureSkipVerify: true},
 		)
 	} else {
-		dst, err = net.Dial("tcp", p.TargetURL.String())
+		dst, err = net.Dial("tcp", t.Value.Addr.String())
 	}
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to connect to target")
 		return
 	}
 
+	// replay preread bytes
+	if len(e.Content) > 0 {
+		_ = dst.SetDeadline(time.Now().Add(p.Config.Timeout))
+		if _, err = dst.Write(e.Content); err != nil {
+			logger.Error().Err(err).Msg("Can't write preread data")
+			dst.Close()
+			return
+		}
+	}
+
 	handler := func(
 		src net.Conn,
 		dst net.Conn,
@@ -244,7 +327,7 @@ func (p *Proxy) serve() {
 		conn, err := p.listener.Accept()
 		if err != nil {
 			if !p.Closing {
-				p.Logger.Error().Err(err).Msg("Unexpected server error")
+				p.Fail(fmt.Errorf("can't accept connection: %w", err))
 			}
 			return
 		}
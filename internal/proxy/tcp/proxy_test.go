@@ -0,0 +1,265 @@
+package tcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// start target which counts accepted connections and echoes data.
+func newTarget(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen target")
+	t.Cleanup(func() { l.Close() })
+
+	dialed := atomic.NewInt64(0)
+	go func() {
+		for {
+			conn, aerr := l.Accept()
+			if aerr != nil {
+				return
+			}
+			dialed.Inc()
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return "tcp://" + l.Addr().String(), dialed
+}
+
+// contentRule fires if packet content contains substr.
+type contentRule struct {
+	substr string
+}
+
+func (r contentRule) Prepare(wrapper.Entity, zerolog.Logger) error {
+	return nil
+}
+
+func (r contentRule) Apply(e wrapper.Entity, _ zerolog.Logger) (bool, error) {
+	b, err := e.GetBody()
+	if err != nil {
+		return false, err //nolint: wrapcheck // test
+	}
+	return bytes.Contains(b, []byte(r.substr)), nil
+}
+
+func (r contentRule) String() string {
+	return "content"
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	rs map[string]rules.Rule,
+	killed bool,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.Timeout = time.Second
+	p, err := NewProxy(cfg, &rules.RuleSet{Rules: rs}, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen proxy")
+	require.NoError(t, p.StartListener(l))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+func TestProxy_EngagementKilled(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		killed bool
+		want   bool
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   true,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+			want:   false,
+		},
+		{
+			name:   "killed drop",
+			action: common.RejectActionDrop,
+			killed: true,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+			}, nil, tt.killed)
+
+			conn, err := net.Dial("tcp", p.listener.Addr().String())
+			require.NoError(t, err, "can't connect to proxy")
+			defer conn.Close()
+
+			// rejected connection is closed without reading, so nothing
+			// is written to it to get EOF instead of reset
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			buf := make([]byte, 4)
+			if tt.want {
+				_, err = conn.Write([]byte("ping"))
+				require.NoError(t, err)
+				_, err = io.ReadFull(conn, buf)
+				require.NoError(t, err)
+				require.Equal(t, "ping", string(buf))
+			} else {
+				_, err = io.ReadFull(conn, buf)
+				require.ErrorIs(t, err, io.EOF)
+			}
+			require.Equal(t, tt.want, dialed.Load() != 0)
+		})
+	}
+}
+
+func TestProxy_Limits(t *testing.T) {
+	for _, action := range []string{
+		common.RejectActionNone,
+		common.RejectActionDrop,
+	} {
+		t.Run(action, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: action,
+				},
+				Limits: common.Limits{MaxConnections: 1},
+			}, nil, false)
+
+			first, err := net.Dial("tcp", p.listener.Addr().String())
+			require.NoError(t, err, "can't connect to proxy")
+			defer first.Close()
+			_, err = first.Write([]byte("ping"))
+			require.NoError(t, err)
+			_, err = io.ReadFull(first, make([]byte, 4))
+			require.NoError(t, err)
+
+			// connection over the limits is closed before target is dialed
+			second, err := net.Dial("tcp", p.listener.Addr().String())
+			require.NoError(t, err, "can't connect to proxy")
+			defer second.Close()
+			_ = second.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = io.ReadFull(second, make([]byte, 4))
+			require.ErrorIs(t, err, io.EOF)
+			require.Equal(t, int64(1), dialed.Load())
+			require.Equal(t, uint64(1), p.Limiter.Limited())
+		})
+	}
+}
+
+func TestProxy_Preread(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "accepted",
+			data: "hello, target",
+			want: "hello, target",
+		},
+		{
+			name: "rejected",
+			data: "scanner!",
+		},
+		{
+			// timeout exceeded before preread size is reached
+			name: "short",
+			data: "hi",
+			want: "hi",
+		},
+		{
+			name: "no data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, dialed := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: common.RejectActionDrop,
+				},
+				Filters: []common.Filter{
+					{Rule: "content", Action: common.FilterActionReject},
+				},
+				Preread: common.Preread{
+					Bytes:   8,
+					Timeout: 100 * time.Millisecond,
+				},
+			}, map[string]rules.Rule{
+				"content": contentRule{substr: "scanner"},
+			}, false)
+
+			conn, err := net.Dial("tcp", p.listener.Addr().String())
+			require.NoError(t, err, "can't connect to proxy")
+			defer conn.Close()
+
+			// target isn't dialed until data is inspected
+			time.Sleep(50 * time.Millisecond)
+			require.Zero(t, dialed.Load())
+
+			if tt.data != "" {
+				_, err = conn.Write([]byte(tt.data))
+				require.NoError(t, err)
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			buf := make([]byte, len(tt.want))
+			_, err = io.ReadFull(conn, buf)
+			if tt.want != "" {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(buf))
+			} else {
+				_, err = conn.Read(make([]byte, 1))
+				require.ErrorIs(t, err, io.EOF)
+			}
+			require.Equal(t, tt.want != "", dialed.Load() != 0)
+		})
+	}
+}
@@ -3,9 +3,32 @@ package tcp
 import (
 	"fmt"
 	"net/netip"
+	"net/url"
 	"strings"
 )
 
+func parseTarget(addr string) (Target, *url.URL, error) {
+	scheme, ap, err := parseSchemeAddrPort(addr)
+	if err != nil {
+		return Target{}, nil, fmt.Errorf("can't parse SchemeAddrPort: %w", err)
+	}
+
+	t := Target{Addr: ap}
+	probe := &url.URL{Host: ap.String()}
+	switch scheme {
+	case "tcp":
+		t.IsTLS = false
+		probe.Scheme = "http"
+	case "tls":
+		t.IsTLS = true
+		probe.Scheme = "https"
+	default:
+		return Target{}, nil, &UnknownShemeError{scheme: scheme}
+	}
+
+	return t, probe, nil
+}
+
 func parseSchemeAddrPort(url string) (string, netip.AddrPort, error) {
 	split := strings.Split(url, "://")
 	if len(split) != 2 { //nolint: gomnd // scheme + addrport
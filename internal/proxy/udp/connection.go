@@ -9,16 +9,22 @@ import (
 
 func newConnection(src *net.UDPAddr) *Connection {
 	return &Connection{
-		Src: src,
+		Src:     src,
+		Release: func() {},
 	}
 }
 
 type Connection struct {
 	Src *net.UDPAddr
 	Dst *net.Conn
+	// Release frees connection limits slot.
+	Release func()
 }
 
 func (c Connection) Close() error {
+	if c.Dst == nil {
+		return nil
+	}
 	if err := (*c.Dst).Close(); err != nil && !base.IsConnectionClosed(err) {
 		return fmt.Errorf("closing connection: %w", err)
 	}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
 	"github.com/D00Movenok/BounceBack/internal/proxy/base"
 	"github.com/D00Movenok/BounceBack/internal/rules"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
@@ -35,8 +36,9 @@ func NewProxy(
 	cfg common.ProxyConfig,
 	rs *rules.RuleSet,
 	db *database.DB,
+	eng *engagement.Engagement,
 ) (*Proxy, error) {
-	baseProxy, err := base.NewBaseProxy(cfg, rs, db, AllowedActions)
+	baseProxy, err := base.NewBaseProxy(cfg, rs, db, eng, AllowedActions)
 	if err != nil {
 		return nil, fmt.Errorf("can't create base proxy: %w", err)
 	}
@@ -49,11 +51,10 @@ func NewProxy(
 		return nil, base.ErrTLSUnsupported
 	}
 
-	ap, err := netip.ParseAddrPort(cfg.TargetAddr)
+	p.Targets, err = base.NewTargets(cfg, parseTarget, baseProxy.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse AddrPort: %w", err)
+		return nil, fmt.Errorf("can't create targets: %w", err)
 	}
-	p.TargetURL = ap
 
 	return p, nil
 }
@@ -61,7 +62,7 @@ func NewProxy(
 type Proxy struct {
 	*base.Proxy
 
-	TargetURL netip.AddrPort
+	Targets *base.Targets[netip.AddrPort]
 
 	connMap  sync.Map
 	listener *net.UDPConn
@@ -88,6 +89,7 @@ func (p *Proxy) Start() error {
 		return fmt.Errorf("can't run listen: %w", err)
 	}
 
+	p.Targets.StartHealthChecks()
 	p.WG.Add(1)
 	go p.serve()
 	return nil
@@ -98,6 +100,7 @@ func (p *Proxy) Shutdown(ctx context.Context) error {
 	if err := p.listener.Close(); err != nil {
 		return fmt.Errorf("can't close listener: %w", err)
 	}
+	p.Targets.StopHealthChecks()
 
 	done := make(chan interface{}, 1)
 	go func() {
@@ -124,10 +127,14 @@ func (p *Proxy) processVerdict(
 	c *Connection,
 	logger zerolog.Logger,
 ) bool {
-	switch p.Config.RuleSettings.RejectAction {
+	switch p.RejectAction() {
 	case common.RejectActionDrop:
 		c.Close()
-		p.connMap.Delete(c.String())
+		// reply loop releases connection itself after close
+		if c.Dst == nil {
+			c.Release()
+			p.connMap.Delete(c.String())
+		}
 		return true
 	default:
 		logger.Warn().Msg("Request was filtered, but action is none")
@@ -141,6 +148,7 @@ func (p *Proxy) replyLoop(
 ) {
 	defer func() {
 		c.Close()
+		c.Release()
 		p.connMap.Delete(c.String())
 		p.WG.Done()
 	}()
@@ -183,6 +191,14 @@ func (p *Proxy) handleConnection(src *net.UDPAddr, data []byte) {
 		c, _ = v.(*Connection)
 	} else {
 		logger.Info().Msg("New request")
+
+		// connection over the limits is dropped even if action is "none"
+		release, ok := p.Acquire(from, logger)
+		if !ok {
+			p.connMap.Delete(c.String())
+			return
+		}
+		c.Release = release
 	}
 
 	e := &wrapper.RawPacket{
@@ -194,9 +210,21 @@ func (p *Proxy) handleConnection(src *net.UDPAddr, data []byte) {
 	}
 
 	if !exist {
-		dst, err := net.Dial("udp", p.TargetURL.String())
+		t, ok := p.Targets.Next(from)
+		if !ok {
+			logger.Error().Msg("No healthy targets")
+			if !p.processVerdict(c, logger) {
+				p.connMap.Delete(c.String())
+				c.Release()
+			}
+			return
+		}
+
+		dst, err := net.Dial("udp", t.Value.String())
 		if err != nil {
 			logger.Error().Err(err).Msg("Failed to connect to target")
+			p.connMap.Delete(c.String())
+			c.Release()
 			return
 		}
 		c.Dst = &dst
@@ -220,7 +248,7 @@ func (p *Proxy) serve() {
 		read, from, err := p.listener.ReadFromUDP(buf)
 		if err != nil {
 			if !p.Closing {
-				p.Logger.Error().Err(err).Msg("Unexpected server error")
+				p.Fail(fmt.Errorf("can't read packet: %w", err))
 			}
 			return
 		}
@@ -0,0 +1,141 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/engagement"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// start target which counts received packets and echoes them.
+func newTarget(t *testing.T) (string, *atomic.Int64) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err, "can't listen target")
+	t.Cleanup(func() { pc.Close() })
+
+	received := atomic.NewInt64(0)
+	go func() {
+		buf := make([]byte, BufSize)
+		for {
+			n, addr, rerr := pc.ReadFrom(buf)
+			if rerr != nil {
+				return
+			}
+			received.Inc()
+			_, _ = pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc.LocalAddr().String(), received
+}
+
+func newTestProxy(
+	t *testing.T,
+	cfg common.ProxyConfig,
+	killed bool,
+) *Proxy {
+	t.Helper()
+
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	t.Cleanup(func() { db.DB.Close() })
+
+	eng, err := engagement.New(common.Engagement{}, db)
+	require.NoError(t, err, "can't create engagement")
+	if killed {
+		require.NoError(t, eng.Kill("test"))
+	}
+
+	cfg.Name = "test"
+	cfg.Type = ProxyType
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.Timeout = time.Second
+	rs := &rules.RuleSet{Rules: map[string]rules.Rule{}}
+	p, err := NewProxy(cfg, rs, db, eng)
+	require.NoError(t, err, "can't create proxy")
+
+	require.NoError(t, p.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+	})
+	return p
+}
+
+// send data to proxy and return reply, empty if there is no reply.
+func exchange(t *testing.T, p *Proxy, data string) string {
+	t.Helper()
+
+	conn, err := net.Dial("udp", p.listener.LocalAddr().String())
+	require.NoError(t, err, "can't connect to proxy")
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(data))
+	require.NoError(t, err)
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second / 2))
+	buf := make([]byte, BufSize)
+	n, err := conn.Read(buf)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ""
+	}
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestProxy_EngagementKilled(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		killed bool
+		want   bool
+	}{
+		{
+			name:   "active none",
+			action: common.RejectActionNone,
+			want:   true,
+		},
+		{
+			name:   "killed none",
+			action: common.RejectActionNone,
+			killed: true,
+			want:   false,
+		},
+		{
+			name:   "killed drop",
+			action: common.RejectActionDrop,
+			killed: true,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, received := newTarget(t)
+			p := newTestProxy(t, common.ProxyConfig{
+				TargetAddr: target,
+				RuleSettings: common.RuleSettings{
+					RejectAction: tt.action,
+				},
+			}, tt.killed)
+
+			reply := exchange(t, p, "ping")
+			if tt.want {
+				require.Equal(t, "ping", reply)
+			} else {
+				require.Empty(t, reply)
+			}
+			require.Equal(t, tt.want, received.Load() != 0)
+		})
+	}
+}
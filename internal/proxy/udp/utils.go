@@ -0,0 +1,19 @@
+package udp
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+)
+
+// UDP targets can't be health checked, so probe is nil.
+func parseTarget(addr string) (netip.AddrPort, *url.URL, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf(
+			"can't parse AddrPort: %w",
+			err,
+		)
+	}
+	return ap, nil, nil
+}
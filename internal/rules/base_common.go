@@ -1,22 +1,30 @@
 package rules
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/netip"
 	"os"
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/asndb"
+	"github.com/D00Movenok/BounceBack/pkg/cloudranges"
+	"github.com/D00Movenok/BounceBack/pkg/cron"
+	"github.com/D00Movenok/BounceBack/pkg/geo"
+	"github.com/D00Movenok/BounceBack/pkg/ics"
 	"github.com/D00Movenok/BounceBack/pkg/ipapico"
 	"github.com/D00Movenok/BounceBack/pkg/ipapicom"
+	"github.com/D00Movenok/BounceBack/pkg/lists"
+	"github.com/D00Movenok/BounceBack/pkg/resolver"
 	badger "github.com/dgraph-io/badger/v3"
 	"github.com/miekg/dns"
 	"github.com/mitchellh/mapstructure"
@@ -35,7 +43,8 @@ func NewRegexpRule(
 ) (Rule, error) {
 	var params RegexpParams
 
-	err := mapstructure.Decode(cfg.Params, &params)
+	// csv column may be a number
+	err := mapstructure.WeakDecode(cfg.Params, &params)
 	if err != nil {
 		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
@@ -44,7 +53,7 @@ func NewRegexpRule(
 		path: params.Path,
 	}
 
-	rule.list, err = getRegexpList(params.Path)
+	rule.list, err = getRegexpList(params.Path, params.options())
 	if err != nil {
 		return nil, fmt.Errorf("can't create regexp list: %w", err)
 	}
@@ -56,67 +65,46 @@ func NewIPRule(
 	_ *database.DB,
 	_ RuleSet,
 	cfg common.RuleConfig,
-	_ common.Globals,
+	globals common.Globals,
 ) (Rule, error) {
-	var (
-		params IPRuleParams
-		subnet netip.Prefix
-		ip     netip.Addr
-	)
+	var params IPRuleParams
 
-	err := mapstructure.Decode(cfg.Params, &params)
+	// csv column may be a number
+	err := mapstructure.WeakDecode(cfg.Params, &params)
 	if err != nil {
 		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
+	if params.Path == "" && len(params.Lists) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
 
 	rule := &IPRule{
-		path: params.Path,
+		path:    params.Path,
+		opts:    params.options(),
+		subnets: atomic.NewPointer[[]ipSubnet](nil),
 	}
 
-	file, err := os.Open(params.Path)
-	if err != nil {
-		return nil, fmt.Errorf("can't open ip list file: %w", err)
+	for _, name := range params.Lists {
+		path, perr := lists.Path(getListsDir(globals), name)
+		if perr != nil {
+			return nil, fmt.Errorf("can't get list path: %w", perr)
+		}
+		rule.lists = append(rule.lists, path)
 	}
 
-	s := bufio.NewScanner(file)
-	for s.Scan() {
-		line := s.Text()
-		line, _, _ = strings.Cut(line, "#") // remove comment
-		line = strings.TrimSpace(line)      // trim spaces
-		if line != "" {
-			ip, err = netip.ParseAddr(line)
-			if err == nil {
-				if ip.Is4() {
-					line += "/32"
-				} else {
-					line += "/128"
-				}
-			}
+	subnets, err := rule.load()
+	if err != nil {
+		return nil, err
+	}
+	rule.subnets.Store(&subnets)
 
-			subnet, err = netip.ParsePrefix(line)
-			rule.subnetBanlist = append(rule.subnetBanlist, subnet)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("can't parse ip/subnet: %w", err)
+	// subscribed lists are replaced by updater
+	for _, path := range rule.lists {
+		if err = watchFile(path, rule.reload); err != nil {
+			return nil, err
 		}
 	}
 
-	// sort and remove equal elements for subnetBanlist
-	// TODO: update with compare func when it will be added
-	// https://github.com/golang/go/issues/61642
-	slices.SortFunc(
-		rule.subnetBanlist,
-		func(e1 netip.Prefix, e2 netip.Prefix) int {
-			return e1.Masked().Addr().Compare(e2.Masked().Addr())
-		},
-	)
-	rule.subnetBanlist = slices.CompactFunc(
-		rule.subnetBanlist,
-		func(e1 netip.Prefix, e2 netip.Prefix) bool {
-			return e1.Overlaps(e2)
-		},
-	)
-
 	return rule, nil
 }
 
@@ -127,22 +115,89 @@ func NewTimeRule(
 	_ common.Globals,
 ) (Rule, error) {
 	var params TimeParams
-	err := mapstructure.Decode(cfg.Params, &params)
+	// YAML parses unquoted dates as timestamps
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: timeToStringHook,
+		Result:     &params,
+	})
 	if err != nil {
+		return nil, fmt.Errorf("can't create params decoder: %w", err)
+	}
+	if err = dec.Decode(cfg.Params); err != nil {
 		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
 
+	// "from", "to" and "weekdays" are the first range, weekdays of "ranges"
+	// and "cron" are set in them
+	if len(params.Weekdays) != 0 && params.From == "" && params.To == "" {
+		return nil, fmt.Errorf(
+			"%w: \"weekdays\" require \"from\" and \"to\"",
+			ErrInvalidRuleArgs,
+		)
+	}
+	if params.From != "" || params.To != "" {
+		params.Ranges = append([]TimeRangeParams{{
+			From:     params.From,
+			To:       params.To,
+			Weekdays: params.Weekdays,
+		}}, params.Ranges...)
+	}
+	if len(params.Ranges) == 0 && len(params.Cron) == 0 &&
+		len(params.Dates) == 0 && params.Holidays == "" {
+		return nil, ErrInvalidRuleArgs
+	}
+
 	loc, err := time.LoadLocation(params.Location)
 	if err != nil {
 		return nil, fmt.Errorf("can't parse location: %w", err)
 	}
+
+	rule := &TimeRule{
+		loc:      loc,
+		holidays: params.Holidays,
+		now:      time.Now,
+	}
+	for _, rp := range params.Ranges {
+		var r timeRange
+		if r, err = newTimeRange(rp, loc); err != nil {
+			return nil, err
+		}
+		rule.ranges = append(rule.ranges, r)
+	}
+	for _, c := range params.Cron {
+		var sched *cron.Schedule
+		if sched, err = cron.Parse(c); err != nil {
+			return nil, fmt.Errorf("can't parse cron expression: %w", err)
+		}
+		rule.cron = append(rule.cron, sched)
+	}
+	for _, dp := range params.Dates {
+		var d dateRange
+		if d, err = newDateRange(dp, loc); err != nil {
+			return nil, err
+		}
+		rule.dates = append(rule.dates, d)
+	}
+	if params.Holidays != "" {
+		if rule.holidayEvents, err = getHolidays(params.Holidays); err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+func newTimeRange(
+	params TimeRangeParams,
+	loc *time.Location,
+) (timeRange, error) {
 	from, err := time.ParseInLocation("15:04", params.From, loc)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse \"from\" time: %w", err)
+		return timeRange{}, fmt.Errorf("can't parse \"from\" time: %w", err)
 	}
 	to, err := time.ParseInLocation("15:04", params.To, loc)
 	if err != nil {
-		return nil, fmt.Errorf("can't parse \"to\" time: %w", err)
+		return timeRange{}, fmt.Errorf("can't parse \"to\" time: %w", err)
 	}
 
 	var (
@@ -160,24 +215,87 @@ func NewTimeRule(
 
 	if len(params.Weekdays) == 0 {
 		days = maps.Values(daysOfWeek)
+		slices.Sort(days)
 	} else {
 		for _, v := range params.Weekdays {
 			d, ok := daysOfWeek[v]
 			if !ok {
-				return nil, &UnknownDayOfWeekError{day: v}
+				return timeRange{}, &UnknownDayOfWeekError{day: v}
 			}
 			days = append(days, d)
 		}
 	}
 
-	rule := &TimeRule{
-		from:     from,
-		to:       to,
-		loc:      loc,
+	r := timeRange{
+		from:     from.Hour()*minutesInHour + from.Minute(),
+		to:       to.Hour()*minutesInHour + to.Minute(),
 		weekdays: days,
 	}
+	return r, nil
+}
+
+// date range "to" is inclusive, so date without time means whole day.
+func newDateRange(
+	params DateRangeParams,
+	loc *time.Location,
+) (dateRange, error) {
+	from, _, err := parseDate(params.From, loc)
+	if err != nil {
+		return dateRange{}, fmt.Errorf("can't parse \"from\" date: %w", err)
+	}
+	to, dateOnly, err := parseDate(params.To, loc)
+	if err != nil {
+		return dateRange{}, fmt.Errorf("can't parse \"to\" date: %w", err)
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	} else {
+		to = to.Add(time.Minute)
+	}
+	if !to.After(from) {
+		return dateRange{}, &InvalidDateRangeError{
+			from: params.From,
+			to:   params.To,
+		}
+	}
+	return dateRange{from: from, to: to}, nil
+}
 
-	return rule, nil
+// parse date with optional time, returns true if there is no time.
+func parseDate(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("can't parse date: %w", err)
+	}
+	return t, false, nil
+}
+
+func timeToStringHook(_, to reflect.Type, data any) (any, error) {
+	t, ok := data.(time.Time)
+	if !ok || to.Kind() != reflect.String {
+		return data, nil
+	}
+	if t.Hour() == 0 && t.Minute() == 0 {
+		return t.Format("2006-01-02"), nil
+	}
+	return t.Format("2006-01-02 15:04"), nil
+}
+
+func getHolidays(path string) ([]ics.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open holidays file: %w", err)
+	}
+	defer file.Close()
+
+	events, err := ics.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse holidays \"%s\": %w", path, err)
+	}
+	return events, nil
 }
 
 func NewGeolocationRule(
@@ -187,36 +305,20 @@ func NewGeolocationRule(
 	gloals common.Globals,
 ) (Rule, error) {
 	var params GeoParams
-	err := mapstructure.Decode(cfg.Params, &params)
+	// csv column may be a number
+	err := mapstructure.WeakDecode(cfg.Params, &params)
 	if err != nil {
 		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
 
-	var ipapicoClient ipapico.Client
-	if gloals.IPApiCoKey != "" {
-		ipapicoClient = ipapico.NewClientWithAPIKey(gloals.IPApiCoKey)
-	} else {
-		ipapicoClient = ipapico.NewClient()
-	}
-
-	var ipapicomClient ipapicom.Client
-	if gloals.IPApiCoKey != "" {
-		ipapicomClient = ipapicom.NewClientWithAPIKey(gloals.IPApiCoKey)
-	} else {
-		ipapicomClient = ipapicom.NewClient()
-	}
-
 	rule := &GeoRule{
-		db:         db,
-		path:       params.Path,
-		geo:        make([]*GeoRegexp, 0, len(params.Geolocations)),
-		apicounter: atomic.NewInt32(0),
-		ipapico:    ipapicoClient,
-		ipapicom:   ipapicomClient,
+		lookup: newGeoLookup(db, gloals),
+		path:   params.Path,
+		geo:    make([]*GeoRegexp, 0, len(params.Geolocations)),
 	}
 
 	if params.Path != "" {
-		rule.list, err = getRegexpList(params.Path)
+		rule.list, err = getRegexpList(params.Path, params.options())
 		if err != nil {
 			return nil, fmt.Errorf("can't create regexp list: %w", err)
 		}
@@ -260,34 +362,295 @@ func NewGeolocationRule(
 	return rule, nil
 }
 
-func NewReverseLookupRule(
+func NewGeoRadiusRule(
+	db *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
+	globals common.Globals,
+) (Rule, error) {
+	var params GeoRadiusParams
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+	if len(params.Circles) == 0 && params.Polygons == "" {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &GeoRadiusRule{
+		lookup:  newGeoLookup(db, globals),
+		circles: make([]geoCircle, 0, len(params.Circles)),
+		path:    params.Polygons,
+	}
+
+	for _, c := range params.Circles {
+		circle := geoCircle{
+			center: geo.Point{Latitude: c.Latitude, Longitude: c.Longitude},
+			radius: c.Radius,
+		}
+		if c.Radius <= 0 || !circle.center.Valid() {
+			return nil, &InvalidCircleError{circle: circle.String()}
+		}
+		rule.circles = append(rule.circles, circle)
+	}
+
+	if params.Polygons != "" {
+		rule.polygons, err = getPolygons(params.Polygons)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+func getPolygons(path string) ([]geo.Polygon, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open polygons \"%s\": %w", path, err)
+	}
+	defer file.Close()
+
+	polygons, err := geo.ParseGeoJSON(file)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse polygons \"%s\": %w", path, err)
+	}
+	return polygons, nil
+}
+
+func NewIPClassRule(
 	db *database.DB,
 	_ RuleSet,
 	cfg common.RuleConfig,
+	globals common.Globals,
+) (Rule, error) {
+	var params IPClassParams
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+	if len(params.Classes) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &IPClassRule{
+		lookup:  newGeoLookup(db, globals),
+		classes: make([]string, 0, len(params.Classes)),
+	}
+	for _, c := range params.Classes {
+		c = strings.ToLower(c)
+		switch c {
+		case IPClassProxy, IPClassHosting, IPClassMobile:
+			rule.classes = append(rule.classes, c)
+		default:
+			return nil, &UnknownIPClassError{class: c}
+		}
+	}
+
+	return rule, nil
+}
+
+func NewASNRule(
+	_ *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
 	_ common.Globals,
 ) (Rule, error) {
-	var (
-		params ReverseLookupParams
-		dns    netip.AddrPort
-	)
+	var params ASNParams
+	// ASNs may be numbers or strings with "AS" prefix
+	err := mapstructure.WeakDecode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+	if params.Database == "" || (len(params.ASNs) == 0 &&
+		params.Path == "" && len(params.Organisations) == 0) {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &ASNRule{
+		database: params.Database,
+		path:     params.Path,
+		asns:     make(map[uint32]struct{}),
+		db:       atomic.NewPointer[asndb.Database](nil),
+	}
+
+	for _, s := range params.ASNs {
+		asn, perr := asndb.ParseASN(s)
+		if perr != nil {
+			return nil, fmt.Errorf("can't parse asn \"%s\": %w", s, perr)
+		}
+		rule.asns[asn] = struct{}{}
+	}
+	if params.Path != "" {
+		list, lerr := getASNList(params.Path)
+		if lerr != nil {
+			return nil, fmt.Errorf("can't create asn list: %w", lerr)
+		}
+		for _, asn := range list {
+			rule.asns[asn] = struct{}{}
+		}
+	}
+
+	rule.organisations, err = compileRegexps(params.Organisations)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := asndb.Open(params.Database)
+	if err != nil {
+		return nil, fmt.Errorf("can't open asn database: %w", err)
+	}
+	rule.db.Store(&db)
+
+	if err = watchFile(params.Database, rule.reload); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
 
+func NewCloudRule(
+	_ *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
+	_ common.Globals,
+) (Rule, error) {
+	var params CloudParams
 	err := mapstructure.Decode(cfg.Params, &params)
 	if err != nil {
 		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
+	if len(params.Sources) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &CloudRule{
+		sources: params.Sources,
+		matches: make([]*cloudSelector, 0, len(params.Match)),
+		index:   atomic.NewPointer[cloudranges.Index](nil),
+	}
+	for _, m := range params.Match {
+		var sel *cloudSelector
+		sel, err = newCloudSelector(m)
+		if err != nil {
+			return nil, err
+		}
+		rule.matches = append(rule.matches, sel)
+	}
+
+	idx, err := loadCloudRanges(params.Sources)
+	if err != nil {
+		return nil, err
+	}
+	rule.index.Store(idx)
+
+	for _, src := range params.Sources {
+		if err = watchFile(src.Path, rule.reload); err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+func newCloudSelector(p CloudMatchParams) (*cloudSelector, error) {
+	if p.Provider != "" &&
+		!slices.Contains(cloudranges.Providers(), p.Provider) {
+		return nil, &UnknownCloudProviderError{provider: p.Provider}
+	}
+	for _, masks := range [][]string{
+		p.Services,
+		p.Regions,
+		p.ExcludeServices,
+		p.ExcludeRegions,
+	} {
+		if slices.Contains(masks, "") {
+			return nil, ErrInvalidRuleArgs
+		}
+	}
+	return &cloudSelector{
+		provider:        p.Provider,
+		services:        p.Services,
+		regions:         p.Regions,
+		excludeServices: p.ExcludeServices,
+		excludeRegions:  p.ExcludeRegions,
+	}, nil
+}
+
+func loadCloudRanges(
+	sources []CloudSourceParams,
+) (*cloudranges.Index, error) {
+	var all []cloudranges.Range
+	for _, src := range sources {
+		file, err := os.Open(src.Path)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't open cloud ranges \"%s\": %w",
+				src.Path,
+				err,
+			)
+		}
+		ranges, err := cloudranges.Parse(src.Provider, file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't parse cloud ranges \"%s\": %w",
+				src.Path,
+				err,
+			)
+		}
+		all = append(all, ranges...)
+	}
+	return cloudranges.NewIndex(all), nil
+}
+
+func NewReverseLookupRule(
+	db *database.DB,
+	rs RuleSet,
+	cfg common.RuleConfig,
+	globals common.Globals,
+) (Rule, error) {
+	return newReverseLookupRule(db, rs, cfg, globals, false)
+}
+
+// NewFCrDNSRule creates "fcrdns" rule. It's "reverse_lookup" rule trusting
+// only PTR domains which resolve back to the client IP, so PTR records
+// can't be spoofed by owner of reverse zone.
+func NewFCrDNSRule(
+	db *database.DB,
+	rs RuleSet,
+	cfg common.RuleConfig,
+	globals common.Globals,
+) (Rule, error) {
+	return newReverseLookupRule(db, rs, cfg, globals, true)
+}
+
+func newReverseLookupRule(
+	db *database.DB,
+	rs RuleSet,
+	cfg common.RuleConfig,
+	globals common.Globals,
+	confirm bool,
+) (Rule, error) {
+	var params ReverseLookupParams
 
-	dns, err = netip.ParseAddrPort(params.DNS)
+	err := mapstructure.Decode(cfg.Params, &params)
 	if err != nil {
-		return nil, fmt.Errorf("dns addr is invalid: %w", err)
+		return nil, fmt.Errorf("can't decode params: %w", err)
 	}
 
 	rule := &ReverseLookupRule{
-		db:   db,
-		path: params.Path,
-		dns:  dns,
+		db:      db,
+		path:    params.Path,
+		confirm: confirm,
 	}
 
-	rule.list, err = getRegexpList(params.Path)
+	rule.resolver, err = getResolver(rs, params.DNS, globals)
+	if err != nil {
+		return nil, fmt.Errorf("dns is invalid: %w", err)
+	}
+
+	rule.list, err = getRegexpList(params.Path, lists.Options{})
 	if err != nil {
 		return nil, fmt.Errorf("can't create regexp list: %w", err)
 	}
@@ -295,8 +658,84 @@ func NewReverseLookupRule(
 	return rule, nil
 }
 
+func NewDNSBLRule(
+	db *database.DB,
+	rs RuleSet,
+	cfg common.RuleConfig,
+	globals common.Globals,
+) (Rule, error) {
+	var params DNSBLParams
+
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+	if params.MinHits == 0 {
+		params.MinHits = 1
+	}
+	if len(params.Zones) == 0 ||
+		params.MinHits < 0 ||
+		params.MinHits > len(params.Zones) {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &DNSBLRule{
+		db:      db,
+		minHits: params.MinHits,
+		zones:   make([]dnsblZone, 0, len(params.Zones)),
+	}
+
+	rule.resolver, err = getResolver(rs, params.DNS, globals)
+	if err != nil {
+		return nil, fmt.Errorf("dns is invalid: %w", err)
+	}
+
+	for _, zp := range params.Zones {
+		if _, ok := dns.IsDomainName(zp.Zone); !ok || zp.Zone == "" {
+			return nil, fmt.Errorf(
+				"%w: invalid zone \"%s\"",
+				ErrInvalidRuleArgs,
+				zp.Zone,
+			)
+		}
+		z := dnsblZone{
+			zone:  dns.Fqdn(strings.ToLower(zp.Zone)),
+			codes: make([]netip.Prefix, 0, len(zp.Codes)),
+			ipv6:  zp.IPv6,
+		}
+		for _, c := range zp.Codes {
+			p, perr := lists.ParsePrefix(c)
+			if perr != nil {
+				return nil, fmt.Errorf("can't parse dnsbl code: %w", perr)
+			}
+			z.codes = append(z.codes, p)
+		}
+		rule.zones = append(rule.zones, z)
+	}
+
+	return rule, nil
+}
+
+// ListParams set format of file in "list" param.
+type ListParams struct {
+	Format    string `mapstructure:"format"`
+	Column    string `mapstructure:"column"`
+	Delimiter string `mapstructure:"delimiter"`
+	Selector  string `mapstructure:"selector"`
+}
+
+func (p ListParams) options() lists.Options {
+	return lists.Options{
+		Format:    p.Format,
+		Column:    p.Column,
+		Delimiter: p.Delimiter,
+		Selector:  p.Selector,
+	}
+}
+
 type RegexpParams struct {
-	Path string `mapstructure:"list"`
+	Path       string `mapstructure:"list"`
+	ListParams `mapstructure:",squash"`
 }
 
 type RegexpRule struct {
@@ -333,12 +772,24 @@ func (f RegexpRule) String() string {
 }
 
 type IPRuleParams struct {
-	Path string `mapstructure:"list"`
+	Path       string   `mapstructure:"list"`
+	Lists      []string `mapstructure:"lists"`
+	ListParams `mapstructure:",squash"`
+}
+
+// ipSubnet is subnet with provenance: name of list and its source URL (empty
+// for local files).
+type ipSubnet struct {
+	prefix netip.Prefix
+	list   string
+	source string
 }
 
 type IPRule struct {
-	path          string
-	subnetBanlist []netip.Prefix
+	path    string
+	opts    lists.Options
+	lists   []string
+	subnets *atomic.Pointer[[]ipSubnet]
 }
 
 func (f *IPRule) Prepare(
@@ -353,46 +804,191 @@ func (f *IPRule) Apply(
 	logger zerolog.Logger,
 ) (bool, error) {
 	ip := e.GetIP()
+	subnets := *f.subnets.Load()
 
-	// search ip in subnetBanlist
+	// search ip in subnets
 	// TODO: use Compare func when
 	// https://github.com/golang/go/issues/61642
 	i, found := slices.BinarySearchFunc(
-		f.subnetBanlist,
+		subnets,
 		ip,
-		func(e1 netip.Prefix, e2 netip.Addr) int {
-			if e1.Contains(e2) {
+		func(e1 ipSubnet, e2 netip.Addr) int {
+			if e1.prefix.Contains(e2) {
 				return 0
 			}
-			return e1.Masked().Addr().Compare(e2)
+			return e1.prefix.Masked().Addr().Compare(e2)
 		},
 	)
 	if found {
-		logger.Debug().Stringer("match", f.subnetBanlist[i]).Msg("Subnet match")
+		logger.Debug().
+			Stringer("match", subnets[i].prefix).
+			Str("list", subnets[i].list).
+			Str("source", subnets[i].source).
+			Msg("Subnet match")
 		return true, nil
 	}
 
 	return false, nil
 }
 
-func (f *IPRule) String() string {
-	return fmt.Sprintf("IP(list=%s)", f.path)
-}
+// load reads local list and subscribed lists.
+func (f *IPRule) load() ([]ipSubnet, error) {
+	var subnets []ipSubnet
 
-type TimeParams struct {
-	From     string   `mapstructure:"from"`
+	if f.path != "" {
+		prefixes, err := getIPList(f.path, f.opts)
+		if err != nil {
+			return nil, fmt.Errorf("can't create ip list: %w", err)
+		}
+		for _, p := range prefixes {
+			subnets = append(subnets, ipSubnet{prefix: p, list: f.path})
+		}
+	}
+
+	for _, path := range f.lists {
+		l, err := lists.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"can't load list \"%s\" (try \"bounceback lists update\"): %w",
+				path,
+				err,
+			)
+		}
+		for _, p := range l.Prefixes {
+			subnets = append(subnets, ipSubnet{
+				prefix: p,
+				list:   l.Name,
+				source: l.Source,
+			})
+		}
+	}
+
+	// sort and remove equal elements for subnets
+	// TODO: update with compare func when it will be added
+	// https://github.com/golang/go/issues/61642
+	slices.SortFunc(
+		subnets,
+		func(e1 ipSubnet, e2 ipSubnet) int {
+			return e1.prefix.Masked().Addr().Compare(e2.prefix.Masked().Addr())
+		},
+	)
+	subnets = slices.CompactFunc(
+		subnets,
+		func(e1 ipSubnet, e2 ipSubnet) bool {
+			return e1.prefix.Overlaps(e2.prefix)
+		},
+	)
+
+	return subnets, nil
+}
+
+func (f *IPRule) reload() {
+	subnets, err := f.load()
+	if err != nil {
+		log.Error().Err(err).Msg("Can't reload IP lists")
+		return
+	}
+	f.subnets.Store(&subnets)
+	log.Info().
+		Strs("lists", f.lists).
+		Int("subnets", len(subnets)).
+		Msg("IP lists reloaded")
+}
+
+func (f *IPRule) String() string {
+	return fmt.Sprintf("IP(list=%s, lists=%v)", f.path, f.lists)
+}
+
+type TimeRangeParams struct {
+	From     string   `mapstructure:"from"`
 	To       string   `mapstructure:"to"`
-	Location string   `mapstructure:"timezone"`
 	Weekdays []string `mapstructure:"weekdays"`
 }
 
-type TimeRule struct {
-	from     time.Time
-	to       time.Time
-	loc      *time.Location
+type DateRangeParams struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+type TimeParams struct {
+	From     string            `mapstructure:"from"`
+	To       string            `mapstructure:"to"`
+	Location string            `mapstructure:"timezone"`
+	Weekdays []string          `mapstructure:"weekdays"`
+	Ranges   []TimeRangeParams `mapstructure:"ranges"`
+	Cron     []string          `mapstructure:"cron"`
+	Dates    []DateRangeParams `mapstructure:"dates"`
+	Holidays string            `mapstructure:"holidays"`
+}
+
+const minutesInHour = 60
+
+// time of day range in minutes since midnight, both ends are inclusive.
+// Range from > to lasts over midnight, from == to means whole day.
+type timeRange struct {
+	from     int
+	to       int
 	weekdays []time.Weekday
 }
 
+func (r timeRange) match(t time.Time) bool {
+	if !slices.Contains(r.weekdays, t.Weekday()) {
+		return false
+	}
+	now := t.Hour()*minutesInHour + t.Minute()
+	switch {
+	case r.from < r.to:
+		return now >= r.from && now <= r.to
+	case r.from > r.to:
+		return now >= r.from || now <= r.to
+	default:
+		return true
+	}
+}
+
+func (r timeRange) String() string {
+	return fmt.Sprintf(
+		"%02d:%02d-%02d:%02d%s",
+		r.from/minutesInHour,
+		r.from%minutesInHour,
+		r.to/minutesInHour,
+		r.to%minutesInHour,
+		common.FormatStringerSlice(r.weekdays),
+	)
+}
+
+// absolute time range, "to" is exclusive.
+type dateRange struct {
+	from time.Time
+	to   time.Time
+}
+
+func (r dateRange) match(t time.Time) bool {
+	return !t.Before(r.from) && t.Before(r.to)
+}
+
+func (r dateRange) String() string {
+	return fmt.Sprintf(
+		"%s-%s",
+		r.from.Format("2006-01-02 15:04"),
+		r.to.Format("2006-01-02 15:04"),
+	)
+}
+
+// TimeRule fires if time matches any of time ranges or cron expressions
+// (any time if there are none), is within any of date ranges (if any)
+// and isn't a holiday.
+type TimeRule struct {
+	loc           *time.Location
+	ranges        []timeRange
+	cron          []*cron.Schedule
+	dates         []dateRange
+	holidays      string
+	holidayEvents []ics.Event
+
+	now func() time.Time
+}
+
 func (f *TimeRule) Prepare(
 	_ wrapper.Entity,
 	_ zerolog.Logger,
@@ -404,36 +1000,63 @@ func (f *TimeRule) Apply(
 	_ wrapper.Entity,
 	logger zerolog.Logger,
 ) (bool, error) {
-	n := time.Now().In(f.loc)
+	n := f.now().In(f.loc)
 
-	d := n.Weekday()
-	if !slices.Contains(f.weekdays, d) {
+	if !f.matchTime(n) {
 		return false, nil
 	}
 
-	now, _ := time.ParseInLocation(
-		"15:04",
-		fmt.Sprintf("%02d:%02d", n.Hour(), n.Minute()),
-		f.loc,
-	)
-	fromLtTo := f.from.Before(f.to) && (now.Before(f.from) || now.After(f.to))
-	fromGtTo := f.from.After(f.to) && (now.Before(f.from) && now.After(f.to))
-	if fromLtTo || fromGtTo {
+	if len(f.dates) > 0 && !f.matchDates(n) {
 		return false, nil
 	}
 
+	for _, h := range f.holidayEvents {
+		if h.Contains(n) {
+			logger.Debug().
+				Stringer("time", n).
+				Str("holiday", h.Summary).
+				Msg("Time is a holiday")
+			return false, nil
+		}
+	}
+
 	logger.Debug().Stringer("match", n).Msg("Time match")
 	return true, nil
 }
 
+func (f *TimeRule) matchTime(t time.Time) bool {
+	if len(f.ranges) == 0 && len(f.cron) == 0 {
+		return true
+	}
+	for _, r := range f.ranges {
+		if r.match(t) {
+			return true
+		}
+	}
+	for _, c := range f.cron {
+		if c.Match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *TimeRule) matchDates(t time.Time) bool {
+	for _, d := range f.dates {
+		if d.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *TimeRule) String() string {
 	return fmt.Sprintf(
-		"Time(from=%02d:%02d, to=%02d:%02d, weekdays=%s, timezone=%s)",
-		f.from.Hour(),
-		f.from.Minute(),
-		f.to.Hour(),
-		f.to.Minute(),
-		common.FormatStringerSlice(f.weekdays),
+		"Time(ranges=%s, cron=%s, dates=%s, holidays=%s, timezone=%s)",
+		common.FormatStringerSlice(f.ranges),
+		common.FormatStringerSlice(f.cron),
+		common.FormatStringerSlice(f.dates),
+		f.holidays,
 		f.loc.String(),
 	)
 }
@@ -453,6 +1076,7 @@ type GeoParam struct {
 type GeoParams struct {
 	Path         string     `mapstructure:"list"`
 	Geolocations []GeoParam `mapstructure:"geolocations"`
+	ListParams   `mapstructure:",squash"`
 }
 
 type GeoRegexp struct {
@@ -482,20 +1106,17 @@ func (r *GeoRegexp) String() string {
 }
 
 type GeoRule struct {
-	db         *database.DB
-	path       string
-	list       []*regexp.Regexp
-	geo        []*GeoRegexp
-	apicounter *atomic.Int32
-	ipapico    ipapico.Client
-	ipapicom   ipapicom.Client
+	lookup *geoLookup
+	path   string
+	list   []*regexp.Regexp
+	geo    []*GeoRegexp
 }
 
 func (f *GeoRule) Prepare(
 	e wrapper.Entity,
 	logger zerolog.Logger,
 ) error {
-	_, err := f.getGeoInfoByEntity(e, logger)
+	_, err := f.lookup.get(e, logger)
 	if err != nil {
 		return fmt.Errorf("can't prepare geolocation info: %w", err)
 	}
@@ -506,7 +1127,7 @@ func (f *GeoRule) Apply(
 	e wrapper.Entity,
 	logger zerolog.Logger,
 ) (bool, error) {
-	geo, err := f.getGeoInfoByEntity(e, logger)
+	geo, err := f.lookup.get(e, logger)
 	if err != nil {
 		return false, fmt.Errorf("can't get geolocation info: %w", err)
 	}
@@ -524,80 +1145,6 @@ func (f *GeoRule) Apply(
 	return false, nil
 }
 
-func (f *GeoRule) getGeoInfoByEntity(
-	e wrapper.Entity,
-	logger zerolog.Logger,
-) (*database.Geolocation, error) {
-	ip := e.GetIP().String()
-
-	geo, err := f.db.GetGeolocation(ip)
-	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("can't get cached geolocation: %w", err)
-	}
-	if geo != nil {
-		return geo, nil
-	}
-
-	ctx, cancel := context.WithTimeout(
-		context.Background(),
-		time.Second*5, //nolint:gomnd
-	)
-	defer cancel()
-
-	geo = &database.Geolocation{}
-	switch f.apicounter.Inc() % 2 {
-	case 0:
-		var g *ipapico.Location
-		g, err = f.ipapico.GetLocationForIP(ctx, ip)
-		if err != nil && !errors.Is(err, ipapico.ErrReservedRange) {
-			return nil, fmt.Errorf(
-				"can't get geolocation with ipapi.co: %w",
-				err,
-			)
-		}
-
-		if g != nil {
-			geo.Organisation = []string{g.Org}
-			geo.CountryCode = g.Country
-			geo.Country = g.CountryName
-			geo.RegionCode = g.RegionCode
-			geo.Region = g.Region
-			geo.City = g.City
-			geo.Timezone = g.Timezone
-			geo.ASN = g.Asn
-		}
-	case 1:
-		var g *ipapicom.Location
-		g, err = f.ipapicom.GetLocationForIP(ctx, ip)
-		if err != nil && !(errors.Is(err, ipapicom.ErrReservedRange) ||
-			errors.Is(err, ipapicom.ErrPrivateRange)) {
-			return nil, fmt.Errorf(
-				"can't get geolocation with ip-api.com: %w",
-				err,
-			)
-		}
-
-		if g != nil {
-			geo.Organisation = []string{g.Org, g.Isp, g.As}
-			geo.CountryCode = g.CountryCode
-			geo.Country = g.Country
-			geo.RegionCode = g.Region
-			geo.Region = g.RegionName
-			geo.City = g.City
-			geo.Timezone = g.Timezone
-			geo.ASN, _, _ = strings.Cut(g.As, " ")
-		}
-	}
-
-	logger.Debug().Any("geo", geo).Msg("New geo lookup")
-	err = f.db.SaveGeolocation(ip, geo)
-	if err != nil {
-		return nil, fmt.Errorf("can't save geolocation: %w", err)
-	}
-
-	return geo, nil
-}
-
 func (f *GeoRule) findByRegexp(
 	geo *database.Geolocation,
 	logger zerolog.Logger,
@@ -610,7 +1157,8 @@ func (f *GeoRule) findByRegexp(
 	gs := reflect.ValueOf(geo).Elem()
 	for i := 0; i < gs.NumField(); i++ {
 		gv := gs.Field(i)
-		if gv.Len() == 0 {
+		if (gv.Kind() != reflect.String && gv.Kind() != reflect.Slice) ||
+			gv.Len() == 0 {
 			continue
 		}
 
@@ -687,16 +1235,507 @@ func (f *GeoRule) String() string {
 	)
 }
 
+type GeoCircleParams struct {
+	Latitude  float64 `mapstructure:"latitude"`
+	Longitude float64 `mapstructure:"longitude"`
+	// Radius in kilometers.
+	Radius float64 `mapstructure:"radius"`
+}
+
+type GeoRadiusParams struct {
+	Circles []GeoCircleParams `mapstructure:"circles"`
+	// Polygons is a path to GeoJSON file.
+	Polygons string `mapstructure:"polygons"`
+}
+
+type geoCircle struct {
+	center geo.Point
+	radius float64
+}
+
+func (c geoCircle) String() string {
+	return fmt.Sprintf("%s/%gkm", c.center, c.radius)
+}
+
+type GeoRadiusRule struct {
+	lookup   *geoLookup
+	circles  []geoCircle
+	path     string
+	polygons []geo.Polygon
+}
+
+func (f *GeoRadiusRule) Prepare(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) error {
+	_, err := f.lookup.get(e, logger)
+	if err != nil {
+		return fmt.Errorf("can't prepare geolocation info: %w", err)
+	}
+	return nil
+}
+
+func (f *GeoRadiusRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	g, err := f.lookup.get(e, logger)
+	if err != nil {
+		return false, fmt.Errorf("can't get geolocation info: %w", err)
+	}
+	if !g.Located {
+		logger.Debug().Msg("Geolocation has no coordinates")
+		return false, nil
+	}
+
+	p := geo.Point{Latitude: g.Latitude, Longitude: g.Longitude}
+	for _, c := range f.circles {
+		d := geo.Distance(c.center, p)
+		if d <= c.radius {
+			logger.Debug().
+				Stringer("match", c).
+				Float64("distance", d).
+				Msg("Geo radius match")
+			return true, nil
+		}
+	}
+	for _, poly := range f.polygons {
+		if poly.Contains(p) {
+			logger.Debug().Stringer("point", p).Msg("Geo polygon match")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *GeoRadiusRule) String() string {
+	return fmt.Sprintf(
+		"GeoRadius(circles=%s, polygons=%s)",
+		common.FormatStringerSlice(f.circles),
+		f.path,
+	)
+}
+
+const (
+	IPClassProxy   = "proxy"
+	IPClassHosting = "hosting"
+	IPClassMobile  = "mobile"
+)
+
+type IPClassParams struct {
+	Classes []string `mapstructure:"classes"`
+}
+
+type IPClassRule struct {
+	lookup  *geoLookup
+	classes []string
+}
+
+func (f *IPClassRule) Prepare(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) error {
+	_, err := f.lookup.getClassified(e, logger)
+	if err != nil {
+		return fmt.Errorf("can't prepare ip class info: %w", err)
+	}
+	return nil
+}
+
+func (f *IPClassRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	geo, err := f.lookup.getClassified(e, logger)
+	if err != nil {
+		return false, fmt.Errorf("can't get ip class info: %w", err)
+	}
+
+	for _, c := range f.classes {
+		var m bool
+		switch c {
+		case IPClassProxy:
+			m = geo.Proxy
+		case IPClassHosting:
+			m = geo.Hosting
+		case IPClassMobile:
+			m = geo.Mobile
+		}
+		if m {
+			logger.Debug().Str("match", c).Msg("IP class match")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *IPClassRule) String() string {
+	return fmt.Sprintf(
+		"IPClass(classes=%s)",
+		common.FormatStringSlice(f.classes),
+	)
+}
+
+type ASNParams struct {
+	// Database is a path to MMDB or TSV database.
+	Database      string   `mapstructure:"database"`
+	ASNs          []string `mapstructure:"asns"`
+	Path          string   `mapstructure:"list"`
+	Organisations []string `mapstructure:"organisations"`
+}
+
+type ASNRule struct {
+	database      string
+	path          string
+	asns          map[uint32]struct{}
+	organisations []*regexp.Regexp
+	db            *atomic.Pointer[asndb.Database]
+}
+
+func (f *ASNRule) Prepare(
+	_ wrapper.Entity,
+	_ zerolog.Logger,
+) error {
+	return nil
+}
+
+func (f *ASNRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	r, ok := (*f.db.Load()).Lookup(e.GetIP())
+	if !ok {
+		return false, nil
+	}
+
+	if _, ok = f.asns[r.ASN]; ok {
+		logger.Debug().Stringer("match", r).Msg("ASN match")
+		return true, nil
+	}
+	if re := matchAny(f.organisations, r.Organisation); re != nil {
+		logger.Debug().
+			Stringer("match", re).
+			Stringer("asn", r).
+			Msg("ASN organisation match")
+		return true, nil
+	}
+	return false, nil
+}
+
+// reload replaces database, old one is kept on error.
+func (f *ASNRule) reload() {
+	db, err := asndb.Open(f.database)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("database", f.database).
+			Msg("Can't reload ASN database")
+		return
+	}
+	f.db.Store(&db)
+	log.Info().
+		Str("database", f.database).
+		Int("networks", db.Len()).
+		Msg("ASN database reloaded")
+}
+
+func (f *ASNRule) String() string {
+	asns := maps.Keys(f.asns)
+	slices.Sort(asns)
+	return fmt.Sprintf(
+		"ASN(database=%s, asns=%v, list=%s, organisations=%s)",
+		f.database,
+		asns,
+		f.path,
+		common.FormatStringerSlice(f.organisations),
+	)
+}
+
+type CloudSourceParams struct {
+	Provider string `mapstructure:"provider"`
+	Path     string `mapstructure:"path"`
+}
+
+// NOTE: services and regions are case-insensitive masks, see
+// matchByMask.
+type CloudMatchParams struct {
+	Provider        string   `mapstructure:"provider"`
+	Services        []string `mapstructure:"services"`
+	Regions         []string `mapstructure:"regions"`
+	ExcludeServices []string `mapstructure:"exclude_services"`
+	ExcludeRegions  []string `mapstructure:"exclude_regions"`
+}
+
+type CloudParams struct {
+	Sources []CloudSourceParams `mapstructure:"sources"`
+	Match   []CloudMatchParams  `mapstructure:"match"`
+}
+
+// cloudSelector matches range if all its non-empty fields match.
+type cloudSelector struct {
+	provider        string
+	services        []string
+	regions         []string
+	excludeServices []string
+	excludeRegions  []string
+}
+
+func (s *cloudSelector) match(r cloudranges.Range) bool {
+	anyMask := func(masks []string, v string) bool {
+		v = strings.ToLower(v)
+		for _, m := range masks {
+			if matchByMask(v, strings.ToLower(m)) {
+				return true
+			}
+		}
+		return false
+	}
+	return (s.provider == "" || s.provider == r.Provider) &&
+		(len(s.services) == 0 || anyMask(s.services, r.Service)) &&
+		(len(s.regions) == 0 || anyMask(s.regions, r.Region)) &&
+		!anyMask(s.excludeServices, r.Service) &&
+		!anyMask(s.excludeRegions, r.Region)
+}
+
+func (s *cloudSelector) String() string {
+	return fmt.Sprintf(
+		"cloud(provider=%s, services=%s, regions=%s, "+
+			"exclude_services=%s, exclude_regions=%s)",
+		s.provider,
+		common.FormatStringSlice(s.services),
+		common.FormatStringSlice(s.regions),
+		common.FormatStringSlice(s.excludeServices),
+		common.FormatStringSlice(s.excludeRegions),
+	)
+}
+
+type CloudRule struct {
+	sources []CloudSourceParams
+	// any range matches if empty.
+	matches []*cloudSelector
+	index   *atomic.Pointer[cloudranges.Index]
+}
+
+func (f *CloudRule) Prepare(
+	_ wrapper.Entity,
+	_ zerolog.Logger,
+) error {
+	return nil
+}
+
+func (f *CloudRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	for _, r := range f.index.Load().Lookup(e.GetIP()) {
+		if len(f.matches) == 0 {
+			f.logMatch(r, logger)
+			return true, nil
+		}
+		for _, s := range f.matches {
+			if s.match(r) {
+				f.logMatch(r, logger)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (f *CloudRule) logMatch(r cloudranges.Range, logger zerolog.Logger) {
+	logger.Debug().
+		Str("provider", r.Provider).
+		Str("service", r.Service).
+		Str("region", r.Region).
+		Stringer("prefix", r.Prefix).
+		Msg("Cloud range match")
+}
+
+// reload replaces ranges of all sources, old ones are kept on error.
+func (f *CloudRule) reload() {
+	idx, err := loadCloudRanges(f.sources)
+	if err != nil {
+		log.Error().Err(err).Msg("Can't reload cloud ranges")
+		return
+	}
+	f.index.Store(idx)
+	log.Info().Int("ranges", idx.Len()).Msg("Cloud ranges reloaded")
+}
+
+func (f *CloudRule) String() string {
+	sources := make([]string, 0, len(f.sources))
+	for _, s := range f.sources {
+		sources = append(sources, s.Provider+":"+s.Path)
+	}
+	return fmt.Sprintf(
+		"Cloud(sources=%s, match=%s)",
+		common.FormatStringSlice(sources),
+		common.FormatStringerSlice(f.matches),
+	)
+}
+
+// geoLookup fetches geolocation of entity from cache or from geo APIs
+// in turn.
+type geoLookup struct {
+	db         *database.DB
+	apicounter *atomic.Int32
+	ipapico    ipapico.Client
+	ipapicom   ipapicom.Client
+}
+
+func newGeoLookup(db *database.DB, globals common.Globals) *geoLookup {
+	l := &geoLookup{
+		db:         db,
+		apicounter: atomic.NewInt32(0),
+		ipapico:    ipapico.NewClient(),
+		ipapicom:   ipapicom.NewClient(),
+	}
+	if globals.IPApiCoKey != "" {
+		l.ipapico = ipapico.NewClientWithAPIKey(globals.IPApiCoKey)
+	}
+	if globals.IPApiComKey != "" {
+		l.ipapicom = ipapicom.NewClientWithAPIKey(globals.IPApiComKey)
+	}
+	return l
+}
+
+func (l *geoLookup) get(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (*database.Geolocation, error) {
+	return l.lookup(e.GetIP().String(), false, logger)
+}
+
+// getClassified is like get, but geolocation is refetched from provider
+// with IP classification if cached one has no classification.
+func (l *geoLookup) getClassified(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (*database.Geolocation, error) {
+	return l.lookup(e.GetIP().String(), true, logger)
+}
+
+func (l *geoLookup) lookup(
+	ip string,
+	classify bool,
+	logger zerolog.Logger,
+) (*database.Geolocation, error) {
+	geo, err := l.db.GetGeolocation(ip)
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("can't get cached geolocation: %w", err)
+	}
+	if geo != nil && (geo.Classified || !classify) {
+		return geo, nil
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Second*5, //nolint:gomnd
+	)
+	defer cancel()
+
+	// only ip-api.com classifies IPs
+	if classify || l.apicounter.Inc()%2 == 1 {
+		geo, err = l.fromIPApiCom(ctx, ip)
+	} else {
+		geo, err = l.fromIPApiCo(ctx, ip)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug().Any("geo", geo).Msg("New geo lookup")
+	err = l.db.SaveGeolocation(ip, geo)
+	if err != nil {
+		return nil, fmt.Errorf("can't save geolocation: %w", err)
+	}
+
+	return geo, nil
+}
+
+func (l *geoLookup) fromIPApiCo(
+	ctx context.Context,
+	ip string,
+) (*database.Geolocation, error) {
+	g, err := l.ipapico.GetLocationForIP(ctx, ip)
+	if err != nil && !errors.Is(err, ipapico.ErrReservedRange) {
+		return nil, fmt.Errorf(
+			"can't get geolocation with ipapi.co: %w",
+			err,
+		)
+	}
+
+	geo := &database.Geolocation{}
+	if g != nil {
+		geo.Organisation = []string{g.Org}
+		geo.CountryCode = g.Country
+		geo.Country = g.CountryName
+		geo.RegionCode = g.RegionCode
+		geo.Region = g.Region
+		geo.City = g.City
+		geo.Timezone = g.Timezone
+		geo.ASN = g.Asn
+		geo.Located = true
+		geo.Latitude = float64(g.Latitude)
+		geo.Longitude = float64(g.Longitude)
+	}
+	return geo, nil
+}
+
+func (l *geoLookup) fromIPApiCom(
+	ctx context.Context,
+	ip string,
+) (*database.Geolocation, error) {
+	g, err := l.ipapicom.GetLocationForIP(ctx, ip)
+	if err != nil && !(errors.Is(err, ipapicom.ErrReservedRange) ||
+		errors.Is(err, ipapicom.ErrPrivateRange)) {
+		return nil, fmt.Errorf(
+			"can't get geolocation with ip-api.com: %w",
+			err,
+		)
+	}
+
+	// reserved and private ranges are not proxy, hosting or mobile
+	geo := &database.Geolocation{Classified: true}
+	if g != nil {
+		geo.Organisation = []string{g.Org, g.Isp, g.As}
+		geo.CountryCode = g.CountryCode
+		geo.Country = g.Country
+		geo.RegionCode = g.Region
+		geo.Region = g.RegionName
+		geo.City = g.City
+		geo.Timezone = g.Timezone
+		geo.ASN, _, _ = strings.Cut(g.As, " ")
+		geo.Located = true
+		geo.Latitude = float64(g.Lat)
+		geo.Longitude = float64(g.Lon)
+		geo.Proxy = g.Proxy
+		geo.Hosting = g.Hosting
+		geo.Mobile = g.Mobile
+	}
+	return geo, nil
+}
+
+// maxConfirmDomains limits forward lookups of one PTR answer.
+const maxConfirmDomains = 10
+
+// defaultConfirmTTL is used for answers without TTL, e.g. NXDOMAIN without
+// SOA record.
+const defaultConfirmTTL = time.Hour
+
 type ReverseLookupParams struct {
 	DNS  string `mapstructure:"dns"`
 	Path string `mapstructure:"list"`
 }
 
 type ReverseLookupRule struct {
-	db   *database.DB
-	path string
-	dns  netip.AddrPort
-	list []*regexp.Regexp
+	db       *database.DB
+	path     string
+	resolver *resolver.Resolver
+	list     []*regexp.Regexp
+
+	// confirm keeps only forward-confirmed domains (FCrDNS)
+	confirm bool
 }
 
 func (f *ReverseLookupRule) Prepare(
@@ -736,9 +1775,14 @@ func (f *ReverseLookupRule) getDomainByEntity(
 	e wrapper.Entity,
 	logger zerolog.Logger,
 ) (*database.ReverseLookup, error) {
-	ip := e.GetIP().String()
+	ip := e.GetIP()
+
+	get := f.db.GetReverseLookup
+	if f.confirm {
+		get = f.db.GetConfirmedLookup
+	}
 
-	rl, err := f.db.GetReverseLookup(ip)
+	rl, err := get(ip.String())
 	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
 		return nil, fmt.Errorf("can't get cached reverse lookup: %w", err)
 	}
@@ -747,18 +1791,68 @@ func (f *ReverseLookupRule) getDomainByEntity(
 	}
 
 	rl = &database.ReverseLookup{}
+	var ttl time.Duration
+	rl.Domains, ttl, err = f.lookupPTR(ip)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug().
+		Strs("ptr", rl.Domains).
+		Msg("New reverse lookup")
 
-	addr, _ := dns.ReverseAddr(ip)
-	m := new(dns.Msg)
-	m.SetQuestion(addr, dns.TypePTR)
-	m.RecursionDesired = true
+	if !f.confirm {
+		err = f.db.SaveReverseLookup(ip.String(), rl)
+		if err != nil {
+			return nil, fmt.Errorf("can't save reverse lookup: %w", err)
+		}
+		return rl, nil
+	}
 
-	c := new(dns.Client)
-	r, _, err := c.Exchange(m, f.dns.String())
+	// confirmation is cached while all its answers are valid
+	rl.Domains, ttl, err = f.confirmDomains(
+		ip.Unmap(),
+		rl.Domains,
+		ttl,
+		logger,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("can't create PTR dns request: %w", err)
+		return nil, err
+	}
+	logger.Debug().
+		Strs("ptr", rl.Domains).
+		Dur("ttl", ttl).
+		Msg("New forward-confirmed reverse lookup")
+	if ttl == 0 {
+		return rl, nil
 	}
 
+	err = f.db.SaveConfirmedLookup(ip.String(), rl, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't save reverse lookup: %w", err)
+	}
+	return rl, nil
+}
+
+// getAnswerTTL returns TTL of DNS answer or defaultConfirmTTL if answer
+// has no records.
+func getAnswerTTL(r *dns.Msg) time.Duration {
+	if t, ok := resolver.TTL(r); ok {
+		return time.Duration(t) * time.Second
+	}
+	return defaultConfirmTTL
+}
+
+// lookupPTR returns PTR domains of ip and TTL of the answer.
+func (f *ReverseLookupRule) lookupPTR(
+	ip netip.Addr,
+) ([]string, time.Duration, error) {
+	addr, _ := dns.ReverseAddr(ip.String())
+	r, err := f.resolver.Lookup(context.Background(), addr, dns.TypePTR)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't create PTR dns request: %w", err)
+	}
+
+	var domains []string
 	for _, a := range r.Answer {
 		ptr, ok := a.(*dns.PTR)
 		if !ok {
@@ -768,24 +1862,265 @@ func (f *ReverseLookupRule) getDomainByEntity(
 			continue
 		}
 
-		rl.Domains = append(rl.Domains, ptr.Ptr[:len(ptr.Ptr)-1])
+		domains = append(domains, ptr.Ptr[:len(ptr.Ptr)-1])
 	}
+	return domains, getAnswerTTL(r), nil
+}
 
-	logger.Debug().
-		Strs("ptr", rl.Domains).
-		Msg("New reverse lookup")
-	err = f.db.SaveReverseLookup(ip, rl)
+// confirmDomains returns domains having A/AAAA record of ip and ttl
+// lowered to the minimal TTL of A/AAAA answers. Only first
+// maxConfirmDomains domains are resolved, so huge PTR answers can't slow
+// down filtering.
+func (f *ReverseLookupRule) confirmDomains(
+	ip netip.Addr,
+	domains []string,
+	ttl time.Duration,
+	logger zerolog.Logger,
+) ([]string, time.Duration, error) {
+	qtype := dns.TypeA
+	if ip.Is6() {
+		qtype = dns.TypeAAAA
+	}
+
+	var confirmed []string
+	for i, d := range domains {
+		if i == maxConfirmDomains {
+			break
+		}
+		addrs, addrsTTL, err := f.lookupAddrs(d, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		if addrsTTL < ttl {
+			ttl = addrsTTL
+		}
+		if slices.Contains(addrs, ip) {
+			confirmed = append(confirmed, d)
+			continue
+		}
+		logger.Debug().
+			Str("ptr", d).
+			Any("addrs", addrs).
+			Msg("PTR domain isn't forward-confirmed")
+	}
+	return confirmed, ttl, nil
+}
+
+// lookupAddrs returns addresses of domain and TTL of the answer.
+func (f *ReverseLookupRule) lookupAddrs(
+	domain string,
+	qtype uint16,
+) ([]netip.Addr, time.Duration, error) {
+	r, err := f.resolver.Lookup(context.Background(), domain, qtype)
 	if err != nil {
-		return nil, fmt.Errorf("can't save reverse lookup: %w", err)
+		return nil, 0, fmt.Errorf("can't create A/AAAA dns request: %w", err)
 	}
 
-	return rl, nil
+	var addrs []netip.Addr
+	for _, a := range r.Answer {
+		var ip net.IP
+		switch rr := a.(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		default:
+			// e.g. CNAME before addresses
+			continue
+		}
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs, getAnswerTTL(r), nil
 }
 
 func (f *ReverseLookupRule) String() string {
 	return fmt.Sprintf(
-		"ReverseLookup(list=%s, dns=%s)",
+		"ReverseLookup(list=%s, dns=%s, confirm=%t)",
 		f.path,
-		f.dns.String(),
+		f.resolver,
+		f.confirm,
+	)
+}
+
+// defaultDNSBLTTL is used for answers without TTL, e.g. NXDOMAIN without
+// SOA record.
+const defaultDNSBLTTL = time.Hour
+
+type DNSBLZoneParams struct {
+	Zone string `mapstructure:"zone"`
+	// Codes are return IPs or subnets meaning listing.
+	Codes []string `mapstructure:"codes"`
+	IPv6  bool     `mapstructure:"ipv6"`
+}
+
+type DNSBLParams struct {
+	DNS     string            `mapstructure:"dns"`
+	Zones   []DNSBLZoneParams `mapstructure:"zones"`
+	MinHits int               `mapstructure:"min_hits"`
+}
+
+type dnsblZone struct {
+	zone  string
+	codes []netip.Prefix
+	ipv6  bool
+}
+
+// listed checks return code of zone. Without codes any 127.0.0.0/8 code
+// except 127.255.255.0/24 (query errors, e.g. of Spamhaus) is listing.
+func (z dnsblZone) listed(code netip.Addr) bool {
+	if len(z.codes) == 0 {
+		return netip.MustParsePrefix("127.0.0.0/8").Contains(code) &&
+			!netip.MustParsePrefix("127.255.255.0/24").Contains(code)
+	}
+	return slices.ContainsFunc(z.codes, func(p netip.Prefix) bool {
+		return p.Contains(code)
+	})
+}
+
+// query returns DNS name of ip in zone: reversed octets for IPv4 and
+// reversed nibbles for IPv6.
+func (z dnsblZone) query(ip netip.Addr) string {
+	addr, _ := dns.ReverseAddr(ip.String())
+	addr = strings.TrimSuffix(addr, "in-addr.arpa.")
+	addr = strings.TrimSuffix(addr, "ip6.arpa.")
+	return addr + z.zone
+}
+
+type DNSBLRule struct {
+	db       *database.DB
+	resolver *resolver.Resolver
+	zones    []dnsblZone
+	minHits  int
+}
+
+func (f *DNSBLRule) Prepare(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) error {
+	f.getAnswers(e, logger)
+	return nil
+}
+
+func (f *DNSBLRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	answers := f.getAnswers(e, logger)
+
+	var hits []string
+	for i, z := range f.zones {
+		if answers[i] == nil {
+			continue
+		}
+		for _, c := range answers[i].Codes {
+			code, perr := netip.ParseAddr(c)
+			if perr == nil && z.listed(code) {
+				logger.Debug().
+					Str("zone", z.zone).
+					Str("code", c).
+					Msg("DNSBL listing")
+				hits = append(hits, z.zone)
+				break
+			}
+		}
+	}
+
+	if len(hits) >= f.minHits {
+		logger.Debug().Strs("match", hits).Msg("DNSBL match")
+		return true, nil
+	}
+	return false, nil
+}
+
+// getAnswers returns answers of zones, nil for zones without IPv6 support
+// if ip is IPv6. Failed zone is logged and its answer is nil too, so dead
+// zone doesn't disable the others.
+func (f *DNSBLRule) getAnswers(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) []*database.DNSBL {
+	ip := e.GetIP().Unmap()
+
+	answers := make([]*database.DNSBL, len(f.zones))
+	var wg sync.WaitGroup
+	for i, z := range f.zones {
+		if ip.Is6() && !z.ipv6 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, z dnsblZone) {
+			defer wg.Done()
+			d, err := f.lookup(ip, z, logger)
+			if err != nil {
+				logger.Error().
+					Err(err).
+					Str("zone", z.zone).
+					Msg("Can't get dnsbl answer, zone is skipped")
+				return
+			}
+			answers[i] = d
+		}(i, z)
+	}
+	wg.Wait()
+	return answers
+}
+
+func (f *DNSBLRule) lookup(
+	ip netip.Addr,
+	z dnsblZone,
+	logger zerolog.Logger,
+) (*database.DNSBL, error) {
+	d, err := f.db.GetDNSBL(z.zone, ip.String())
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("can't get cached dnsbl answer: %w", err)
+	}
+	if d != nil {
+		return d, nil
+	}
+
+	r, err := f.resolver.Lookup(context.Background(), z.query(ip), dns.TypeA)
+	if err != nil {
+		return nil, fmt.Errorf("can't query dnsbl \"%s\": %w", z.zone, err)
+	}
+
+	// NXDOMAIN means IP isn't listed
+	d = &database.DNSBL{}
+	for _, a := range r.Answer {
+		if rr, ok := a.(*dns.A); ok {
+			d.Codes = append(d.Codes, rr.A.String())
+		}
+	}
+
+	ttl := defaultDNSBLTTL
+	if t, ok := resolver.TTL(r); ok {
+		ttl = time.Duration(t) * time.Second
+	}
+	logger.Debug().
+		Str("zone", z.zone).
+		Strs("codes", d.Codes).
+		Dur("ttl", ttl).
+		Msg("New dnsbl answer")
+	if ttl == 0 {
+		return d, nil
+	}
+	err = f.db.SaveDNSBL(z.zone, ip.String(), d, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't save dnsbl answer: %w", err)
+	}
+	return d, nil
+}
+
+func (f *DNSBLRule) String() string {
+	zones := make([]string, 0, len(f.zones))
+	for _, z := range f.zones {
+		zones = append(zones, z.zone)
+	}
+	return fmt.Sprintf(
+		"DNSBL(zones=%s, min_hits=%d, dns=%s)",
+		common.FormatStringSlice(zones),
+		f.minHits,
+		f.resolver,
 	)
 }
@@ -1,17 +1,26 @@
 package rules_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
 	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/lists"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	badger "github.com/dgraph-io/badger/v3"
 	"github.com/miekg/dns"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/mock"
@@ -66,6 +75,24 @@ func (m *MockEntity) GetQuestions() ([]dns.Question, error) {
 	return args.Get(0).([]dns.Question), args.Error(1)
 }
 
+func (m *MockEntity) GetClientHello() (*tlshello.ClientHello, error) {
+	args := m.Called()
+	//nolint: wrapcheck // mock
+	return args.Get(0).(*tlshello.ClientHello), args.Error(1)
+}
+
+func (m *MockEntity) GetSSHHandshake() (*hassh.Handshake, error) {
+	args := m.Called()
+	//nolint: wrapcheck // mock
+	return args.Get(0).(*hassh.Handshake), args.Error(1)
+}
+
+func (m *MockEntity) GetSMTPEnvelope() (*wrapper.SMTPEnvelope, error) {
+	args := m.Called()
+	//nolint: wrapcheck // mock
+	return args.Get(0).(*wrapper.SMTPEnvelope), args.Error(1)
+}
+
 func mod(x int, y int) int {
 	return (x%y + y) % y
 }
@@ -130,6 +157,88 @@ func TestBase_RegexpRule(t *testing.T) {
 				applyErr:   false,
 			},
 		},
+		{
+			"regexp json format true",
+			args{
+				raw:       []byte("test of that nice rule with two word"),
+				getRawErr: nil,
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "regexp",
+					Params: map[string]any{
+						"list":     "../../test/testdata/words_lists/banlist_regexp.json",
+						"format":   "json",
+						"selector": "patterns[*].regexp",
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"regexp builtin pack true",
+			args{
+				raw:       []byte("GET /.git/config HTTP/1.1\r\nHost: a\r\n"),
+				getRawErr: nil,
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "regexp",
+					Params: map[string]any{
+						"list": "builtin://scanner-paths",
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"regexp builtin pack false",
+			args{
+				raw:       []byte("GET /index.html HTTP/1.1\r\nHost: a\r\n"),
+				getRawErr: nil,
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "regexp",
+					Params: map[string]any{
+						"list": "builtin://scanner-paths",
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"regexp unknown pack",
+			args{
+				raw:       []byte("test"),
+				getRawErr: nil,
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "regexp",
+					Params: map[string]any{
+						"list": "builtin://unknown",
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
 		{
 			"regexp false",
 			args{
@@ -295,6 +404,63 @@ func TestBase_IPRule(t *testing.T) {
 				applyErr:   false,
 			},
 		},
+		{
+			"ip rule builtin pack true",
+			args{
+				ip: "10.1.2.3",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "ip",
+					Params: map[string]any{
+						"list": "builtin://reserved-networks",
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"ip rule builtin pack false",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "ip",
+					Params: map[string]any{
+						"list": "builtin://reserved-networks",
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"ip rule unknown pack",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "ip",
+					Params: map[string]any{
+						"list": "builtin://unknown",
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
 		{
 			"ip rule true ip v6",
 			args{
@@ -502,82 +668,16 @@ func TestBase_IPRule(t *testing.T) {
 				applyErr:   false,
 			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			rule, err := rules.NewIPRule(
-				nil,
-				rules.RuleSet{},
-				tt.args.cfg,
-				common.Globals{},
-			)
-			require.Equalf(
-				t,
-				tt.want.createErr,
-				err != nil,
-				"NewIPRule() error mismatch: %s",
-				err,
-			)
-
-			if !tt.want.createErr {
-				e := new(MockEntity)
-				e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
-
-				err = rule.Prepare(e, log.Logger)
-				require.Equalf(
-					t,
-					tt.want.prepareErr,
-					err != nil,
-					"Prepare() error mismatch: %s",
-					err,
-				)
-
-				res, err := rule.Apply(e, log.Logger)
-				require.Equalf(
-					t,
-					tt.want.applyErr,
-					err != nil,
-					"Apply() error mismatch: %s",
-					err,
-				)
-				require.Equal(
-					t,
-					tt.want.res,
-					res,
-					"Apply() result mismatch",
-				)
-				e.AssertExpectations(t)
-			}
-		})
-	}
-}
-
-// test ignores timezone.
-func TestBase_TimeRule(t *testing.T) {
-	type args struct {
-		cfg common.RuleConfig
-	}
-	type want struct {
-		res        bool
-		createErr  bool
-		prepareErr bool
-		applyErr   bool
-	}
-	tests := []struct {
-		name string
-		args args
-		want want
-	}{
 		{
-			"time hour true",
+			"ip rule drop format true",
 			args{
+				ip: "1.19.2.3",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from":     getTime(-1, 0),
-						"to":       getTime(1, 0),
-						"weekdays": []string{},
+						"list":   "../../test/testdata/ip_lists/drop.txt",
+						"format": "drop",
 					},
 				},
 			},
@@ -589,37 +689,35 @@ func TestBase_TimeRule(t *testing.T) {
 			},
 		},
 		{
-			"time minute true",
+			"ip rule drop format false",
 			args{
+				ip: "1.20.2.3",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from":     getTime(0, -10),
-						"to":       getTime(0, 10),
-						"weekdays": []string{},
+						"list":   "../../test/testdata/ip_lists/drop.txt",
+						"format": "drop",
 					},
 				},
 			},
 			want{
-				res:        true,
+				res:        false,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"time weekday true",
+			"ip rule range format true",
 			args{
+				ip: "10.0.0.15",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from": getTime(0, -10),
-						"to":   getTime(0, 10),
-						"weekdays": []string{
-							getWeekdayName(0),
-						},
+						"list":   "../../test/testdata/ip_lists/ranges.txt",
+						"format": "range",
 					},
 				},
 			},
@@ -631,147 +729,99 @@ func TestBase_TimeRule(t *testing.T) {
 			},
 		},
 		{
-			"time between days true",
+			"ip rule range format false",
 			args{
+				ip: "10.0.0.21",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from": getTime(0, -1),
-						"to":   getTime(23, 59),
-						"weekdays": []string{
-							getWeekdayName(-1),
-							getWeekdayName(0),
-							getWeekdayName(1),
-						},
+						"list":   "../../test/testdata/ip_lists/ranges.txt",
+						"format": "range",
 					},
 				},
 			},
 			want{
-				res:        true,
+				res:        false,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"time hour false",
+			"ip rule range format spaces true",
 			args{
+				ip: "10.1.200.1",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from":     getTime(-2, 0),
-						"to":       getTime(-1, 0),
-						"weekdays": []string{},
+						"list":   "../../test/testdata/ip_lists/ranges.txt",
+						"format": "range",
 					},
 				},
 			},
 			want{
-				res:        false,
+				res:        true,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"time minute false",
+			"ip rule csv format true",
 			args{
+				ip: "5.5.5.5",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from":     getTime(0, -20),
-						"to":       getTime(0, -10),
-						"weekdays": []string{},
+						"list":   "../../test/testdata/ip_lists/allowlist.csv",
+						"format": "csv",
+						"column": "ip",
 					},
 				},
 			},
 			want{
-				res:        false,
+				res:        true,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"time weekday false",
+			"ip rule csv numbered column true",
 			args{
+				ip: "4.4.4.4",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from": getTime(0, -10),
-						"to":   getTime(0, 10),
-						"weekdays": []string{
-							getWeekdayName(1),
-						},
+						"list":      "../../test/testdata/ip_lists/allowlist_semicolon.csv",
+						"format":    "csv",
+						"column":    2,
+						"delimiter": ";",
 					},
 				},
 			},
 			want{
-				res:        false,
-				createErr:  false,
-				prepareErr: false,
-				applyErr:   false,
-			},
-		},
-		{
-			"time between days false",
-			args{
-				cfg: common.RuleConfig{
-					Name: "test",
-					Type: "time",
-					Params: map[string]any{
-						"from": getTime(-23, -59),
-						"to":   getTime(0, 1),
-						"weekdays": []string{
-							getWeekdayName(-1),
-						},
-					},
-				},
-			},
-			want{
-				res:        false,
+				res:        true,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"time bad from",
-			args{
-				cfg: common.RuleConfig{
-					Name: "test",
-					Type: "time",
-					Params: map[string]any{
-						"from": "",
-						"to":   getTime(0, 10),
-						"weekdays": []string{
-							getWeekdayName(1),
-						},
-					},
-				},
-			},
-			want{
-				res:        false,
-				createErr:  true,
-				prepareErr: false,
-				applyErr:   false,
-			},
-		},
-		{
-			"time bad to",
+			"ip rule csv wrong column",
 			args{
+				ip: "4.4.4.4",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from": getTime(0, -10),
-						"to":   "",
-						"weekdays": []string{
-							getWeekdayName(1),
-						},
+						"list":   "../../test/testdata/ip_lists/allowlist.csv",
+						"format": "csv",
+						"column": "comment",
 					},
 				},
 			},
@@ -783,17 +833,15 @@ func TestBase_TimeRule(t *testing.T) {
 			},
 		},
 		{
-			"time bad weekday",
+			"ip rule broken range",
 			args{
+				ip: "10.0.0.15",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from": getTime(0, -10),
-						"to":   getTime(0, 10),
-						"weekdays": []string{
-							"some bad weekday",
-						},
+						"list":   "../../test/testdata/ip_lists/broken_range.txt",
+						"format": "range",
 					},
 				},
 			},
@@ -805,16 +853,15 @@ func TestBase_TimeRule(t *testing.T) {
 			},
 		},
 		{
-			"time bad timezone",
+			"ip rule unknown format",
 			args{
+				ip: "10.0.0.15",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "time",
+					Type: "ip",
 					Params: map[string]any{
-						"from":     getTime(0, -10),
-						"to":       getTime(0, 10),
-						"weekdays": []string{},
-						"timezone": "some bad timezone",
+						"list":   "../../test/testdata/ip_lists/ranges.txt",
+						"format": "xml",
 					},
 				},
 			},
@@ -828,7 +875,7 @@ func TestBase_TimeRule(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rule, err := rules.NewTimeRule(
+			rule, err := rules.NewIPRule(
 				nil,
 				rules.RuleSet{},
 				tt.args.cfg,
@@ -838,12 +885,15 @@ func TestBase_TimeRule(t *testing.T) {
 				t,
 				tt.want.createErr,
 				err != nil,
-				"NewTimeRule() error mismatch: %s",
+				"NewIPRule() error mismatch: %s",
 				err,
 			)
 
 			if !tt.want.createErr {
-				err = rule.Prepare(nil, log.Logger)
+				e := new(MockEntity)
+				e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
+
+				err = rule.Prepare(e, log.Logger)
 				require.Equalf(
 					t,
 					tt.want.prepareErr,
@@ -852,26 +902,29 @@ func TestBase_TimeRule(t *testing.T) {
 					err,
 				)
 
-				res, err := rule.Apply(nil, log.Logger)
-				require.Equalf(t,
+				res, err := rule.Apply(e, log.Logger)
+				require.Equalf(
+					t,
 					tt.want.applyErr,
 					err != nil,
 					"Apply() error mismatch: %s",
 					err,
 				)
-				require.Equal(t,
+				require.Equal(
+					t,
 					tt.want.res,
 					res,
 					"Apply() result mismatch",
 				)
+				e.AssertExpectations(t)
 			}
 		})
 	}
 }
 
-func TestBase_GeoRule(t *testing.T) {
+// test ignores timezone.
+func TestBase_TimeRule(t *testing.T) {
 	type args struct {
-		ip  string
 		cfg common.RuleConfig
 	}
 	type want struct {
@@ -886,19 +939,15 @@ func TestBase_GeoRule(t *testing.T) {
 		want want
 	}{
 		{
-			"geo geolocations array true",
+			"time hour true",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{"(?i)google"},
-							},
-						},
+						"from":     getTime(-1, 0),
+						"to":       getTime(1, 0),
+						"weekdays": []string{},
 					},
 				},
 			},
@@ -910,19 +959,15 @@ func TestBase_GeoRule(t *testing.T) {
 			},
 		},
 		{
-			"geo geolocations string true",
+			"time minute true",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"country": []string{"(?i)united states"},
-							},
-						},
+						"from":     getTime(0, -10),
+						"to":       getTime(0, 10),
+						"weekdays": []string{},
 					},
 				},
 			},
@@ -934,18 +979,16 @@ func TestBase_GeoRule(t *testing.T) {
 			},
 		},
 		{
-			"geo list array true",
+			"time weekday true",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "../../test/testdata/words_lists/banlist_geo_true_array.txt",
-						"geolocations": []map[string][]string{
-							{
-								"country": []string{"some false regexp"},
-							},
+						"from": getTime(0, -10),
+						"to":   getTime(0, 10),
+						"weekdays": []string{
+							getWeekdayName(0),
 						},
 					},
 				},
@@ -958,91 +1001,80 @@ func TestBase_GeoRule(t *testing.T) {
 			},
 		},
 		{
-			"geo list array true",
+			"time weekdays with cron only err",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "../../test/testdata/words_lists/banlist_geo_true_string.txt",
-						"geolocations": []map[string][]string{
-							{
-								"country": []string{"some false regexp"},
-							},
-						},
+						"cron":     []string{"* * * * *"},
+						"weekdays": []string{getWeekdayName(0)},
 					},
 				},
 			},
 			want{
-				res:        true,
-				createErr:  false,
+				res:        false,
+				createErr:  true,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"geo geolocations array false",
+			"time weekdays with ranges only err",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{"some false org"},
-							},
-						},
+						"ranges": []map[string]any{{
+							"from": getTime(-1, 0),
+							"to":   getTime(1, 0),
+						}},
+						"weekdays": []string{getWeekdayName(0)},
 					},
 				},
 			},
 			want{
 				res:        false,
-				createErr:  false,
+				createErr:  true,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"geo geolocations string false",
+			"time between days true",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"country": []string{"some false regexp"},
-							},
+						"from": getTime(0, -1),
+						"to":   getTime(23, 59),
+						"weekdays": []string{
+							getWeekdayName(-1),
+							getWeekdayName(0),
+							getWeekdayName(1),
 						},
 					},
 				},
 			},
 			want{
-				res:        false,
+				res:        true,
 				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"geo list false",
+			"time hour false",
 			args{
-				ip: "8.8.8.8",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "../../test/testdata/words_lists/banlist_geo_false.txt",
-						"geolocations": []map[string][]string{
-							{
-								"country": []string{"some false regexp"},
-							},
-						},
+						"from":     getTime(-2, 0),
+						"to":       getTime(-1, 0),
+						"weekdays": []string{},
 					},
 				},
 			},
@@ -1054,20 +1086,15 @@ func TestBase_GeoRule(t *testing.T) {
 			},
 		},
 		{
-			"geo empty false",
+			"time minute false",
 			args{
-				ip: "1.1.1.1",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{},
-								"country":      []string{},
-							},
-						},
+						"from":     getTime(0, -20),
+						"to":       getTime(0, -10),
+						"weekdays": []string{},
 					},
 				},
 			},
@@ -1079,66 +1106,60 @@ func TestBase_GeoRule(t *testing.T) {
 			},
 		},
 		{
-			"geo geolocations err bad regexp",
+			"time weekday false",
 			args{
-				ip: "1.1.1.1",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{"(?i"},
-							},
+						"from": getTime(0, -10),
+						"to":   getTime(0, 10),
+						"weekdays": []string{
+							getWeekdayName(1),
 						},
 					},
 				},
 			},
 			want{
 				res:        false,
-				createErr:  true,
+				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"geo list err can't open file",
+			"time between days false",
 			args{
-				ip: "1.1.1.1",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "../../test/testdata/words_lists/banlist_1337.txt",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{"(?i)google"},
-							},
+						"from": getTime(-23, -59),
+						"to":   getTime(0, 1),
+						"weekdays": []string{
+							getWeekdayName(-1),
 						},
 					},
 				},
 			},
 			want{
 				res:        false,
-				createErr:  true,
+				createErr:  false,
 				prepareErr: false,
 				applyErr:   false,
 			},
 		},
 		{
-			"geo list err bad regexp",
+			"time bad from",
 			args{
-				ip: "1.1.1.1",
 				cfg: common.RuleConfig{
 					Name: "test",
-					Type: "geo",
+					Type: "time",
 					Params: map[string]any{
-						"list": "../../test/testdata/words_lists/broken_regexp.txt",
-						"geolocations": []map[string][]string{
-							{
-								"organisation": []string{"(?i)google"},
-							},
+						"from": "",
+						"to":   getTime(0, 10),
+						"weekdays": []string{
+							getWeekdayName(1),
 						},
 					},
 				},
@@ -1150,61 +1171,1590 @@ func TestBase_GeoRule(t *testing.T) {
 				applyErr:   false,
 			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			db, err := database.New("", true)
+		{
+			"time bad to",
+			args{
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "time",
+					Params: map[string]any{
+						"from": getTime(0, -10),
+						"to":   "",
+						"weekdays": []string{
+							getWeekdayName(1),
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"time bad weekday",
+			args{
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "time",
+					Params: map[string]any{
+						"from": getTime(0, -10),
+						"to":   getTime(0, 10),
+						"weekdays": []string{
+							"some bad weekday",
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"time bad timezone",
+			args{
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "time",
+					Params: map[string]any{
+						"from":     getTime(0, -10),
+						"to":       getTime(0, 10),
+						"weekdays": []string{},
+						"timezone": "some bad timezone",
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewTimeRule(
+				nil,
+				rules.RuleSet{},
+				tt.args.cfg,
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewTimeRule() error mismatch: %s",
+				err,
+			)
+
+			if !tt.want.createErr {
+				err = rule.Prepare(nil, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.prepareErr,
+					err != nil,
+					"Prepare() error mismatch: %s",
+					err,
+				)
+
+				res, err := rule.Apply(nil, log.Logger)
+				require.Equalf(t,
+					tt.want.applyErr,
+					err != nil,
+					"Apply() error mismatch: %s",
+					err,
+				)
+				require.Equal(t,
+					tt.want.res,
+					res,
+					"Apply() result mismatch",
+				)
+			}
+		})
+	}
+}
+
+func TestBase_TimeRuleCalendar(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// 2024-01-01 is Monday and a holiday
+	at := func(s string) time.Time {
+		tm, perr := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		require.NoError(t, perr)
+		return tm
+	}
+
+	type args struct {
+		params map[string]any
+		now    time.Time
+	}
+	type want struct {
+		res       bool
+		createErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"several ranges true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"ranges": []map[string]any{
+						{"from": "09:00", "to": "12:00"},
+						{"from": "13:00", "to": "18:00"},
+					},
+				},
+				now: at("2024-01-02 14:00"),
+			},
+			want{res: true},
+		},
+		{
+			"several ranges false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"ranges": []map[string]any{
+						{"from": "09:00", "to": "12:00"},
+						{"from": "13:00", "to": "18:00"},
+					},
+				},
+				now: at("2024-01-02 12:30"),
+			},
+			want{res: false},
+		},
+		{
+			"legacy range and ranges true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"from":     "09:00",
+					"to":       "10:00",
+					"ranges": []map[string]any{
+						{
+							"from":     "20:00",
+							"to":       "21:00",
+							"weekdays": []string{"Tuesday"},
+						},
+					},
+				},
+				now: at("2024-01-02 20:30"),
+			},
+			want{res: true},
+		},
+		{
+			"range weekday false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"ranges": []map[string]any{
+						{
+							"from":     "20:00",
+							"to":       "21:00",
+							"weekdays": []string{"Monday"},
+						},
+					},
+				},
+				now: at("2024-01-02 20:30"),
+			},
+			want{res: false},
+		},
+		{
+			"overnight range true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"from":     "22:00",
+					"to":       "02:00",
+				},
+				now: at("2024-01-02 01:00"),
+			},
+			want{res: true},
+		},
+		{
+			"range in timezone true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"from":     "09:00",
+					"to":       "10:00",
+				},
+				now: time.Date(2024, 1, 2, 8, 30, 0, 0, time.UTC),
+			},
+			want{res: true},
+		},
+		{
+			"cron true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"cron":     []string{"* 9-17 * * 1-5"},
+				},
+				now: at("2024-01-02 10:00"),
+			},
+			want{res: true},
+		},
+		{
+			"cron weekend false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"cron":     []string{"* 9-17 * * 1-5"},
+				},
+				now: at("2024-01-06 10:00"),
+			},
+			want{res: false},
+		},
+		{
+			"dates whole day true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"dates": []map[string]any{
+						{"from": "2024-01-02", "to": "2024-01-05"},
+					},
+				},
+				now: at("2024-01-05 23:59"),
+			},
+			want{res: true},
+		},
+		{
+			"dates whole day false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"dates": []map[string]any{
+						{"from": "2024-01-02", "to": "2024-01-05"},
+					},
+				},
+				now: at("2024-01-06 00:00"),
+			},
+			want{res: false},
+		},
+		{
+			"dates with time true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"dates": []map[string]any{
+						{"from": "2024-01-02 09:00", "to": "2024-01-02 10:00"},
+					},
+				},
+				now: at("2024-01-02 10:00"),
+			},
+			want{res: true},
+		},
+		{
+			"dates parsed by yaml true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"dates": []map[string]any{
+						{
+							"from": time.Date(2024, 1, 2, 0, 0, 0, 0, berlin),
+							"to":   time.Date(2024, 1, 2, 10, 0, 0, 0, berlin),
+						},
+					},
+				},
+				now: at("2024-01-02 10:00"),
+			},
+			want{res: true},
+		},
+		{
+			"dates with time false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"dates": []map[string]any{
+						{"from": "2024-01-02 09:00", "to": "2024-01-02 10:00"},
+					},
+				},
+				now: at("2024-01-02 10:01"),
+			},
+			want{res: false},
+		},
+		{
+			"ranges outside dates false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"from":     "09:00",
+					"to":       "18:00",
+					"dates": []map[string]any{
+						{"from": "2024-02-01", "to": "2024-02-29"},
+					},
+				},
+				now: at("2024-01-02 10:00"),
+			},
+			want{res: false},
+		},
+		{
+			"working day true",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"cron":     []string{"* 9-17 * * mon-fri"},
+					"holidays": "../../test/testdata/calendars/holidays.ics",
+				},
+				now: at("2024-01-02 10:00"),
+			},
+			want{res: true},
+		},
+		{
+			"holiday false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"cron":     []string{"* 9-17 * * mon-fri"},
+					"holidays": "../../test/testdata/calendars/holidays.ics",
+				},
+				now: at("2024-01-01 10:00"),
+			},
+			want{res: false},
+		},
+		{
+			"yearly holiday false",
+			args{
+				params: map[string]any{
+					"timezone": "Europe/Berlin",
+					"cron":     []string{"* 9-17 * * mon-fri"},
+					"holidays": "../../test/testdata/calendars/holidays.ics",
+				},
+				now: at("2030-12-26 10:00"),
+			},
+			want{res: false},
+		},
+		{
+			"no params",
+			args{
+				params: map[string]any{"timezone": "Europe/Berlin"},
+			},
+			want{createErr: true},
+		},
+		{
+			"bad cron",
+			args{
+				params: map[string]any{"cron": []string{"* * *"}},
+			},
+			want{createErr: true},
+		},
+		{
+			"bad date",
+			args{
+				params: map[string]any{
+					"dates": []map[string]any{
+						{"from": "01.01.2024", "to": "2024-01-05"},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"reversed dates",
+			args{
+				params: map[string]any{
+					"dates": []map[string]any{
+						{"from": "2024-01-05", "to": "2024-01-02"},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"bad range weekday",
+			args{
+				params: map[string]any{
+					"ranges": []map[string]any{
+						{
+							"from":     "09:00",
+							"to":       "10:00",
+							"weekdays": []string{"some bad weekday"},
+						},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"no holidays file",
+			args{
+				params: map[string]any{
+					"holidays": "../../test/testdata/calendars/notexist.ics",
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"broken holidays file",
+			args{
+				params: map[string]any{
+					"holidays": "../../test/testdata/calendars/broken.ics",
+				},
+			},
+			want{createErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewTimeRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "time",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewTimeRule() error mismatch: %s",
+				err,
+			)
+			if tt.want.createErr {
+				return
+			}
+
+			rules.SetTimeRuleClock(rule, func() time.Time {
+				return tt.args.now
+			})
+			res, err := rule.Apply(nil, log.Logger)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.res, res, "Apply() result mismatch")
+		})
+	}
+}
+
+func TestBase_GeoRule(t *testing.T) {
+	type args struct {
+		ip  string
+		cfg common.RuleConfig
+	}
+	type want struct {
+		res        bool
+		createErr  bool
+		prepareErr bool
+		applyErr   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"geo geolocations array true",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{"(?i)google"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo geolocations string true",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"country": []string{"(?i)united states"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo list array true",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "../../test/testdata/words_lists/banlist_geo_true_array.txt",
+						"geolocations": []map[string][]string{
+							{
+								"country": []string{"some false regexp"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo list array true",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "../../test/testdata/words_lists/banlist_geo_true_string.txt",
+						"geolocations": []map[string][]string{
+							{
+								"country": []string{"some false regexp"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        true,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo geolocations array false",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{"some false org"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo geolocations string false",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"country": []string{"some false regexp"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo list false",
+			args{
+				ip: "8.8.8.8",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "../../test/testdata/words_lists/banlist_geo_false.txt",
+						"geolocations": []map[string][]string{
+							{
+								"country": []string{"some false regexp"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo empty false",
+			args{
+				ip: "1.1.1.1",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{},
+								"country":      []string{},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  false,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo geolocations err bad regexp",
+			args{
+				ip: "1.1.1.1",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{"(?i"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo list err can't open file",
+			args{
+				ip: "1.1.1.1",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "../../test/testdata/words_lists/banlist_1337.txt",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{"(?i)google"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+		{
+			"geo list err bad regexp",
+			args{
+				ip: "1.1.1.1",
+				cfg: common.RuleConfig{
+					Name: "test",
+					Type: "geo",
+					Params: map[string]any{
+						"list": "../../test/testdata/words_lists/broken_regexp.txt",
+						"geolocations": []map[string][]string{
+							{
+								"organisation": []string{"(?i)google"},
+							},
+						},
+					},
+				},
+			},
+			want{
+				res:        false,
+				createErr:  true,
+				prepareErr: false,
+				applyErr:   false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			rule, err := rules.NewGeolocationRule(
+				db,
+				rules.RuleSet{},
+				tt.args.cfg,
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewGeolocationRule() error mismatch: %s",
+				err,
+			)
+
+			if !tt.want.createErr {
+				const geolocationInfoCount = 2
+				for i := 0; i < geolocationInfoCount; i++ {
+					e := new(MockEntity)
+					e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
+
+					err = rule.Prepare(e, log.Logger)
+					require.Equalf(
+						t,
+						tt.want.prepareErr,
+						err != nil,
+						"Prepare() error mismatch: %s",
+						err,
+					)
+
+					res, err := rule.Apply(e, log.Logger)
+					require.Equalf(
+						t,
+						tt.want.applyErr,
+						err != nil,
+						"Apply() error mismatch: %s",
+						err,
+					)
+					require.Equal(
+						t,
+						tt.want.res,
+						res,
+						"Apply() result mismatch",
+					)
+					e.AssertExpectations(t)
+
+					// clear db so all geo getters will be tested
+					err = db.DB.DropAll()
+					require.NoError(t, err, "Can't clear db")
+				}
+			}
+		})
+	}
+}
+
+func TestBase_GeoRadiusRule(t *testing.T) {
+	// cached geolocations, so no geo APIs are requested
+	geolocations := map[string]*database.Geolocation{
+		// Berlin
+		"10.0.0.1": {Located: true, Latitude: 52.52, Longitude: 13.405},
+		// Potsdam, 27 km from Berlin
+		"10.0.0.2": {Located: true, Latitude: 52.3906, Longitude: 13.0645},
+		// Paris
+		"10.0.0.3": {Located: true, Latitude: 48.8566, Longitude: 2.3522},
+		// unknown location
+		"10.0.0.4": {CountryCode: "DE"},
+	}
+	berlin := map[string]any{
+		"latitude":  52.52,
+		"longitude": 13.405,
+		"radius":    20,
+	}
+
+	type args struct {
+		ip     string
+		params map[string]any
+	}
+	type want struct {
+		res       bool
+		createErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"circle center true",
+			args{
+				ip:     "10.0.0.1",
+				params: map[string]any{"circles": []map[string]any{berlin}},
+			},
+			want{res: true},
+		},
+		{
+			"circle outside false",
+			args{
+				ip:     "10.0.0.2",
+				params: map[string]any{"circles": []map[string]any{berlin}},
+			},
+			want{res: false},
+		},
+		{
+			"second circle true",
+			args{
+				ip: "10.0.0.3",
+				params: map[string]any{
+					"circles": []map[string]any{
+						berlin,
+						{"latitude": 48.86, "longitude": 2.35, "radius": 1},
+					},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"polygon true",
+			args{
+				ip: "10.0.0.1",
+				params: map[string]any{
+					"polygons": "../../test/testdata/geo/berlin.geojson",
+				},
+			},
+			want{res: true},
+		},
+		{
+			"polygon false",
+			args{
+				ip: "10.0.0.3",
+				params: map[string]any{
+					"polygons": "../../test/testdata/geo/berlin.geojson",
+				},
+			},
+			want{res: false},
+		},
+		{
+			"unknown location false",
+			args{
+				ip: "10.0.0.4",
+				params: map[string]any{
+					"circles":  []map[string]any{berlin},
+					"polygons": "../../test/testdata/geo/berlin.geojson",
+				},
+			},
+			want{res: false},
+		},
+		{
+			"err no areas",
+			args{
+				ip:     "10.0.0.1",
+				params: map[string]any{},
+			},
+			want{createErr: true},
+		},
+		{
+			"err zero radius",
+			args{
+				ip: "10.0.0.1",
+				params: map[string]any{
+					"circles": []map[string]any{
+						{"latitude": 52.52, "longitude": 13.405},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err bad latitude",
+			args{
+				ip: "10.0.0.1",
+				params: map[string]any{
+					"circles": []map[string]any{
+						{"latitude": 152.52, "longitude": 13.405, "radius": 1},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err not polygon",
+			args{
+				ip: "10.0.0.1",
+				params: map[string]any{
+					"polygons": "../../test/testdata/geo/point.geojson",
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err no polygons file",
+			args{
+				ip: "10.0.0.1",
+				params: map[string]any{
+					"polygons": "../../test/testdata/geo/notexist.geojson",
+				},
+			},
+			want{createErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
 			require.NoError(t, err, "can't create db")
-			rule, err := rules.NewGeolocationRule(
+			for ip, g := range geolocations {
+				require.NoError(t, db.SaveGeolocation(ip, g))
+			}
+
+			rule, err := rules.NewGeoRadiusRule(
+				db,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "geo_radius",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewGeoRadiusRule() error mismatch: %s",
+				err,
+			)
+			if tt.want.createErr {
+				return
+			}
+
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
+
+			require.NoError(t, rule.Prepare(e, log.Logger))
+			res, err := rule.Apply(e, log.Logger)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.res, res, "Apply() result mismatch")
+			e.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBase_IPClassRule(t *testing.T) {
+	// cached classified geolocations, so no geo APIs are requested
+	geolocations := map[string]*database.Geolocation{
+		"10.0.0.1": {Classified: true, Hosting: true},
+		"10.0.0.2": {Classified: true, Proxy: true, Mobile: true},
+		"10.0.0.3": {Classified: true},
+	}
+
+	type args struct {
+		ip      string
+		classes []string
+	}
+	type want struct {
+		res       bool
+		createErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"hosting true",
+			args{ip: "10.0.0.1", classes: []string{"hosting"}},
+			want{res: true},
+		},
+		{
+			"hosting false",
+			args{ip: "10.0.0.2", classes: []string{"hosting"}},
+			want{res: false},
+		},
+		{
+			"any class true",
+			args{ip: "10.0.0.2", classes: []string{"hosting", "Mobile"}},
+			want{res: true},
+		},
+		{
+			"residential false",
+			args{
+				ip:      "10.0.0.3",
+				classes: []string{"proxy", "hosting", "mobile"},
+			},
+			want{res: false},
+		},
+		{
+			"err no classes",
+			args{ip: "10.0.0.1"},
+			want{createErr: true},
+		},
+		{
+			"err unknown class",
+			args{ip: "10.0.0.1", classes: []string{"tor"}},
+			want{createErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			for ip, g := range geolocations {
+				require.NoError(t, db.SaveGeolocation(ip, g))
+			}
+
+			rule, err := rules.NewIPClassRule(
 				db,
 				rules.RuleSet{},
-				tt.args.cfg,
+				common.RuleConfig{
+					Name: "test",
+					Type: "ip_class",
+					Params: map[string]any{
+						"classes": tt.args.classes,
+					},
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewIPClassRule() error mismatch: %s",
+				err,
+			)
+			if tt.want.createErr {
+				return
+			}
+
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
+
+			require.NoError(t, rule.Prepare(e, log.Logger))
+			res, err := rule.Apply(e, log.Logger)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.res, res, "Apply() result mismatch")
+			e.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBase_ASNRule(t *testing.T) {
+	const database = "../../test/testdata/asn/iptoasn.tsv"
+
+	type args struct {
+		ip     string
+		params map[string]any
+	}
+	type want struct {
+		res       bool
+		createErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"asn number true",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database": database,
+					"asns":     []any{13335},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"asn string ipv6 true",
+			args{
+				ip: "2a00:1450:4001::1",
+				params: map[string]any{
+					"database": database,
+					"asns":     []any{"AS15169"},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"asn list true",
+			args{
+				ip: "8.8.8.8",
+				params: map[string]any{
+					"database": database,
+					"list":     "../../test/testdata/asn/asns.txt",
+				},
+			},
+			want{res: true},
+		},
+		{
+			"organisation true",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database":      database,
+					"organisations": []string{"(?i)cloudflare"},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"other asn false",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database":      database,
+					"asns":          []any{15169},
+					"organisations": []string{"(?i)google"},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"not routed false",
+			args{
+				ip: "9.9.9.9",
+				params: map[string]any{
+					"database":      database,
+					"organisations": []string{".*"},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"err no database",
+			args{
+				ip:     "1.1.1.1",
+				params: map[string]any{"asns": []any{13335}},
+			},
+			want{createErr: true},
+		},
+		{
+			"err nothing to match",
+			args{
+				ip:     "1.1.1.1",
+				params: map[string]any{"database": database},
+			},
+			want{createErr: true},
+		},
+		{
+			"err bad asn",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database": database,
+					"asns":     []any{"ASN1"},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err bad regexp",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database":      database,
+					"organisations": []string{"("},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err bad database",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"database": "../../test/testdata/asn/asns.txt",
+					"asns":     []any{13335},
+				},
+			},
+			want{createErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewASNRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "asn",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewASNRule() error mismatch: %s",
+				err,
+			)
+			if tt.want.createErr {
+				return
+			}
+
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
+
+			require.NoError(t, rule.Prepare(e, log.Logger))
+			res, err := rule.Apply(e, log.Logger)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.res, res, "Apply() result mismatch")
+			e.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBase_ASNRuleReload(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "asn.tsv")
+	write := func(data string) {
+		// replace by rename like downloaders do
+		tmp := database + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, []byte(data), 0o600))
+		require.NoError(t, os.Rename(tmp, database))
+	}
+	write("1.1.1.0\t1.1.1.255\t13335\tUS\tCLOUDFLARENET\n")
+
+	rule, err := rules.NewASNRule(
+		nil,
+		rules.RuleSet{},
+		common.RuleConfig{
+			Name: "test",
+			Type: "asn",
+			Params: map[string]any{
+				"database": database,
+				"asns":     []any{15169},
+			},
+		},
+		common.Globals{},
+	)
+	require.NoError(t, err)
+
+	e := new(MockEntity)
+	e.On("GetIP").Return(netip.MustParseAddr("1.1.1.1"))
+	res, err := rule.Apply(e, log.Logger)
+	require.NoError(t, err)
+	require.False(t, res)
+
+	// broken database is ignored
+	write("broken")
+	time.Sleep(time.Second)
+	res, err = rule.Apply(e, log.Logger)
+	require.NoError(t, err)
+	require.False(t, res)
+
+	write("1.1.1.0\t1.1.1.255\t15169\tUS\tGOOGLE\n")
+	require.Eventually(t, func() bool {
+		res, err = rule.Apply(e, log.Logger)
+		return err == nil && res
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestBase_ListErrorPositions(t *testing.T) {
+	_, err := rules.NewIPRule(
+		nil,
+		rules.RuleSet{},
+		common.RuleConfig{
+			Name: "test",
+			Type: "ip",
+			Params: map[string]any{
+				"list":   "../../test/testdata/ip_lists/broken_range.txt",
+				"format": "range",
+			},
+		},
+		common.Globals{},
+	)
+	require.ErrorContains(t, err, "broken_range.txt:2: invalid ip range")
+
+	_, err = rules.NewRegexpRule(
+		nil,
+		rules.RuleSet{},
+		common.RuleConfig{
+			Name: "test",
+			Type: "regexp",
+			Params: map[string]any{
+				"list": "../../test/testdata/words_lists/broken_regexp.txt",
+			},
+		},
+		common.Globals{},
+	)
+	require.ErrorContains(t, err, "broken_regexp.txt:")
+}
+
+func TestBase_IPRuleLists(t *testing.T) {
+	feed := "4.4.4.4\n"
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(feed))
+		},
+	))
+	defer srv.Close()
+
+	globals := common.Globals{ListsDir: t.TempDir()}
+	updater := lists.NewUpdater(globals.ListsDir, srv.Client())
+	src := lists.Source{Name: "feed", URL: srv.URL}
+	_, err := updater.Update(context.Background(), src)
+	require.NoError(t, err)
+
+	cfg := common.RuleConfig{
+		Name: "test",
+		Type: "ip",
+		Params: map[string]any{
+			"list":  "../../test/testdata/ip_lists/allowlist_1.txt",
+			"lists": []string{"feed"},
+		},
+	}
+	rule, err := rules.NewIPRule(nil, rules.RuleSet{}, cfg, globals)
+	require.NoError(t, err)
+
+	apply := func(ip string) bool {
+		e := new(MockEntity)
+		e.On("GetIP").Return(netip.MustParseAddr(ip))
+		res, aerr := rule.Apply(e, log.Logger)
+		require.NoError(t, aerr)
+		return res
+	}
+	require.True(t, apply("4.4.4.4"))
+	require.True(t, apply("3.3.3.3"))
+	require.False(t, apply("5.5.5.5"))
+
+	// updated list is reloaded
+	feed = "5.5.5.0/24\n"
+	_, err = updater.Update(context.Background(), src)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return apply("5.5.5.5")
+	}, 5*time.Second, 100*time.Millisecond)
+	require.False(t, apply("4.4.4.4"))
+	require.True(t, apply("3.3.3.3"))
+
+	// list must be fetched before use
+	cfg.Params["lists"] = []string{"missing"}
+	_, err = rules.NewIPRule(nil, rules.RuleSet{}, cfg, globals)
+	require.Error(t, err)
+
+	cfg.Params["lists"] = []string{"../feed"}
+	_, err = rules.NewIPRule(nil, rules.RuleSet{}, cfg, globals)
+	require.Error(t, err)
+}
+
+func TestBase_CloudRule(t *testing.T) {
+	sources := []map[string]any{
+		{"provider": "aws", "path": "../../test/testdata/cloud/aws.json"},
+		{"provider": "gcp", "path": "../../test/testdata/cloud/gcp.json"},
+		{
+			"provider": "cloudflare",
+			"path":     "../../test/testdata/cloud/cloudflare-ips.txt",
+		},
+	}
+	ec2ExceptIreland := []map[string]any{
+		{
+			"provider":        "aws",
+			"services":        []string{"ec2"},
+			"exclude_regions": []string{"eu-west-1"},
+		},
+	}
+
+	type args struct {
+		ip     string
+		params map[string]any
+	}
+	type want struct {
+		res       bool
+		createErr bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"any range true",
+			args{
+				ip:     "103.21.244.1",
+				params: map[string]any{"sources": sources},
+			},
+			want{res: true},
+		},
+		{
+			"no range false",
+			args{
+				ip:     "1.1.1.1",
+				params: map[string]any{"sources": sources},
+			},
+			want{res: false},
+		},
+		{
+			"ec2 except region true",
+			args{
+				ip: "18.156.1.1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   ec2ExceptIreland,
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ec2 except region ipv6 true",
+			args{
+				ip: "2a05:d014::1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   ec2ExceptIreland,
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ec2 excluded region false",
+			args{
+				ip: "3.5.140.1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   ec2ExceptIreland,
+				},
+			},
+			want{res: false},
+		},
+		{
+			"other service false",
+			args{
+				ip: "52.95.0.1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   ec2ExceptIreland,
+				},
+			},
+			want{res: false},
+		},
+		{
+			"region mask true",
+			args{
+				ip: "34.1.208.1",
+				params: map[string]any{
+					"sources": sources,
+					"match": []map[string]any{
+						{"regions": []string{"eu-*"}},
+						{"provider": "gcp", "regions": []string{"africa-*"}},
+					},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"other provider false",
+			args{
+				ip: "173.245.48.1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   []map[string]any{{"provider": "gcp"}},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"err no sources",
+			args{
+				ip:     "1.1.1.1",
+				params: map[string]any{"match": ec2ExceptIreland},
+			},
+			want{createErr: true},
+		},
+		{
+			"err unknown match provider",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"sources": sources,
+					"match":   []map[string]any{{"provider": "amazon"}},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err wrong source provider",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"sources": []map[string]any{
+						{
+							"provider": "azure",
+							"path":     "../../test/testdata/cloud/aws.json",
+						},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"err no source file",
+			args{
+				ip: "1.1.1.1",
+				params: map[string]any{
+					"sources": []map[string]any{
+						{
+							"provider": "aws",
+							"path":     "../../test/testdata/cloud/notexist",
+						},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewCloudRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "cloud",
+					Params: tt.args.params,
+				},
 				common.Globals{},
 			)
 			require.Equalf(
 				t,
 				tt.want.createErr,
 				err != nil,
-				"NewGeolocationRule() error mismatch: %s",
+				"NewCloudRule() error mismatch: %s",
 				err,
 			)
+			if tt.want.createErr {
+				return
+			}
 
-			if !tt.want.createErr {
-				const geolocationInfoCount = 2
-				for i := 0; i < geolocationInfoCount; i++ {
-					e := new(MockEntity)
-					e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
-
-					err = rule.Prepare(e, log.Logger)
-					require.Equalf(
-						t,
-						tt.want.prepareErr,
-						err != nil,
-						"Prepare() error mismatch: %s",
-						err,
-					)
-
-					res, err := rule.Apply(e, log.Logger)
-					require.Equalf(
-						t,
-						tt.want.applyErr,
-						err != nil,
-						"Apply() error mismatch: %s",
-						err,
-					)
-					require.Equal(
-						t,
-						tt.want.res,
-						res,
-						"Apply() result mismatch",
-					)
-					e.AssertExpectations(t)
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.args.ip))
 
-					// clear db so all geo getters will be tested
-					err = db.DB.DropAll()
-					require.NoError(t, err, "Can't clear db")
-				}
-			}
+			require.NoError(t, rule.Prepare(e, log.Logger))
+			res, err := rule.Apply(e, log.Logger)
+			require.NoError(t, err)
+			require.Equal(t, tt.want.res, res, "Apply() result mismatch")
+			e.AssertExpectations(t)
 		})
 	}
 }
@@ -1420,3 +2970,445 @@ func TestBase_ReverseLookupRule(t *testing.T) {
 		})
 	}
 }
+
+// newDNSServer starts local DNS server answering with records, unknown
+// names get NXDOMAIN, names in "servfail.test." zone get SERVFAIL. Returns
+// server address.
+func newDNSServer(t *testing.T, records ...string) string {
+	answers := make(map[dns.Question][]dns.RR)
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		h := rr.Header()
+		q := dns.Question{Name: h.Name, Qtype: h.Rrtype, Qclass: h.Class}
+		answers[q] = append(answers[q], rr)
+	}
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Addr: "127.0.0.1:0",
+		Net:  "udp",
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = answers[r.Question[0]]
+			switch {
+			case dns.IsSubDomain("servfail.test.", r.Question[0].Name):
+				m.Rcode = dns.RcodeServerFailure
+			case m.Answer == nil:
+				m.Rcode = dns.RcodeNameError
+			}
+			_ = w.WriteMsg(m)
+		}),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+	return srv.PacketConn.LocalAddr().String()
+}
+
+func TestBase_FCrDNSRule(t *testing.T) {
+	addr := newDNSServer(
+		t,
+		"1.0.0.10.in-addr.arpa. 60 IN PTR crawl.example.test.",
+		"crawl.example.test. 60 IN A 10.0.0.1",
+		// spoofed PTR, domain resolves to other IP
+		"2.0.0.10.in-addr.arpa. 60 IN PTR mail.example.test.",
+		"mail.example.test. 60 IN A 10.0.0.9",
+		// PTR without forward record
+		"3.0.0.10.in-addr.arpa. 60 IN PTR ghost.example.test.",
+		// several PTR, the second one is confirmed
+		"4.0.0.10.in-addr.arpa. 60 IN PTR fake.example.test.",
+		"4.0.0.10.in-addr.arpa. 60 IN PTR www.example.test.",
+		"www.example.test. 60 IN A 10.0.0.4",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2."+
+			"ip6.arpa. 60 IN PTR v6.example.test.",
+		"v6.example.test. 60 IN AAAA 2001:db8::1",
+		"v6.example.test. 60 IN A 10.0.0.1",
+	)
+
+	tests := []struct {
+		name      string
+		ip        string
+		confirmed bool
+		reverse   bool
+	}{
+		{"confirmed", "10.0.0.1", true, true},
+		{"spoofed", "10.0.0.2", false, true},
+		{"no forward record", "10.0.0.3", false, true},
+		{"one of several confirmed", "10.0.0.4", true, true},
+		{"confirmed ipv6", "2001:db8::1", true, true},
+		{"no ptr", "10.0.0.5", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			defer db.DB.Close()
+
+			cfg := common.RuleConfig{
+				Name: "test",
+				Params: map[string]any{
+					"dns":  addr,
+					"list": "../../test/testdata/words_lists/ptr_regexp.txt",
+				},
+			}
+			fcrdns, err := rules.NewFCrDNSRule(
+				db,
+				rules.RuleSet{},
+				cfg,
+				common.Globals{},
+			)
+			require.NoError(t, err)
+			reverse, err := rules.NewReverseLookupRule(
+				db,
+				rules.RuleSet{},
+				cfg,
+				common.Globals{},
+			)
+			require.NoError(t, err)
+
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.ip))
+
+			// cached results of rules must not mix
+			for i := 0; i < 2; i++ {
+				res, aerr := fcrdns.Apply(e, log.Logger)
+				require.NoError(t, aerr)
+				require.Equal(t, tt.confirmed, res, "fcrdns")
+
+				res, aerr = reverse.Apply(e, log.Logger)
+				require.NoError(t, aerr)
+				require.Equal(t, tt.reverse, res, "reverse_lookup")
+			}
+		})
+	}
+}
+
+func TestBase_ReverseLookupResolver(t *testing.T) {
+	addr := newDNSServer(
+		t,
+		"1.0.0.10.in-addr.arpa. 60 IN PTR crawl.example.test.",
+		"crawl.example.test. 60 IN A 10.0.0.1",
+	)
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	defer db.DB.Close()
+
+	cfg := []common.RuleConfig{
+		{
+			Name: "reverse",
+			Type: "reverse_lookup",
+			Params: map[string]any{
+				"list": "../../test/testdata/words_lists/ptr_regexp.txt",
+			},
+		},
+		{
+			Name: "fcrdns",
+			Type: "fcrdns",
+			Params: map[string]any{
+				"list": "../../test/testdata/words_lists/ptr_regexp.txt",
+			},
+		},
+	}
+
+	_, err = rules.NewRuleSet(db, cfg, common.Globals{})
+	require.ErrorIs(t, err, rules.ErrNoResolver)
+
+	_, err = rules.NewRuleSet(db, cfg, common.Globals{
+		Resolver: common.ResolverConfig{Upstreams: []string{"1.1.1.1"}},
+	})
+	require.Error(t, err, "upstream without port")
+
+	rs, err := rules.NewRuleSet(db, cfg, common.Globals{
+		Resolver: common.ResolverConfig{
+			Upstreams: []string{addr},
+			Timeout:   time.Second,
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, rs.Resolver)
+
+	e := new(MockEntity)
+	e.On("GetIP").Return(netip.MustParseAddr("10.0.0.1"))
+	for _, name := range []string{"reverse", "fcrdns"} {
+		rule, ok := rs.Get(name)
+		require.True(t, ok)
+		res, aerr := rule.Apply(e, log.Logger)
+		require.NoError(t, aerr)
+		require.True(t, res, name)
+	}
+}
+
+func TestBase_DNSBLRule(t *testing.T) {
+	addr := newDNSServer(
+		t,
+		"2.0.0.10.bl.test. 300 IN A 127.0.0.2",
+		"2.0.0.10.bl.test. 600 IN A 127.0.0.4",
+		"2.0.0.10.codes.test. 60 IN A 127.0.0.10",
+		"3.0.0.10.codes.test. 60 IN A 127.0.0.5",
+		"3.0.0.10.bl.test. 60 IN A 127.0.0.3",
+		// query error of zone isn't listing
+		"4.0.0.10.bl.test. 60 IN A 127.255.255.254",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2."+
+			"bl.test. 60 IN A 127.0.0.2",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2."+
+			"codes.test. 60 IN A 127.0.0.2",
+	)
+	zones := []map[string]any{
+		{"zone": "bl.test", "ipv6": true},
+		{"zone": "Codes.test.", "codes": []string{"127.0.0.2", "127.0.0.4/30"}},
+	}
+
+	tests := []struct {
+		name      string
+		ip        string
+		params    map[string]any
+		res       bool
+		createErr bool
+	}{
+		{"listed", "10.0.0.2", map[string]any{}, true, false},
+		{"listed by code subnet", "10.0.0.3", map[string]any{}, true, false},
+		{"not listed", "10.0.0.5", map[string]any{}, false, false},
+		{"zone error code", "10.0.0.4", map[string]any{}, false, false},
+		{"ipv4 mapped", "::ffff:10.0.0.2", map[string]any{}, true, false},
+		{"ipv6 zone", "2001:db8::1", map[string]any{}, true, false},
+		{
+			"min hits",
+			"10.0.0.3",
+			map[string]any{"min_hits": 2},
+			true,
+			false,
+		},
+		{
+			"min hits with not accepted code",
+			"10.0.0.2",
+			map[string]any{"min_hits": 2},
+			false,
+			false,
+		},
+		{
+			"min hits ipv4 only zone",
+			"2001:db8::1",
+			map[string]any{"min_hits": 2},
+			false,
+			false,
+		},
+		{
+			"failed zone is skipped",
+			"10.0.0.2",
+			map[string]any{"zones": append(
+				[]map[string]any{{"zone": "servfail.test"}},
+				zones...,
+			)},
+			true,
+			false,
+		},
+		{
+			"failed zone isn't hit",
+			"10.0.0.3",
+			map[string]any{
+				"zones": append(
+					[]map[string]any{{"zone": "servfail.test"}},
+					zones...,
+				),
+				"min_hits": 3,
+			},
+			false,
+			false,
+		},
+		{
+			"min hits more than zones",
+			"10.0.0.2",
+			map[string]any{"min_hits": 3},
+			false,
+			true,
+		},
+		{
+			"no zones",
+			"10.0.0.2",
+			map[string]any{"zones": []map[string]any{}},
+			false,
+			true,
+		},
+		{
+			"invalid zone",
+			"10.0.0.2",
+			map[string]any{"zones": []map[string]any{{"zone": "a..b"}}},
+			false,
+			true,
+		},
+		{
+			"invalid code",
+			"10.0.0.2",
+			map[string]any{"zones": []map[string]any{
+				{"zone": "bl.test", "codes": []string{"127.0.0"}},
+			}},
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := database.New("", true)
+			require.NoError(t, err, "can't create db")
+			defer db.DB.Close()
+
+			params := map[string]any{"dns": addr, "zones": zones}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+			rule, err := rules.NewDNSBLRule(
+				db,
+				rules.RuleSet{},
+				common.RuleConfig{Name: "test", Type: "dnsbl", Params: params},
+				common.Globals{},
+			)
+			require.Equalf(t, tt.createErr, err != nil, "create error: %s", err)
+			if tt.createErr {
+				return
+			}
+
+			e := new(MockEntity)
+			e.On("GetIP").Return(netip.MustParseAddr(tt.ip))
+			require.NoError(t, rule.Prepare(e, log.Logger))
+			// the second time answers are cached
+			for i := 0; i < 2; i++ {
+				res, aerr := rule.Apply(e, log.Logger)
+				require.NoError(t, aerr)
+				require.Equal(t, tt.res, res)
+			}
+		})
+	}
+}
+
+func TestBase_DNSBLRuleCache(t *testing.T) {
+	addr := newDNSServer(
+		t,
+		"2.0.0.10.bl.test. 300 IN A 127.0.0.2",
+		"2.0.0.10.bl.test. 600 IN A 127.0.0.4",
+	)
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	defer db.DB.Close()
+
+	rule, err := rules.NewDNSBLRule(
+		db,
+		rules.RuleSet{},
+		common.RuleConfig{
+			Name: "test",
+			Type: "dnsbl",
+			Params: map[string]any{
+				"dns": addr,
+				"zones": []map[string]any{
+					{"zone": "bl.test"},
+					{"zone": "other.test"},
+				},
+			},
+		},
+		common.Globals{},
+	)
+	require.NoError(t, err)
+
+	for _, ip := range []string{"10.0.0.2", "10.0.0.3"} {
+		e := new(MockEntity)
+		e.On("GetIP").Return(netip.MustParseAddr(ip))
+		_, err = rule.Apply(e, log.Logger)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		zone  string
+		ip    string
+		codes []string
+		ttl   time.Duration
+	}{
+		// minimal TTL of answer
+		{
+			"bl.test.",
+			"10.0.0.2",
+			[]string{"127.0.0.2", "127.0.0.4"},
+			300 * time.Second,
+		},
+		// NXDOMAIN without SOA
+		{"other.test.", "10.0.0.2", nil, time.Hour},
+		{"bl.test.", "10.0.0.3", nil, time.Hour},
+	}
+	for _, tt := range tests {
+		d, gerr := db.GetDNSBL(tt.zone, tt.ip)
+		require.NoError(t, gerr)
+		require.Equal(t, tt.codes, d.Codes)
+
+		err = db.DB.View(func(txn *badger.Txn) error {
+			item, ierr := txn.Get([]byte(
+				database.DNSBLPrefix + tt.zone + "-" + tt.ip,
+			))
+			require.NoError(t, ierr)
+			ttl := time.Until(time.Unix(int64(item.ExpiresAt()), 0))
+			require.InDelta(t, tt.ttl, ttl, float64(5*time.Second))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestBase_FCrDNSCacheTTL(t *testing.T) {
+	addr := newDNSServer(
+		t,
+		// A answer expires first
+		"1.0.0.10.in-addr.arpa. 300 IN PTR crawl.example.test.",
+		"crawl.example.test. 60 IN A 10.0.0.1",
+		// PTR answer expires first
+		"2.0.0.10.in-addr.arpa. 120 IN PTR www.example.test.",
+		"www.example.test. 600 IN A 10.0.0.2",
+	)
+	db, err := database.New("", true)
+	require.NoError(t, err, "can't create db")
+	defer db.DB.Close()
+
+	rule, err := rules.NewFCrDNSRule(
+		db,
+		rules.RuleSet{},
+		common.RuleConfig{
+			Name: "test",
+			Params: map[string]any{
+				"dns":  addr,
+				"list": "../../test/testdata/words_lists/ptr_regexp.txt",
+			},
+		},
+		common.Globals{},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip  string
+		ttl time.Duration
+	}{
+		{"10.0.0.1", 60 * time.Second},
+		{"10.0.0.2", 120 * time.Second},
+		// NXDOMAIN without SOA
+		{"10.0.0.3", time.Hour},
+	}
+	for _, tt := range tests {
+		e := new(MockEntity)
+		e.On("GetIP").Return(netip.MustParseAddr(tt.ip))
+		_, err = rule.Apply(e, log.Logger)
+		require.NoError(t, err)
+
+		err = db.DB.View(func(txn *badger.Txn) error {
+			item, ierr := txn.Get([]byte(
+				database.ConfirmedLookupPrefix + tt.ip,
+			))
+			require.NoError(t, ierr)
+			ttl := time.Until(time.Unix(int64(item.ExpiresAt()), 0))
+			require.InDelta(t, tt.ttl, ttl, float64(5*time.Second))
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
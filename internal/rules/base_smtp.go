@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"fmt"
+	"net/textproto"
+	"regexp"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+func NewSMTPRule(
+	_ *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
+	_ common.Globals,
+) (Rule, error) {
+	var params SMTPParams
+
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+
+	if len(params.Helo) == 0 && len(params.From) == 0 &&
+		len(params.To) == 0 && len(params.Headers) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &SMTPRule{
+		headers: make(map[string][]*regexp.Regexp, len(params.Headers)),
+	}
+	if rule.helo, err = compileRegexps(params.Helo); err != nil {
+		return nil, err
+	}
+	if rule.from, err = compileRegexps(params.From); err != nil {
+		return nil, err
+	}
+	if rule.to, err = compileRegexps(params.To); err != nil {
+		return nil, err
+	}
+	for k, v := range params.Headers {
+		var res []*regexp.Regexp
+		if res, err = compileRegexps(v); err != nil {
+			return nil, err
+		}
+		rule.headers[textproto.CanonicalMIMEHeaderKey(k)] = res
+	}
+
+	return rule, nil
+}
+
+type SMTPParams struct {
+	Helo    []string            `mapstructure:"helo"`
+	From    []string            `mapstructure:"from"`
+	To      []string            `mapstructure:"to"`
+	Headers map[string][]string `mapstructure:"headers"`
+}
+
+type SMTPRule struct {
+	helo    []*regexp.Regexp
+	from    []*regexp.Regexp
+	to      []*regexp.Regexp
+	headers map[string][]*regexp.Regexp
+}
+
+func (f *SMTPRule) Prepare(
+	_ wrapper.Entity,
+	_ zerolog.Logger,
+) error {
+	return nil
+}
+
+func (f *SMTPRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	env, err := e.GetSMTPEnvelope()
+	if err != nil {
+		return false, fmt.Errorf("can't get smtp envelope: %w", err)
+	}
+
+	if r := matchAny(f.helo, env.Helo); r != nil {
+		logger.Debug().Stringer("match", r).Msg("HELO match")
+		return true, nil
+	}
+	if r := matchAny(f.from, env.MailFrom); r != nil {
+		logger.Debug().Stringer("match", r).Msg("MAIL FROM match")
+		return true, nil
+	}
+	for _, rcpt := range env.RcptTo {
+		if r := matchAny(f.to, rcpt); r != nil {
+			logger.Debug().Stringer("match", r).Msg("RCPT TO match")
+			return true, nil
+		}
+	}
+
+	if len(f.headers) == 0 {
+		return false, nil
+	}
+	headers, err := e.GetHeaders()
+	if err != nil {
+		return false, fmt.Errorf("can't get headers: %w", err)
+	}
+	for k, res := range f.headers {
+		for _, v := range headers[k] {
+			if r := matchAny(res, v); r != nil {
+				logger.Debug().
+					Str("header", k).
+					Stringer("match", r).
+					Msg("Header match")
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (f SMTPRule) String() string {
+	keys := maps.Keys(f.headers)
+	slices.Sort(keys)
+	headers := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, r := range f.headers[k] {
+			headers = append(headers, k+": "+r.String())
+		}
+	}
+	return fmt.Sprintf(
+		"SMTP(helo=%s, from=%s, to=%s, headers=%s)",
+		common.FormatStringSlice(regexpStrings(f.helo)),
+		common.FormatStringSlice(regexpStrings(f.from)),
+		common.FormatStringSlice(regexpStrings(f.to)),
+		common.FormatStringSlice(headers),
+	)
+}
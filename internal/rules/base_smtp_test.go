@@ -0,0 +1,197 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase_SMTPRule(t *testing.T) {
+	envelope := &wrapper.SMTPEnvelope{
+		Helo:     "mx.sandbox.example",
+		MailFrom: "scanner@sandbox.example",
+		RcptTo:   []string{"ceo@target.example", "it@target.example"},
+	}
+	headers := map[string][]string{
+		"Subject":  {"Quarterly report"},
+		"X-Mailer": {"Microsoft Outlook 16.0"},
+	}
+
+	type args struct {
+		envelope       *wrapper.SMTPEnvelope
+		getEnvelopeErr error
+		headers        map[string][]string
+		params         map[string]any
+	}
+	type want struct {
+		res        bool
+		createErr  bool
+		prepareErr bool
+		applyErr   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"smtp helo true",
+			args{
+				envelope: envelope,
+				params: map[string]any{
+					"helo": []string{`\.sandbox\.example$`},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"smtp from true",
+			args{
+				envelope: envelope,
+				params: map[string]any{
+					"from": []string{`^scanner@`},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"smtp to true",
+			args{
+				envelope: envelope,
+				params: map[string]any{
+					"to": []string{`^it@`},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"smtp envelope false",
+			args{
+				envelope: envelope,
+				params: map[string]any{
+					"helo": []string{`^localhost$`},
+					"from": []string{`@analyst\.example$`},
+					"to":   []string{`^abuse@`},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"smtp headers true",
+			args{
+				envelope: envelope,
+				headers:  headers,
+				params: map[string]any{
+					"headers": map[string]any{
+						"x-mailer": []string{`Outlook`},
+					},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"smtp headers false",
+			args{
+				envelope: envelope,
+				headers:  headers,
+				params: map[string]any{
+					"headers": map[string]any{
+						"Subject": []string{`(?i)invoice`},
+						"X-Spam":  []string{`.*`},
+					},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"smtp err empty params",
+			args{
+				envelope: envelope,
+				params:   map[string]any{},
+			},
+			want{createErr: true},
+		},
+		{
+			"smtp err can't parse regexp",
+			args{
+				envelope: envelope,
+				params: map[string]any{
+					"headers": map[string]any{
+						"Subject": []string{"("},
+					},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"smtp err GetSMTPEnvelope",
+			args{
+				envelope:       nil,
+				getEnvelopeErr: wrapper.ErrNotSupported,
+				params: map[string]any{
+					"helo": []string{`.*`},
+				},
+			},
+			want{applyErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewSMTPRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "smtp",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewSMTPRule() error mismatch: %s",
+				err,
+			)
+
+			if !tt.want.createErr {
+				e := new(MockEntity)
+				e.On("GetSMTPEnvelope").
+					Return(tt.args.envelope, tt.args.getEnvelopeErr)
+				if tt.args.headers != nil {
+					e.On("GetHeaders").Return(tt.args.headers, nil)
+				}
+
+				err = rule.Prepare(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.prepareErr,
+					err != nil,
+					"Prepare() error mismatch: %s",
+					err,
+				)
+
+				res, err := rule.Apply(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.applyErr,
+					err != nil,
+					"Apply() error mismatch: %s",
+					err,
+				)
+				require.Equal(
+					t,
+					tt.want.res,
+					res,
+					"Apply() result mismatch",
+				)
+				e.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
+)
+
+func NewSSHRule(
+	_ *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
+	_ common.Globals,
+) (Rule, error) {
+	var params SSHParams
+
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+
+	if len(params.Version) == 0 && len(params.Software) == 0 &&
+		len(params.HASSH) == 0 && len(params.Algorithms) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &SSHRule{
+		version:    params.Version,
+		software:   make([]*regexp.Regexp, 0, len(params.Software)),
+		hassh:      make([]string, 0, len(params.HASSH)),
+		algorithms: params.Algorithms,
+	}
+	for _, s := range params.Software {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+		rule.software = append(rule.software, re)
+	}
+	for _, h := range params.HASSH {
+		rule.hassh = append(rule.hassh, strings.ToLower(h))
+	}
+
+	return rule, nil
+}
+
+type SSHParams struct {
+	Version    []string `mapstructure:"version"`
+	Software   []string `mapstructure:"software"`
+	HASSH      []string `mapstructure:"hassh"`
+	Algorithms []string `mapstructure:"algorithms"`
+}
+
+type SSHRule struct {
+	version    []string
+	software   []*regexp.Regexp
+	hassh      []string
+	algorithms []string
+}
+
+func (f *SSHRule) Prepare(
+	_ wrapper.Entity,
+	_ zerolog.Logger,
+) error {
+	return nil
+}
+
+func (f *SSHRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	h, err := e.GetSSHHandshake()
+	if err != nil {
+		return false, fmt.Errorf("can't get ssh handshake: %w", err)
+	}
+
+	if slices.Contains(f.version, h.Ident.ProtoVersion) {
+		logger.Debug().Str("match", h.Ident.ProtoVersion).Msg("Version match")
+		return true, nil
+	}
+	// software is matched with comments, e.g. "OpenSSH_8.9p1 Ubuntu-3"
+	software := strings.TrimPrefix(
+		h.Ident.Raw,
+		"SSH-"+h.Ident.ProtoVersion+"-",
+	)
+	for _, r := range f.software {
+		if r.MatchString(software) {
+			logger.Debug().Stringer("match", r).Msg("Software match")
+			return true, nil
+		}
+	}
+	if len(f.hassh) > 0 {
+		hash := h.KexInit.HASSH()
+		if slices.Contains(f.hassh, hash) {
+			logger.Debug().Str("match", hash).Msg("HASSH match")
+			return true, nil
+		}
+	}
+	for _, a := range h.KexInit.Algorithms() {
+		if slices.Contains(f.algorithms, a) {
+			logger.Debug().Str("match", a).Msg("Algorithm match")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f SSHRule) String() string {
+	software := make([]string, 0, len(f.software))
+	for _, r := range f.software {
+		software = append(software, r.String())
+	}
+	return fmt.Sprintf(
+		"SSH(version=%s, software=%s, hassh=%s, algorithms=%s)",
+		common.FormatStringSlice(f.version),
+		common.FormatStringSlice(software),
+		common.FormatStringSlice(f.hassh),
+		common.FormatStringSlice(f.algorithms),
+	)
+}
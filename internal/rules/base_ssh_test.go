@@ -0,0 +1,190 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase_SSHRule(t *testing.T) {
+	handshake := &hassh.Handshake{
+		Ident: &hassh.Ident{
+			Raw:          "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3",
+			ProtoVersion: "2.0",
+			Software:     "OpenSSH_8.9p1",
+			Comments:     "Ubuntu-3",
+		},
+		KexInit: &hassh.KexInit{
+			KexAlgorithms:           []string{"curve25519-sha256"},
+			ServerHostKeyAlgorithms: []string{"ssh-ed25519"},
+			CiphersClientServer:     []string{"aes128-ctr"},
+			MACsClientServer:        []string{"hmac-sha2-256"},
+			CompressionClientServer: []string{"none"},
+		},
+	}
+
+	type args struct {
+		handshake       *hassh.Handshake
+		getHandshakeErr error
+		params          map[string]any
+	}
+	type want struct {
+		res        bool
+		createErr  bool
+		prepareErr bool
+		applyErr   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"ssh version true",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"version": []string{"1.99", "2.0"},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ssh software true",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"software": []string{`^libssh`, `Ubuntu`},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ssh software false",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"software": []string{`^libssh`, `^Go$`},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"ssh hassh true",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"hassh": []string{handshake.KexInit.HASSH()},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ssh algorithms true",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"algorithms": []string{"ssh-ed25519"},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"ssh algorithms false",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"hassh":      []string{"ec7378c1a92f5a8dde7e8b7a1ddf33d1"},
+					"algorithms": []string{"diffie-hellman-group1-sha1"},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"ssh err empty params",
+			args{
+				handshake: handshake,
+				params:    map[string]any{},
+			},
+			want{createErr: true},
+		},
+		{
+			"ssh err can't parse regexp",
+			args{
+				handshake: handshake,
+				params: map[string]any{
+					"software": []string{"("},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"ssh err GetSSHHandshake",
+			args{
+				handshake:       nil,
+				getHandshakeErr: wrapper.ErrNotSupported,
+				params: map[string]any{
+					"version": []string{"2.0"},
+				},
+			},
+			want{applyErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewSSHRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "ssh",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewSSHRule() error mismatch: %s",
+				err,
+			)
+
+			if !tt.want.createErr {
+				e := new(MockEntity)
+				e.On("GetSSHHandshake").
+					Return(tt.args.handshake, tt.args.getHandshakeErr)
+
+				err = rule.Prepare(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.prepareErr,
+					err != nil,
+					"Prepare() error mismatch: %s",
+					err,
+				)
+
+				res, err := rule.Apply(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.applyErr,
+					err != nil,
+					"Apply() error mismatch: %s",
+					err,
+				)
+				require.Equal(
+					t,
+					tt.want.res,
+					res,
+					"Apply() result mismatch",
+				)
+				e.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
+)
+
+func NewTLSRule(
+	_ *database.DB,
+	_ RuleSet,
+	cfg common.RuleConfig,
+	_ common.Globals,
+) (Rule, error) {
+	var params TLSParams
+
+	err := mapstructure.Decode(cfg.Params, &params)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode params: %w", err)
+	}
+
+	if len(params.SNI) == 0 && len(params.ALPN) == 0 &&
+		len(params.JA3) == 0 && len(params.JA4) == 0 {
+		return nil, ErrInvalidRuleArgs
+	}
+
+	rule := &TLSRule{
+		sni:  make([]*regexp.Regexp, 0, len(params.SNI)),
+		alpn: params.ALPN,
+		ja3:  make([]string, 0, len(params.JA3)),
+		ja4:  make([]string, 0, len(params.JA4)),
+	}
+	for _, s := range params.SNI {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+		rule.sni = append(rule.sni, re)
+	}
+	for _, h := range params.JA3 {
+		rule.ja3 = append(rule.ja3, strings.ToLower(h))
+	}
+	for _, h := range params.JA4 {
+		rule.ja4 = append(rule.ja4, strings.ToLower(h))
+	}
+
+	return rule, nil
+}
+
+type TLSParams struct {
+	SNI  []string `mapstructure:"sni"`
+	ALPN []string `mapstructure:"alpn"`
+	JA3  []string `mapstructure:"ja3"`
+	JA4  []string `mapstructure:"ja4"`
+}
+
+type TLSRule struct {
+	sni  []*regexp.Regexp
+	alpn []string
+	ja3  []string
+	ja4  []string
+}
+
+func (f *TLSRule) Prepare(
+	_ wrapper.Entity,
+	_ zerolog.Logger,
+) error {
+	return nil
+}
+
+func (f *TLSRule) Apply(
+	e wrapper.Entity,
+	logger zerolog.Logger,
+) (bool, error) {
+	hello, err := e.GetClientHello()
+	if err != nil {
+		return false, fmt.Errorf("can't get client hello: %w", err)
+	}
+
+	for _, r := range f.sni {
+		if r.MatchString(hello.ServerName) {
+			logger.Debug().Stringer("match", r).Msg("SNI match")
+			return true, nil
+		}
+	}
+	for _, a := range hello.ALPN {
+		if slices.Contains(f.alpn, a) {
+			logger.Debug().Str("match", a).Msg("ALPN match")
+			return true, nil
+		}
+	}
+	if len(f.ja3) > 0 {
+		h := hello.JA3Hash()
+		if slices.Contains(f.ja3, h) {
+			logger.Debug().Str("match", h).Msg("JA3 match")
+			return true, nil
+		}
+	}
+	if len(f.ja4) > 0 {
+		h := hello.JA4()
+		if slices.Contains(f.ja4, h) {
+			logger.Debug().Str("match", h).Msg("JA4 match")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f TLSRule) String() string {
+	sni := make([]string, 0, len(f.sni))
+	for _, r := range f.sni {
+		sni = append(sni, r.String())
+	}
+	return fmt.Sprintf(
+		"TLS(sni=%s, alpn=%s, ja3=%s, ja4=%s)",
+		common.FormatStringSlice(sni),
+		common.FormatStringSlice(f.alpn),
+		common.FormatStringSlice(f.ja3),
+		common.FormatStringSlice(f.ja4),
+	)
+}
@@ -0,0 +1,182 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase_TLSRule(t *testing.T) {
+	hello := &tlshello.ClientHello{
+		Version:      0x0303,
+		CipherSuites: []uint16{0x1301, 0x1302},
+		Extensions:   []uint16{0x0000, 0x0010},
+		ServerName:   "cdn.example.com",
+		ALPN:         []string{"h2"},
+	}
+
+	type args struct {
+		hello       *tlshello.ClientHello
+		getHelloErr error
+		params      map[string]any
+	}
+	type want struct {
+		res        bool
+		createErr  bool
+		prepareErr bool
+		applyErr   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			"tls sni true",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"sni": []string{`^www\.`, `^cdn\.example\.com$`},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"tls sni false",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"sni": []string{`^www\.`},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"tls alpn true",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"alpn": []string{"http/1.1", "h2"},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"tls ja3 true",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"ja3": []string{hello.JA3Hash()},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"tls ja4 true",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"ja4": []string{hello.JA4()},
+				},
+			},
+			want{res: true},
+		},
+		{
+			"tls ja4 false",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"ja3": []string{"e7d705a3286e19ea42f587b344ee6865"},
+					"ja4": []string{"t13d1516h2_8daaf6152771_e5627efa2ab1"},
+				},
+			},
+			want{res: false},
+		},
+		{
+			"tls err empty params",
+			args{
+				hello:  hello,
+				params: map[string]any{},
+			},
+			want{createErr: true},
+		},
+		{
+			"tls err can't parse regexp",
+			args{
+				hello: hello,
+				params: map[string]any{
+					"sni": []string{"("},
+				},
+			},
+			want{createErr: true},
+		},
+		{
+			"tls err GetClientHello",
+			args{
+				hello:       nil,
+				getHelloErr: wrapper.ErrNotSupported,
+				params: map[string]any{
+					"sni": []string{".*"},
+				},
+			},
+			want{applyErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := rules.NewTLSRule(
+				nil,
+				rules.RuleSet{},
+				common.RuleConfig{
+					Name:   "test",
+					Type:   "tls",
+					Params: tt.args.params,
+				},
+				common.Globals{},
+			)
+			require.Equalf(
+				t,
+				tt.want.createErr,
+				err != nil,
+				"NewTLSRule() error mismatch: %s",
+				err,
+			)
+
+			if !tt.want.createErr {
+				e := new(MockEntity)
+				e.On("GetClientHello").
+					Return(tt.args.hello, tt.args.getHelloErr)
+
+				err = rule.Prepare(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.prepareErr,
+					err != nil,
+					"Prepare() error mismatch: %s",
+					err,
+				)
+
+				res, err := rule.Apply(e, log.Logger)
+				require.Equalf(
+					t,
+					tt.want.applyErr,
+					err != nil,
+					"Apply() error mismatch: %s",
+					err,
+				)
+				require.Equal(
+					t,
+					tt.want.res,
+					res,
+					"Apply() result mismatch",
+				)
+				e.AssertExpectations(t)
+			}
+		})
+	}
+}
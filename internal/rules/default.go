@@ -15,10 +15,19 @@ func GetRuleBase() map[string]RuleBaseCreator {
 		// ip rules
 		"ip":             NewIPRule,
 		"geo":            NewGeolocationRule,
+		"geo_radius":     NewGeoRadiusRule,
+		"ip_class":       NewIPClassRule,
+		"asn":            NewASNRule,
+		"cloud":          NewCloudRule,
 		"reverse_lookup": NewReverseLookupRule,
+		"fcrdns":         NewFCrDNSRule,
+		"dnsbl":          NewDNSBLRule,
 		// packet inspection
 		"regexp":    NewRegexpRule,
 		"malleable": NewMalleableRule,
+		"tls":       NewTLSRule,
+		"ssh":       NewSSHRule,
+		"smtp":      NewSMTPRule,
 		// misc
 		"time": NewTimeRule,
 	}
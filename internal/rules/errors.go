@@ -10,6 +10,7 @@ var (
 	ErrInvalidRuleArgs = errors.New("invalid rule arguments")
 	ErrOddOrZero       = errors.New("data length is odd or equal zero")
 	ErrCaseMismatch    = errors.New("case mismatch")
+	ErrNoResolver      = errors.New("no \"dns\" param and globals resolver")
 )
 
 type UnknownBaseRuleError struct {
@@ -50,6 +51,39 @@ func (e UnknownDayOfWeekError) Error() string {
 	return fmt.Sprintf("unknown day of week: %s", e.day)
 }
 
+type InvalidDateRangeError struct {
+	from string
+	to   string
+}
+
+func (e InvalidDateRangeError) Error() string {
+	return fmt.Sprintf("date range end is before start: %s-%s", e.from, e.to)
+}
+
+type InvalidCircleError struct {
+	circle string
+}
+
+func (e InvalidCircleError) Error() string {
+	return fmt.Sprintf("invalid circle: %s", e.circle)
+}
+
+type UnknownIPClassError struct {
+	class string
+}
+
+func (e UnknownIPClassError) Error() string {
+	return fmt.Sprintf("unknown ip class: %s", e.class)
+}
+
+type UnknownCloudProviderError struct {
+	provider string
+}
+
+func (e UnknownCloudProviderError) Error() string {
+	return fmt.Sprintf("unknown cloud provider: %s", e.provider)
+}
+
 type UnknownTransformError struct {
 	transform string
 }
@@ -0,0 +1,19 @@
+package rules
+
+import "time"
+
+// SetTimeRuleClock replaces clock of time rule, so it can be tested at
+// fixed time.
+func SetTimeRuleClock(r Rule, now func() time.Time) {
+	r.(*TimeRule).now = now
+}
+
+// WatchFile is exported for tests of shared file watcher.
+var WatchFile = watchFile
+
+// WatchedDirs returns number of directories watched by shared watcher.
+func WatchedDirs() int {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	return len(watcher.dirs)
+}
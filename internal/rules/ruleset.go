@@ -6,11 +6,14 @@ import (
 
 	"github.com/D00Movenok/BounceBack/internal/common"
 	"github.com/D00Movenok/BounceBack/internal/database"
+	"github.com/D00Movenok/BounceBack/pkg/resolver"
 	"github.com/rs/zerolog/log"
 )
 
 type RuleSet struct {
 	Rules map[string]Rule
+	// Resolver is shared by DNS rules, nil if globals have no upstreams.
+	Resolver *resolver.Resolver
 }
 
 func (rs *RuleSet) Get(name string) (Rule, bool) {
@@ -26,6 +29,13 @@ func NewRuleSet(
 	globals common.Globals,
 ) (*RuleSet, error) {
 	rs := RuleSet{Rules: map[string]Rule{}}
+	if len(globals.Resolver.Upstreams) != 0 {
+		r, err := newResolver(globals.Resolver.Upstreams, globals)
+		if err != nil {
+			return nil, err
+		}
+		rs.Resolver = r
+	}
 
 	for _, rc := range cfg {
 		tokens := strings.Split(rc.Type, "::")
@@ -3,11 +3,18 @@ package rules
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"net/netip"
 	"os"
 	"regexp"
 	"strings"
 
+	"github.com/D00Movenok/BounceBack/internal/common"
+	"github.com/D00Movenok/BounceBack/internal/packs"
 	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/D00Movenok/BounceBack/pkg/asndb"
+	"github.com/D00Movenok/BounceBack/pkg/lists"
+	"github.com/D00Movenok/BounceBack/pkg/resolver"
 	"github.com/rs/zerolog"
 	"golang.org/x/sync/errgroup"
 )
@@ -32,34 +39,137 @@ func PrepareMany(
 	return eg.Wait() //nolint: wrapcheck // wrapped above
 }
 
-// parses regexp list (one regexp per line) removing content.
-func getRegexpList(path string) ([]*regexp.Regexp, error) {
-	var (
-		re *regexp.Regexp
-		l  []*regexp.Regexp
-	)
-
+// opens list file or embedded pack if path is "builtin://<name>".
+func openList(path string) (io.ReadCloser, error) {
+	if name, ok := packs.IsBuiltin(path); ok {
+		r, err := packs.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("can't open pack: %w", err)
+		}
+		return r, nil
+	}
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("can't open regexp file: %w", err)
+		return nil, fmt.Errorf("can't open list: %w", err)
+	}
+	return file, nil
+}
+
+// reads entries of list file or embedded pack.
+func readList(path string, opts lists.Options) ([]lists.Entry, error) {
+	r, err := openList(path)
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
+	return lists.Read(path, r, opts) //nolint: wrapcheck // has position
+}
 
+// parses regexp list (one regexp per entry of list format).
+func getRegexpList(
+	path string,
+	opts lists.Options,
+) ([]*regexp.Regexp, error) {
+	entries, err := readList(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't read regexp file: %w", err)
+	}
+
+	l := make([]*regexp.Regexp, 0, len(entries))
+	for _, e := range entries {
+		re, cerr := regexp.Compile(e.Value)
+		if cerr != nil {
+			return nil, e.Wrap(fmt.Errorf("can't parse regexp: %w", cerr))
+		}
+		l = append(l, re)
+	}
+
+	return l, nil
+}
+
+// parses IP list (IP addresses, subnets and ranges of list format).
+func getIPList(path string, opts lists.Options) ([]netip.Prefix, error) {
+	entries, err := readList(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't read ip list file: %w", err)
+	}
+	l, err := lists.ParsePrefixes(entries)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse ip list: %w", err)
+	}
+	return l, nil
+}
+
+// returns directory of subscribed lists.
+func getListsDir(globals common.Globals) string {
+	if globals.ListsDir == "" {
+		return lists.DefaultDir
+	}
+	return globals.ListsDir
+}
+
+// parses ASN list (one ASN per line, "AS" prefix is optional) removing
+// comments.
+func getASNList(path string) ([]uint32, error) {
+	file, err := openList(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open asn file: %w", err)
+	}
+	defer file.Close()
+
+	var l []uint32
 	s := bufio.NewScanner(file)
 	for s.Scan() {
-		line := s.Text()
-		line, _, _ = strings.Cut(line, "#") // remove comment
-		if line != "" {
-			re, err = regexp.Compile(line)
-			if err != nil {
-				return nil, fmt.Errorf("can't parse regexp: %w", err)
-			}
-			l = append(l, re)
+		line, _, _ := strings.Cut(s.Text(), "#") // remove comment
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		asn, perr := asndb.ParseASN(line)
+		if perr != nil {
+			return nil, fmt.Errorf("can't parse asn \"%s\": %w", line, perr)
 		}
+		l = append(l, asn)
+	}
+	if err = s.Err(); err != nil {
+		return nil, fmt.Errorf("can't read asn file: %w", err)
 	}
 
 	return l, nil
 }
 
+// creates resolver of upstreams with settings from globals.
+func newResolver(
+	upstreams []string,
+	globals common.Globals,
+) (*resolver.Resolver, error) {
+	r, err := resolver.New(resolver.Config{
+		Upstreams: upstreams,
+		Timeout:   globals.Resolver.Timeout,
+		Retries:   globals.Resolver.Retries,
+		CacheSize: globals.Resolver.CacheSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't create resolver: %w", err)
+	}
+	return r, nil
+}
+
+// returns resolver of "dns" param if set, shared resolver otherwise.
+func getResolver(
+	rs RuleSet,
+	dns string,
+	globals common.Globals,
+) (*resolver.Resolver, error) {
+	if dns != "" {
+		return newResolver([]string{dns}, globals)
+	}
+	if rs.Resolver == nil {
+		return nil, ErrNoResolver
+	}
+	return rs.Resolver, nil
+}
+
 func xorDecrypt(key []byte, data []byte) []byte {
 	for i := 0; i < len(data); i++ {
 		data[i] ^= key[i%len(key)]
@@ -119,3 +229,33 @@ func checksum8(data []byte) uint8 {
 	}
 	return out
 }
+
+func compileRegexps(list []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(list))
+	for _, s := range list {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// returns first matched regexp or nil.
+func matchAny(list []*regexp.Regexp, s string) *regexp.Regexp {
+	for _, r := range list {
+		if r.MatchString(s) {
+			return r
+		}
+	}
+	return nil
+}
+
+func regexpStrings(list []*regexp.Regexp) []string {
+	res := make([]string, 0, len(list))
+	for _, r := range list {
+		res = append(res, r.String())
+	}
+	return res
+}
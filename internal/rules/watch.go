@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadDelay merges bursts of file events (e.g. truncate and write) into
+// one reload.
+const reloadDelay = 500 * time.Millisecond
+
+// watcher is shared by all rules, so number of watched files isn't limited
+// by inotify instances.
+var watcher fileWatcher
+
+type fileWatcher struct {
+	mu    sync.Mutex
+	w     *fsnotify.Watcher
+	dirs  map[string]struct{}
+	files map[string]*watchedFile
+}
+
+type watchedFile struct {
+	reloads []func()
+	timer   *time.Timer
+}
+
+// watchFile calls reload after file is written or replaced. Directory is
+// watched, because files are often replaced by rename. Removal of file is
+// ignored, so the last loaded data is kept.
+func watchFile(path string, reload func()) error {
+	return watcher.add(filepath.Clean(path), reload)
+}
+
+// CloseWatcher stops watching rule files and cancels pending reloads.
+// Files watched later start a new watcher.
+func CloseWatcher() error {
+	return watcher.close()
+}
+
+func (fw *fileWatcher) add(path string, reload func()) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.w == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("can't create file watcher: %w", err)
+		}
+		fw.w = w
+		fw.dirs = make(map[string]struct{})
+		fw.files = make(map[string]*watchedFile)
+		go fw.run(w)
+	}
+
+	dir := filepath.Dir(path)
+	if _, ok := fw.dirs[dir]; !ok {
+		if err := fw.w.Add(dir); err != nil {
+			return fmt.Errorf("can't watch \"%s\": %w", path, err)
+		}
+		fw.dirs[dir] = struct{}{}
+	}
+
+	f, ok := fw.files[path]
+	if !ok {
+		f = &watchedFile{}
+		fw.files[path] = f
+	}
+	f.reloads = append(f.reloads, reload)
+	return nil
+}
+
+func (fw *fileWatcher) run(w *fsnotify.Watcher) {
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if e.Has(fsnotify.Write | fsnotify.Create) {
+				fw.schedule(filepath.Clean(e.Name))
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Error().Err(err).Msg("File watcher error")
+		}
+	}
+}
+
+func (fw *fileWatcher) schedule(path string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	f, ok := fw.files[path]
+	if !ok {
+		return
+	}
+	if f.timer == nil {
+		f.timer = time.AfterFunc(reloadDelay, func() { fw.reload(path) })
+	} else {
+		f.timer.Reset(reloadDelay)
+	}
+}
+
+func (fw *fileWatcher) reload(path string) {
+	fw.mu.Lock()
+	var reloads []func()
+	// file may be unwatched by close while timer is fired
+	if f, ok := fw.files[path]; ok {
+		reloads = f.reloads
+	}
+	fw.mu.Unlock()
+
+	for _, reload := range reloads {
+		reload()
+	}
+}
+
+func (fw *fileWatcher) close() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.w == nil {
+		return nil
+	}
+	for _, f := range fw.files {
+		if f.timer != nil {
+			f.timer.Stop()
+		}
+	}
+	err := fw.w.Close()
+	fw.w = nil
+	fw.dirs = nil
+	fw.files = nil
+	if err != nil {
+		return fmt.Errorf("can't close file watcher: %w", err)
+	}
+	return nil
+}
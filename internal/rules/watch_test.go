@@ -0,0 +1,49 @@
+package rules_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/internal/rules"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestWatchFile(t *testing.T) {
+	require.NoError(t, rules.CloseWatcher())
+	t.Cleanup(func() { require.NoError(t, rules.CloseWatcher()) })
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	firstReloads := atomic.NewInt64(0)
+	secondReloads := atomic.NewInt64(0)
+	require.NoError(t, rules.WatchFile(first, func() { firstReloads.Inc() }))
+	require.NoError(t, rules.WatchFile(first, func() { firstReloads.Inc() }))
+	require.NoError(t, rules.WatchFile(second, func() { secondReloads.Inc() }))
+	require.Equal(t, 1, rules.WatchedDirs())
+
+	// only callbacks of changed file are called
+	require.NoError(t, os.WriteFile(first, []byte("data"), 0o600))
+	require.Eventually(t, func() bool {
+		return firstReloads.Load() == 2
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Zero(t, secondReloads.Load())
+
+	// pending reload is canceled by close
+	require.NoError(t, os.WriteFile(second, []byte("data"), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, rules.CloseWatcher())
+	require.Zero(t, rules.WatchedDirs())
+	time.Sleep(time.Second)
+	require.Zero(t, secondReloads.Load())
+
+	// watcher is recreated after close
+	require.NoError(t, rules.WatchFile(second, func() { secondReloads.Inc() }))
+	require.NoError(t, os.WriteFile(second, []byte("new"), 0o600))
+	require.Eventually(t, func() bool {
+		return secondReloads.Load() == 1
+	}, 5*time.Second, 100*time.Millisecond)
+}
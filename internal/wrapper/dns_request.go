@@ -7,6 +7,8 @@ import (
 	"net/netip"
 	"net/url"
 
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
 	"github.com/miekg/dns"
 )
 
@@ -60,3 +62,15 @@ func (r *DNSRequest) GetMethod() (string, error) {
 func (r *DNSRequest) GetQuestions() ([]dns.Question, error) {
 	return r.Request.Question, nil
 }
+
+func (r *DNSRequest) GetClientHello() (*tlshello.ClientHello, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *DNSRequest) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *DNSRequest) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
 	"github.com/miekg/dns"
 	"github.com/rs/zerolog/log"
 )
@@ -17,6 +19,8 @@ import (
 // It's expected that Request.Body is already wrapped with BodyReader.
 type HTTPRequest struct {
 	Request *http.Request
+	// ClientHello is nil for plain HTTP requests.
+	ClientHello *tlshello.ClientHello
 }
 
 // TODO: FIX IP may be hijacked if set one of used headers.
@@ -92,3 +96,18 @@ func (r *HTTPRequest) resetBody() {
 func (r *HTTPRequest) GetQuestions() ([]dns.Question, error) {
 	return nil, ErrNotSupported
 }
+
+func (r *HTTPRequest) GetClientHello() (*tlshello.ClientHello, error) {
+	if r.ClientHello == nil {
+		return nil, ErrNotSupported
+	}
+	return r.ClientHello, nil
+}
+
+func (r *HTTPRequest) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *HTTPRequest) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
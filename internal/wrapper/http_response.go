@@ -0,0 +1,103 @@
+package wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/miekg/dns"
+)
+
+// ResponseBodyPrefixSize is a max size of upstream response body
+// visible for rules.
+const ResponseBodyPrefixSize = 64 * 1024
+
+// HTTPResponse is a wrapper around upstream http.Response implementing
+// Entity interface. It's expected that response body or its first
+// ResponseBodyPrefixSize bytes are already read into Body, so
+// Response.Body is not used.
+type HTTPResponse struct {
+	Response *http.Response
+	Body     []byte
+	From     netip.Addr
+}
+
+func (r *HTTPResponse) GetIP() netip.Addr {
+	return r.From
+}
+
+// Return status line, headers and body prefix.
+func (r *HTTPResponse) GetRaw() ([]byte, error) {
+	var d bytes.Buffer
+	_, err := fmt.Fprintf(
+		&d,
+		"%s %s\r\n",
+		r.Response.Proto,
+		r.Response.Status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't write status line: %w", err)
+	}
+	if err = r.Response.Header.Write(&d); err != nil {
+		return nil, fmt.Errorf("can't write headers: %w", err)
+	}
+	d.WriteString("\r\n")
+	d.Write(r.bodyPrefix())
+	return d.Bytes(), nil
+}
+
+func (r *HTTPResponse) GetBody() ([]byte, error) {
+	b := r.bodyPrefix()
+	dst := make([]byte, len(b))
+	copy(dst, b)
+	return dst, nil
+}
+
+func (r *HTTPResponse) GetCookies() ([]*http.Cookie, error) {
+	return r.Response.Cookies(), nil
+}
+
+func (r *HTTPResponse) GetHeaders() (map[string][]string, error) {
+	return r.Response.Header, nil
+}
+
+func (r *HTTPResponse) GetURL() (*url.URL, error) {
+	if r.Response.Request == nil {
+		return nil, ErrNotSupported
+	}
+	return r.Response.Request.URL, nil
+}
+
+func (r *HTTPResponse) GetMethod() (string, error) {
+	if r.Response.Request == nil {
+		return "", ErrNotSupported
+	}
+	return r.Response.Request.Method, nil
+}
+
+func (r *HTTPResponse) GetQuestions() ([]dns.Question, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *HTTPResponse) bodyPrefix() []byte {
+	if len(r.Body) > ResponseBodyPrefixSize {
+		return r.Body[:ResponseBodyPrefixSize]
+	}
+	return r.Body
+}
+
+func (r *HTTPResponse) GetClientHello() (*tlshello.ClientHello, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *HTTPResponse) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (r *HTTPResponse) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,109 @@
+package wrapper_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/internal/wrapper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPResponse(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), wrapper.ResponseBodyPrefixSize+1)
+
+	tests := []struct {
+		name     string
+		request  *http.Request
+		body     []byte
+		wantBody []byte
+	}{
+		{
+			name: "small body",
+			request: &http.Request{
+				Method: http.MethodPost,
+				URL:    &url.URL{Path: "/api"},
+			},
+			body:     []byte("body"),
+			wantBody: []byte("body"),
+		},
+		{
+			name:     "large body",
+			body:     large,
+			wantBody: large[:wrapper.ResponseBodyPrefixSize],
+		},
+		{
+			name:     "empty body",
+			wantBody: []byte{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &wrapper.HTTPResponse{
+				Response: &http.Response{
+					Proto:  "HTTP/1.1",
+					Status: "200 OK",
+					Header: http.Header{
+						"Server":     {"nginx"},
+						"Set-Cookie": {"session=1"},
+					},
+					Request: tt.request,
+				},
+				Body: tt.body,
+				From: netip.MustParseAddr("10.0.0.1"),
+			}
+
+			require.Equal(t, netip.MustParseAddr("10.0.0.1"), r.GetIP())
+
+			body, err := r.GetBody()
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, body)
+			if len(body) > 0 {
+				// body is a copy
+				first := r.Body[0]
+				body[0]++
+				require.Equal(t, first, r.Body[0])
+			}
+
+			raw, err := r.GetRaw()
+			require.NoError(t, err)
+			require.Equal(
+				t,
+				append(
+					[]byte("HTTP/1.1 200 OK\r\n"+
+						"Server: nginx\r\nSet-Cookie: session=1\r\n\r\n"),
+					tt.wantBody...,
+				),
+				raw,
+			)
+
+			headers, err := r.GetHeaders()
+			require.NoError(t, err)
+			require.Equal(t, []string{"nginx"}, headers["Server"])
+
+			cookies, err := r.GetCookies()
+			require.NoError(t, err)
+			require.Len(t, cookies, 1)
+			require.Equal(t, "session", cookies[0].Name)
+
+			u, err := r.GetURL()
+			m, merr := r.GetMethod()
+			if tt.request == nil {
+				require.ErrorIs(t, err, wrapper.ErrNotSupported)
+				require.ErrorIs(t, merr, wrapper.ErrNotSupported)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, merr)
+				require.Equal(t, "/api", u.Path)
+				require.Equal(t, http.MethodPost, m)
+			}
+
+			_, err = r.GetQuestions()
+			require.ErrorIs(t, err, wrapper.ErrNotSupported)
+			_, err = r.GetClientHello()
+			require.ErrorIs(t, err, wrapper.ErrNotSupported)
+		})
+	}
+}
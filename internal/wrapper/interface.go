@@ -5,6 +5,8 @@ import (
 	"net/netip"
 	"net/url"
 
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
 	"github.com/miekg/dns"
 )
 
@@ -21,4 +23,13 @@ type Entity interface {
 
 	// DNS
 	GetQuestions() ([]dns.Question, error)
+
+	// TLS
+	GetClientHello() (*tlshello.ClientHello, error)
+
+	// SSH
+	GetSSHHandshake() (*hassh.Handshake, error)
+
+	// SMTP
+	GetSMTPEnvelope() (*SMTPEnvelope, error)
 }
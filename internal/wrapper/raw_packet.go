@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"sync"
 
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
 	"github.com/miekg/dns"
 )
 
@@ -52,3 +54,15 @@ func (p *RawPacket) GetMethod() (string, error) {
 func (p *RawPacket) GetQuestions() ([]dns.Question, error) {
 	return nil, ErrNotSupported
 }
+
+func (p *RawPacket) GetClientHello() (*tlshello.ClientHello, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *RawPacket) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *RawPacket) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
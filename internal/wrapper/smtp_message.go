@@ -0,0 +1,121 @@
+package wrapper
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/textproto"
+	"net/url"
+
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/miekg/dns"
+)
+
+// SMTPEnvelope contains SMTP dialogue values.
+type SMTPEnvelope struct {
+	Helo     string
+	MailFrom string
+	RcptTo   []string
+}
+
+// SMTPMessage is a wrapper around SMTP envelope and message data
+// implementing Entity interface.
+type SMTPMessage struct {
+	Envelope *SMTPEnvelope
+	// Data is a message (headers and body) without dot-stuffing
+	// and with LF line endings.
+	Data []byte
+	From netip.Addr
+
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// NewSMTPMessage parses message headers. Malformed headers are
+// parsed partially, the rest of message is treated as body.
+func NewSMTPMessage(
+	env *SMTPEnvelope,
+	data []byte,
+	from netip.Addr,
+) *SMTPMessage {
+	r := bufio.NewReader(bytes.NewReader(data))
+	header, _ := textproto.NewReader(r).ReadMIMEHeader()
+	body, _ := io.ReadAll(r)
+	return &SMTPMessage{
+		Envelope: env,
+		Data:     data,
+		From:     from,
+
+		header: header,
+		body:   body,
+	}
+}
+
+func (m *SMTPMessage) GetIP() netip.Addr {
+	return m.From
+}
+
+// Return envelope commands and message data.
+func (m *SMTPMessage) GetRaw() ([]byte, error) {
+	var d bytes.Buffer
+	_, err := fmt.Fprintf(
+		&d,
+		"HELO %s\r\nMAIL FROM:<%s>\r\n",
+		m.Envelope.Helo,
+		m.Envelope.MailFrom,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't write envelope: %w", err)
+	}
+	for _, rcpt := range m.Envelope.RcptTo {
+		if _, err = fmt.Fprintf(&d, "RCPT TO:<%s>\r\n", rcpt); err != nil {
+			return nil, fmt.Errorf("can't write envelope: %w", err)
+		}
+	}
+	d.WriteString("DATA\r\n")
+	d.Write(m.Data)
+	return d.Bytes(), nil
+}
+
+func (m *SMTPMessage) GetBody() ([]byte, error) {
+	dst := make([]byte, len(m.body))
+	copy(dst, m.body)
+	return dst, nil
+}
+
+func (m *SMTPMessage) GetCookies() ([]*http.Cookie, error) {
+	return nil, ErrNotSupported
+}
+
+// Return message headers.
+func (m *SMTPMessage) GetHeaders() (map[string][]string, error) {
+	return m.header, nil
+}
+
+func (m *SMTPMessage) GetURL() (*url.URL, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *SMTPMessage) GetMethod() (string, error) {
+	return "", ErrNotSupported
+}
+
+func (m *SMTPMessage) GetQuestions() ([]dns.Question, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *SMTPMessage) GetClientHello() (*tlshello.ClientHello, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *SMTPMessage) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *SMTPMessage) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return m.Envelope, nil
+}
@@ -0,0 +1,65 @@
+package wrapper
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/miekg/dns"
+)
+
+// SSHHandshake is a wrapper around cleartext SSH client handshake
+// (identification string and KEXINIT) implementing Entity interface.
+type SSHHandshake struct {
+	Handshake *hassh.Handshake
+	From      netip.Addr
+}
+
+func (h *SSHHandshake) GetIP() netip.Addr {
+	return h.From
+}
+
+// Return raw identification string and KEXINIT packet.
+func (h *SSHHandshake) GetRaw() ([]byte, error) {
+	dst := make([]byte, len(h.Handshake.Raw))
+	copy(dst, h.Handshake.Raw)
+	return dst, nil
+}
+
+func (h *SSHHandshake) GetBody() ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetCookies() ([]*http.Cookie, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetHeaders() (map[string][]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetURL() (*url.URL, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetMethod() (string, error) {
+	return "", ErrNotSupported
+}
+
+func (h *SSHHandshake) GetQuestions() ([]dns.Question, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetClientHello() (*tlshello.ClientHello, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *SSHHandshake) GetSSHHandshake() (*hassh.Handshake, error) {
+	return h.Handshake, nil
+}
+
+func (h *SSHHandshake) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
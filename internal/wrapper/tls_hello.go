@@ -0,0 +1,65 @@
+package wrapper
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/miekg/dns"
+)
+
+// TLSHello is a wrapper around TLS ClientHello of not terminated TLS
+// connection implementing Entity interface.
+type TLSHello struct {
+	Hello *tlshello.ClientHello
+	From  netip.Addr
+}
+
+func (h *TLSHello) GetIP() netip.Addr {
+	return h.From
+}
+
+// Return raw TLS records of ClientHello.
+func (h *TLSHello) GetRaw() ([]byte, error) {
+	dst := make([]byte, len(h.Hello.Raw))
+	copy(dst, h.Hello.Raw)
+	return dst, nil
+}
+
+func (h *TLSHello) GetBody() ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetCookies() ([]*http.Cookie, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetHeaders() (map[string][]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetURL() (*url.URL, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetMethod() (string, error) {
+	return "", ErrNotSupported
+}
+
+func (h *TLSHello) GetQuestions() ([]dns.Question, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetClientHello() (*tlshello.ClientHello, error) {
+	return h.Hello, nil
+}
+
+func (h *TLSHello) GetSSHHandshake() (*hassh.Handshake, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *TLSHello) GetSMTPEnvelope() (*SMTPEnvelope, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,66 @@
+package asndb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+)
+
+var (
+	ErrMalformed = errors.New("malformed asn database")
+	ErrNoASN     = errors.New("database has no asn records")
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Record is an autonomous system of IP.
+type Record struct {
+	ASN          uint32
+	Organisation string
+	// Country is empty if database doesn't contain it.
+	Country string
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("AS%d %s", r.ASN, r.Organisation)
+}
+
+type Database interface {
+	// Lookup returns record of IP, false if IP isn't routed.
+	Lookup(ip netip.Addr) (Record, bool)
+	// Len returns count of networks in database.
+	Len() int
+}
+
+// Open reads ASN database from MMDB (MaxMind GeoLite2-ASN format), iptoasn
+// TSV (start, end, ASN, country, description) or RouteViews pfx2as
+// (IP, prefix length, ASN) file. Files may be gzipped.
+func Open(path string) (Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read asn database: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse detects format of data like Open does.
+func Parse(data []byte) (Database, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("can't open gzip: %w", err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("can't read gzip: %w", err)
+		}
+	}
+
+	if isMMDB(data) {
+		return newMMDB(data)
+	}
+	return parseTSV(bytes.NewReader(data))
+}
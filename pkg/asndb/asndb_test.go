@@ -0,0 +1,308 @@
+package asndb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/asndb"
+	"github.com/stretchr/testify/require"
+)
+
+type network struct {
+	prefix netip.Prefix
+	asn    uint32
+	org    string
+}
+
+var networks = []network{
+	{netip.MustParsePrefix("1.1.1.0/24"), 13335, "CLOUDFLARENET"},
+	{netip.MustParsePrefix("8.8.8.0/24"), 15169, "GOOGLE"},
+	// nested in 8.8.8.0/24
+	{netip.MustParsePrefix("8.8.8.128/25"), 396982, "GOOGLE-CLOUD"},
+	{netip.MustParsePrefix("2a00:1450::/32"), 15169, "GOOGLE"},
+}
+
+// mmdbTree is a binary trie of MMDB writer. Record is either child node
+// or data offset.
+type mmdbTree struct {
+	child [2]*mmdbTree
+	data  [2]int
+}
+
+func writeRecord(
+	b []byte,
+	size int,
+	left uint32,
+	right uint32,
+) {
+	switch size {
+	case 24:
+		b[0], b[1], b[2] = byte(left>>16), byte(left>>8), byte(left)
+		b[3], b[4], b[5] = byte(right>>16), byte(right>>8), byte(right)
+	case 28:
+		b[0], b[1], b[2] = byte(left>>16), byte(left>>8), byte(left)
+		b[3] = byte(left>>20)&0xf0 | byte(right>>24)&0x0f
+		b[4], b[5], b[6] = byte(right>>16), byte(right>>8), byte(right)
+	case 32:
+		binary.BigEndian.PutUint32(b, left)
+		binary.BigEndian.PutUint32(b[4:], right)
+	}
+}
+
+func ctrl(typ byte, size int) []byte {
+	var b []byte
+	if typ > 7 {
+		b = []byte{0, typ - 7}
+	} else {
+		b = []byte{typ << 5}
+	}
+	if size < 29 {
+		b[0] |= byte(size)
+		return b
+	}
+	b[0] |= 29
+	return append(b, byte(size-29))
+}
+
+func str(s string) []byte {
+	return append(ctrl(2, len(s)), s...)
+}
+
+func uint32Value(v uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, v)
+	return append(ctrl(6, len(b)), b...)
+}
+
+func uint16Value(v uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, v)
+	return append(ctrl(5, len(b)), b...)
+}
+
+// writeMMDB creates GeoLite2-ASN like database.
+func writeMMDB(
+	t *testing.T,
+	ipVersion int,
+	recordSize int,
+	nets []network,
+) []byte {
+	var data []byte
+	root := &mmdbTree{}
+	orgKey := -1
+	for _, n := range nets {
+		offset := len(data)
+		data = append(data, ctrl(7, 2)...)
+		data = append(data, str("autonomous_system_number")...)
+		data = append(data, uint32Value(n.asn)...)
+		// reuse key with pointer
+		if orgKey < 0 {
+			orgKey = len(data)
+			data = append(data, str("autonomous_system_organization")...)
+		} else {
+			data = append(data, 1<<5|byte(orgKey>>8), byte(orgKey))
+		}
+		data = append(data, str(n.org)...)
+
+		addr := n.prefix.Addr().AsSlice()
+		bits := n.prefix.Bits()
+		if ipVersion == 6 && n.prefix.Addr().Is4() {
+			addr = append(make([]byte, 12), addr...)
+			bits += 96
+		}
+		require.True(t, ipVersion == 6 || len(addr) == 4)
+
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if i == bits-1 {
+				node.data[bit] = offset + 1
+				break
+			}
+			if node.child[bit] == nil {
+				// nested network splits data of parent network
+				d := node.data[bit]
+				node.child[bit] = &mmdbTree{data: [2]int{d, d}}
+			}
+			node = node.child[bit]
+		}
+	}
+
+	// number nodes in BFS order
+	var nodes []*mmdbTree
+	ids := map[*mmdbTree]int{}
+	for q := []*mmdbTree{root}; len(q) > 0; q = q[1:] {
+		ids[q[0]] = len(nodes)
+		nodes = append(nodes, q[0])
+		for _, c := range q[0].child {
+			if c != nil {
+				q = append(q, c)
+			}
+		}
+	}
+
+	nodeSize := recordSize / 4
+	tree := make([]byte, len(nodes)*nodeSize)
+	for i, n := range nodes {
+		var records [2]uint32
+		for bit := 0; bit < 2; bit++ {
+			switch {
+			case n.child[bit] != nil:
+				records[bit] = uint32(ids[n.child[bit]])
+			case n.data[bit] > 0:
+				records[bit] = uint32(len(nodes) + 16 + n.data[bit] - 1)
+			default:
+				records[bit] = uint32(len(nodes))
+			}
+		}
+		writeRecord(tree[i*nodeSize:], recordSize, records[0], records[1])
+	}
+
+	var db []byte
+	db = append(db, tree...)
+	db = append(db, make([]byte, 16)...)
+	db = append(db, data...)
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, ctrl(7, 4)...)
+	db = append(db, str("node_count")...)
+	db = append(db, uint32Value(uint32(len(nodes)))...)
+	db = append(db, str("record_size")...)
+	db = append(db, uint16Value(uint16(recordSize))...)
+	db = append(db, str("ip_version")...)
+	db = append(db, uint16Value(uint16(ipVersion))...)
+	db = append(db, str("database_type")...)
+	db = append(db, str("GeoLite2-ASN")...)
+	return db
+}
+
+const iptoasn = "# iptoasn\n" +
+	"1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE - Google LLC #1\n" +
+	"2a00:1450::\t2a00:1450:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n"
+
+const pfx2as = "8.8.0.0\t16\t15169\n" +
+	"8.8.8.0\t24\t396982_15169\n" +
+	"9.9.9.0\t24\t19281,42\n" +
+	"2a00:1450::\t32\t15169\n"
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	type lookup struct {
+		ip  string
+		asn uint32
+		org string
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		lookups []lookup
+	}{
+		{
+			"iptoasn",
+			[]byte(iptoasn),
+			[]lookup{
+				{"1.0.0.1", 13335, "CLOUDFLARENET"},
+				{"1.0.2.1", 0, ""},
+				{"8.8.8.8", 15169, "GOOGLE - Google LLC #1"},
+				{"8.8.9.8", 0, ""},
+				{"::ffff:8.8.8.8", 15169, "GOOGLE - Google LLC #1"},
+				{"2a00:1450:4001::1", 15169, "GOOGLE"},
+				{"2a01::1", 0, ""},
+			},
+		},
+		{
+			"gzipped iptoasn",
+			gzipped(t, iptoasn),
+			[]lookup{{"1.0.0.1", 13335, "CLOUDFLARENET"}},
+		},
+		{
+			"pfx2as longest prefix",
+			[]byte(pfx2as),
+			[]lookup{
+				{"8.8.4.4", 15169, ""},
+				{"8.8.8.8", 396982, ""},
+				{"9.9.9.9", 19281, ""},
+				{"2a00:1450:4001::1", 15169, ""},
+				{"1.1.1.1", 0, ""},
+			},
+		},
+		{
+			"ipv4 mmdb 24 bit records",
+			writeMMDB(t, 4, 24, networks[:3]),
+			[]lookup{
+				{"1.1.1.1", 13335, "CLOUDFLARENET"},
+				{"8.8.8.8", 15169, "GOOGLE"},
+				{"8.8.8.200", 396982, "GOOGLE-CLOUD"},
+				{"8.8.4.4", 0, ""},
+				{"2a00:1450::1", 0, ""},
+			},
+		},
+		{
+			"ipv6 mmdb 28 bit records",
+			writeMMDB(t, 6, 28, networks),
+			[]lookup{
+				{"1.1.1.1", 13335, "CLOUDFLARENET"},
+				{"8.8.8.200", 396982, "GOOGLE-CLOUD"},
+				{"2a00:1450:4001::1", 15169, "GOOGLE"},
+				{"2a01::1", 0, ""},
+			},
+		},
+		{
+			"ipv6 mmdb 32 bit records",
+			writeMMDB(t, 6, 32, networks),
+			[]lookup{
+				{"::ffff:1.1.1.1", 13335, "CLOUDFLARENET"},
+				{"2a00:1450:4001::1", 15169, "GOOGLE"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := asndb.Parse(tt.data)
+			require.NoError(t, err)
+			for _, l := range tt.lookups {
+				r, ok := db.Lookup(netip.MustParseAddr(l.ip))
+				require.Equal(t, l.asn != 0, ok, l.ip)
+				require.Equal(t, l.asn, r.ASN, l.ip)
+				require.Equal(t, l.org, r.Organisation, l.ip)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"empty", "", asndb.ErrNoASN},
+		{"only comments", "# nothing\n", asndb.ErrNoASN},
+		{"bad ip", "1.0.0\t1.0.0.255\t1\tUS\tX\n", asndb.ErrMalformed},
+		{"reversed range", "1.0.0.9\t1.0.0.1\t1\tUS\tX\n", asndb.ErrMalformed},
+		{"bad asn", "1.0.0.0\t24\tASX\n", asndb.ErrMalformed},
+		{"bad prefix", "1.0.0.0\t33\t1\n", asndb.ErrMalformed},
+		{"bad columns", "1.0.0.0\t1\n", asndb.ErrMalformed},
+		{
+			"bad mmdb",
+			"\x00\x00\x00\xab\xcd\xefMaxMind.com\xe1\x4anode_count",
+			asndb.ErrMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := asndb.Parse([]byte(tt.data))
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package asndb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/netip"
+)
+
+// MaxMind DB format: https://maxmind.github.io/MaxMind-DB/
+const (
+	// metadata is within last 128KiB of file.
+	maxMetadataSize = 128 * 1024
+	// zero bytes between search tree and data section.
+	dataSeparatorSize = 16
+	// IPv4 addresses are stored in IPv6 tree as ::a.b.c.d.
+	ipv4SubtreeDepth = 96
+)
+
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+func isMMDB(data []byte) bool {
+	return findMetadata(data) >= 0
+}
+
+// findMetadata returns start of metadata, -1 if not found.
+func findMetadata(data []byte) int {
+	tail := data
+	if len(tail) > maxMetadataSize {
+		tail = tail[len(tail)-maxMetadataSize:]
+	}
+	i := bytes.LastIndex(tail, metadataMarker)
+	if i < 0 {
+		return -1
+	}
+	return len(data) - len(tail) + i + len(metadataMarker)
+}
+
+type mmdb struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipv6       bool
+	ipv4Start  uint
+}
+
+func newMMDB(data []byte) (*mmdb, error) {
+	start := findMetadata(data)
+	md, _, err := decoder{buf: data[start:]}.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := md.(map[string]any)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	nodeCount, _ := meta["node_count"].(uint64)
+	recordSize, _ := meta["record_size"].(uint64)
+	ipVersion, _ := meta["ip_version"].(uint64)
+
+	//nolint:gomnd // supported record sizes
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, ErrMalformed
+	}
+	treeSize := nodeCount * recordSize / 4 //nolint:gomnd // 2 records
+	dataStart := treeSize + dataSeparatorSize
+	if dataStart > uint64(start-len(metadataMarker)) {
+		return nil, ErrMalformed
+	}
+
+	db := &mmdb{
+		tree:       data[:treeSize],
+		data:       data[dataStart : start-len(metadataMarker)],
+		nodeCount:  uint(nodeCount),
+		recordSize: uint(recordSize),
+		ipv6:       ipVersion == 6, //nolint:gomnd // IPv6
+	}
+	if db.ipv6 {
+		for i := 0; i < ipv4SubtreeDepth && db.ipv4Start < db.nodeCount; i++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// record returns left (bit is 0) or right record of node.
+func (db *mmdb) record(node uint, bit uint) uint {
+	size := db.recordSize / 4 //nolint:gomnd // bytes in node
+	b := db.tree[node*size:]
+	switch db.recordSize {
+	case 24: //nolint:gomnd // record size
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28: //nolint:gomnd // record size
+		// middle byte has 4 high bits of both records
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 |
+				uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 |
+			uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+func (db *mmdb) Lookup(ip netip.Addr) (Record, bool) {
+	ip = ip.Unmap()
+	if ip.Is6() && !db.ipv6 {
+		return Record{}, false
+	}
+
+	var node uint
+	if ip.Is4() && db.ipv6 {
+		node = db.ipv4Start
+	}
+	addr := ip.AsSlice()
+	for i := 0; i < len(addr)*8 && node < db.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-i%8)) & 1 //nolint:gomnd // bits in byte
+		node = db.record(node, bit)
+	}
+	// node count is "not found" value
+	if node <= db.nodeCount {
+		return Record{}, false
+	}
+
+	v, _, err := decoder{buf: db.data}.decode(
+		node - db.nodeCount - dataSeparatorSize,
+	)
+	if err != nil {
+		return Record{}, false
+	}
+	m, _ := v.(map[string]any)
+	asn, _ := m["autonomous_system_number"].(uint64)
+	org, _ := m["autonomous_system_organization"].(string)
+	if asn == 0 {
+		return Record{}, false
+	}
+	return Record{ASN: uint32(asn), Organisation: org}, true
+}
+
+// Len returns count of search tree nodes, not networks.
+func (db *mmdb) Len() int {
+	return int(db.nodeCount)
+}
+
+type decoder struct {
+	buf []byte
+}
+
+// decode returns value at offset and offset after it.
+func (d decoder) decode(offset uint) (any, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, ErrMalformed
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5) //nolint:gomnd // type bits
+
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// pointer to pointer is invalid and may loop
+		if ptr < uint(len(d.buf)) &&
+			uint(d.buf[ptr]>>5) == typePointer { //nolint:gomnd // type bits
+			return nil, 0, ErrMalformed
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, ErrMalformed
+		}
+		typ = uint(d.buf[offset]) + 7 //nolint:gomnd // extended types
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d.value(typ, size, offset)
+}
+
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&3 + 1 //nolint:gomnd // pointer size bits
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, ErrMalformed
+	}
+	var ptr uint
+	// 4 bytes pointers don't use control byte bits
+	if n < 4 { //nolint:gomnd // max pointer size
+		ptr = uint(ctrl & 0x07)
+	}
+	for _, b := range d.buf[offset : offset+n] {
+		ptr = ptr<<8 | uint(b)
+	}
+	//nolint:gomnd // pointer biases
+	switch n {
+	case 2:
+		ptr += 2048
+	case 3:
+		ptr += 526336
+	}
+	return ptr, offset + n, nil
+}
+
+func (d decoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f) //nolint:gomnd // size bits
+	//nolint:gomnd // size is extended by next bytes
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28 //nolint:gomnd // extra bytes count
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, ErrMalformed
+	}
+	var ext uint
+	for _, b := range d.buf[offset : offset+n] {
+		ext = ext<<8 | uint(b)
+	}
+	//nolint:gomnd // size biases
+	switch n {
+	case 1:
+		size = 29 + ext
+	case 2:
+		size = 285 + ext
+	default:
+		size = 65821 + ext
+	}
+	return size, offset + n, nil
+}
+
+func (d decoder) value(typ, size, offset uint) (any, uint, error) {
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, ErrMalformed
+			}
+			if m[key], offset, err = d.decode(next); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, ErrMalformed
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return b, offset, nil
+	case typeDouble:
+		if size != 8 { //nolint:gomnd // float64 size
+			return nil, 0, ErrMalformed
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 { //nolint:gomnd // float32 size
+			return nil, 0, ErrMalformed
+		}
+		f := math.Float32frombits(binary.BigEndian.Uint32(b))
+		return float64(f), offset, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		if size > 8 { //nolint:gomnd // uint64 size
+			return nil, 0, ErrMalformed
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		if typ == typeInt32 {
+			return int64(int32(v)), offset, nil
+		}
+		return v, offset, nil
+	case typeUint128:
+		// ASN databases don't use it
+		return b, offset, nil
+	default:
+		return nil, 0, ErrMalformed
+	}
+}
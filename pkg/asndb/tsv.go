@@ -0,0 +1,197 @@
+package asndb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// start, end, ASN, country, description.
+	rangeColumns = 5
+	// IP, prefix length, ASN.
+	prefixColumns = 3
+)
+
+type LineError struct {
+	line int
+	err  error
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.err)
+}
+
+func (e LineError) Unwrap() error {
+	return e.err
+}
+
+type ipRange struct {
+	start  netip.Addr
+	end    netip.Addr
+	record Record
+}
+
+// table is a TSV database. Ranges don't overlap, prefixes may be nested
+// and are matched by longest prefix.
+type table struct {
+	ranges   []ipRange
+	prefixes map[netip.Prefix]Record
+	// prefix lengths in descending order.
+	lengths4 []int
+	lengths6 []int
+}
+
+func parseTSV(r io.Reader) (*table, error) {
+	t := &table{prefixes: make(map[netip.Prefix]Record)}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		// descriptions may contain "#", so only whole line comments
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+
+		var err error
+		switch {
+		case len(fields) >= rangeColumns:
+			err = t.addRange(fields)
+		case len(fields) == prefixColumns:
+			err = t.addPrefix(fields)
+		default:
+			err = ErrMalformed
+		}
+		if err != nil {
+			return nil, &LineError{line: n, err: err}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("can't read asn database: %w", err)
+	}
+	if len(t.ranges) == 0 && len(t.prefixes) == 0 {
+		return nil, ErrNoASN
+	}
+
+	sort.Slice(t.ranges, func(i, j int) bool {
+		return t.ranges[i].start.Less(t.ranges[j].start)
+	})
+	for p := range t.prefixes {
+		l := &t.lengths6
+		if p.Addr().Is4() {
+			l = &t.lengths4
+		}
+		if !slices.Contains(*l, p.Bits()) {
+			*l = append(*l, p.Bits())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(t.lengths4)))
+	sort.Sort(sort.Reverse(sort.IntSlice(t.lengths6)))
+	return t, nil
+}
+
+func (t *table) addRange(fields []string) error {
+	start, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return ErrMalformed
+	}
+	end, err := netip.ParseAddr(fields[1])
+	if err != nil || end.Less(start) || start.Is4() != end.Is4() {
+		return ErrMalformed
+	}
+	asn, err := ParseASN(fields[2])
+	if err != nil {
+		return err
+	}
+	// not routed
+	if asn == 0 {
+		return nil
+	}
+
+	country := fields[3]
+	if country == "None" {
+		country = ""
+	}
+	t.ranges = append(t.ranges, ipRange{
+		start: start.Unmap(),
+		end:   end.Unmap(),
+		record: Record{
+			ASN:          asn,
+			Organisation: strings.Join(fields[4:], " "),
+			Country:      country,
+		},
+	})
+	return nil
+}
+
+func (t *table) addPrefix(fields []string) error {
+	addr, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return ErrMalformed
+	}
+	bits, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	p, err := addr.Unmap().Prefix(bits)
+	if err != nil {
+		return ErrMalformed
+	}
+	// multi-origin prefixes are "AS1_AS2", AS sets are "AS1,AS2"
+	first, _, _ := strings.Cut(fields[2], "_")
+	first, _, _ = strings.Cut(first, ",")
+	asn, err := ParseASN(first)
+	if err != nil {
+		return err
+	}
+	t.prefixes[p] = Record{ASN: asn}
+	return nil
+}
+
+// ParseASN parses ASN with optional "AS" prefix.
+func ParseASN(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	return uint32(asn), nil
+}
+
+func (t *table) Lookup(ip netip.Addr) (Record, bool) {
+	ip = ip.Unmap()
+
+	lengths := t.lengths6
+	if ip.Is4() {
+		lengths = t.lengths4
+	}
+	for _, l := range lengths {
+		p, _ := ip.Prefix(l)
+		if r, ok := t.prefixes[p]; ok {
+			return r, true
+		}
+	}
+
+	// first range starting after ip
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return ip.Less(t.ranges[i].start)
+	})
+	if i == 0 {
+		return Record{}, false
+	}
+	r := t.ranges[i-1]
+	if r.end.Less(ip) {
+		return Record{}, false
+	}
+	return r.record, true
+}
+
+func (t *table) Len() int {
+	return len(t.ranges) + len(t.prefixes)
+}
@@ -0,0 +1,369 @@
+package cloudranges
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ProviderAWS        = "aws"
+	ProviderAzure      = "azure"
+	ProviderGCP        = "gcp"
+	ProviderOracle     = "oracle"
+	ProviderCloudflare = "cloudflare"
+)
+
+var (
+	ErrNoRanges = errors.New("no ranges found")
+)
+
+type UnknownProviderError struct {
+	provider string
+}
+
+func (e UnknownProviderError) Error() string {
+	return fmt.Sprintf("unknown cloud provider: %s", e.provider)
+}
+
+// Range is a network of cloud provider. Service and region may be empty.
+type Range struct {
+	Prefix   netip.Prefix
+	Provider string
+	Service  string
+	Region   string
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf(
+		"%s(%s, service=%s, region=%s)",
+		r.Provider,
+		r.Prefix,
+		r.Service,
+		r.Region,
+	)
+}
+
+// Providers returns supported providers.
+func Providers() []string {
+	return []string{
+		ProviderAWS,
+		ProviderAzure,
+		ProviderGCP,
+		ProviderOracle,
+		ProviderCloudflare,
+	}
+}
+
+// Parse reads official range publication of provider:
+//   - aws: ip-ranges.json.
+//   - azure: Service Tags JSON (ServiceTags_Public_*.json).
+//   - gcp: cloud.json.
+//   - oracle: public_ip_ranges.json.
+//   - cloudflare: ips-v4/ips-v6 text lists or API JSON.
+func Parse(provider string, r io.Reader) ([]Range, error) {
+	var (
+		ranges []Range
+		err    error
+	)
+	switch provider {
+	case ProviderAWS:
+		ranges, err = parseAWS(r)
+	case ProviderAzure:
+		ranges, err = parseAzure(r)
+	case ProviderGCP:
+		ranges, err = parseGCP(r)
+	case ProviderOracle:
+		ranges, err = parseOracle(r)
+	case ProviderCloudflare:
+		ranges, err = parseCloudflare(r)
+	default:
+		return nil, &UnknownProviderError{provider: provider}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, ErrNoRanges
+	}
+	return ranges, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("can't parse prefix: %w", err)
+	}
+	return p.Masked(), nil
+}
+
+func decode(r io.Reader, v any) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("can't decode json: %w", err)
+	}
+	return nil
+}
+
+type awsRanges struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+func parseAWS(r io.Reader) ([]Range, error) {
+	var data awsRanges
+	if err := decode(r, &data); err != nil {
+		return nil, err
+	}
+
+	ranges := make([]Range, 0, len(data.Prefixes)+len(data.IPv6Prefixes))
+	add := func(prefix, service, region string) error {
+		p, err := parsePrefix(prefix)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, Range{
+			Prefix:   p,
+			Provider: ProviderAWS,
+			Service:  service,
+			Region:   region,
+		})
+		return nil
+	}
+	for _, p := range data.Prefixes {
+		if err := add(p.IPPrefix, p.Service, p.Region); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range data.IPv6Prefixes {
+		if err := add(p.IPv6Prefix, p.Service, p.Region); err != nil {
+			return nil, err
+		}
+	}
+	return ranges, nil
+}
+
+type azureServiceTags struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+func parseAzure(r io.Reader) ([]Range, error) {
+	var data azureServiceTags
+	if err := decode(r, &data); err != nil {
+		return nil, err
+	}
+
+	var ranges []Range
+	for _, v := range data.Values {
+		// regional tags are named like "AzureCloud.eastus"
+		service := v.Properties.SystemService
+		if service == "" {
+			service, _, _ = strings.Cut(v.Name, ".")
+		}
+		for _, prefix := range v.Properties.AddressPrefixes {
+			p, err := parsePrefix(prefix)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, Range{
+				Prefix:   p,
+				Provider: ProviderAzure,
+				Service:  service,
+				Region:   v.Properties.Region,
+			})
+		}
+	}
+	return ranges, nil
+}
+
+type gcpRanges struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Service    string `json:"service"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+func parseGCP(r io.Reader) ([]Range, error) {
+	var data gcpRanges
+	if err := decode(r, &data); err != nil {
+		return nil, err
+	}
+
+	ranges := make([]Range, 0, len(data.Prefixes))
+	for _, v := range data.Prefixes {
+		prefix := v.IPv4Prefix
+		if prefix == "" {
+			prefix = v.IPv6Prefix
+		}
+		p, err := parsePrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, Range{
+			Prefix:   p,
+			Provider: ProviderGCP,
+			Service:  v.Service,
+			Region:   v.Scope,
+		})
+	}
+	return ranges, nil
+}
+
+type oracleRanges struct {
+	Regions []struct {
+		Region string `json:"region"`
+		CIDRs  []struct {
+			CIDR string   `json:"cidr"`
+			Tags []string `json:"tags"`
+		} `json:"cidrs"`
+	} `json:"regions"`
+}
+
+func parseOracle(r io.Reader) ([]Range, error) {
+	var data oracleRanges
+	if err := decode(r, &data); err != nil {
+		return nil, err
+	}
+
+	var ranges []Range
+	for _, region := range data.Regions {
+		for _, c := range region.CIDRs {
+			p, err := parsePrefix(c.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			// CIDR may be tagged with several services, e.g. OCI and OSN
+			tags := c.Tags
+			if len(tags) == 0 {
+				tags = []string{""}
+			}
+			for _, tag := range tags {
+				ranges = append(ranges, Range{
+					Prefix:   p,
+					Provider: ProviderOracle,
+					Service:  tag,
+					Region:   region.Region,
+				})
+			}
+		}
+	}
+	return ranges, nil
+}
+
+type cloudflareIPs struct {
+	Result struct {
+		IPv4CIDRs []string `json:"ipv4_cidrs"`
+		IPv6CIDRs []string `json:"ipv6_cidrs"`
+	} `json:"result"`
+}
+
+func parseCloudflare(r io.Reader) ([]Range, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("can't read ranges: %w", err)
+	}
+
+	var prefixes []string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var ips cloudflareIPs
+		if err = decode(bytes.NewReader(data), &ips); err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, ips.Result.IPv4CIDRs...)
+		prefixes = append(prefixes, ips.Result.IPv6CIDRs...)
+	} else {
+		s := bufio.NewScanner(bytes.NewReader(data))
+		for s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				prefixes = append(prefixes, l)
+			}
+		}
+	}
+
+	ranges := make([]Range, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		p, perr := parsePrefix(prefix)
+		if perr != nil {
+			return nil, perr
+		}
+		ranges = append(ranges, Range{Prefix: p, Provider: ProviderCloudflare})
+	}
+	return ranges, nil
+}
+
+// Index finds all ranges containing IP.
+type Index struct {
+	prefixes map[netip.Prefix][]Range
+	// prefix lengths in descending order.
+	lengths4 []int
+	lengths6 []int
+	count    int
+}
+
+func NewIndex(ranges []Range) *Index {
+	idx := &Index{
+		prefixes: make(map[netip.Prefix][]Range),
+		count:    len(ranges),
+	}
+	for _, r := range ranges {
+		idx.prefixes[r.Prefix] = append(idx.prefixes[r.Prefix], r)
+	}
+
+	for p := range idx.prefixes {
+		l := &idx.lengths6
+		if p.Addr().Is4() {
+			l = &idx.lengths4
+		}
+		if !slices.Contains(*l, p.Bits()) {
+			*l = append(*l, p.Bits())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(idx.lengths4)))
+	sort.Sort(sort.Reverse(sort.IntSlice(idx.lengths6)))
+	return idx
+}
+
+// Lookup returns ranges containing ip, most specific first.
+func (idx *Index) Lookup(ip netip.Addr) []Range {
+	ip = ip.Unmap()
+	lengths := idx.lengths6
+	if ip.Is4() {
+		lengths = idx.lengths4
+	}
+
+	var res []Range
+	for _, l := range lengths {
+		p, _ := ip.Prefix(l)
+		res = append(res, idx.prefixes[p]...)
+	}
+	return res
+}
+
+// Len returns count of indexed ranges.
+func (idx *Index) Len() int {
+	return idx.count
+}
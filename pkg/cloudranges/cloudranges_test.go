@@ -0,0 +1,248 @@
+package cloudranges_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/cloudranges"
+	"github.com/stretchr/testify/require"
+)
+
+const aws = `{
+  "syncToken": "1700000000",
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2",
+     "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2",
+     "service": "EC2", "network_border_group": "ap-northeast-2"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2",
+     "service": "EC2", "network_border_group": "us-west-2"}
+  ]
+}`
+
+const azure = `{
+  "changeNumber": 1,
+  "cloud": "Public",
+  "values": [
+    {"name": "AzureCloud.eastus", "id": "AzureCloud.eastus",
+     "properties": {"region": "eastus", "platform": "Azure",
+       "systemService": "", "addressPrefixes": ["13.68.128.0/17"]}},
+    {"name": "Storage.WestEurope", "id": "Storage.WestEurope",
+     "properties": {"region": "westeurope", "platform": "Azure",
+       "systemService": "AzureStorage",
+       "addressPrefixes": ["13.69.40.16/28", "2603:1020:206::/48"]}}
+  ]
+}`
+
+const gcp = `{
+  "prefixes": [
+    {"ipv4Prefix": "34.1.208.0/20", "service": "Google Cloud",
+     "scope": "africa-south1"},
+    {"ipv6Prefix": "2600:1900:8000::/44", "service": "Google Cloud",
+     "scope": "us-central1"}
+  ]
+}`
+
+const oracle = `{
+  "regions": [
+    {"region": "us-phoenix-1", "cidrs": [
+      {"cidr": "129.146.0.0/21", "tags": ["OCI"]},
+      {"cidr": "134.70.8.0/21", "tags": ["OSN", "OBJECT_STORAGE"]}
+    ]}
+  ]
+}`
+
+const cloudflareText = "173.245.48.0/20\n103.21.244.0/22\n\n2400:cb00::/32\n"
+
+const cloudflareJSON = `{"result": {
+  "ipv4_cidrs": ["173.245.48.0/20"],
+  "ipv6_cidrs": ["2400:cb00::/32"]
+}, "success": true}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		data     string
+		want     []cloudranges.Range
+	}{
+		{
+			"aws",
+			cloudranges.ProviderAWS,
+			aws,
+			[]cloudranges.Range{
+				{
+					Prefix:   netip.MustParsePrefix("3.5.140.0/22"),
+					Provider: "aws",
+					Service:  "AMAZON",
+					Region:   "ap-northeast-2",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("3.5.140.0/22"),
+					Provider: "aws",
+					Service:  "EC2",
+					Region:   "ap-northeast-2",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("2600:1f14::/35"),
+					Provider: "aws",
+					Service:  "EC2",
+					Region:   "us-west-2",
+				},
+			},
+		},
+		{
+			"azure",
+			cloudranges.ProviderAzure,
+			azure,
+			[]cloudranges.Range{
+				{
+					Prefix:   netip.MustParsePrefix("13.68.128.0/17"),
+					Provider: "azure",
+					Service:  "AzureCloud",
+					Region:   "eastus",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("13.69.40.16/28"),
+					Provider: "azure",
+					Service:  "AzureStorage",
+					Region:   "westeurope",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("2603:1020:206::/48"),
+					Provider: "azure",
+					Service:  "AzureStorage",
+					Region:   "westeurope",
+				},
+			},
+		},
+		{
+			"gcp",
+			cloudranges.ProviderGCP,
+			gcp,
+			[]cloudranges.Range{
+				{
+					Prefix:   netip.MustParsePrefix("34.1.208.0/20"),
+					Provider: "gcp",
+					Service:  "Google Cloud",
+					Region:   "africa-south1",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("2600:1900:8000::/44"),
+					Provider: "gcp",
+					Service:  "Google Cloud",
+					Region:   "us-central1",
+				},
+			},
+		},
+		{
+			"oracle",
+			cloudranges.ProviderOracle,
+			oracle,
+			[]cloudranges.Range{
+				{
+					Prefix:   netip.MustParsePrefix("129.146.0.0/21"),
+					Provider: "oracle",
+					Service:  "OCI",
+					Region:   "us-phoenix-1",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("134.70.8.0/21"),
+					Provider: "oracle",
+					Service:  "OSN",
+					Region:   "us-phoenix-1",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("134.70.8.0/21"),
+					Provider: "oracle",
+					Service:  "OBJECT_STORAGE",
+					Region:   "us-phoenix-1",
+				},
+			},
+		},
+		{
+			"cloudflare json",
+			cloudranges.ProviderCloudflare,
+			cloudflareJSON,
+			[]cloudranges.Range{
+				{
+					Prefix:   netip.MustParsePrefix("173.245.48.0/20"),
+					Provider: "cloudflare",
+				},
+				{
+					Prefix:   netip.MustParsePrefix("2400:cb00::/32"),
+					Provider: "cloudflare",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := cloudranges.Parse(tt.provider, strings.NewReader(tt.data))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, r)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		data     string
+	}{
+		{"unknown provider", "digitalocean", cloudflareText},
+		{"not json", cloudranges.ProviderAWS, cloudflareText},
+		{"empty", cloudranges.ProviderGCP, `{"prefixes": []}`},
+		{"bad prefix", cloudranges.ProviderCloudflare, "1.2.3.4/40\n"},
+		{
+			"bad azure prefix",
+			cloudranges.ProviderAzure,
+			`{"values": [{"properties": {"addressPrefixes": ["x"]}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cloudranges.Parse(tt.provider, strings.NewReader(tt.data))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestIndex_Lookup(t *testing.T) {
+	var all []cloudranges.Range
+	for provider, data := range map[string]string{
+		cloudranges.ProviderAWS:        aws,
+		cloudranges.ProviderCloudflare: cloudflareText,
+	} {
+		r, err := cloudranges.Parse(provider, strings.NewReader(data))
+		require.NoError(t, err)
+		all = append(all, r...)
+	}
+	idx := cloudranges.NewIndex(all)
+	require.Equal(t, 6, idx.Len())
+
+	tests := []struct {
+		ip       string
+		services []string
+	}{
+		{"3.5.141.1", []string{"AMAZON", "EC2"}},
+		{"::ffff:3.5.141.1", []string{"AMAZON", "EC2"}},
+		{"2600:1f14::1", []string{"EC2"}},
+		{"103.21.245.1", []string{""}},
+		{"3.5.144.1", nil},
+		{"2600:1f15::1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			var services []string
+			for _, r := range idx.Lookup(netip.MustParseAddr(tt.ip)) {
+				services = append(services, r.Service)
+			}
+			require.ElementsMatch(t, tt.services, services)
+		})
+	}
+}
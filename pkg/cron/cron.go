@@ -0,0 +1,168 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFieldCount = errors.New("cron expression must have 5 fields")
+	ErrMalformed  = errors.New("malformed cron field")
+	ErrOutOfRange = errors.New("cron value is out of range")
+)
+
+const fieldCount = 5
+
+type field struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+//nolint:gomnd // cron field bounds
+func getFields() [fieldCount]field {
+	return [fieldCount]field{
+		{min: 0, max: 59},
+		{min: 0, max: 23},
+		{min: 1, max: 31},
+		{min: 1, max: 12, names: map[string]int{
+			"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+			"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+		}},
+		// 7 is Sunday too
+		{min: 0, max: 7, names: map[string]int{
+			"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5,
+			"sat": 6,
+		}},
+	}
+}
+
+// Schedule is a parsed standard 5-field cron expression:
+// minute, hour, day of month, month and day of week. Fields support
+// "*", lists, ranges, steps and (for month and weekday) names.
+type Schedule struct {
+	expr string
+	// bit sets of allowed values.
+	minute, hour, dom, month, dow uint64
+	// day matches if any of restricted dom and dow matches, like in cron.
+	domStar, dowStar bool
+}
+
+func Parse(expr string) (*Schedule, error) {
+	tokens := strings.Fields(expr)
+	if len(tokens) != fieldCount {
+		return nil, ErrFieldCount
+	}
+
+	var (
+		sets   [fieldCount]uint64
+		err    error
+		fields = getFields()
+	)
+	for i, t := range tokens {
+		if sets[i], err = parseField(t, fields[i]); err != nil {
+			return nil, fmt.Errorf("can't parse \"%s\": %w", t, err)
+		}
+	}
+
+	s := &Schedule{
+		expr:    expr,
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(tokens[2], "*"),
+		dowStar: strings.HasPrefix(tokens[4], "*"),
+	}
+	// Sunday is 0
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, ErrMalformed
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(loStr, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, ErrMalformed
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rng, f); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// "5/15" means from 5 to max with step 15
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	if v < f.min || v > f.max {
+		return 0, ErrOutOfRange
+	}
+	return v, nil
+}
+
+// Match returns true if minute of t matches schedule.
+func (s *Schedule) Match(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 ||
+		s.hour&(1<<t.Hour()) == 0 ||
+		s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
@@ -0,0 +1,79 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/pkg/cron"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr error
+	}{
+		{"every minute", "* * * * *", nil},
+		{"lists ranges steps", "0,30 9-17/2 1-15 */3 1-5", nil},
+		{"names", "0 9 * jan-mar MON-fri", nil},
+		{"sunday as 7", "0 0 * * 7", nil},
+		{"too few fields", "* * * *", cron.ErrFieldCount},
+		{"too many fields", "* * * * * *", cron.ErrFieldCount},
+		{"minute out of range", "60 * * * *", cron.ErrOutOfRange},
+		{"day out of range", "* * 0 * *", cron.ErrOutOfRange},
+		{"bad step", "*/0 * * * *", cron.ErrMalformed},
+		{"reversed range", "* 17-9 * * *", cron.ErrMalformed},
+		{"bad name", "* * * * someday", cron.ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cron.Parse(tt.expr)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSchedule_Match(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		time string
+		want bool
+	}{
+		// 2024-01-01 is Monday
+		{"every minute", "* * * * *", "2024-01-01 03:17", true},
+		{"working hours", "* 9-17 * * 1-5", "2024-01-01 09:00", true},
+		{"working hours late", "* 9-17 * * 1-5", "2024-01-01 18:00", false},
+		{"weekend", "* 9-17 * * mon-fri", "2024-01-06 10:00", false},
+		{"step", "*/15 * * * *", "2024-01-01 10:45", true},
+		{"step miss", "*/15 * * * *", "2024-01-01 10:46", false},
+		{"value with step", "5/20 * * * *", "2024-01-01 10:45", true},
+		{"list", "0,30 * * * *", "2024-01-01 10:30", true},
+		{"month name", "* * * feb *", "2024-01-01 10:30", false},
+		{"sunday as 7", "* * * * 7", "2024-01-07 10:30", true},
+		{"sunday as 0", "* * * * 0", "2024-01-07 10:30", true},
+		// both day fields restricted: either matches
+		{"dom or dow by dom", "* * 15 * 5", "2024-01-15 10:00", true},
+		{"dom or dow by dow", "* * 15 * 5", "2024-01-05 10:00", true},
+		{"dom or dow none", "* * 15 * 5", "2024-01-04 10:00", false},
+		// one day field is star: both must match
+		{"dom and star dow", "* * 15 * *", "2024-01-04 10:00", false},
+		{"star dom and dow", "* * * * 5", "2024-01-05 10:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := cron.Parse(tt.expr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, s.Match(date(tt.time)))
+		})
+	}
+}
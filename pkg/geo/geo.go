@@ -0,0 +1,204 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+var (
+	ErrMalformed = errors.New("malformed geojson")
+)
+
+// mean Earth radius.
+const earthRadiusKm = 6371.0
+
+type UnsupportedGeometryError struct {
+	geometry string
+}
+
+func (e UnsupportedGeometryError) Error() string {
+	return fmt.Sprintf(
+		"unsupported geometry, only polygons are supported: %s",
+		e.geometry,
+	)
+}
+
+// Point is a WGS 84 coordinate in degrees.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%.4f,%.4f", p.Latitude, p.Longitude)
+}
+
+// Valid returns true if coordinates are within their ranges.
+func (p Point) Valid() bool {
+	return p.Latitude >= -90 && p.Latitude <= 90 && //nolint:gomnd // degrees
+		p.Longitude >= -180 && p.Longitude <= 180 //nolint:gomnd // degrees
+}
+
+// Distance returns great-circle distance between points in kilometers.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	// haversine formula
+	//nolint:gomnd // half angles
+	sinLat, sinLon := math.Sin(dLat/2), math.Sin(dLon/2)
+	h := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h)) //nolint:gomnd // 2r
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180 //nolint:gomnd // degrees in pi
+}
+
+// Polygon is an outer ring with optional holes. Edges are straight lines
+// in longitude/latitude plane, polygons crossing antimeridian must be
+// split.
+type Polygon struct {
+	Outer []Point
+	Holes [][]Point
+}
+
+// Contains returns true if p is inside polygon and outside of its holes.
+func (g Polygon) Contains(p Point) bool {
+	if !ringContains(g.Outer, p) {
+		return false
+	}
+	for _, h := range g.Holes {
+		if ringContains(h, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ringContains is a ray casting point-in-polygon test.
+func ringContains(ring []Point, p Point) bool {
+	var in bool
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*
+				(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+
+				a.Longitude {
+			in = !in
+		}
+	}
+	return in
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Features    []geoJSON       `json:"features"`
+}
+
+// ParseGeoJSON reads polygons from GeoJSON (RFC 7946) object: Polygon,
+// MultiPolygon or Feature, FeatureCollection and GeometryCollection of
+// them.
+func ParseGeoJSON(r io.Reader) ([]Polygon, error) {
+	var g geoJSON
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, fmt.Errorf("can't decode geojson: %w", err)
+	}
+	return g.polygons()
+}
+
+func (g *geoJSON) polygons() ([]Polygon, error) {
+	switch g.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return nil, ErrMalformed
+		}
+		p, err := newPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{p}, nil
+	case "MultiPolygon":
+		var polys [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+			return nil, ErrMalformed
+		}
+		res := make([]Polygon, 0, len(polys))
+		for _, rings := range polys {
+			p, err := newPolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p)
+		}
+		return res, nil
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, ErrMalformed
+		}
+		return g.Geometry.polygons()
+	case "FeatureCollection":
+		return collect(g.Features)
+	case "GeometryCollection":
+		return collect(g.Geometries)
+	default:
+		return nil, &UnsupportedGeometryError{geometry: g.Type}
+	}
+}
+
+func collect(objects []geoJSON) ([]Polygon, error) {
+	var res []Polygon
+	for i := range objects {
+		p, err := objects[i].polygons()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p...)
+	}
+	return res, nil
+}
+
+func newPolygon(rings [][][]float64) (Polygon, error) {
+	if len(rings) == 0 {
+		return Polygon{}, ErrMalformed
+	}
+	var (
+		p   Polygon
+		err error
+	)
+	if p.Outer, err = newRing(rings[0]); err != nil {
+		return Polygon{}, err
+	}
+	for _, r := range rings[1:] {
+		var h []Point
+		if h, err = newRing(r); err != nil {
+			return Polygon{}, err
+		}
+		p.Holes = append(p.Holes, h)
+	}
+	return p, nil
+}
+
+// newRing converts [longitude, latitude] positions to points.
+func newRing(positions [][]float64) ([]Point, error) {
+	// closed ring of triangle has 4 positions
+	if len(positions) < 4 { //nolint:gomnd // min ring size
+		return nil, ErrMalformed
+	}
+	ring := make([]Point, 0, len(positions))
+	for _, pos := range positions {
+		if len(pos) < 2 { //nolint:gomnd // longitude and latitude
+			return nil, ErrMalformed
+		}
+		ring = append(ring, Point{Latitude: pos[1], Longitude: pos[0]})
+	}
+	return ring, nil
+}
@@ -0,0 +1,130 @@
+package geo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/geo"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	berlin = geo.Point{Latitude: 52.52, Longitude: 13.405}
+	paris  = geo.Point{Latitude: 48.8566, Longitude: 2.3522}
+	sydney = geo.Point{Latitude: -33.8688, Longitude: 151.2093}
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b geo.Point
+		want float64
+	}{
+		{"same point", berlin, berlin, 0},
+		{"berlin paris", berlin, paris, 878},
+		{"paris berlin", paris, berlin, 878},
+		{"berlin sydney", berlin, sydney, 16090},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 0.5% error of spherical model
+			require.InDelta(t, tt.want, geo.Distance(tt.a, tt.b), tt.want/200)
+		})
+	}
+}
+
+// square around Berlin with a hole around the center.
+const square = `{
+	"type": "Feature",
+	"properties": {"name": "berlin"},
+	"geometry": {
+		"type": "Polygon",
+		"coordinates": [
+			[[13, 52], [14, 52], [14, 53], [13, 53], [13, 52]],
+			[[13.3, 52.4], [13.5, 52.4], [13.5, 52.6], [13.3, 52.6],
+			 [13.3, 52.4]]
+		]
+	}
+}`
+
+const collection = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"geometry": {
+				"type": "MultiPolygon",
+				"coordinates": [
+					[[[2, 48], [3, 48], [3, 49], [2, 49], [2, 48]]],
+					[[[151, -34], [152, -34], [152, -33], [151, -33],
+					  [151, -34]]]
+				]
+			}
+		}
+	]
+}`
+
+func TestParseGeoJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		count   int
+		wantErr bool
+	}{
+		{"feature with hole", square, 1, false},
+		{"collection of multipolygon", collection, 2, false},
+		{"point", `{"type": "Point", "coordinates": [1, 2]}`, 0, true},
+		{"short ring", `{"type": "Polygon",
+			"coordinates": [[[1, 2], [2, 3], [1, 2]]]}`, 0, true},
+		{"feature without geometry", `{"type": "Feature"}`, 0, true},
+		{"not json", `type: Polygon`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := geo.ParseGeoJSON(strings.NewReader(tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, p, tt.count)
+		})
+	}
+}
+
+func TestPolygon_Contains(t *testing.T) {
+	sq, err := geo.ParseGeoJSON(strings.NewReader(square))
+	require.NoError(t, err)
+	coll, err := geo.ParseGeoJSON(strings.NewReader(collection))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		polygons []geo.Polygon
+		p        geo.Point
+		want     bool
+	}{
+		{"inside", sq, geo.Point{Latitude: 52.2, Longitude: 13.8}, true},
+		{"in hole", sq, berlin, false},
+		{"outside", sq, paris, false},
+		{"first of multi", coll, paris, true},
+		{"second of multi", coll, sydney, true},
+		{"none of multi", coll, berlin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in bool
+			for _, p := range tt.polygons {
+				in = in || p.Contains(tt.p)
+			}
+			require.Equal(t, tt.want, in)
+		})
+	}
+}
+
+func TestPoint_Valid(t *testing.T) {
+	require.True(t, berlin.Valid())
+	require.True(t, geo.Point{Latitude: -90, Longitude: 180}.Valid())
+	require.False(t, geo.Point{Latitude: 91, Longitude: 0}.Valid())
+	require.False(t, geo.Point{Latitude: 0, Longitude: -181}.Valid())
+}
@@ -0,0 +1,243 @@
+package hassh
+
+import (
+	"bytes"
+	"crypto/md5" //nolint: gosec // HASSH uses md5
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrIncomplete = errors.New("incomplete ssh handshake")
+	ErrNotSSH     = errors.New("not a ssh identification string")
+	ErrNotKexInit = errors.New("not a kexinit message")
+	ErrMalformed  = errors.New("malformed ssh handshake")
+)
+
+const (
+	// max identification string length including CR LF (RFC 4253).
+	maxIdentLength = 255
+	// max packet length implementations must support (RFC 4253).
+	maxPacketLength = 35000
+
+	identPrefix    = "SSH-"
+	msgKexInit     = 20
+	cookieLength   = 16
+	packetLenSize  = 4
+	kexInitLists   = 10
+	minPaddingSize = 4
+)
+
+// Ident is a parsed SSH identification string.
+type Ident struct {
+	// Raw is the identification string without CR LF.
+	Raw          string
+	ProtoVersion string
+	Software     string
+	Comments     string
+}
+
+// KexInit contains algorithm lists of SSH_MSG_KEXINIT message.
+type KexInit struct {
+	KexAlgorithms           []string
+	ServerHostKeyAlgorithms []string
+	CiphersClientServer     []string
+	CiphersServerClient     []string
+	MACsClientServer        []string
+	MACsServerClient        []string
+	CompressionClientServer []string
+	CompressionServerClient []string
+	LanguagesClientServer   []string
+	LanguagesServerClient   []string
+	FirstKexFollows         bool
+}
+
+// Handshake is a cleartext beginning of SSH client stream.
+type Handshake struct {
+	Ident   *Ident
+	KexInit *KexInit
+	// Raw contains identification string and KEXINIT packet.
+	Raw []byte
+}
+
+// ParseIdent parses identification string without CR LF.
+func ParseIdent(line string) (*Ident, error) {
+	if !strings.HasPrefix(line, identPrefix) {
+		return nil, ErrNotSSH
+	}
+	id := &Ident{Raw: line}
+
+	rest := line[len(identPrefix):]
+	rest, id.Comments, _ = strings.Cut(rest, " ")
+	var ok bool
+	id.ProtoVersion, id.Software, ok = strings.Cut(rest, "-")
+	if !ok || id.ProtoVersion == "" {
+		return nil, ErrMalformed
+	}
+	return id, nil
+}
+
+// Parse parses client identification string followed by KEXINIT packet.
+// Returns ErrIncomplete if data does not contain the whole KEXINIT yet.
+func Parse(data []byte) (*Handshake, error) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		if len(data) >= maxIdentLength {
+			return nil, ErrMalformed
+		}
+		if !bytes.HasPrefix(data, []byte(identPrefix)) &&
+			!bytes.HasPrefix([]byte(identPrefix), data) {
+			return nil, ErrNotSSH
+		}
+		return nil, ErrIncomplete
+	}
+
+	ident, err := ParseIdent(strings.TrimRight(string(data[:i]), "\r"))
+	if err != nil {
+		return nil, err
+	}
+
+	payload, n, err := readPacket(data[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	kex, err := ParseKexInit(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handshake{
+		Ident:   ident,
+		KexInit: kex,
+		Raw:     data[:i+1+n],
+	}, nil
+}
+
+// read unencrypted binary packet, returns payload and count of used bytes.
+func readPacket(data []byte) ([]byte, int, error) {
+	if len(data) < packetLenSize {
+		return nil, 0, ErrIncomplete
+	}
+	l := int(binary.BigEndian.Uint32(data))
+	if l > maxPacketLength || l < minPaddingSize+1 {
+		return nil, 0, ErrMalformed
+	}
+	if len(data)-packetLenSize < l {
+		return nil, 0, ErrIncomplete
+	}
+
+	packet := data[packetLenSize : packetLenSize+l]
+	padding := int(packet[0])
+	if padding+1 > l {
+		return nil, 0, ErrMalformed
+	}
+	return packet[1 : l-padding], packetLenSize + l, nil
+}
+
+// ParseKexInit parses SSH_MSG_KEXINIT payload.
+func ParseKexInit(payload []byte) (*KexInit, error) {
+	if len(payload) == 0 || payload[0] != msgKexInit {
+		return nil, ErrNotKexInit
+	}
+	if len(payload) < 1+cookieLength {
+		return nil, ErrMalformed
+	}
+	data := payload[1+cookieLength:]
+
+	lists := make([][]string, kexInitLists)
+	for i := range lists {
+		if len(data) < packetLenSize {
+			return nil, ErrMalformed
+		}
+		l := int(binary.BigEndian.Uint32(data))
+		data = data[packetLenSize:]
+		if len(data) < l {
+			return nil, ErrMalformed
+		}
+		if l > 0 {
+			lists[i] = strings.Split(string(data[:l]), ",")
+		}
+		data = data[l:]
+	}
+	if len(data) < 1 {
+		return nil, ErrMalformed
+	}
+
+	return &KexInit{
+		KexAlgorithms:           lists[0],
+		ServerHostKeyAlgorithms: lists[1],
+		CiphersClientServer:     lists[2],
+		CiphersServerClient:     lists[3],
+		MACsClientServer:        lists[4],
+		MACsServerClient:        lists[5],
+		CompressionClientServer: lists[6],
+		CompressionServerClient: lists[7],
+		LanguagesClientServer:   lists[8],
+		LanguagesServerClient:   lists[9],
+		FirstKexFollows:         data[0] != 0,
+	}, nil
+}
+
+// Algorithms returns all algorithms offered in KEXINIT.
+func (k *KexInit) Algorithms() []string {
+	var all []string
+	for _, l := range [][]string{
+		k.KexAlgorithms,
+		k.ServerHostKeyAlgorithms,
+		k.CiphersClientServer,
+		k.CiphersServerClient,
+		k.MACsClientServer,
+		k.MACsServerClient,
+		k.CompressionClientServer,
+		k.CompressionServerClient,
+	} {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// HASSHAlgorithms returns client HASSH fingerprint string.
+func (k *KexInit) HASSHAlgorithms() string {
+	return strings.Join([]string{
+		strings.Join(k.KexAlgorithms, ","),
+		strings.Join(k.CiphersClientServer, ","),
+		strings.Join(k.MACsClientServer, ","),
+		strings.Join(k.CompressionClientServer, ","),
+	}, ";")
+}
+
+// HASSH returns md5 hex digest of client HASSH fingerprint string.
+func (k *KexInit) HASSH() string {
+	sum := md5.Sum([]byte(k.HASSHAlgorithms())) //nolint: gosec // HASSH
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadIdent reads identification string from server stream skipping
+// other lines sent before it. Data after CR LF is not read.
+func ReadIdent(r io.Reader) (string, error) {
+	var (
+		line []byte
+		b    = make([]byte, 1)
+	)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err //nolint: wrapcheck // reader error
+		}
+		if b[0] != '\n' {
+			if len(line) >= maxIdentLength {
+				return "", ErrMalformed
+			}
+			line = append(line, b[0])
+			continue
+		}
+
+		s := strings.TrimRight(string(line), "\r")
+		if strings.HasPrefix(s, identPrefix) {
+			return s, nil
+		}
+		line = line[:0]
+	}
+}
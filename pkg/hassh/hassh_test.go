@@ -0,0 +1,118 @@
+package hassh_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/hassh"
+	"github.com/stretchr/testify/require"
+)
+
+const ident = "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"
+
+func buildKexInit(lists [10]string) []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(20) //nolint: gomnd // SSH_MSG_KEXINIT
+	payload.Write(make([]byte, 16))
+	for _, l := range lists {
+		_ = binary.Write(&payload, binary.BigEndian, uint32(len(l)))
+		payload.WriteString(l)
+	}
+	payload.WriteByte(0)
+	payload.Write(make([]byte, 4))
+
+	padding := 8 - (payload.Len()+5)%8
+	if padding < 4 {
+		padding += 8
+	}
+
+	var packet bytes.Buffer
+	_ = binary.Write(
+		&packet,
+		binary.BigEndian,
+		uint32(payload.Len()+padding+1),
+	)
+	packet.WriteByte(byte(padding))
+	packet.Write(payload.Bytes())
+	packet.Write(make([]byte, padding))
+	return packet.Bytes()
+}
+
+func handshake() []byte {
+	kex := buildKexInit([10]string{
+		"curve25519-sha256,diffie-hellman-group14-sha256",
+		"ssh-ed25519",
+		"aes128-ctr,aes256-gcm@openssh.com",
+		"aes128-ctr",
+		"hmac-sha2-256",
+		"hmac-sha2-256",
+		"none,zlib@openssh.com",
+		"none",
+		"",
+		"",
+	})
+	return append([]byte(ident+"\r\n"), kex...)
+}
+
+func TestParse(t *testing.T) {
+	data := handshake()
+	h, err := hassh.Parse(append(data, []byte("next packet")...))
+	require.NoError(t, err)
+
+	require.Equal(t, ident, h.Ident.Raw)
+	require.Equal(t, "2.0", h.Ident.ProtoVersion)
+	require.Equal(t, "OpenSSH_8.9p1", h.Ident.Software)
+	require.Equal(t, "Ubuntu-3ubuntu0.6", h.Ident.Comments)
+	require.Equal(t, data, h.Raw)
+
+	require.Equal(
+		t,
+		[]string{"curve25519-sha256", "diffie-hellman-group14-sha256"},
+		h.KexInit.KexAlgorithms,
+	)
+	require.Nil(t, h.KexInit.LanguagesClientServer)
+	require.Contains(t, h.KexInit.Algorithms(), "ssh-ed25519")
+	require.Equal(t, "7b3f76e580e44aea1e396d8af55a8228", h.KexInit.HASSH())
+}
+
+func TestParseErrors(t *testing.T) {
+	data := handshake()
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, hassh.ErrIncomplete},
+		{"partial prefix", []byte("SS"), hassh.ErrIncomplete},
+		{"ident only", []byte(ident + "\r\n"), hassh.ErrIncomplete},
+		{"truncated kexinit", data[:len(data)-1], hassh.ErrIncomplete},
+		{"http", []byte("GET / HTTP/1.1\r\n"), hassh.ErrNotSSH},
+		{"no version", []byte("SSH-\r\n"), hassh.ErrMalformed},
+		{
+			"long ident",
+			[]byte("SSH-2.0-" + strings.Repeat("a", 300)),
+			hassh.ErrMalformed,
+		},
+		{
+			"not kexinit",
+			[]byte(ident + "\r\n\x00\x00\x00\x06\x04\x15\x00\x00\x00\x00"),
+			hassh.ErrNotKexInit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := hassh.Parse(tt.data)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestReadIdent(t *testing.T) {
+	r := strings.NewReader("hello\r\n" + ident + "\r\nrest")
+	s, err := hassh.ReadIdent(r)
+	require.NoError(t, err)
+	require.Equal(t, ident, s)
+	require.Equal(t, 4, r.Len())
+}
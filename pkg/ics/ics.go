@@ -0,0 +1,283 @@
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed calendar")
+	ErrNoStart   = errors.New("event has no DTSTART")
+)
+
+const (
+	dateLayout    = "20060102"
+	maxLineLength = 64 * 1024
+	day           = 24 * time.Hour
+)
+
+type UnsupportedRRuleError struct {
+	rrule string
+}
+
+func (e UnsupportedRRuleError) Error() string {
+	return fmt.Sprintf(
+		"unsupported RRULE, only yearly is supported: %s",
+		e.rrule,
+	)
+}
+
+type LineError struct {
+	line int
+	err  error
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.err)
+}
+
+func (e LineError) Unwrap() error {
+	return e.err
+}
+
+// Event is an all-day calendar event. Timed events are extended to
+// whole days. Dates are stored in UTC, but mean calendar dates in any
+// location.
+type Event struct {
+	Summary string
+	Start   time.Time
+	// End is exclusive.
+	End time.Time
+	// Yearly events repeat every year since Start, last occurrence
+	// starts not later than Until (if set).
+	Yearly bool
+	Until  time.Time
+}
+
+// Contains returns true if date of t (in t's location) is within event.
+func (e Event) Contains(t time.Time) bool {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if d.Before(e.Start) {
+		return false
+	}
+	if !e.Yearly {
+		return d.Before(e.End)
+	}
+
+	// previous year's occurrence may last until this year
+	length := e.End.Sub(e.Start)
+	for _, y := range []int{d.Year() - 1, d.Year()} {
+		start := e.Start.AddDate(y-e.Start.Year(), 0, 0)
+		if !e.Until.IsZero() && start.After(e.Until) {
+			continue
+		}
+		if !d.Before(start) && d.Before(start.Add(length)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads VEVENTs of iCalendar (RFC 5545) stream, other components
+// are skipped.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events []Event
+		cur    *event
+	)
+	for _, l := range lines {
+		name, value, ok := parseLine(l.text)
+		if !ok {
+			return nil, &LineError{line: l.num, err: ErrMalformed}
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &event{}
+		case cur == nil:
+			continue
+		case name == "END" && value == "VEVENT":
+			if cur.Start.IsZero() {
+				return nil, &LineError{line: l.num, err: ErrNoStart}
+			}
+			events = append(events, cur.resolve())
+			cur = nil
+		default:
+			if err = cur.set(name, value); err != nil {
+				return nil, &LineError{line: l.num, err: err}
+			}
+		}
+	}
+	return events, nil
+}
+
+// event being parsed. Properties may go in any order, so relative ones
+// are resolved after the whole event is read.
+type event struct {
+	Event
+
+	days  int
+	count int
+}
+
+func (e *event) set(name, value string) error {
+	var err error
+	switch name {
+	case "SUMMARY":
+		e.Summary = unescape(value)
+	case "DTSTART":
+		e.Start, _, err = parseDate(value)
+	case "DTEND":
+		var timed bool
+		e.End, timed, err = parseDate(value)
+		// timed end is within its date
+		if timed {
+			e.End = e.End.Add(day)
+		}
+	case "DURATION":
+		e.days, err = parseDuration(value)
+	case "RRULE":
+		err = e.setRRule(value)
+	}
+	return err
+}
+
+func (e *event) resolve() Event {
+	if e.End.IsZero() && e.days > 0 {
+		e.End = e.Start.AddDate(0, 0, e.days)
+	}
+	if !e.End.After(e.Start) {
+		e.End = e.Start.Add(day)
+	}
+	if e.count > 0 {
+		e.Until = e.Start.AddDate(e.count-1, 0, 0)
+	}
+	return e.Event
+}
+
+type line struct {
+	num  int
+	text string
+}
+
+// unfold joins folded lines (continuation lines start with whitespace).
+func unfold(r io.Reader) ([]line, error) {
+	var lines []line
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineLength)
+	for n := 1; s.Scan(); n++ {
+		t := strings.TrimRight(s.Text(), "\r")
+		if t == "" {
+			continue
+		}
+		if (t[0] == ' ' || t[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1].text += t[1:]
+			continue
+		}
+		lines = append(lines, line{num: n, text: t})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("can't read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine splits "NAME;PARAM=x:VALUE" into name and value.
+func parseLine(s string) (string, string, bool) {
+	head, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", "", false
+	}
+	name, _, _ := strings.Cut(head, ";")
+	return strings.ToUpper(name), value, true
+}
+
+// parseDate parses DATE or DATE-TIME value, time is truncated. Returns
+// true if value has non-midnight time.
+func parseDate(s string) (time.Time, bool, error) {
+	if len(s) < len(dateLayout) {
+		return time.Time{}, false, ErrMalformed
+	}
+	d, err := time.Parse(dateLayout, s[:len(dateLayout)])
+	if err != nil {
+		return time.Time{}, false, ErrMalformed
+	}
+	clock := strings.TrimSuffix(s[len(dateLayout):], "Z")
+	timed := clock != "" && clock != "T000000"
+	return d, timed, nil
+}
+
+// parseDuration parses day-precision durations like P1D or P2W.
+func parseDuration(s string) (int, error) {
+	if len(s) < 3 || s[0] != 'P' { //nolint:gomnd // P, number and unit
+		return 0, ErrMalformed
+	}
+	n, err := strconv.Atoi(s[1 : len(s)-1])
+	if err != nil || n < 0 {
+		return 0, ErrMalformed
+	}
+	switch s[len(s)-1] {
+	case 'D':
+		return n, nil
+	case 'W':
+		return n * 7, nil //nolint:gomnd // days in week
+	default:
+		return 0, ErrMalformed
+	}
+}
+
+func (e *event) setRRule(s string) error {
+	for _, part := range strings.Split(s, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			if strings.ToUpper(v) != "YEARLY" {
+				return &UnsupportedRRuleError{rrule: s}
+			}
+			e.Yearly = true
+		case "INTERVAL":
+			if v != "1" {
+				return &UnsupportedRRuleError{rrule: s}
+			}
+		case "UNTIL":
+			until, _, err := parseDate(v)
+			if err != nil {
+				return err
+			}
+			e.Until = until
+		case "COUNT":
+			var err error
+			if e.count, err = strconv.Atoi(v); err != nil || e.count <= 0 {
+				return ErrMalformed
+			}
+		// same month and day as DTSTART in holiday calendars
+		case "BYMONTH", "BYMONTHDAY", "WKST":
+		default:
+			return &UnsupportedRRuleError{rrule: s}
+		}
+	}
+	if !e.Yearly {
+		return &UnsupportedRRuleError{rrule: s}
+	}
+	return nil
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(
+		`\n`, " ",
+		`\N`, " ",
+		`\,`, ",",
+		`\;`, ";",
+		`\\`, `\`,
+	).Replace(s)
+}
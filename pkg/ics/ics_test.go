@@ -0,0 +1,144 @@
+package ics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/pkg/ics"
+	"github.com/stretchr/testify/require"
+)
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Berlin\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20240101\r\n" +
+	"DTEND;VALUE=DATE:20240102\r\n" +
+	"SUMMARY:New Year\\, observed\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"RRULE:FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=24\r\n" +
+	"DTSTART;VALUE=DATE:20201224\r\n" +
+	"DURATION:P3D\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240501T090000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20240501T170000\r\n" +
+	"SUMMARY:Labour\r\n" +
+	"  Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20221231\r\n" +
+	"DTEND;VALUE=DATE:20230102\r\n" +
+	"RRULE:FREQ=YEARLY;COUNT=2\r\n" +
+	"SUMMARY:New Year's Eve\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	events, err := ics.Parse(strings.NewReader(calendar))
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	require.Equal(t, "New Year, observed", events[0].Summary)
+	require.Equal(t, date("2024-01-01"), events[0].Start)
+	require.Equal(t, date("2024-01-02"), events[0].End)
+
+	require.True(t, events[1].Yearly)
+	require.Equal(t, date("2020-12-27"), events[1].End)
+
+	require.Equal(t, "Labour Day", events[2].Summary)
+	require.Equal(t, date("2024-05-02"), events[2].End)
+
+	require.Equal(t, date("2023-12-31"), events[3].Until)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{
+			"no start",
+			"BEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\n",
+			ics.ErrNoStart,
+		},
+		{
+			"malformed line",
+			"BEGIN:VEVENT\nDTSTART\nEND:VEVENT\n",
+			ics.ErrMalformed,
+		},
+		{
+			"malformed date",
+			"BEGIN:VEVENT\nDTSTART:2024-01-01\nEND:VEVENT\n",
+			ics.ErrMalformed,
+		},
+		{
+			"unsupported rrule",
+			"BEGIN:VEVENT\nDTSTART:20240101\nRRULE:FREQ=WEEKLY\nEND:VEVENT\n",
+			&ics.UnsupportedRRuleError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ics.Parse(strings.NewReader(tt.data))
+			var lineErr *ics.LineError
+			require.ErrorAs(t, err, &lineErr)
+			if target, ok := tt.wantErr.(*ics.UnsupportedRRuleError); ok {
+				require.ErrorAs(t, err, &target)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEvent_Contains(t *testing.T) {
+	events, err := ics.Parse(strings.NewReader(calendar))
+	require.NoError(t, err)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		event int
+		time  time.Time
+		want  bool
+	}{
+		{"single day", 0, date("2024-01-01"), true},
+		{"single day end excluded", 0, date("2024-01-02"), false},
+		{"single day before", 0, date("2023-12-31"), false},
+		{
+			"date in location",
+			0,
+			time.Date(2024, 1, 1, 23, 30, 0, 0, berlin),
+			true,
+		},
+		{"yearly", 1, date("2031-12-25"), true},
+		{"yearly outside", 1, date("2031-12-27"), false},
+		{"yearly before start", 1, date("2019-12-25"), false},
+		{"timed extended to day", 2, date("2024-05-01"), true},
+		{"yearly crossing year", 3, date("2023-01-01"), true},
+		{"yearly crossing year next", 3, date("2024-01-01"), true},
+		{"yearly after count", 3, date("2025-01-01"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, events[tt.event].Contains(tt.time))
+		})
+	}
+}
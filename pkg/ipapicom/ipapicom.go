@@ -23,16 +23,26 @@ const StandardURL = "http://ip-api.com"
 // Pro URL.
 const ProURL = "https://pro.ip-api.com"
 
+// Fields are default response fields with mobile, proxy and hosting
+// flags, which are not returned by default.
+const Fields = "status,message,country,countryCode,region,regionName," +
+	"city,zip,lat,lon,timezone,isp,org,as,query,mobile,proxy,hosting"
+
 func NewClient() Client {
 	return &client{
-		FmtURL:     fmt.Sprintf("%s/json/%%s", StandardURL),
+		FmtURL:     fmt.Sprintf("%s/json/%%s?fields=%s", StandardURL, Fields),
 		HTTPClient: &http.Client{},
 	}
 }
 
 func NewClientWithAPIKey(apiKey string) Client {
 	return &client{
-		FmtURL:     fmt.Sprintf("%s/json/%%s?key=%s", ProURL, apiKey),
+		FmtURL: fmt.Sprintf(
+			"%s/json/%%s?key=%s&fields=%s",
+			ProURL,
+			apiKey,
+			Fields,
+		),
 		HTTPClient: &http.Client{},
 	}
 }
@@ -43,11 +53,14 @@ type Location struct {
 	City        string  `json:"city"`
 	Country     string  `json:"country"`
 	CountryCode string  `json:"countryCode"`
+	Hosting     bool    `json:"hosting"`
 	Isp         string  `json:"isp"`
 	Lat         float32 `json:"lat"`
 	Lon         float32 `json:"lon"`
 	Message     string  `json:"message"`
+	Mobile      bool    `json:"mobile"`
 	Org         string  `json:"org"`
+	Proxy       bool    `json:"proxy"`
 	Query       string  `json:"query"`
 	Region      string  `json:"region"`
 	RegionName  string  `json:"regionName"`
@@ -31,6 +31,7 @@ func TestGetLocation(t *testing.T) {
 				Org:     "APNIC and Cloudflare DNS Resolver project",
 				Query:   "1.1.1.1",
 				Status:  "success",
+				Hosting: true,
 			},
 		},
 		{
@@ -46,6 +47,7 @@ func TestGetLocation(t *testing.T) {
 				Org:     "Cloudflare, Inc.",
 				Query:   "2606:4700:4700::1111",
 				Status:  "success",
+				Hosting: true,
 			},
 		},
 		{
@@ -108,6 +110,12 @@ func TestGetLocation(t *testing.T) {
 					l.Status,
 					"GetLocationForIP() ip Status mismatch",
 				)
+				require.Equal(
+					t,
+					tt.want.Hosting,
+					l.Hosting,
+					"GetLocationForIP() ip Hosting mismatch",
+				)
 			}
 		})
 	}
@@ -0,0 +1,110 @@
+package lists
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultDir is a directory for fetched lists.
+const DefaultDir = "lists"
+
+// header keys of stored list.
+const (
+	headerList    = "list"
+	headerSource  = "source"
+	headerUpdated = "updated"
+	headerSHA256  = "sha256"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid list name")
+	ErrEmpty       = errors.New("list is empty")
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+// List is a fetched list with its provenance.
+type List struct {
+	Name     string
+	Source   string
+	Updated  time.Time
+	SHA256   string
+	Prefixes []netip.Prefix
+}
+
+// Path returns path of list named name in dir.
+func Path(dir string, name string) (string, error) {
+	if !nameRegexp.MatchString(name) {
+		return "", fmt.Errorf("%w: \"%s\"", ErrInvalidName, name)
+	}
+	return filepath.Join(dir, name+".txt"), nil
+}
+
+// Load reads list stored by Updater.
+func Load(path string) (*List, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open list: %w", err)
+	}
+	defer file.Close()
+
+	l := &List{Name: strings.TrimSuffix(filepath.Base(path), ".txt")}
+	r := bufio.NewReader(file)
+	for {
+		line, perr := r.Peek(2) //nolint:gomnd // "# " prefix
+		if perr != nil || string(line) != "# " {
+			break
+		}
+		header, rerr := r.ReadString('\n')
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return nil, fmt.Errorf("can't read list: %w", rerr)
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(header[2:]), ": ")
+		switch key {
+		case headerList:
+			l.Name = value
+		case headerSource:
+			l.Source = value
+		case headerUpdated:
+			l.Updated, _ = time.Parse(time.RFC3339, value)
+		case headerSHA256:
+			l.SHA256 = value
+		}
+	}
+
+	if l.Prefixes, err = Parse(path, r, Options{}); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *List) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s: %s\n", headerList, l.Name)
+	fmt.Fprintf(bw, "# %s: %s\n", headerSource, l.Source)
+	fmt.Fprintf(bw, "# %s: %s\n", headerUpdated, l.Updated.Format(time.RFC3339))
+	fmt.Fprintf(bw, "# %s: %s\n", headerSHA256, l.SHA256)
+	for _, p := range l.Prefixes {
+		fmt.Fprintln(bw, p)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("can't write list: %w", err)
+	}
+	return nil
+}
+
+// comparePrefix orders subnets by address, then by length.
+func comparePrefix(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
@@ -0,0 +1,358 @@
+package lists_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/pkg/lists"
+	"github.com/stretchr/testify/require"
+)
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPath(t *testing.T) {
+	p, err := lists.Path("lists", "tor-exits_v2.1")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("lists", "tor-exits_v2.1.txt"), p)
+
+	for _, name := range []string{"", "../etc/passwd", ".hidden", "a/b"} {
+		_, err = lists.Path("lists", name)
+		require.ErrorIs(t, err, lists.ErrInvalidName, name)
+	}
+}
+
+// server is a stand-in of list hosting.
+type server struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func newServer(t *testing.T, files map[string]string) *server {
+	s := &server{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			data, ok := s.files[r.URL.Path]
+			s.mu.Unlock()
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(data))
+		},
+	))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// set replaces file, empty data removes it.
+func (s *server) set(path string, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if data == "" {
+		delete(s.files, path)
+	} else {
+		s.files[path] = data
+	}
+}
+
+func TestUpdater_Update(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	sig := ed25519.Sign(priv, []byte(tor))
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	srv := newServer(t, map[string]string{
+		"/tor.txt":        tor,
+		"/tor.txt.sha256": sha(tor) + "  tor.txt\n",
+		"/tor.txt.sig":    string(sig),
+		"/tor.txt.sig64":  base64.StdEncoding.EncodeToString(sig) + "\n",
+		"/bad.sha256":     sha("other") + "  tor.txt\n",
+		"/proxies.txt":    proxies,
+		"/empty.txt":      "# nothing\n",
+	})
+
+	tests := []struct {
+		name    string
+		src     lists.Source
+		want    []netip.Prefix
+		wantErr error
+	}{
+		{
+			"plain",
+			lists.Source{Name: "tor", URL: srv.URL + "/tor.txt"},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+			nil,
+		},
+		{
+			"extract",
+			lists.Source{
+				Name:    "proxies",
+				URL:     srv.URL + "/proxies.txt",
+				Options: lists.Options{Format: lists.FormatExtract},
+			},
+			prefixes("3.3.3.3/32", "4.4.4.0/24"),
+			nil,
+		},
+		{
+			"pinned sha256",
+			lists.Source{
+				Name:   "tor",
+				URL:    srv.URL + "/tor.txt",
+				SHA256: strings.ToUpper(sha(tor)),
+			},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+			nil,
+		},
+		{
+			"checksum url",
+			lists.Source{
+				Name:        "tor",
+				URL:         srv.URL + "/tor.txt",
+				ChecksumURL: srv.URL + "/tor.txt.sha256",
+			},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+			nil,
+		},
+		{
+			"raw signature",
+			lists.Source{
+				Name:         "tor",
+				URL:          srv.URL + "/tor.txt",
+				SignatureURL: srv.URL + "/tor.txt.sig",
+				PublicKey:    key,
+			},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+			nil,
+		},
+		{
+			"base64 signature",
+			lists.Source{
+				Name:         "tor",
+				URL:          srv.URL + "/tor.txt",
+				SignatureURL: srv.URL + "/tor.txt.sig64",
+				PublicKey:    key,
+			},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+			nil,
+		},
+		{
+			"pinned sha256 mismatch",
+			lists.Source{
+				Name:   "tor",
+				URL:    srv.URL + "/tor.txt",
+				SHA256: sha("other"),
+			},
+			nil,
+			lists.ErrChecksumMismatch,
+		},
+		{
+			"checksum url mismatch",
+			lists.Source{
+				Name:        "tor",
+				URL:         srv.URL + "/tor.txt",
+				ChecksumURL: srv.URL + "/bad.sha256",
+			},
+			nil,
+			lists.ErrChecksumMismatch,
+		},
+		{
+			"signature of other content",
+			lists.Source{
+				Name:         "proxies",
+				URL:          srv.URL + "/proxies.txt",
+				SignatureURL: srv.URL + "/tor.txt.sig",
+				PublicKey:    key,
+			},
+			nil,
+			lists.ErrBadSignature,
+		},
+		{
+			"empty",
+			lists.Source{Name: "empty", URL: srv.URL + "/empty.txt"},
+			nil,
+			lists.ErrEmpty,
+		},
+		{
+			"invalid name",
+			lists.Source{Name: "../tor", URL: srv.URL + "/tor.txt"},
+			nil,
+			lists.ErrInvalidName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			u := lists.NewUpdater(dir, srv.Client())
+
+			l, err := u.Update(context.Background(), tt.src)
+			require.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				entries, rerr := os.ReadDir(dir)
+				require.NoError(t, rerr)
+				require.Empty(t, entries, "nothing is stored on error")
+				return
+			}
+			require.Equal(t, tt.want, l.Prefixes)
+
+			path, err := u.Path(tt.src)
+			require.NoError(t, err)
+			stored, err := lists.Load(path)
+			require.NoError(t, err)
+			require.Equal(t, tt.src.Name, stored.Name)
+			require.Equal(t, tt.src.URL, stored.Source)
+			require.Equal(t, l.SHA256, stored.SHA256)
+			require.Equal(t, l.Updated.Truncate(time.Second), stored.Updated)
+			require.Equal(t, tt.want, stored.Prefixes)
+		})
+	}
+}
+
+func TestUpdater_UpdateKeepsList(t *testing.T) {
+	srv := newServer(t, map[string]string{"/tor.txt": tor})
+	dir := t.TempDir()
+	u := lists.NewUpdater(dir, srv.Client())
+	src := lists.Source{Name: "tor", URL: srv.URL + "/tor.txt"}
+
+	_, err := u.Update(context.Background(), src)
+	require.NoError(t, err)
+	age, ok := u.Age(src)
+	require.True(t, ok)
+	require.Less(t, age, time.Minute)
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"broken list", "1.1.1.1\nnot an ip\n"},
+		{"empty list", "\n"},
+		{"missing list", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.set("/tor.txt", tt.data)
+			_, err = u.Update(context.Background(), src)
+			require.Error(t, err)
+
+			path, perr := u.Path(src)
+			require.NoError(t, perr)
+			l, lerr := lists.Load(path)
+			require.NoError(t, lerr)
+			require.Len(t, l.Prefixes, 3)
+		})
+	}
+
+	var statusErr *lists.StatusError
+	require.ErrorAs(t, err, &statusErr)
+}
+
+func TestSource_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     lists.Source
+		wantErr bool
+	}{
+		{"valid", lists.Source{Name: "a", URL: "http://x/a"}, false},
+		{"no url", lists.Source{Name: "a"}, true},
+		{"bad name", lists.Source{Name: "a/b", URL: "http://x/a"}, true},
+		{
+			"bad format",
+			lists.Source{
+				Name:    "a",
+				URL:     "http://x/a",
+				Options: lists.Options{Format: "xml"},
+			},
+			true,
+		},
+		{
+			"bad sha256",
+			lists.Source{Name: "a", URL: "http://x/a", SHA256: "abc"},
+			true,
+		},
+		{
+			"signature without key",
+			lists.Source{
+				Name:         "a",
+				URL:          "http://x/a",
+				SignatureURL: "http://x/a.sig",
+			},
+			true,
+		},
+		{
+			"bad key",
+			lists.Source{
+				Name:         "a",
+				URL:          "http://x/a",
+				SignatureURL: "http://x/a.sig",
+				PublicKey:    "AAAA",
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.src.Validate()
+			require.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	srv := newServer(t, map[string]string{"/tor.txt": tor})
+	dir := t.TempDir()
+	u := lists.NewUpdater(dir, srv.Client())
+	sources := []lists.Source{
+		{
+			Name:    "tor",
+			URL:     srv.URL + "/tor.txt",
+			Refresh: 50 * time.Millisecond,
+		},
+		// not scheduled
+		{Name: "manual", URL: srv.URL + "/tor.txt"},
+	}
+
+	type report struct {
+		name string
+		err  error
+	}
+	updated := make(chan report, 10)
+	s := lists.NewScheduler(u, sources, func(
+		src lists.Source,
+		_ *lists.List,
+		err error,
+	) {
+		updated <- report{src.Name, err}
+	})
+	s.Start()
+
+	// missing list is fetched at once, then refreshed
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-updated:
+			require.NoError(t, r.err)
+			require.Equal(t, "tor", r.name)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "list wasn't updated")
+		}
+	}
+	s.Stop()
+
+	_, ok := u.Age(sources[1])
+	require.False(t, ok)
+}
@@ -0,0 +1,548 @@
+package lists
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// FormatText is one entry per line with "#" comments.
+	FormatText = "text"
+	// FormatCSV takes entries from Column of CSV records.
+	FormatCSV = "csv"
+	// FormatJSON takes entries selected by Selector from JSON document.
+	FormatJSON = "json"
+	// FormatDROP is Spamhaus DROP like list: entry is the first field of
+	// line with ";" comments.
+	FormatDROP = "drop"
+	// FormatRange is one "start-end" IP range (or IP address/subnet) per
+	// line with "#" comments.
+	FormatRange = "range"
+	// FormatExtract takes every IPv4 address or subnet found in text, e.g.
+	// in proxy lists ("1.2.3.4:1080") or .htaccess files.
+	FormatExtract = "extract"
+)
+
+// defaultSelector selects items of top level JSON array.
+const defaultSelector = "[*]"
+
+var (
+	ErrInvalidSelector = errors.New("invalid json selector")
+	ErrInvalidColumn   = errors.New("invalid csv column")
+	ErrInvalidRange    = errors.New("invalid ip range")
+)
+
+var extractRegexp = regexp.MustCompile(
+	`(?:^|[^0-9.])((?:[0-9]{1,3}\.){3}[0-9]{1,3}(?:/[0-9]{1,2})?)`,
+)
+
+type UnknownFormatError struct {
+	format string
+}
+
+func (e UnknownFormatError) Error() string {
+	return fmt.Sprintf("unknown list format: %s", e.format)
+}
+
+// PositionError is an error of list entry, line is 0 if unknown.
+type PositionError struct {
+	source string
+	line   int
+	err    error
+}
+
+func (e PositionError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("%s: %s", e.source, e.err)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.source, e.line, e.err)
+}
+
+func (e PositionError) Unwrap() error {
+	return e.err
+}
+
+// Options configure list format.
+type Options struct {
+	Format string
+	// Column is CSV column: 1-based number or name from header record.
+	// Default is the first column.
+	Column string
+	// Delimiter is CSV field delimiter, default is ",".
+	Delimiter string
+	// Selector is JSONPath-like path of entries, e.g. "$.items[*].cidr"
+	// or "result.ipv4_cidrs". Selected array means its items. Default
+	// is "[*]" (top level array).
+	Selector string
+}
+
+// Validate checks options without reading list.
+func (o Options) Validate() error {
+	switch o.Format {
+	case "", FormatText, FormatDROP, FormatRange, FormatExtract:
+	case FormatCSV:
+		if utf8.RuneCountInString(o.Delimiter) > 1 {
+			return fmt.Errorf(
+				"%w: delimiter must be one character",
+				ErrInvalidColumn,
+			)
+		}
+	case FormatJSON:
+		if _, err := parseSelector(o.Selector); err != nil {
+			return err
+		}
+	default:
+		return &UnknownFormatError{format: o.Format}
+	}
+	return nil
+}
+
+// Entry is a list value with its position.
+type Entry struct {
+	Value  string
+	Source string
+	Line   int
+}
+
+// Wrap adds entry position to err.
+func (e Entry) Wrap(err error) error {
+	return &PositionError{source: e.Source, line: e.Line, err: err}
+}
+
+// ReadFile reads list entries from file.
+func ReadFile(path string, opts Options) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open list: %w", err)
+	}
+	defer file.Close()
+	return Read(path, file, opts)
+}
+
+// Read reads list entries of format from r, source is used in errors.
+func Read(source string, r io.Reader, opts Options) ([]Entry, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, &PositionError{source: source, err: err}
+	}
+
+	switch opts.Format {
+	case FormatCSV:
+		return readCSV(source, r, opts)
+	case FormatJSON:
+		return readJSON(source, r, opts)
+	case FormatDROP:
+		return readLines(source, r, dropLine)
+	case FormatRange:
+		return readLines(source, r, rangeLine)
+	case FormatExtract:
+		return readLines(source, r, extractLine)
+	default:
+		return readLines(source, r, textLine)
+	}
+}
+
+func readLines(
+	source string,
+	r io.Reader,
+	parse func(line string) ([]string, error),
+) ([]Entry, error) {
+	var (
+		l []Entry
+		n int
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		n++
+		values, err := parse(s.Text())
+		if err != nil {
+			return nil, &PositionError{source: source, line: n, err: err}
+		}
+		for _, v := range values {
+			l = append(l, Entry{Value: v, Source: source, Line: n})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, &PositionError{
+			source: source,
+			line:   n + 1,
+			err:    fmt.Errorf("can't read list: %w", err),
+		}
+	}
+	return l, nil
+}
+
+func textLine(line string) ([]string, error) {
+	line, _, _ = strings.Cut(line, "#") // remove comment
+	if line = strings.TrimSpace(line); line == "" {
+		return nil, nil
+	}
+	return []string{line}, nil
+}
+
+// dropLine parses "1.10.16.0/20 ; SBL256894".
+func dropLine(line string) ([]string, error) {
+	line, _, _ = strings.Cut(line, ";") // remove comment
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields[:1], nil
+}
+
+// rangeLine normalizes "start - end" to "start-end".
+func rangeLine(line string) ([]string, error) {
+	line, _, _ = strings.Cut(line, "#") // remove comment
+	if line = strings.TrimSpace(line); line == "" {
+		return nil, nil
+	}
+	from, to, ok := strings.Cut(line, "-")
+	if !ok {
+		return []string{line}, nil
+	}
+	if _, _, err := parseRange(from, to); err != nil {
+		return nil, err
+	}
+	return []string{strings.TrimSpace(from) + "-" + strings.TrimSpace(to)}, nil
+}
+
+// extractLine skips invalid candidates (e.g. "999.1.1.1"), like grep does.
+func extractLine(line string) ([]string, error) {
+	line, _, _ = strings.Cut(line, "#") // remove comment
+	var l []string
+	for _, m := range extractRegexp.FindAllStringSubmatch(line, -1) {
+		if _, err := ParsePrefix(m[1]); err == nil {
+			l = append(l, m[1])
+		}
+	}
+	return l, nil
+}
+
+func readCSV(source string, r io.Reader, opts Options) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	if opts.Delimiter != "" {
+		cr.Comma, _ = utf8.DecodeRuneInString(opts.Delimiter)
+	}
+
+	column := 0
+	header := false
+	if opts.Column != "" {
+		n, err := strconv.Atoi(opts.Column)
+		switch {
+		case err != nil:
+			header = true
+		case n < 1:
+			return nil, &PositionError{source: source, err: ErrInvalidColumn}
+		default:
+			column = n - 1
+		}
+	}
+
+	var l []Entry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			line := 0
+			if errors.As(err, &perr) {
+				line = perr.Line
+			}
+			return nil, &PositionError{
+				source: source,
+				line:   line,
+				err:    fmt.Errorf("can't read csv: %w", err),
+			}
+		}
+
+		if header {
+			header = false
+			i := slices.IndexFunc(record, func(name string) bool {
+				return strings.EqualFold(strings.TrimSpace(name), opts.Column)
+			})
+			if i < 0 {
+				line, _ := cr.FieldPos(0)
+				return nil, &PositionError{
+					source: source,
+					line:   line,
+					err: fmt.Errorf(
+						"%w: no \"%s\" in header",
+						ErrInvalidColumn,
+						opts.Column,
+					),
+				}
+			}
+			column = i
+			continue
+		}
+
+		// short records (e.g. trailers) have no value
+		if column >= len(record) {
+			continue
+		}
+		if v := strings.TrimSpace(record[column]); v != "" {
+			line, _ := cr.FieldPos(column)
+			l = append(l, Entry{Value: v, Source: source, Line: line})
+		}
+	}
+	return l, nil
+}
+
+// selectorPart is a key, an index or a wildcard of them.
+type selectorPart struct {
+	key      string
+	index    int
+	isKey    bool
+	wildcard bool
+}
+
+func (p selectorPart) match(e any) bool {
+	switch v := e.(type) {
+	case string:
+		return p.isKey && (p.wildcard || p.key == v)
+	case int:
+		return !p.isKey && (p.wildcard || p.index == v)
+	default:
+		return false
+	}
+}
+
+var selectorRegexp = regexp.MustCompile(
+	`^(?:\.?([^.\[\]]+)|\[(\*|[0-9]*)\])`,
+)
+
+// parseSelector parses "$.a.b[*].c[0]", "$" and "." are optional, "*" is
+// any key, "[*]" and "[]" are any index.
+func parseSelector(s string) ([]selectorPart, error) {
+	if s == "" {
+		s = defaultSelector
+	}
+	s = strings.TrimPrefix(s, "$")
+
+	var parts []selectorPart
+	for s != "" {
+		m := selectorRegexp.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("%w: \"%s\"", ErrInvalidSelector, s)
+		}
+		s = s[len(m[0]):]
+		switch {
+		case m[1] != "":
+			parts = append(parts, selectorPart{
+				key:      m[1],
+				isKey:    true,
+				wildcard: m[1] == "*",
+			})
+		case m[2] == "*" || m[2] == "":
+			parts = append(parts, selectorPart{wildcard: true})
+		default:
+			i, _ := strconv.Atoi(m[2])
+			parts = append(parts, selectorPart{index: i})
+		}
+	}
+	return parts, nil
+}
+
+// jsonReader walks JSON tokens keeping path of current value.
+type jsonReader struct {
+	source     string
+	dec        *json.Decoder
+	selector   []selectorPart
+	path       []any
+	lineStarts []int
+	entries    []Entry
+}
+
+func readJSON(source string, r io.Reader, opts Options) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &PositionError{
+			source: source,
+			err:    fmt.Errorf("can't read list: %w", err),
+		}
+	}
+	selector, _ := parseSelector(opts.Selector)
+
+	jr := &jsonReader{
+		source:     source,
+		dec:        json.NewDecoder(bytes.NewReader(data)),
+		selector:   selector,
+		lineStarts: []int{0},
+	}
+	jr.dec.UseNumber()
+	for i, c := range data {
+		if c == '\n' {
+			jr.lineStarts = append(jr.lineStarts, i+1)
+		}
+	}
+
+	if err = jr.value(false); err != nil {
+		return nil, err
+	}
+	return jr.entries, nil
+}
+
+// line returns line of input offset.
+func (jr *jsonReader) line(offset int64) int {
+	return sort.Search(len(jr.lineStarts), func(i int) bool {
+		return jr.lineStarts[i] >= int(offset)
+	})
+}
+
+func (jr *jsonReader) wrap(err error) error {
+	offset := jr.dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	return &PositionError{
+		source: jr.source,
+		line:   jr.line(offset),
+		err:    fmt.Errorf("can't decode json: %w", err),
+	}
+}
+
+func (jr *jsonReader) selected() bool {
+	if len(jr.path) != len(jr.selector) {
+		return false
+	}
+	for i, p := range jr.selector {
+		if !p.match(jr.path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// value reads value at current path. Items of selected array are selected
+// too.
+func (jr *jsonReader) value(parentSelected bool) error {
+	t, err := jr.dec.Token()
+	if err != nil {
+		return jr.wrap(err)
+	}
+	selected := jr.selected()
+
+	switch v := t.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			for jr.dec.More() {
+				k, kerr := jr.dec.Token()
+				if kerr != nil {
+					return jr.wrap(kerr)
+				}
+				jr.path = append(jr.path, k)
+				if err = jr.value(false); err != nil {
+					return err
+				}
+				jr.path = jr.path[:len(jr.path)-1]
+			}
+		case '[':
+			for i := 0; jr.dec.More(); i++ {
+				jr.path = append(jr.path, i)
+				if err = jr.value(selected); err != nil {
+					return err
+				}
+				jr.path = jr.path[:len(jr.path)-1]
+			}
+		}
+		// closing delimiter
+		if _, err = jr.dec.Token(); err != nil {
+			return jr.wrap(err)
+		}
+	case string, json.Number:
+		if selected || parentSelected {
+			jr.entries = append(jr.entries, Entry{
+				Value:  fmt.Sprint(v),
+				Source: jr.source,
+				Line:   jr.line(jr.dec.InputOffset()),
+			})
+		}
+	}
+	return nil
+}
+
+// ParsePrefixes parses IP addresses, subnets and "start-end" ranges of
+// entries.
+func ParsePrefixes(entries []Entry) ([]netip.Prefix, error) {
+	var l []netip.Prefix
+	for _, e := range entries {
+		if from, to, ok := strings.Cut(e.Value, "-"); ok {
+			start, end, err := parseRange(from, to)
+			if err != nil {
+				return nil, e.Wrap(err)
+			}
+			l = append(l, RangePrefixes(start, end)...)
+			continue
+		}
+		p, err := ParsePrefix(e.Value)
+		if err != nil {
+			return nil, e.Wrap(err)
+		}
+		l = append(l, p)
+	}
+	return l, nil
+}
+
+// Parse reads IP addresses, subnets and ranges of list.
+func Parse(source string, r io.Reader, opts Options) ([]netip.Prefix, error) {
+	entries, err := Read(source, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrefixes(entries)
+}
+
+// ParsePrefix parses subnet or IP address as single address subnet.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if ip, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(ip, ip.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("can't parse ip/subnet: %w", err)
+	}
+	return p.Masked(), nil
+}
+
+func parseRange(from, to string) (netip.Addr, netip.Addr, error) {
+	invalid := func() (netip.Addr, netip.Addr, error) {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf(
+			"%w: \"%s-%s\"",
+			ErrInvalidRange,
+			strings.TrimSpace(from),
+			strings.TrimSpace(to),
+		)
+	}
+	start, err := netip.ParseAddr(strings.TrimSpace(from))
+	if err != nil {
+		return invalid()
+	}
+	end, err := netip.ParseAddr(strings.TrimSpace(to))
+	if err != nil {
+		return invalid()
+	}
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return invalid()
+	}
+	return start, end, nil
+}
@@ -0,0 +1,300 @@
+package lists_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/lists"
+	"github.com/stretchr/testify/require"
+)
+
+const tor = "# tor exits\n1.1.1.1\n2.2.2.0/24 # subnet\n\n2001:db8::1\n"
+
+const proxies = "# socks5\n3.3.3.3:1080\n999.1.1.1:80\n" +
+	"RewriteCond 4.4.4.0/24\n"
+
+const drop = "; Spamhaus DROP List 2024/01/01\n" +
+	"1.10.16.0/20 ; SBL256894\n" +
+	"\n" +
+	"1.19.0.0/16 ; SBL434604\n"
+
+const ranges = "# ranges\n" +
+	"10.0.0.0-10.0.0.255\n" +
+	"10.1.0.1 - 10.1.0.6 # unaligned\n" +
+	"2001:db8::-2001:db8::1\n" +
+	"192.168.0.1\n"
+
+const csvList = "ip,comment,score\n" +
+	"# comment\n" +
+	"5.5.5.5, scanner, 10\n" +
+	"\"6.6.6.0/24\",\"hosting, bad\",3\n" +
+	"\n"
+
+const jsonList = `{
+  "result": {
+    "ipv4_cidrs": ["173.245.48.0/20", "103.21.244.0/22"],
+    "ipv6_cidrs": ["2400:cb00::/32"]
+  },
+  "items": [
+    {"cidr": "7.7.7.0/24", "tags": ["a"]},
+    {"cidr": "8.8.8.8", "tags": ["b"]}
+  ]
+}`
+
+func prefixes(s ...string) []netip.Prefix {
+	l := make([]netip.Prefix, 0, len(s))
+	for _, p := range s {
+		l = append(l, netip.MustParsePrefix(p))
+	}
+	return l
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts lists.Options
+		want []netip.Prefix
+	}{
+		{
+			"text",
+			tor,
+			lists.Options{},
+			prefixes("1.1.1.1/32", "2.2.2.0/24", "2001:db8::1/128"),
+		},
+		{
+			"extract",
+			proxies,
+			lists.Options{Format: lists.FormatExtract},
+			prefixes("3.3.3.3/32", "4.4.4.0/24"),
+		},
+		{
+			"drop",
+			drop,
+			lists.Options{Format: lists.FormatDROP},
+			prefixes("1.10.16.0/20", "1.19.0.0/16"),
+		},
+		{
+			"range",
+			ranges,
+			lists.Options{Format: lists.FormatRange},
+			prefixes(
+				"10.0.0.0/24",
+				"10.1.0.1/32",
+				"10.1.0.2/31",
+				"10.1.0.4/31",
+				"10.1.0.6/32",
+				"2001:db8::/127",
+				"192.168.0.1/32",
+			),
+		},
+		{
+			"csv header column",
+			csvList,
+			lists.Options{Format: lists.FormatCSV, Column: "IP"},
+			prefixes("5.5.5.5/32", "6.6.6.0/24"),
+		},
+		{
+			"csv numbered column",
+			"1;5.5.5.5\n2;6.6.6.6\ntotal\n",
+			lists.Options{Format: lists.FormatCSV, Column: "2", Delimiter: ";"},
+			prefixes("5.5.5.5/32", "6.6.6.6/32"),
+		},
+		{
+			"json array",
+			`["1.1.1.1", "2.2.2.0/24"]`,
+			lists.Options{Format: lists.FormatJSON},
+			prefixes("1.1.1.1/32", "2.2.2.0/24"),
+		},
+		{
+			"json selected array",
+			jsonList,
+			lists.Options{
+				Format:   lists.FormatJSON,
+				Selector: "result.ipv4_cidrs",
+			},
+			prefixes("173.245.48.0/20", "103.21.244.0/22"),
+		},
+		{
+			"json wildcards",
+			jsonList,
+			lists.Options{Format: lists.FormatJSON, Selector: "$.result.*[]"},
+			prefixes("173.245.48.0/20", "103.21.244.0/22", "2400:cb00::/32"),
+		},
+		{
+			"json object field",
+			jsonList,
+			lists.Options{
+				Format:   lists.FormatJSON,
+				Selector: "$.items[*].cidr",
+			},
+			prefixes("7.7.7.0/24", "8.8.8.8/32"),
+		},
+		{
+			"json index",
+			jsonList,
+			lists.Options{Format: lists.FormatJSON, Selector: "items[1].cidr"},
+			prefixes("8.8.8.8/32"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lists.Parse("test", strings.NewReader(tt.data), tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, l)
+		})
+	}
+}
+
+func TestRead_Positions(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		opts  lists.Options
+		lines []int
+	}{
+		{"text", tor, lists.Options{}, []int{2, 3, 5}},
+		{
+			"csv",
+			csvList,
+			lists.Options{Format: lists.FormatCSV, Column: "ip"},
+			[]int{3, 4},
+		},
+		{
+			"json",
+			jsonList,
+			lists.Options{Format: lists.FormatJSON, Selector: "items[*].cidr"},
+			[]int{7, 8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.data)
+			entries, err := lists.Read("test", r, tt.opts)
+			require.NoError(t, err)
+			lines := make([]int, 0, len(entries))
+			for _, e := range entries {
+				require.Equal(t, "test", e.Source)
+				lines = append(lines, e.Line)
+			}
+			require.Equal(t, tt.lines, lines)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		opts    lists.Options
+		wantErr string
+	}{
+		{
+			"text with ports",
+			proxies,
+			lists.Options{},
+			"list.txt:2: can't parse ip/subnet",
+		},
+		{"unknown format", tor, lists.Options{Format: "xml"}, "list.txt: "},
+		{
+			"reversed range",
+			"10.0.0.1-10.0.0.2\n\n10.0.0.9-10.0.0.1\n",
+			lists.Options{Format: lists.FormatRange},
+			"list.txt:3: invalid ip range",
+		},
+		{
+			"mixed range",
+			"10.0.0.1-::1\n",
+			lists.Options{Format: lists.FormatRange},
+			"list.txt:1: invalid ip range",
+		},
+		{
+			"bad drop entry",
+			drop + "1.2.3/24 ; SBL1\n",
+			lists.Options{Format: lists.FormatDROP},
+			"list.txt:5: can't parse ip/subnet",
+		},
+		{
+			"csv missing column",
+			csvList,
+			lists.Options{Format: lists.FormatCSV, Column: "cidr"},
+			"list.txt:1: invalid csv column",
+		},
+		{
+			"csv zero column",
+			csvList,
+			lists.Options{Format: lists.FormatCSV, Column: "0"},
+			"list.txt: invalid csv column",
+		},
+		{
+			"csv bad quotes",
+			"1.1.1.1\n\"2.2.2.2\n",
+			lists.Options{Format: lists.FormatCSV},
+			"list.txt:2: can't read csv",
+		},
+		{
+			"csv bad value",
+			csvList,
+			lists.Options{Format: lists.FormatCSV, Column: "comment"},
+			"list.txt:3: can't parse ip/subnet",
+		},
+		{
+			"json bad value",
+			jsonList,
+			lists.Options{Format: lists.FormatJSON, Selector: "items[*].tags"},
+			"list.txt:7: can't parse ip/subnet",
+		},
+		{
+			"json syntax",
+			"[\n\"1.1.1.1\"\n\"2.2.2.2\"]",
+			lists.Options{Format: lists.FormatJSON},
+			"list.txt:3: can't decode json",
+		},
+		{
+			"json bad selector",
+			jsonList,
+			lists.Options{Format: lists.FormatJSON, Selector: "items[x]"},
+			"list.txt: invalid json selector",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := strings.NewReader(tt.data)
+			_, err := lists.Parse("list.txt", r, tt.opts)
+			var posErr *lists.PositionError
+			require.ErrorAs(t, err, &posErr)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	tests := []struct {
+		start string
+		end   string
+		want  []netip.Prefix
+	}{
+		{"0.0.0.0", "255.255.255.255", prefixes("0.0.0.0/0")},
+		{"1.1.1.1", "1.1.1.1", prefixes("1.1.1.1/32")},
+		{
+			"255.255.255.254",
+			"255.255.255.255",
+			prefixes("255.255.255.254/31"),
+		},
+		{
+			"10.0.0.255",
+			"10.0.2.0",
+			prefixes("10.0.0.255/32", "10.0.1.0/24", "10.0.2.0/32"),
+		},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", prefixes("::/0")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.start+"-"+tt.end, func(t *testing.T) {
+			require.Equal(t, tt.want, lists.RangePrefixes(
+				netip.MustParseAddr(tt.start),
+				netip.MustParseAddr(tt.end),
+			))
+		})
+	}
+}
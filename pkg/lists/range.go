@@ -0,0 +1,40 @@
+package lists
+
+import "net/netip"
+
+// RangePrefixes returns the smallest set of subnets covering start-end
+// range. Both addresses must be of the same family.
+func RangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var l []netip.Prefix
+	for start.IsValid() && !end.Less(start) {
+		// the largest subnet starting at start and not exceeding end
+		p := netip.PrefixFrom(start, start.BitLen())
+		for bits := 0; bits < start.BitLen(); bits++ {
+			c := netip.PrefixFrom(start, bits)
+			if c.Masked().Addr() == start && !end.Less(lastAddr(c)) {
+				p = c
+				break
+			}
+		}
+		l = append(l, p)
+		start = lastAddr(p).Next()
+	}
+	return l
+}
+
+// lastAddr returns the last address of subnet.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Masked().Addr().As16()
+	offset := 0
+	if p.Addr().Is4() {
+		offset = 96 //nolint:gomnd // IPv4 bits in IPv6 address
+	}
+	for i := offset + p.Bits(); i < 128; i++ { //nolint:gomnd // IPv6 bits
+		a[i/8] |= 1 << (7 - i%8)
+	}
+	addr := netip.AddrFrom16(a)
+	if p.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
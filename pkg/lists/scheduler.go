@@ -0,0 +1,84 @@
+package lists
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReportFunc receives result of every scheduled update, l is nil on error.
+type ReportFunc func(src Source, l *List, err error)
+
+// Scheduler periodically updates sources with non-zero Refresh. Update is
+// due when stored list is older than Refresh, so restarts don't cause
+// extra downloads.
+type Scheduler struct {
+	updater *Updater
+	sources []Source
+	report  ReportFunc
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(
+	updater *Updater,
+	sources []Source,
+	report ReportFunc,
+) *Scheduler {
+	return &Scheduler{
+		updater: updater,
+		sources: sources,
+		report:  report,
+	}
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, src := range s.sources {
+		if src.Refresh <= 0 {
+			continue
+		}
+		s.wg.Add(1)
+		go func(src Source) {
+			defer s.wg.Done()
+			s.run(ctx, src)
+		}(src)
+	}
+}
+
+// Stop cancels running updates and waits for them.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context, src Source) {
+	var wait time.Duration
+	if age, ok := s.updater.Age(src); ok && age < src.Refresh {
+		wait = src.Refresh - age
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		l, err := s.updater.Update(ctx, src)
+		if ctx.Err() != nil {
+			return
+		}
+		if s.report != nil {
+			s.report(src, l, err)
+		}
+		t.Reset(src.Refresh)
+	}
+}
@@ -0,0 +1,288 @@
+package lists
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// maxSize limits size of downloaded list and its checksum and signature.
+const maxSize = 64 << 20
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrBadSignature     = errors.New("signature verification failed")
+	ErrTooLarge         = errors.New("response is too large")
+)
+
+type InvalidSourceError struct {
+	name   string
+	reason string
+}
+
+func (e InvalidSourceError) Error() string {
+	return fmt.Sprintf("invalid list source \"%s\": %s", e.name, e.reason)
+}
+
+type StatusError struct {
+	url    string
+	status int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unexpected status of %s: %d", e.url, e.status)
+}
+
+// Source describes where and how list is fetched.
+type Source struct {
+	Name string
+	URL  string
+	Options
+	// Refresh is an interval of Scheduler updates, 0 disables them.
+	Refresh time.Duration
+
+	// SHA256 pins content to hex digest.
+	SHA256 string
+	// ChecksumURL points to sha256sum-like file for content.
+	ChecksumURL string
+	// SignatureURL points to ed25519 signature (raw or base64) of content
+	// made with base64 encoded PublicKey.
+	SignatureURL string
+	PublicKey    string
+}
+
+// Validate checks source without fetching it.
+func (s Source) Validate() error {
+	invalid := func(reason string) error {
+		return &InvalidSourceError{name: s.Name, reason: reason}
+	}
+	if _, err := Path("", s.Name); err != nil {
+		return err
+	}
+	if s.URL == "" {
+		return invalid("empty url")
+	}
+	if err := s.Options.Validate(); err != nil {
+		return err
+	}
+	if s.SHA256 != "" {
+		if b, err := hex.DecodeString(s.SHA256); err != nil ||
+			len(b) != sha256.Size {
+			return invalid("sha256 must be hex digest")
+		}
+	}
+	if (s.SignatureURL == "") != (s.PublicKey == "") {
+		return invalid("signature_url and public_key must be set together")
+	}
+	if s.PublicKey != "" {
+		if _, err := s.publicKey(); err != nil {
+			return invalid(err.Error())
+		}
+	}
+	return nil
+}
+
+func (s Source) publicKey() (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("public_key must be base64 ed25519 key")
+	}
+	return key, nil
+}
+
+// Updater fetches lists into directory.
+type Updater struct {
+	dir    string
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewUpdater(dir string, client *http.Client) *Updater {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Updater{
+		dir:    dir,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Path returns path of source list.
+func (u *Updater) Path(src Source) (string, error) {
+	return Path(u.dir, src.Name)
+}
+
+// Age returns time since last update of source list, or false if it
+// wasn't fetched yet.
+func (u *Updater) Age(src Source) (time.Duration, bool) {
+	path, err := u.Path(src)
+	if err != nil {
+		return 0, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return u.now().Sub(info.ModTime()), true
+}
+
+// Update fetches, verifies and parses source, then atomically replaces
+// stored list. Stored list is kept untouched on any error.
+func (u *Updater) Update(ctx context.Context, src Source) (*List, error) {
+	if err := src.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := u.fetch(ctx, src.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err = u.verify(ctx, src, data); err != nil {
+		return nil, err
+	}
+
+	prefixes, err := Parse(src.URL, bytes.NewReader(data), src.Options)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse list: %w", err)
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("%s: %w", src.URL, ErrEmpty)
+	}
+	slices.SortFunc(prefixes, comparePrefix)
+	prefixes = slices.Compact(prefixes)
+
+	sum := sha256.Sum256(data)
+	l := &List{
+		Name:     src.Name,
+		Source:   src.URL,
+		Updated:  u.now().UTC(),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Prefixes: prefixes,
+	}
+	if err = u.store(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (u *Updater) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create request: %w", err)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{url: url, status: resp.StatusCode}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't read %s: %w", url, err)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("%s: %w", url, ErrTooLarge)
+	}
+	return data, nil
+}
+
+func (u *Updater) verify(ctx context.Context, src Source, data []byte) error {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	if src.SHA256 != "" && !strings.EqualFold(src.SHA256, digest) {
+		return fmt.Errorf("%s: %w", src.URL, ErrChecksumMismatch)
+	}
+
+	if src.ChecksumURL != "" {
+		checksum, err := u.fetch(ctx, src.ChecksumURL)
+		if err != nil {
+			return err
+		}
+		// sha256sum output: "<digest>  <file>"
+		fields := strings.Fields(string(checksum))
+		if len(fields) == 0 || !strings.EqualFold(fields[0], digest) {
+			return fmt.Errorf("%s: %w", src.URL, ErrChecksumMismatch)
+		}
+	}
+
+	if src.SignatureURL != "" {
+		key, err := src.publicKey()
+		if err != nil {
+			return err
+		}
+		sig, err := u.fetch(ctx, src.SignatureURL)
+		if err != nil {
+			return err
+		}
+		if len(sig) != ed25519.SignatureSize {
+			decoded, derr := base64.StdEncoding.DecodeString(
+				strings.TrimSpace(string(sig)),
+			)
+			if derr == nil {
+				sig = decoded
+			}
+		}
+		if len(sig) != ed25519.SignatureSize ||
+			!ed25519.Verify(key, data, sig) {
+			return fmt.Errorf("%s: %w", src.URL, ErrBadSignature)
+		}
+	}
+
+	return nil
+}
+
+// store writes list to temporary file and renames it, so readers never see
+// partially written list.
+func (u *Updater) store(l *List) error {
+	path, err := Path(u.dir, l.Name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(u.dir, 0755); err != nil { //nolint:gomnd // rwxr-xr-x
+		return fmt.Errorf("can't create lists directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(u.dir, "."+l.Name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("can't create temporary list: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = l.write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("can't sync list: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("can't close list: %w", err)
+	}
+	//nolint:gomnd // rw-r--r--
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("can't chmod list: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), filepath.Clean(path)); err != nil {
+		return fmt.Errorf("can't replace list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package resolver
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type cacheEntry struct {
+	msg     *dns.Msg
+	stored  time.Time
+	expires time.Time
+}
+
+// cache keeps answers for their TTL. When cache is full, expired answers
+// are removed, then random ones.
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[dns.Question]cacheEntry
+	now     func() time.Time
+}
+
+// newCache returns nil (disabled cache) if size isn't positive.
+func newCache(size int) *cache {
+	if size <= 0 {
+		return nil
+	}
+	return &cache{
+		size:    size,
+		entries: make(map[dns.Question]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+func cacheKey(q dns.Question) dns.Question {
+	q.Name = strings.ToLower(q.Name)
+	return q
+}
+
+// get returns copy of answer with TTLs decreased by its age.
+func (c *cache) get(key dns.Question) (*dns.Msg, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	now := c.now()
+	if ok && !now.Before(e.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	m := e.msg.Copy()
+	age := uint32(now.Sub(e.stored) / time.Second)
+	for _, rr := range records(m) {
+		h := rr.Header()
+		if h.Ttl > age {
+			h.Ttl -= age
+		} else {
+			h.Ttl = 0
+		}
+	}
+	return m, true
+}
+
+func (c *cache) set(key dns.Question, m *dns.Msg) {
+	if c == nil || m.Truncated {
+		return
+	}
+	ttl, ok := TTL(m)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{
+		msg:     m.Copy(),
+		stored:  now,
+		expires: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+// evict removes expired entries, or one random entry if there are none.
+func (c *cache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) < c.size {
+		return
+	}
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
+
+// TTL returns time to live of answer in seconds: the minimal TTL of its
+// records, negative answer (e.g. NXDOMAIN) lives for SOA minimum TTL
+// (RFC 2308). Answers without records have no TTL.
+func TTL(m *dns.Msg) (uint32, bool) {
+	var (
+		ttl uint32
+		ok  bool
+	)
+	for _, rr := range records(m) {
+		t := rr.Header().Ttl
+		if soa, isSOA := rr.(*dns.SOA); isSOA && soa.Minttl < t {
+			t = soa.Minttl
+		}
+		if !ok || t < ttl {
+			ttl, ok = t, true
+		}
+	}
+	return ttl, ok
+}
+
+// records returns records of all sections except OPT pseudo-record.
+func records(m *dns.Msg) []dns.RR {
+	l := make([]dns.RR, 0, len(m.Answer)+len(m.Ns)+len(m.Extra))
+	l = append(l, m.Answer...)
+	l = append(l, m.Ns...)
+	for _, rr := range m.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			l = append(l, rr)
+		}
+	}
+	return l
+}
@@ -0,0 +1,257 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Schemes of upstream address, address without scheme is UDP.
+const (
+	SchemeUDP = "udp"
+	SchemeTCP = "tcp"
+	SchemeTLS = "tls"
+)
+
+const (
+	DefaultTimeout   = 2 * time.Second
+	DefaultCacheSize = 10000
+)
+
+var (
+	ErrNoUpstreams = errors.New("no upstreams")
+	ErrNoQuestion  = errors.New("no question in query")
+)
+
+type InvalidUpstreamError struct {
+	upstream string
+	reason   string
+}
+
+func (e InvalidUpstreamError) Error() string {
+	return fmt.Sprintf("invalid upstream \"%s\": %s", e.upstream, e.reason)
+}
+
+// RcodeError is returned when every upstream failed to answer the query,
+// e.g. with SERVFAIL.
+type RcodeError struct {
+	upstream string
+	rcode    int
+}
+
+func (e RcodeError) Error() string {
+	return fmt.Sprintf(
+		"upstream %s answered %s",
+		e.upstream,
+		dns.RcodeToString[e.rcode],
+	)
+}
+
+type Config struct {
+	// Upstreams are "host:port" (UDP with TCP fallback), "tcp://host:port"
+	// or "tls://host:port#server.name" (DoT, server name is optional).
+	Upstreams []string
+	// Timeout of one query, DefaultTimeout if zero.
+	Timeout time.Duration
+	// Retries of every upstream after all of them failed.
+	Retries int
+	// CacheSize is max number of cached answers, DefaultCacheSize if zero,
+	// cache is disabled if negative.
+	CacheSize int
+	// TLSConfig is a base config of DoT upstreams, may be nil.
+	TLSConfig *tls.Config
+}
+
+type upstream struct {
+	scheme string
+	addr   string
+	client *dns.Client
+	// tcp is used if UDP answer is truncated, nil for TCP and DoT
+	tcp *dns.Client
+}
+
+func (u upstream) exchange(
+	ctx context.Context,
+	m *dns.Msg,
+) (*dns.Msg, error) {
+	r, _, err := u.client.ExchangeContext(ctx, m, u.addr)
+	if err == nil && r.Truncated && u.tcp != nil {
+		r, _, err = u.tcp.ExchangeContext(ctx, m, u.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't query %s: %w", u, err)
+	}
+	return r, nil
+}
+
+func (u upstream) String() string {
+	return u.scheme + "://" + u.addr
+}
+
+// Resolver sends queries to upstreams in order, the next upstream is used
+// if previous one failed. Answers are cached for their TTL. Resolver is
+// safe for concurrent use.
+type Resolver struct {
+	upstreams []upstream
+	retries   int
+	cache     *cache
+}
+
+func New(cfg Config) (*Resolver, error) {
+	if len(cfg.Upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.CacheSize == 0 {
+		cfg.CacheSize = DefaultCacheSize
+	}
+
+	r := &Resolver{
+		retries: cfg.Retries,
+		cache:   newCache(cfg.CacheSize),
+	}
+	for _, s := range cfg.Upstreams {
+		u, err := parseUpstream(s, cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.upstreams = append(r.upstreams, u)
+	}
+	return r, nil
+}
+
+func parseUpstream(s string, cfg Config) (upstream, error) {
+	if !strings.Contains(s, "://") {
+		s = SchemeUDP + "://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return upstream{}, &InvalidUpstreamError{
+			upstream: s,
+			reason:   err.Error(),
+		}
+	}
+	if _, _, err = net.SplitHostPort(u.Host); err != nil {
+		return upstream{}, &InvalidUpstreamError{
+			upstream: s,
+			reason:   err.Error(),
+		}
+	}
+	if u.Path != "" || u.User != nil || u.RawQuery != "" {
+		return upstream{}, &InvalidUpstreamError{
+			upstream: s,
+			reason:   "must be host:port",
+		}
+	}
+
+	if u.Scheme != SchemeTLS && u.Fragment != "" {
+		return upstream{}, &InvalidUpstreamError{
+			upstream: s,
+			reason:   "server name is for tls only",
+		}
+	}
+
+	up := upstream{scheme: u.Scheme, addr: u.Host}
+	switch u.Scheme {
+	case SchemeUDP:
+		up.client = &dns.Client{Net: "udp", Timeout: cfg.Timeout}
+		up.tcp = &dns.Client{Net: "tcp", Timeout: cfg.Timeout}
+	case SchemeTCP:
+		up.client = &dns.Client{Net: "tcp", Timeout: cfg.Timeout}
+	case SchemeTLS:
+		var tlsConfig *tls.Config
+		if cfg.TLSConfig != nil {
+			tlsConfig = cfg.TLSConfig.Clone()
+		} else {
+			tlsConfig = new(tls.Config)
+		}
+		if u.Fragment != "" {
+			tlsConfig.ServerName = u.Fragment
+		}
+		up.client = &dns.Client{
+			Net:       "tcp-tls",
+			Timeout:   cfg.Timeout,
+			TLSConfig: tlsConfig,
+		}
+	default:
+		return upstream{}, &InvalidUpstreamError{
+			upstream: s,
+			reason:   "unknown scheme",
+		}
+	}
+	return up, nil
+}
+
+// Lookup sends recursive query of name and qtype.
+func (r *Resolver) Lookup(
+	ctx context.Context,
+	name string,
+	qtype uint16,
+) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+	return r.Exchange(ctx, m)
+}
+
+// Exchange sends query and returns answer. Answers with SERVFAIL and
+// REFUSED codes are errors, the other codes (e.g. NXDOMAIN) are returned
+// to caller. TTLs of cached answers are decreased by their age.
+func (r *Resolver) Exchange(
+	ctx context.Context,
+	m *dns.Msg,
+) (*dns.Msg, error) {
+	if len(m.Question) == 0 {
+		return nil, ErrNoQuestion
+	}
+	key := cacheKey(m.Question[0])
+	if a, ok := r.cache.get(key); ok {
+		a.Id = m.Id
+		return a, nil
+	}
+
+	var err error
+	for i := 0; i <= r.retries; i++ {
+		for _, u := range r.upstreams {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("can't resolve: %w", ctx.Err())
+			}
+
+			a, uerr := u.exchange(ctx, m)
+			if uerr != nil {
+				err = uerr
+				continue
+			}
+			if a.Rcode == dns.RcodeServerFailure ||
+				a.Rcode == dns.RcodeRefused {
+				err = &RcodeError{upstream: u.String(), rcode: a.Rcode}
+				continue
+			}
+
+			r.cache.set(key, a)
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf(
+		"can't resolve \"%s\": %w",
+		m.Question[0].Name,
+		err,
+	)
+}
+
+func (r *Resolver) String() string {
+	s := make([]string, 0, len(r.upstreams))
+	for _, u := range r.upstreams {
+		s = append(s, u.String())
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
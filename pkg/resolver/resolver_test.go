@@ -0,0 +1,373 @@
+package resolver_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/D00Movenok/BounceBack/pkg/resolver"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// answer replies with A record of every question.
+func answer(ttl uint32) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   r.Question[0].Name,
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+				Ttl:    ttl,
+			},
+			A: net.IPv4(10, 0, 0, 1),
+		})
+		_ = w.WriteMsg(m)
+	}
+}
+
+func rcode(code int) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, code)
+		_ = w.WriteMsg(m)
+	}
+}
+
+// counter counts queries passed to handler.
+func counter(h dns.Handler) (dns.HandlerFunc, *atomic.Int32) {
+	n := atomic.NewInt32(0)
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		n.Inc()
+		h.ServeDNS(w, r)
+	}, n
+}
+
+func start(t *testing.T, srv *dns.Server) {
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() {
+		_ = srv.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+}
+
+func serveUDP(t *testing.T, h dns.Handler) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	start(t, &dns.Server{PacketConn: pc, Handler: h})
+	return pc.LocalAddr().String()
+}
+
+func serveTCP(t *testing.T, addr string, h dns.Handler) string {
+	l, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	start(t, &dns.Server{Listener: l, Handler: h})
+	return l.Addr().String()
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	good := serveUDP(t, answer(60))
+	servfail := serveUDP(t, rcode(dns.RcodeServerFailure))
+	nxdomain := serveUDP(t, rcode(dns.RcodeNameError))
+	tcp := serveTCP(t, "127.0.0.1:0", answer(60))
+	// nothing listens on closed port
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dead := pc.LocalAddr().String()
+	pc.Close()
+
+	tests := []struct {
+		name      string
+		upstreams []string
+		rcode     int
+		wantErr   bool
+	}{
+		{"udp", []string{good}, dns.RcodeSuccess, false},
+		{"tcp", []string{"tcp://" + tcp}, dns.RcodeSuccess, false},
+		{"dead upstream", []string{dead, good}, dns.RcodeSuccess, false},
+		{"servfail", []string{servfail, good}, dns.RcodeSuccess, false},
+		{"nxdomain", []string{nxdomain, good}, dns.RcodeNameError, false},
+		{"all failed", []string{dead, servfail}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, rerr := resolver.New(resolver.Config{
+				Upstreams: tt.upstreams,
+				Timeout:   time.Second,
+			})
+			require.NoError(t, rerr)
+			a, rerr := r.Lookup(context.Background(), "a.test", dns.TypeA)
+			if tt.wantErr {
+				var rcodeErr *resolver.RcodeError
+				require.ErrorAs(t, rerr, &rcodeErr)
+				return
+			}
+			require.NoError(t, rerr)
+			require.Equal(t, tt.rcode, a.Rcode)
+		})
+	}
+}
+
+func TestResolver_Cache(t *testing.T) {
+	h, n := counter(answer(60))
+	r, err := resolver.New(resolver.Config{Upstreams: []string{serveUDP(t, h)}})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		a, lerr := r.Lookup(context.Background(), "A.test", dns.TypeA)
+		require.NoError(t, lerr)
+		require.Len(t, a.Answer, 1)
+		// name case doesn't matter
+		_, lerr = r.Lookup(context.Background(), "a.TEST.", dns.TypeA)
+		require.NoError(t, lerr)
+	}
+	require.Equal(t, int32(1), n.Load())
+
+	_, err = r.Lookup(context.Background(), "a.test", dns.TypeAAAA)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), n.Load())
+
+	// zero TTL and disabled cache
+	h, n = counter(answer(0))
+	r, err = resolver.New(resolver.Config{Upstreams: []string{serveUDP(t, h)}})
+	require.NoError(t, err)
+	h2, n2 := counter(answer(60))
+	r2, err := resolver.New(resolver.Config{
+		Upstreams: []string{serveUDP(t, h2)},
+		CacheSize: -1,
+	})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = r.Lookup(context.Background(), "a.test", dns.TypeA)
+		require.NoError(t, err)
+		_, err = r2.Lookup(context.Background(), "a.test", dns.TypeA)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(2), n.Load())
+	require.Equal(t, int32(2), n2.Load())
+}
+
+func TestResolver_Expiry(t *testing.T) {
+	h, n := counter(answer(1))
+	r, err := resolver.New(resolver.Config{Upstreams: []string{serveUDP(t, h)}})
+	require.NoError(t, err)
+
+	_, err = r.Lookup(context.Background(), "a.test", dns.TypeA)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, lerr := r.Lookup(context.Background(), "a.test", dns.TypeA)
+		require.NoError(t, lerr)
+		return n.Load() == 2
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestResolver_Retries(t *testing.T) {
+	failed := atomic.NewBool(false)
+	flaky := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if failed.CompareAndSwap(false, true) {
+			rcode(dns.RcodeServerFailure)(w, r)
+			return
+		}
+		answer(60)(w, r)
+	})
+	addr := serveUDP(t, flaky)
+
+	r, err := resolver.New(resolver.Config{Upstreams: []string{addr}})
+	require.NoError(t, err)
+	_, err = r.Lookup(context.Background(), "a.test", dns.TypeA)
+	require.Error(t, err)
+
+	failed.Store(false)
+	r, err = resolver.New(resolver.Config{
+		Upstreams: []string{addr},
+		Retries:   1,
+	})
+	require.NoError(t, err)
+	_, err = r.Lookup(context.Background(), "a.test", dns.TypeA)
+	require.NoError(t, err)
+}
+
+func TestResolver_Timeout(t *testing.T) {
+	silent := serveUDP(t, dns.HandlerFunc(func(dns.ResponseWriter, *dns.Msg) {
+	}))
+	r, err := resolver.New(resolver.Config{
+		Upstreams: []string{silent},
+		Timeout:   100 * time.Millisecond,
+		Retries:   2,
+	})
+	require.NoError(t, err)
+
+	started := time.Now()
+	_, err = r.Lookup(context.Background(), "a.test", dns.TypeA)
+	require.Error(t, err)
+	require.Less(t, time.Since(started), 2*time.Second)
+}
+
+func TestResolver_TCPFallback(t *testing.T) {
+	truncated := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		_ = w.WriteMsg(m)
+	})
+	h, n := counter(answer(60))
+	addr := serveTCP(t, "127.0.0.1:0", h)
+	pc, err := net.ListenPacket("udp", addr)
+	require.NoError(t, err)
+	start(t, &dns.Server{PacketConn: pc, Handler: truncated})
+
+	r, err := resolver.New(resolver.Config{Upstreams: []string{addr}})
+	require.NoError(t, err)
+	a, err := r.Lookup(context.Background(), "a.test", dns.TypeA)
+	require.NoError(t, err)
+	require.False(t, a.Truncated)
+	require.Len(t, a.Answer, 1)
+	require.Equal(t, int32(1), n.Load())
+}
+
+func TestResolver_TLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.test"},
+		DNSNames:     []string{"dns.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader,
+		tmpl,
+		tmpl,
+		&key.PublicKey,
+		key,
+	)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+		MinVersion: tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	start(t, &dns.Server{Listener: l, Net: "tcp-tls", Handler: answer(60)})
+	addr := l.Addr().String()
+
+	tests := []struct {
+		name     string
+		upstream string
+		wantErr  bool
+	}{
+		{"server name", "tls://" + addr + "#dns.test", false},
+		{"wrong server name", "tls://" + addr + "#other.test", true},
+		{"ip without san", "tls://" + addr, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, rerr := resolver.New(resolver.Config{
+				Upstreams: []string{tt.upstream},
+				TLSConfig: &tls.Config{
+					RootCAs:    roots,
+					MinVersion: tls.VersionTLS12,
+				},
+			})
+			require.NoError(t, rerr)
+			a, rerr := r.Lookup(context.Background(), "a.test", dns.TypeA)
+			require.Equal(t, tt.wantErr, rerr != nil, rerr)
+			if !tt.wantErr {
+				require.Len(t, a.Answer, 1)
+			}
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := resolver.New(resolver.Config{})
+	require.ErrorIs(t, err, resolver.ErrNoUpstreams)
+
+	for _, upstream := range []string{
+		"1.1.1.1",
+		"https://1.1.1.1:443",
+		"1.1.1.1:53#dns.test",
+		"tcp://1.1.1.1:53/path",
+		"tls://user@1.1.1.1:853",
+	} {
+		_, err = resolver.New(resolver.Config{Upstreams: []string{upstream}})
+		var upstreamErr *resolver.InvalidUpstreamError
+		require.ErrorAs(t, err, &upstreamErr, upstream)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		require.NoError(t, err)
+		return r
+	}
+	tests := []struct {
+		name string
+		msg  *dns.Msg
+		ttl  uint32
+		ok   bool
+	}{
+		{
+			"answer",
+			&dns.Msg{Answer: []dns.RR{
+				rr("a.test. 300 IN A 10.0.0.1"),
+				rr("a.test. 60 IN A 10.0.0.2"),
+			}},
+			60,
+			true,
+		},
+		{
+			"negative",
+			&dns.Msg{Ns: []dns.RR{
+				rr("test. 3600 IN SOA ns.test. admin.test. 1 2 3 4 900"),
+			}},
+			900,
+			true,
+		},
+		{
+			"opt is ignored",
+			&dns.Msg{
+				Answer: []dns.RR{rr("a.test. 60 IN A 10.0.0.1")},
+				Extra: []dns.RR{
+					&dns.OPT{Hdr: dns.RR_Header{Rrtype: dns.TypeOPT}},
+				},
+			},
+			60,
+			true,
+		},
+		{"empty", new(dns.Msg), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, ok := resolver.TTL(tt.msg)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.ttl, ttl)
+		})
+	}
+}
@@ -0,0 +1,455 @@
+package tlshello
+
+import (
+	"crypto/md5" //nolint: gosec // JA3 uses md5
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrIncomplete = errors.New("incomplete client hello")
+	ErrNotTLS     = errors.New("not a tls handshake record")
+	ErrNotHello   = errors.New("not a client hello message")
+	ErrMalformed  = errors.New("malformed client hello")
+	ErrTooLong    = errors.New("client hello is too long")
+)
+
+const (
+	maxHelloLength = 64 * 1024
+
+	recordTypeHandshake    = 0x16
+	handshakeClientHello   = 0x01
+	recordHeaderLen        = 5
+	handshakeHeaderLen     = 4
+	extServerName          = 0
+	extSupportedGroups     = 10
+	extPointFormats        = 11
+	extSignatureAlgorithms = 13
+	extALPN                = 16
+	extSupportedVersions   = 43
+
+	ja4HashLen  = 12
+	ja4MaxCount = 99
+)
+
+// ClientHello contains parsed fields of TLS ClientHello message.
+type ClientHello struct {
+	Version             uint16
+	CipherSuites        []uint16
+	Extensions          []uint16
+	SupportedGroups     []uint16
+	PointFormats        []uint8
+	SignatureAlgorithms []uint16
+	SupportedVersions   []uint16
+	ServerName          string
+	ALPN                []string
+	// Raw contains all TLS records the ClientHello was read from.
+	Raw []byte
+}
+
+// Parse parses ClientHello from the beginning of TLS stream.
+// Returns ErrIncomplete if data does not contain the whole message yet.
+func Parse(data []byte) (*ClientHello, error) {
+	msg, n, err := readHandshake(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &ClientHello{Raw: data[:n]}
+	if err = h.unmarshal(msg); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// collect handshake message from one or more TLS records.
+// returns message and count of used bytes.
+func readHandshake(data []byte) ([]byte, int, error) {
+	var (
+		msg []byte
+		n   int
+	)
+	for {
+		if len(data)-n < recordHeaderLen {
+			return nil, 0, ErrIncomplete
+		}
+		if data[n] != recordTypeHandshake {
+			return nil, 0, ErrNotTLS
+		}
+		l := int(binary.BigEndian.Uint16(data[n+3 : n+5]))
+		if len(data)-n-recordHeaderLen < l {
+			return nil, 0, ErrIncomplete
+		}
+		msg = append(msg, data[n+recordHeaderLen:n+recordHeaderLen+l]...)
+		n += recordHeaderLen + l
+
+		if len(msg) < handshakeHeaderLen {
+			continue
+		}
+		if msg[0] != handshakeClientHello {
+			return nil, 0, ErrNotHello
+		}
+		ml := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+		if ml > maxHelloLength {
+			return nil, 0, ErrTooLong
+		}
+		if len(msg)-handshakeHeaderLen >= ml {
+			return msg[handshakeHeaderLen : handshakeHeaderLen+ml], n, nil
+		}
+	}
+}
+
+func (h *ClientHello) unmarshal(msg []byte) error {
+	r := reader(msg)
+
+	var ok bool
+	if h.Version, ok = r.uint16(); !ok {
+		return ErrMalformed
+	}
+	if !r.skip(32) { //nolint: gomnd // random
+		return ErrMalformed
+	}
+	if _, ok = r.vector8(); !ok { // session id
+		return ErrMalformed
+	}
+	ciphers, ok := r.vector16()
+	if !ok {
+		return ErrMalformed
+	}
+	if h.CipherSuites, ok = ciphers.uint16s(); !ok {
+		return ErrMalformed
+	}
+	if _, ok = r.vector8(); !ok { // compression methods
+		return ErrMalformed
+	}
+	if r.empty() {
+		return nil
+	}
+
+	exts, ok := r.vector16()
+	if !ok {
+		return ErrMalformed
+	}
+	for !exts.empty() {
+		var (
+			t    uint16
+			data reader
+		)
+		if t, ok = exts.uint16(); !ok {
+			return ErrMalformed
+		}
+		if data, ok = exts.vector16(); !ok {
+			return ErrMalformed
+		}
+		h.Extensions = append(h.Extensions, t)
+		if !h.unmarshalExtension(t, data) {
+			return ErrMalformed
+		}
+	}
+	return nil
+}
+
+func (h *ClientHello) unmarshalExtension(t uint16, data reader) bool {
+	var ok bool
+	switch t {
+	case extServerName:
+		ok = h.unmarshalServerName(data)
+	case extSupportedGroups:
+		h.SupportedGroups, ok = data.vector16Uint16s()
+	case extPointFormats:
+		var list reader
+		list, ok = data.vector8()
+		h.PointFormats = list
+	case extSignatureAlgorithms:
+		h.SignatureAlgorithms, ok = data.vector16Uint16s()
+	case extALPN:
+		ok = h.unmarshalALPN(data)
+	case extSupportedVersions:
+		var list reader
+		if list, ok = data.vector8(); ok {
+			h.SupportedVersions, ok = list.uint16s()
+		}
+	default:
+		ok = true
+	}
+	return ok
+}
+
+func (h *ClientHello) unmarshalServerName(data reader) bool {
+	list, ok := data.vector16()
+	if !ok {
+		return false
+	}
+	for !list.empty() {
+		var (
+			nameType uint8
+			name     reader
+		)
+		if nameType, ok = list.uint8(); !ok {
+			return false
+		}
+		if name, ok = list.vector16(); !ok {
+			return false
+		}
+		if nameType == 0 {
+			h.ServerName = string(name)
+		}
+	}
+	return true
+}
+
+func (h *ClientHello) unmarshalALPN(data reader) bool {
+	list, ok := data.vector16()
+	if !ok {
+		return false
+	}
+	for !list.empty() {
+		var proto reader
+		if proto, ok = list.vector8(); !ok {
+			return false
+		}
+		h.ALPN = append(h.ALPN, string(proto))
+	}
+	return true
+}
+
+// JA3 returns JA3 fingerprint string with GREASE values removed.
+func (h *ClientHello) JA3() string {
+	points := make([]uint16, 0, len(h.PointFormats))
+	for _, p := range h.PointFormats {
+		points = append(points, uint16(p))
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinUint16(h.CipherSuites),
+		joinUint16(h.Extensions),
+		joinUint16(h.SupportedGroups),
+		joinUint16(points),
+	}, ",")
+}
+
+// JA3Hash returns md5 hex digest of JA3 fingerprint string.
+func (h *ClientHello) JA3Hash() string {
+	sum := md5.Sum([]byte(h.JA3())) //nolint: gosec // JA3 uses md5
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4 returns JA4 (TCP) fingerprint string.
+func (h *ClientHello) JA4() string {
+	var (
+		ciphers = withoutGREASE(h.CipherSuites)
+		exts    = withoutGREASE(h.Extensions)
+		sni     = "i"
+	)
+	if h.ServerName != "" {
+		sni = "d"
+	}
+
+	a := fmt.Sprintf(
+		"t%s%s%02d%02d%s",
+		ja4Version(h.maxVersion()),
+		sni,
+		min99(len(ciphers)),
+		min99(len(exts)),
+		ja4ALPN(h.ALPN),
+	)
+
+	slices.Sort(ciphers)
+	b := ja4Hash(joinHex(ciphers))
+
+	sorted := make([]uint16, 0, len(exts))
+	for _, e := range exts {
+		if e != extServerName && e != extALPN {
+			sorted = append(sorted, e)
+		}
+	}
+	slices.Sort(sorted)
+	c := joinHex(sorted)
+	if sigs := withoutGREASE(h.SignatureAlgorithms); len(sigs) > 0 {
+		c += "_" + joinHex(sigs)
+	}
+	if len(exts) == 0 {
+		c = ""
+	}
+
+	return a + "_" + b + "_" + ja4Hash(c)
+}
+
+func (h *ClientHello) maxVersion() uint16 {
+	v := h.Version
+	for _, sv := range h.SupportedVersions {
+		if !IsGREASE(sv) && sv > v {
+			v = sv
+		}
+	}
+	return v
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case 0x0304: //nolint: gomnd // TLS 1.3
+		return "13"
+	case 0x0303: //nolint: gomnd // TLS 1.2
+		return "12"
+	case 0x0302: //nolint: gomnd // TLS 1.1
+		return "11"
+	case 0x0301: //nolint: gomnd // TLS 1.0
+		return "10"
+	case 0x0300: //nolint: gomnd // SSL 3.0
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// first and last characters of the first ALPN value.
+func ja4ALPN(alpn []string) string {
+	if len(alpn) == 0 || alpn[0] == "" {
+		return "00"
+	}
+	v := alpn[0]
+	first, last := v[0], v[len(v)-1]
+	if !isAlnum(first) || !isAlnum(last) {
+		return hex.EncodeToString([]byte{first})[:1] +
+			hex.EncodeToString([]byte{last})[1:]
+	}
+	return string([]byte{first, last})
+}
+
+func ja4Hash(s string) string {
+	if s == "" {
+		return strings.Repeat("0", ja4HashLen)
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:ja4HashLen]
+}
+
+func joinHex(values []uint16) string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(s, ",")
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	res := make([]uint16, 0, len(values))
+	for _, v := range values {
+		if !IsGREASE(v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func min99(n int) int {
+	if n > ja4MaxCount {
+		return ja4MaxCount
+	}
+	return n
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// IsGREASE reports whether value is reserved GREASE value (RFC 8701).
+func IsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func joinUint16(values []uint16) string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		if IsGREASE(v) {
+			continue
+		}
+		s = append(s, strconv.Itoa(int(v)))
+	}
+	return strings.Join(s, "-")
+}
+
+// reader is a tiny helper over TLS presentation language vectors.
+type reader []byte
+
+func (r *reader) empty() bool {
+	return len(*r) == 0
+}
+
+func (r *reader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+func (r *reader) uint8() (uint8, bool) {
+	if len(*r) < 1 {
+		return 0, false
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v, true
+}
+
+func (r *reader) uint16() (uint16, bool) {
+	if len(*r) < 2 { //nolint: gomnd // uint16 size
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+func (r *reader) bytes(n int) (reader, bool) {
+	if len(*r) < n {
+		return nil, false
+	}
+	v := (*r)[:n]
+	*r = (*r)[n:]
+	return v, true
+}
+
+func (r *reader) vector8() (reader, bool) {
+	l, ok := r.uint8()
+	if !ok {
+		return nil, false
+	}
+	return r.bytes(int(l))
+}
+
+func (r *reader) vector16() (reader, bool) {
+	l, ok := r.uint16()
+	if !ok {
+		return nil, false
+	}
+	return r.bytes(int(l))
+}
+
+func (r *reader) vector16Uint16s() ([]uint16, bool) {
+	list, ok := r.vector16()
+	if !ok {
+		return nil, false
+	}
+	return list.uint16s()
+}
+
+func (r reader) uint16s() ([]uint16, bool) {
+	if len(r)%2 != 0 {
+		return nil, false
+	}
+	values := make([]uint16, 0, len(r)/2)
+	for !r.empty() {
+		v, _ := r.uint16()
+		values = append(values, v)
+	}
+	return values, true
+}
@@ -0,0 +1,127 @@
+package tlshello_test
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/D00Movenok/BounceBack/pkg/tlshello"
+	"github.com/stretchr/testify/require"
+)
+
+// record ClientHello sent by crypto/tls client.
+func recordHello(t *testing.T, cfg *tls.Config) []byte {
+	t.Helper()
+
+	client, server := net.Pipe()
+	go func() {
+		//nolint: errcheck // handshake fails after server closes pipe
+		tls.Client(client, cfg).Handshake()
+		client.Close()
+	}()
+
+	var data []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := server.Read(buf)
+		data = append(data, buf[:n]...)
+		if _, perr := tlshello.Parse(data); perr == nil || err != nil {
+			break
+		}
+	}
+	server.Close()
+	return data
+}
+
+func TestParse(t *testing.T) {
+	//nolint: gosec // client is used only to generate hello
+	data := recordHello(t, &tls.Config{
+		ServerName:         "example.com",
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+
+	hello, err := tlshello.Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, "example.com", hello.ServerName)
+	require.Equal(t, []string{"h2", "http/1.1"}, hello.ALPN)
+	require.Equal(t, uint16(tls.VersionTLS12), hello.Version)
+	require.Contains(t, hello.SupportedVersions, uint16(tls.VersionTLS13))
+	require.NotEmpty(t, hello.CipherSuites)
+	require.Len(t, hello.Raw, len(data))
+
+	ja3 := strings.Split(hello.JA3(), ",")
+	require.Len(t, ja3, 5)
+	require.Equal(t, "771", ja3[0])
+	require.Len(t, hello.JA3Hash(), 32)
+	require.Regexp(t, `^t13d\d{4}h2_[0-9a-f]{12}_[0-9a-f]{12}$`, hello.JA4())
+}
+
+func TestParseErrors(t *testing.T) {
+	//nolint: gosec // client is used only to generate hello
+	data := recordHello(t, &tls.Config{
+		ServerName:         "example.com",
+		InsecureSkipVerify: true,
+	})
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, tlshello.ErrIncomplete},
+		{"truncated header", data[:3], tlshello.ErrIncomplete},
+		{"truncated body", data[:len(data)-1], tlshello.ErrIncomplete},
+		{"http", []byte("GET / HTTP/1.1\r\n\r\n"), tlshello.ErrNotTLS},
+		{
+			"server hello",
+			[]byte{0x16, 0x03, 0x03, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00},
+			tlshello.ErrNotHello,
+		},
+		{
+			"malformed",
+			[]byte{0x16, 0x03, 0x01, 0x00, 0x05, 0x01, 0x00, 0x00, 0x01, 0x03},
+			tlshello.ErrMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tlshello.Parse(tt.data)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestIsGREASE(t *testing.T) {
+	require.True(t, tlshello.IsGREASE(0x0a0a))
+	require.True(t, tlshello.IsGREASE(0xfafa))
+	require.False(t, tlshello.IsGREASE(0x0a1a))
+	require.False(t, tlshello.IsGREASE(0x1301))
+}
+
+// example from JA4 specification.
+func TestJA4(t *testing.T) {
+	hello := &tlshello.ClientHello{
+		Version: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030,
+			0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035,
+		},
+		Extensions: []uint16{
+			0x1a1a, 0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010,
+			0x0005, 0x000d, 0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x4469,
+			0x0015,
+		},
+		SignatureAlgorithms: []uint16{
+			0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601,
+		},
+		SupportedVersions: []uint16{0x2a2a, 0x0304, 0x0303},
+		ServerName:        "example.com",
+		ALPN:              []string{"h2", "http/1.1"},
+	}
+	require.Equal(t, "t13d1516h2_8daaf6152771_e5627efa2ab1", hello.JA4())
+
+	hello = &tlshello.ClientHello{Version: tls.VersionTLS12}
+	require.Equal(t, "t12i000000_000000000000_000000000000", hello.JA4())
+}